@@ -7,4 +7,10 @@ const (
 	Healthy       HealthCheckResponseCode = iota
 	Unhealthy
 	ApiError
+	// AuthFailed is used when the peer rejected our client certificate, or we rejected its server certificate
+	AuthFailed HealthCheckResponseCode = -2
+	// ClockSkewed is used when the clock of the peer deviates too much from ours, so that its response isn't trusted
+	ClockSkewed HealthCheckResponseCode = -3
+	// Throttled is used when the peer dropped our request because it's busy with the requests of other nodes
+	Throttled HealthCheckResponseCode = -4
 )
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,8 +27,21 @@ import (
 const (
 	configCRName                          = "poison-pill-config"
 	templateCRName                        = "poison-pill-default-template"
-	defaultWatchdogPath                   = "/dev/watchdog1"
 	defaultSafetToAssumeNodeRebootTimeout = 180
+	defaultPeerPort                       = 30001
+	defaultPeerMinTLSVersion              = "1.2"
+	defaultPeerClientAuth                 = "Required"
+)
+
+const (
+	// WatchdogTimeoutValidConditionType is the condition type which reports if the configured watchdog timeout
+	// is supported by the watchdog devices of all nodes
+	WatchdogTimeoutValidConditionType = "WatchdogTimeoutValid"
+	// WatchdogTimeoutAcceptedReason is used when no agent reported an unsupported watchdog timeout
+	WatchdogTimeoutAcceptedReason = "TimeoutAccepted"
+	// WatchdogTimeoutExceedsMaximumReason is used when the configured watchdog timeout exceeds the
+	// maximum supported by the watchdog device of a node
+	WatchdogTimeoutExceedsMaximumReason = "TimeoutExceedsDeviceMaximum"
 )
 
 // PoisonPillConfigSpec defines the desired state of PoisonPillConfig
@@ -35,10 +49,20 @@ type PoisonPillConfigSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// WatchdogFilePath is the watchdog file path that should be available on each node, e.g. /dev/watchdog
-	// +kubebuilder:default=/dev/watchdog1
+	// WatchdogFilePath is the watchdog file path that should be available on each node, e.g. /dev/watchdog1 on nodes
+	// which expose an iTCO and an IPMI watchdog. Agents which can't find it are not ready. When not set, the agents
+	// use the first usable watchdog device of their node.
+	// +kubebuilder:validation:Pattern=^/dev/
+	// +optional
 	WatchdogFilePath string `json:"watchdogFilePath,omitempty"`
 
+	// WatchdogFilePaths are the paths of several watchdog devices which are armed together, e.g. /dev/watchdog0 and
+	// /dev/watchdog1 on nodes which expose an iTCO and an IPMI watchdog, so that a single failing device doesn't
+	// prevent the reboot. All devices are armed with the smallest of their timeouts. When set, WatchdogFilePath is
+	// ignored.
+	// +optional
+	WatchdogFilePaths []string `json:"watchdogFilePaths,omitempty"`
+
 	// SafeTimeToAssumeNodeRebootedSeconds is the time after which the healthy poison pill
 	// agents will assume the unhealthy node has been rebooted and it is safe to remove the node
 	// from the cluster. This is extremely important. Deleting a node while the workload is still
@@ -46,12 +70,377 @@ type PoisonPillConfigSpec struct {
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:default=180
 	SafeTimeToAssumeNodeRebootedSeconds int `json:"safeTimeToAssumeNodeRebootedSeconds,omitempty"`
+
+	// WatchdogTimeoutSeconds is the timeout which will be set on the watchdog device. The value is clamped to the
+	// timeout range supported by the device. When not set, the default timeout of the device is used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WatchdogTimeoutSeconds int `json:"watchdogTimeoutSeconds,omitempty"`
+
+	// WatchdogKeepaliveIntervalMilliseconds is the interval in which the agents feed the watchdog. Shorter intervals
+	// suit devices with a short timeout, longer intervals reduce the wakeups of stable systems. It must be at most
+	// half of the watchdog timeout, so that a single delayed feed doesn't reboot the node, otherwise the default is
+	// used. When not set, the watchdog is fed every third of its timeout.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WatchdogKeepaliveIntervalMilliseconds int `json:"watchdogKeepaliveIntervalMilliseconds,omitempty"`
+
+	// WatchdogArmDelaySeconds is the time after the start of an agent before it arms the watchdog, so that a node
+	// which just rebooted can settle, e.g. mount its disks and bring up its network, before a transient api server
+	// failure fences it again. Failed api server checks are ignored during the delay, like during the
+	// ApiCheckStartupGracePeriodSeconds, but unlike the grace period the delay doesn't end with a successful check.
+	// Be aware that an agent which restarts while its node is partitioned fences the node that much later, so the
+	// delay raises the min SafeTimeToAssumeNodeRebootedSeconds. When not set, the watchdog is armed right away.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WatchdogArmDelaySeconds int `json:"watchdogArmDelaySeconds,omitempty"`
+
+	// RebootMethod defines how the agents use the watchdog for rebooting their node. StopFeeding stops feeding the
+	// watchdog, so that the node reboots when the watchdog timeout elapsed. ShortTimeout sets the min timeout of the
+	// device before it stops feeding, which reboots faster with long timeouts and with drivers which only evaluate
+	// the timeout when it's set. The agents log the measured time to reboot after the node started again, which helps
+	// tuning SafeTimeToAssumeNodeRebootedSeconds. When not set, StopFeeding is used.
+	// +kubebuilder:validation:Enum=StopFeeding;ShortTimeout
+	// +optional
+	RebootMethod string `json:"rebootMethod,omitempty"`
+
+	// DryRun enables the dry run mode of the agents. In dry run mode remediations are only recorded in events and
+	// in the remediation's status, but nodes are neither rebooted nor modified.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// ExternalFencing is used in environments which can't expose a watchdog device to the agents. The agents don't
+	// reboot unhealthy nodes, instead the node objects are deleted, and the remediation waits for the cloud provider
+	// to recreate them. The deleted nodes are not restored by the agents.
+	// +optional
+	ExternalFencing bool `json:"externalFencing,omitempty"`
+
+	// SoftwareRebootFallback makes agents on nodes without a usable watchdog device reboot their node with the reboot
+	// syscall, and with the sysrq trigger when the syscall fails, instead of asking systemd for a forced reboot.
+	// IMPORTANT: a software reboot is less safe than watchdog based fencing! It relies on a kernel which is still
+	// responsive enough to execute the reboot, while the peers assume the node has been rebooted after
+	// SafeTimeToAssumeNodeRebootedSeconds anyway. It has no effect on nodes with a watchdog, or with ExternalFencing.
+	// +optional
+	SoftwareRebootFallback bool `json:"softwareRebootFallback,omitempty"`
+
+	// AnnotateMachines annotates the Machine of a fenced node with poison-pill.medik8s.io/fenced, so that the machine
+	// health check flow is aware of the fencing. The annotation is removed when the remediation completes. It has no
+	// effect on clusters without the machine API.
+	// +optional
+	AnnotateMachines bool `json:"annotateMachines,omitempty"`
+
+	// PromptEtcdMemberRemoval prompts the cluster admin with events and a condition of the remediation to remove the
+	// etcd member of a fenced control plane node, when the node doesn't become ready again after its reboot. The
+	// agents never remove etcd members themselves. Only remove the member after verifying that the node is gone for
+	// good, removing the member of a node which recovers breaks etcd. It has no effect on clusters without the etcd
+	// operator API.
+	// +optional
+	PromptEtcdMemberRemoval bool `json:"promptEtcdMemberRemoval,omitempty"`
+
+	// ForceDeletePods deletes the pods of a fenced node with a zero grace period, before the node is deleted, so that
+	// their controllers recreate them right away, regardless of their termination grace periods. The pods are only
+	// deleted after the node is assumed to be rebooted, since deleting pods of a node which is still running risks
+	// two instances of stateful workloads writing at the same time. DaemonSet pods and mirror pods are skipped.
+	// +optional
+	ForceDeletePods bool `json:"forceDeletePods,omitempty"`
+
+	// PodDeletionConcurrency is the max number of pods of a fenced node which are deleted at the same time. Pods which
+	// fail to be deleted don't stop the deletion of the others, they are retried later. When not set, up to 10 pods
+	// are deleted at the same time.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PodDeletionConcurrency int `json:"podDeletionConcurrency,omitempty"`
+
+	// DeleteCriticalPodsLast deletes the pods of a fenced node in the kube-* and openshift-* namespaces only after the
+	// pods of all other namespaces, so that the workloads are rescheduled first.
+	// +optional
+	DeleteCriticalPodsLast bool `json:"deleteCriticalPodsLast,omitempty"`
+
+	// GracefulDeletionNamespaces are the namespaces whose pods are evicted from fenced nodes instead of being force
+	// deleted, honoring their termination grace periods and PodDisruptionBudgets, e.g. for reducing the gaps of
+	// monitoring and logging during incidents. The node is only deleted after these pods are gone.
+	// +optional
+	GracefulDeletionNamespaces []string `json:"gracefulDeletionNamespaces,omitempty"`
+
+	// GracefulDeletionTimeoutSeconds is the max time for evicting the pods of GracefulDeletionNamespaces, counted from
+	// the time the node is assumed to be rebooted. When it elapses, these pods are force deleted as well. When not
+	// set, they are evicted for 5 minutes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracefulDeletionTimeoutSeconds int `json:"gracefulDeletionTimeoutSeconds,omitempty"`
+
+	// PeerPort is the port the agents use for communicating with their peers. It's used as host port, so it must
+	// not be used by anything else on the nodes.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=30001
+	PeerPort int `json:"peerPort,omitempty"`
+
+	// PeerMinTLSVersion is the minimum TLS version used for communicating with peers
+	// +kubebuilder:validation:Enum="1.2";"1.3"
+	// +kubebuilder:default="1.2"
+	PeerMinTLSVersion string `json:"peerMinTLSVersion,omitempty"`
+
+	// PeerTLSCipherSuites restricts the cipher suites used for communicating with peers over TLS 1.2, e.g.
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure cipher suites are not supported, and the cipher suites of
+	// TLS 1.3 are not configurable. When not set, the default cipher suites of the Go runtime are used.
+	// +optional
+	PeerTLSCipherSuites []string `json:"peerTLSCipherSuites,omitempty"`
+
+	// PeerClientAuth is the verification mode of the client certificates of peers. Required rejects peers which
+	// don't present a client certificate signed by the CA of the agents. Optional accepts peers without client
+	// certificate, but still rejects certificates which aren't signed by the CA of the agents.
+	// +kubebuilder:validation:Enum=Required;Optional
+	// +kubebuilder:default=Required
+	PeerClientAuth string `json:"peerClientAuth,omitempty"`
+
+	// PeerNetworkInterface is the network interface of the nodes which the agents use for connecting to their peers,
+	// e.g. the one of the cluster network on nodes with several NICs, so that an outage of another network doesn't
+	// affect the peer health checks. The interface must exist on all nodes, agents which don't find it don't start.
+	// When not set, the peers are connected through the network of the agent pods.
+	// +optional
+	PeerNetworkInterface string `json:"peerNetworkInterface,omitempty"`
+
+	// MaxPeerClockSkewSeconds is the max deviation of the clock of a peer from the clock of a node without api server
+	// access which asks it for its health. The healthy and api error responses of peers whose clocks deviate more
+	// are ignored, since they might judge the freshness of the remediations wrongly, but their unhealthy responses
+	// still count, since the remediation exists regardless of the clocks. Since they are reachable, the node isn't
+	// isolated, so it doesn't reboot when they respond, unless peers confirm that it's unhealthy, or
+	// FenceOnIndeterminate is set. When not set, the clocks may deviate up to 30 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxPeerClockSkewSeconds int `json:"maxPeerClockSkewSeconds,omitempty"`
+
+	// MinPeersForQuorum is the minimum number of peers which a node without api server access needs to ask without a
+	// conclusive response, before it considers itself isolated and reboots itself. When less peers are asked, the
+	// node does not reboot. Be aware that other nodes might assume the node has been rebooted while it is still
+	// running its workloads in that case. On two node clusters a value above 1 prevents fencing because of isolation.
+	// The first peer which reports the node as unhealthy always triggers a reboot. When not set, no peer response at
+	// all triggers a reboot as well.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinPeersForQuorum int `json:"minPeersForQuorum,omitempty"`
+
+	// FenceOnIndeterminate makes a node without api server access reboot itself when its peers can't establish a
+	// quorum, because less than MinPeersForQuorum peers could be asked, or because it has no peers. This is risky,
+	// the node reboots although no peer confirmed that it's unhealthy, so it's meant for nodes with workloads which
+	// must not keep running unsupervised. On single node clusters the node reboots on every api server outage,
+	// unless MinClusterSizeForFencing prevents it. When not set, such nodes don't reboot.
+	// +optional
+	FenceOnIndeterminate bool `json:"fenceOnIndeterminate,omitempty"`
+
+	// PeerSampleSize limits the number of peers a node without api server access asks for its health to a random
+	// sample, which reduces the requests in large clusters. The node decides with the sample only: its first healthy
+	// or unhealthy response is conclusive, and a majority of api server errors within the sample is considered a
+	// control plane failure. The smaller the sample, the more likely its majority differs from the majority of all
+	// peers, e.g. during a partial network partition. The sample is at least MinPeersForQuorum. When not set, the
+	// peers are asked until their responses are conclusive, which might involve all of them.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PeerSampleSize int `json:"peerSampleSize,omitempty"`
+
+	// MinClusterSizeForFencing is the minimum number of nodes in the cluster, as observed by the agents, for a node
+	// without api server access to reboot itself. In smaller clusters a peer quorum isn't meaningful, and nodes don't
+	// fence themselves, unless a peer reports that the node is being remediated, since the remediation assumes that
+	// it reboots. The cluster size is evaluated on every decision, so it follows nodes joining and leaving.
+	// When not set, fencing isn't restricted by the cluster size.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinClusterSizeForFencing int `json:"minClusterSizeForFencing,omitempty"`
+
+	// GracefulRebootTimeoutSeconds is the max time the unhealthy node tries to evict its pods before it reboots,
+	// honoring PodDisruptionBudgets. When it elapses, the node reboots anyway. The time to assume that the node has
+	// been rebooted is extended by this timeout. When not set, pods are not evicted.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracefulRebootTimeoutSeconds int `json:"gracefulRebootTimeoutSeconds,omitempty"`
+
+	// DeleteDaemonSetPods evicts DaemonSet pods before the reboot as well, when GracefulRebootTimeoutSeconds is set.
+	// The poison pill agent itself is never evicted. When not set, DaemonSet pods are skipped like kubectl drain does.
+	// +optional
+	DeleteDaemonSetPods bool `json:"deleteDaemonSetPods,omitempty"`
+
+	// MaxConcurrentRemediations is the max number of remediations which are reconciled by each agent at the same time.
+	// When not set, remediations are reconciled one after another.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentRemediations int `json:"maxConcurrentRemediations,omitempty"`
+
+	// MaxConcurrentReboots is the max number of nodes which reboot themselves at the same time, in order to prevent
+	// that a correlated failure takes down the whole cluster. Nodes exceeding it wait up to 5 minutes before they
+	// reboot anyway, and the time to assume that a node has been rebooted is extended accordingly. The limit is only
+	// applied by nodes which can reach the api server, so it only helps in partial failures. When not set, the number
+	// of concurrent reboots is not limited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentReboots int `json:"maxConcurrentReboots,omitempty"`
+
+	// MaxRemediationDurationSeconds is the max time from the creation of a remediation until it completes. When it's
+	// exceeded, the agents give up on the remediation and set its Failed condition, so that it can be escalated to
+	// another remediation. The node is left as it is until the remediation is deleted. Remediations which didn't
+	// start yet, e.g. because they are deferred, don't fail. When not set, remediations never fail because of their
+	// duration.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRemediationDurationSeconds int `json:"maxRemediationDurationSeconds,omitempty"`
+
+	// RemediationCooldownSeconds is the time after a completed remediation of a node in which no new remediation of
+	// that node is started, in order to prevent reboot loops. Remediations within that time are deferred until the
+	// cooldown ended. When not set, there is no cooldown.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RemediationCooldownSeconds int `json:"remediationCooldownSeconds,omitempty"`
+
+	// NodeReadyGracePeriodSeconds is the time a node needs to be ready after it was rebooted and restored, before it
+	// is marked as schedulable again. This avoids scheduling pods onto a node which is still booting. When the node
+	// doesn't become ready within the grace period plus 10 minutes, it is marked as schedulable anyway and the
+	// remediation records a warning. When not set, the node is schedulable right after it was restored.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NodeReadyGracePeriodSeconds int `json:"nodeReadyGracePeriodSeconds,omitempty"`
+
+	// StatusBindAddress is the address the agents serve their current self assessment on as JSON, at the /status
+	// path, e.g. ":8090". The peers the agent knows about, with their addresses and last responses, are served at the
+	// /peers path. When not set, the status is not served.
+	// +optional
+	StatusBindAddress string `json:"statusBindAddress,omitempty"`
+
+	// ApiFailureSimulation enables the /simulate-api-failure endpoint of the agents' status server for chaos testing.
+	// A POST request with a duration, e.g. /simulate-api-failure?duration=60s, makes the agent treat the api server as
+	// unreachable for that time, while its real api server connection keeps working. The agent asks its peers like on
+	// a real failure, but when they confirm that its node is unhealthy, the reboot is only logged like in dry run
+	// mode, unless ApiFailureSimulationFencing is set. The endpoint only accepts requests from within the agent pod,
+	// e.g. by kubectl exec, so that only users who may exec into the agent pods can start simulations. Requires
+	// StatusBindAddress. Never enable it in production clusters.
+	// +optional
+	ApiFailureSimulation bool `json:"apiFailureSimulation,omitempty"`
+
+	// ApiFailureSimulationFencing makes the agents really reboot their nodes when the peers confirm a simulated api
+	// server failure, unless DryRun is set. When not set, simulated failures never reboot nodes.
+	// +optional
+	ApiFailureSimulationFencing bool `json:"apiFailureSimulationFencing,omitempty"`
+
+	// ApiCheckIntervalSeconds is the interval between two checks of the api server connectivity by the agents.
+	// When not set, the api server is checked every 15 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ApiCheckIntervalSeconds int `json:"apiCheckIntervalSeconds,omitempty"`
+
+	// ApiCheckStartupGracePeriodSeconds is the time after the start of an agent in which failed api server checks
+	// don't count towards fencing, because the api server connection might not be established yet, e.g. right after
+	// a reboot. The grace period ends with the first successful check. Be aware that an agent which restarts while
+	// its node is partitioned fences the node that much later. When not set, there is no grace period.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ApiCheckStartupGracePeriodSeconds int `json:"apiCheckStartupGracePeriodSeconds,omitempty"`
+
+	// ApiServerTimeoutSeconds is the max time the agents wait for the api server to respond to a connectivity check,
+	// before they count it as an error. It's independent of ApiCheckIntervalSeconds, and might exceed it. When not
+	// set, the timeout is 5 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ApiServerTimeoutSeconds int `json:"apiServerTimeoutSeconds,omitempty"`
+
+	// ApiCheckProbeMode defines how the agents probe the api server. TCPConnect only opens a TCP connection,
+	// TLSHandshake additionally completes a TLS handshake, and HTTPGet requests the /readyz endpoint and treats every
+	// status code but 200 as error. When not set, HTTPGet is used.
+	// +kubebuilder:validation:Enum=TCPConnect;TLSHandshake;HTTPGet
+	// +optional
+	ApiCheckProbeMode string `json:"apiCheckProbeMode,omitempty"`
+
+	// ApiServerEndpoints are the api server endpoints the agents check, e.g. https://10.0.0.1:6443 for every control
+	// plane node, so that a failing load balancer in front of the api servers doesn't make the nodes fence themselves.
+	// The api server is considered to be reachable when any endpoint responds. When not set, the endpoint of the
+	// in-cluster config is checked.
+	// +optional
+	ApiServerEndpoints []string `json:"apiServerEndpoints,omitempty"`
+
+	// ExternalProbeEndpoints are known good endpoints outside of the control plane, e.g. a cluster ingress VIP, as
+	// host:port or URL. The agents probe them with a TCP connect when the api server isn't reachable. When any of them
+	// is reachable, the node isn't partitioned from the network and the error is assumed to be an api server side
+	// problem, so the node doesn't reboot. When not set, no external endpoints are probed.
+	// +optional
+	ExternalProbeEndpoints []string `json:"externalProbeEndpoints,omitempty"`
+
+	// ProbeKubelet lets the agents probe the healthz endpoint of their local kubelet on every api server check. When
+	// the api server isn't reachable and the local kubelet is unhealthy as well, the node itself is considered sick,
+	// and it reboots without asking its peers once the api server error threshold is reached. The kubelet health is
+	// logged and reported by the status endpoint.
+	// +optional
+	ProbeKubelet bool `json:"probeKubelet,omitempty"`
+
+	// PeerUpdateIntervalSeconds is the interval in which the agents update their list of peers. Shorter intervals
+	// consider scaled up nodes sooner, longer intervals reduce the load on the api server. When not set, the peers are
+	// updated every 15 minutes. Changes are applied by the running agents, without restarting them.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PeerUpdateIntervalSeconds int `json:"peerUpdateIntervalSeconds,omitempty"`
+
+	// PeerNodeSelector restricts the peers to the nodes matching the selector, e.g. for excluding nodes with flaky
+	// networking from the health decisions of others. Nodes which don't match are still remediated, but they aren't
+	// asked for the health of other nodes. When not set, all nodes of the same role are peers.
+	// +optional
+	PeerNodeSelector *metav1.LabelSelector `json:"peerNodeSelector,omitempty"`
+
+	// PeerSelectionStrategy defines the order in which a node without api server access asks its peers. Random asks
+	// them in random order, SameZoneFirst asks the peers of its own failure zone first, based on the
+	// topology.kubernetes.io/zone label, which keeps the verdict within the zone during a partition between zones.
+	// When not set, Random is used.
+	// +kubebuilder:validation:Enum=Random;SameZoneFirst
+	// +optional
+	PeerSelectionStrategy string `json:"peerSelectionStrategy,omitempty"`
+
+	// NodeDeletingTaint is the taint which marks nodes under remediation. Only the NoSchedule and NoExecute effects
+	// are supported. When not set, the node.kubernetes.io/unschedulable taint is used.
+	// +optional
+	NodeDeletingTaint *v1.Taint `json:"nodeDeletingTaint,omitempty"`
+
+	// FencedNodeLabelKey is the key of the label which marks nodes under remediation, so that fenced nodes can be
+	// found easily. The label is removed when the node is restored, or when the remediation is aborted. When not set,
+	// poison-pill.medik8s.io/remediation is used.
+	// +optional
+	FencedNodeLabelKey string `json:"fencedNodeLabelKey,omitempty"`
+
+	// FencedNodeLabelValue is the value of the label which marks nodes under remediation. When not set, in-progress
+	// is used.
+	// +optional
+	FencedNodeLabelValue string `json:"fencedNodeLabelValue,omitempty"`
+
+	// AuditLogPath is the path of a file on the nodes, which the agents append a JSON line to for every decision to
+	// reboot their node, with the time, the node, the peer responses and the outcome. The line is written before the
+	// watchdog stops being fed, so that the record survives the reboot. Aborted reboots are recorded as well. The
+	// directory of the file is mounted into the agents, so it must not be the root directory. When not set, no audit
+	// log is written.
+	// +kubebuilder:validation:Pattern=^/
+	// +optional
+	AuditLogPath string `json:"auditLogPath,omitempty"`
+
+	// AuditLogMaxSizeMegabytes is the size at which the audit log is rotated. The rotated files get the time of the
+	// rotation as suffix, and they are never removed by the agents. When not set, the audit log is rotated at 10
+	// megabytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	AuditLogMaxSizeMegabytes int `json:"auditLogMaxSizeMegabytes,omitempty"`
+
+	// Resources are the compute resources of the agent container. Setting equal requests and limits gives the agents
+	// the Guaranteed QoS class, which protects them from being OOM killed under memory pressure. When not set, the
+	// agents request 20m cpu and 60Mi memory without limits.
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 // PoisonPillConfigStatus defines the observed state of PoisonPillConfig
 type PoisonPillConfigStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions represents the observations of the config's current state
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
@@ -84,8 +473,10 @@ func NewDefaultPoisonPillConfig() PoisonPillConfig {
 	return PoisonPillConfig{
 		ObjectMeta: metav1.ObjectMeta{Name: configCRName},
 		Spec: PoisonPillConfigSpec{
-			WatchdogFilePath:                    defaultWatchdogPath,
 			SafeTimeToAssumeNodeRebootedSeconds: defaultSafetToAssumeNodeRebootTimeout,
+			PeerPort:                            defaultPeerPort,
+			PeerMinTLSVersion:                   defaultPeerMinTLSVersion,
+			PeerClientAuth:                      defaultPeerClientAuth,
 		},
 	}
 }
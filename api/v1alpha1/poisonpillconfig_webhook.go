@@ -0,0 +1,212 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *PoisonPillConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-poison-pill-medik8s-io-v1alpha1-poisonpillconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=poison-pill.medik8s.io,resources=poisonpillconfigs,verbs=create;update,versions=v1alpha1,name=vpoisonpillconfig.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &PoisonPillConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PoisonPillConfig) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PoisonPillConfig) ValidateUpdate(_ runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PoisonPillConfig) ValidateDelete() error {
+	return nil
+}
+
+// validate rejects specs which would only fail at runtime in the agents. The watchdog timeout can't be validated,
+// since the supported range depends on the device of each node.
+func (r *PoisonPillConfig) validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := r.Spec
+
+	if spec.WatchdogFilePath != "" && !strings.HasPrefix(filepath.Clean(spec.WatchdogFilePath), "/dev/") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("watchdogFilePath"), spec.WatchdogFilePath, "must be located in /dev"))
+	}
+	watchdogPaths := make(map[string]bool, len(spec.WatchdogFilePaths))
+	for i, path := range spec.WatchdogFilePaths {
+		pathField := specPath.Child("watchdogFilePaths").Index(i)
+		if !strings.HasPrefix(filepath.Clean(path), "/dev/") {
+			allErrs = append(allErrs, field.Invalid(pathField, path, "must be located in /dev"))
+		}
+		if watchdogPaths[filepath.Clean(path)] {
+			allErrs = append(allErrs, field.Duplicate(pathField, path))
+		}
+		watchdogPaths[filepath.Clean(path)] = true
+	}
+
+	// the directory of the audit log is mounted into the agents
+	if spec.AuditLogPath != "" && (!filepath.IsAbs(spec.AuditLogPath) || filepath.Dir(filepath.Clean(spec.AuditLogPath)) == "/") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("auditLogPath"), spec.AuditLogPath, "must be an absolute path outside of the root directory"))
+	}
+
+	// zero values select the defaults
+	nonNegative := []struct {
+		name  string
+		value int
+	}{
+		{"safeTimeToAssumeNodeRebootedSeconds", spec.SafeTimeToAssumeNodeRebootedSeconds},
+		{"watchdogTimeoutSeconds", spec.WatchdogTimeoutSeconds},
+		{"watchdogKeepaliveIntervalMilliseconds", spec.WatchdogKeepaliveIntervalMilliseconds},
+		{"watchdogArmDelaySeconds", spec.WatchdogArmDelaySeconds},
+		{"minPeersForQuorum", spec.MinPeersForQuorum},
+		{"peerSampleSize", spec.PeerSampleSize},
+		{"minClusterSizeForFencing", spec.MinClusterSizeForFencing},
+		{"gracefulRebootTimeoutSeconds", spec.GracefulRebootTimeoutSeconds},
+		{"maxConcurrentRemediations", spec.MaxConcurrentRemediations},
+		{"maxConcurrentReboots", spec.MaxConcurrentReboots},
+		{"maxRemediationDurationSeconds", spec.MaxRemediationDurationSeconds},
+		{"remediationCooldownSeconds", spec.RemediationCooldownSeconds},
+		{"nodeReadyGracePeriodSeconds", spec.NodeReadyGracePeriodSeconds},
+		{"apiCheckIntervalSeconds", spec.ApiCheckIntervalSeconds},
+		{"apiCheckStartupGracePeriodSeconds", spec.ApiCheckStartupGracePeriodSeconds},
+		{"apiServerTimeoutSeconds", spec.ApiServerTimeoutSeconds},
+		{"peerUpdateIntervalSeconds", spec.PeerUpdateIntervalSeconds},
+		{"auditLogMaxSizeMegabytes", spec.AuditLogMaxSizeMegabytes},
+		{"podDeletionConcurrency", spec.PodDeletionConcurrency},
+		{"gracefulDeletionTimeoutSeconds", spec.GracefulDeletionTimeoutSeconds},
+		{"maxPeerClockSkewSeconds", spec.MaxPeerClockSkewSeconds},
+	}
+	for _, f := range nonNegative {
+		if f.value < 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child(f.name), f.value, "must not be negative"))
+		}
+	}
+
+	// the timeout of the device is unknown without WatchdogTimeoutSeconds, the agents fall back to the default interval
+	// when the keepalive interval is too long for it
+	if spec.WatchdogTimeoutSeconds > 0 && spec.WatchdogKeepaliveIntervalMilliseconds > spec.WatchdogTimeoutSeconds*1000/2 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("watchdogKeepaliveIntervalMilliseconds"), spec.WatchdogKeepaliveIntervalMilliseconds,
+			"must be at most half of watchdogTimeoutSeconds"))
+	}
+
+	if spec.PeerPort < 0 || spec.PeerPort > 65535 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("peerPort"), spec.PeerPort, "must be a valid port number"))
+	}
+
+	if spec.PeerNodeSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(spec.PeerNodeSelector, specPath.Child("peerNodeSelector"))...)
+	}
+
+	if taint := spec.NodeDeletingTaint; taint != nil {
+		taintPath := specPath.Child("nodeDeletingTaint")
+		for _, msg := range validation.IsQualifiedName(taint.Key) {
+			allErrs = append(allErrs, field.Invalid(taintPath.Child("key"), taint.Key, msg))
+		}
+		switch taint.Effect {
+		// PreferNoSchedule doesn't keep new workloads away from the unhealthy node, the reconciler refuses it as well
+		case v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			allErrs = append(allErrs, field.NotSupported(taintPath.Child("effect"), taint.Effect,
+				[]string{string(v1.TaintEffectNoSchedule), string(v1.TaintEffectNoExecute)}))
+		}
+	}
+
+	if spec.FencedNodeLabelKey != "" {
+		for _, msg := range validation.IsQualifiedName(spec.FencedNodeLabelKey) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("fencedNodeLabelKey"), spec.FencedNodeLabelKey, msg))
+		}
+	}
+	for _, msg := range validation.IsValidLabelValue(spec.FencedNodeLabelValue) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("fencedNodeLabelValue"), spec.FencedNodeLabelValue, msg))
+	}
+
+	for i, namespace := range spec.GracefulDeletionNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("gracefulDeletionNamespaces").Index(i), namespace, msg))
+		}
+	}
+
+	if spec.PeerNetworkInterface != "" && !isValidInterfaceName(spec.PeerNetworkInterface) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("peerNetworkInterface"), spec.PeerNetworkInterface,
+			"must be a valid network interface name"))
+	}
+
+	for i, endpoint := range spec.ApiServerEndpoints {
+		if !isValidEndpoint(endpoint) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("apiServerEndpoints").Index(i), endpoint,
+				"must be a URL or host:port"))
+		}
+	}
+	for i, endpoint := range spec.ExternalProbeEndpoints {
+		if !isValidEndpoint(endpoint) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("externalProbeEndpoints").Index(i), endpoint,
+				"must be a URL or host:port"))
+		}
+	}
+
+	if spec.ApiFailureSimulation && spec.StatusBindAddress == "" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("apiFailureSimulation"), spec.ApiFailureSimulation,
+			"requires statusBindAddress, the simulation is started on the status server"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PoisonPillConfig").GroupKind(), r.Name, allErrs)
+}
+
+// isValidEndpoint returns if the given endpoint is a URL with a host, or a host with an optional port, like the
+// endpoints probed by the agents
+func isValidEndpoint(endpoint string) bool {
+	if endpoint == "" || strings.ContainsAny(endpoint, ", \t\n") {
+		return false
+	}
+	if !strings.Contains(endpoint, "://") {
+		// no scheme, e.g. 10.0.0.1:6443
+		endpoint = "https://" + endpoint
+	}
+	endpointURL, err := url.Parse(endpoint)
+	return err == nil && endpointURL.Hostname() != ""
+}
+
+// isValidInterfaceName returns if the given name is accepted by the kernel as name of a network interface
+func isValidInterfaceName(name string) bool {
+	if len(name) > 15 || name == "." || name == ".." {
+		return false
+	}
+	return !strings.ContainsAny(name, "/: \t\n")
+}
@@ -0,0 +1,184 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("PoisonPillConfig validation", func() {
+
+	var config PoisonPillConfig
+
+	BeforeEach(func() {
+		config = NewDefaultPoisonPillConfig()
+	})
+
+	It("should accept the default config", func() {
+		Expect(config.ValidateCreate()).To(Succeed())
+	})
+
+	It("should accept a valid config", func() {
+		config.Spec.WatchdogFilePath = "/dev/watchdog1"
+		config.Spec.GracefulRebootTimeoutSeconds = 60
+		config.Spec.PeerNodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""}}
+		config.Spec.NodeDeletingTaint = &v1.Taint{Key: "medik8s.io/deleting", Effect: v1.TaintEffectNoExecute}
+		Expect(config.ValidateUpdate(&PoisonPillConfig{})).To(Succeed())
+	})
+
+	It("should reject a watchdog path outside of /dev", func() {
+		config.Spec.WatchdogFilePath = "/dev/../etc/watchdog"
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.watchdogFilePath"))
+	})
+
+	It("should reject invalid watchdog paths", func() {
+		config.Spec.WatchdogFilePaths = []string{"/dev/watchdog0", "/dev/watchdog1"}
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.WatchdogFilePaths = []string{"/dev/watchdog0", "/tmp/watchdog", "/dev/watchdog0"}
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.watchdogFilePaths[1]"))
+		Expect(err.Error()).To(ContainSubstring("spec.watchdogFilePaths[2]"))
+	})
+
+	It("should reject negative durations", func() {
+		config.Spec.ApiServerTimeoutSeconds = -1
+		config.Spec.RemediationCooldownSeconds = -5
+		config.Spec.WatchdogArmDelaySeconds = -10
+		config.Spec.PodDeletionConcurrency = -1
+		config.Spec.MaxPeerClockSkewSeconds = -30
+		err := config.ValidateUpdate(&PoisonPillConfig{})
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.apiServerTimeoutSeconds"))
+		Expect(err.Error()).To(ContainSubstring("spec.remediationCooldownSeconds"))
+		Expect(err.Error()).To(ContainSubstring("spec.watchdogArmDelaySeconds"))
+		Expect(err.Error()).To(ContainSubstring("spec.podDeletionConcurrency"))
+		Expect(err.Error()).To(ContainSubstring("spec.maxPeerClockSkewSeconds"))
+	})
+
+	It("should reject audit log paths whose directory can't be mounted", func() {
+		config.Spec.AuditLogPath = "/var/log/poison-pill/audit.log"
+		config.Spec.AuditLogMaxSizeMegabytes = 5
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.AuditLogPath = "/audit.log"
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.auditLogPath"))
+
+		config.Spec.AuditLogPath = "audit.log"
+		Expect(config.ValidateCreate()).ToNot(Succeed())
+	})
+
+	It("should reject invalid graceful deletion namespaces", func() {
+		config.Spec.GracefulDeletionNamespaces = []string{"monitoring", "openshift-logging"}
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.GracefulDeletionNamespaces = []string{"monitoring", "Logging,Audit"}
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.gracefulDeletionNamespaces[1]"))
+	})
+
+	It("should reject invalid api server endpoints", func() {
+		config.Spec.ApiServerEndpoints = []string{"https://10.0.0.1:6443", "10.0.0.2:6443", "api.cluster.example.com"}
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.ApiServerEndpoints = []string{"https://10.0.0.1:6443", "https://", "10.0.0.2:6443,10.0.0.3:6443"}
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).ToNot(ContainSubstring("spec.apiServerEndpoints[0]"))
+		Expect(err.Error()).To(ContainSubstring("spec.apiServerEndpoints[1]"))
+		Expect(err.Error()).To(ContainSubstring("spec.apiServerEndpoints[2]"))
+	})
+
+	It("should reject invalid external probe endpoints", func() {
+		config.Spec.ExternalProbeEndpoints = []string{"10.0.0.100:443", "https://ingress.cluster.example.com"}
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.ExternalProbeEndpoints = []string{":443"}
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.externalProbeEndpoints[0]"))
+	})
+
+	It("should reject invalid peer network interfaces", func() {
+		config.Spec.PeerNetworkInterface = "br-ex"
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.PeerNetworkInterface = "ens3/cluster"
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.peerNetworkInterface"))
+
+		config.Spec.PeerNetworkInterface = "cluster-network-0"
+		Expect(config.ValidateCreate()).ToNot(Succeed())
+	})
+
+	It("should reject keepalive intervals which aren't well below the watchdog timeout", func() {
+		config.Spec.WatchdogTimeoutSeconds = 10
+		config.Spec.WatchdogKeepaliveIntervalMilliseconds = 5000
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.WatchdogKeepaliveIntervalMilliseconds = 6000
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.watchdogKeepaliveIntervalMilliseconds"))
+	})
+
+	It("should reject invalid taints", func() {
+		config.Spec.NodeDeletingTaint = &v1.Taint{Key: "medik8s.io/deleting", Effect: "NoReboot"}
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.nodeDeletingTaint.effect"))
+
+		config.Spec.NodeDeletingTaint = &v1.Taint{Key: "medik8s.io/deleting", Effect: v1.TaintEffectPreferNoSchedule}
+		err = config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.nodeDeletingTaint.effect"))
+
+		config.Spec.NodeDeletingTaint = &v1.Taint{Key: "not a key", Effect: v1.TaintEffectNoSchedule}
+		Expect(config.ValidateCreate()).ToNot(Succeed())
+	})
+
+	It("should reject invalid fenced node labels", func() {
+		config.Spec.FencedNodeLabelKey = "medik8s.io/fenced"
+		config.Spec.FencedNodeLabelValue = "true"
+		Expect(config.ValidateCreate()).To(Succeed())
+
+		config.Spec.FencedNodeLabelKey = "not a key"
+		config.Spec.FencedNodeLabelValue = "not a value"
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.fencedNodeLabelKey"))
+		Expect(err.Error()).To(ContainSubstring("spec.fencedNodeLabelValue"))
+	})
+
+	It("should reject the api failure simulation without status server", func() {
+		config.Spec.ApiFailureSimulation = true
+		err := config.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.apiFailureSimulation"))
+
+		config.Spec.StatusBindAddress = ":8090"
+		Expect(config.ValidateCreate()).To(Succeed())
+	})
+
+	It("should reject invalid peer node selectors", func() {
+		config.Spec.PeerNodeSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "zone", Operator: metav1.LabelSelectorOpIn},
+		}}
+		Expect(config.ValidateCreate()).ToNot(Succeed())
+	})
+
+	It("should always allow deletion", func() {
+		config.Spec.WatchdogFilePath = "/tmp/watchdog"
+		Expect(config.ValidateDelete()).To(Succeed())
+	})
+})
@@ -24,9 +24,190 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+const (
+	// ProcessingConditionType is true while the remediation is in progress
+	ProcessingConditionType = "Processing"
+	// FencingCompletedConditionType is true when the unhealthy node is assumed to be rebooted
+	FencingCompletedConditionType = "FencingCompleted"
+	// SucceededConditionType is true when the node has been restored
+	SucceededConditionType = "Succeeded"
+	// DryRunConditionType is true when the remediation was only simulated, no node was fenced
+	DryRunConditionType = "DryRun"
+	// DeferredConditionType is true when the remediation is postponed, because the node was remediated recently or
+	// because its remediation is disabled
+	DeferredConditionType = "Deferred"
+	// NodeReadyVerifiedConditionType is true when the restored node was ready for the configured grace period before
+	// it was marked as schedulable, and false when it was marked as schedulable after it didn't become ready in time
+	NodeReadyVerifiedConditionType = "NodeReadyVerified"
+	// EtcdMemberRemovalRequiredConditionType is true when a fenced control plane node didn't come back, and the
+	// cluster admin was prompted to remove its etcd member
+	EtcdMemberRemovalRequiredConditionType = "EtcdMemberRemovalRequired"
+	// FailedConditionType is true when the remediation didn't complete within the max remediation duration, and the
+	// agents gave up on it, so that it can be escalated to another remediation
+	FailedConditionType = "Failed"
+
+	// RemediationStartedReason is used when the node was marked as unschedulable and its reboot is awaited
+	RemediationStartedReason = "RemediationStarted"
+	// NodeRebootedReason is used when the time to assume the node has been rebooted has passed
+	NodeRebootedReason = "NodeRebooted"
+	// NodeRestoredReason is used when the node has been deleted and restored
+	NodeRestoredReason = "NodeRestored"
+	// DryRunCompletedReason is used when the remediation actions were recorded but not executed
+	DryRunCompletedReason = "DryRunCompleted"
+	// RemediationCooldownReason is used when the remediation cooldown after the node's last remediation didn't end yet
+	RemediationCooldownReason = "RemediationCooldown"
+	// CooldownElapsedReason is used when a deferred remediation is started because the cooldown ended
+	CooldownElapsedReason = "CooldownElapsed"
+	// RemediationDisabledReason is used when the remediation of the node is disabled by annotation
+	RemediationDisabledReason = "RemediationDisabled"
+	// RemediationEnabledReason is used when a deferred remediation is started because the node's remediation isn't
+	// disabled anymore
+	RemediationEnabledReason = "RemediationEnabled"
+	// NodeReadyReason is used when the restored node was ready for the grace period
+	NodeReadyReason = "NodeReady"
+	// NodeReadyTimeoutReason is used when the restored node didn't become ready in time
+	NodeReadyTimeoutReason = "NodeReadyTimeout"
+	// ResourcesDeletedReason is used when the workloads of the node were deleted without deleting the node
+	ResourcesDeletedReason = "ResourcesDeleted"
+	// ControlPlaneNodeNotRecoveredReason is used when a fenced control plane node didn't become ready in time
+	ControlPlaneNodeNotRecoveredReason = "ControlPlaneNodeNotRecovered"
+	// RemediationTimedOutReason is used when the remediation didn't complete within the max remediation duration
+	RemediationTimedOutReason = "RemediationTimedOut"
+)
+
+// The annotations of a remediation which identify the agents which processed it, for audits
+const (
+	// AgentPodAnnotation, AgentNodeAnnotation and AgentVersionAnnotation identify the agent which started the
+	// remediation
+	AgentPodAnnotation     = "poison-pill.medik8s.io/agent-pod"
+	AgentNodeAnnotation    = "poison-pill.medik8s.io/agent-node"
+	AgentVersionAnnotation = "poison-pill.medik8s.io/agent-version"
+	// FencingAgentPodAnnotation and FencingAgentVersionAnnotation identify the agent of the unhealthy node, which
+	// rebooted the node while it had api server access
+	FencingAgentPodAnnotation     = "poison-pill.medik8s.io/fencing-agent-pod"
+	FencingAgentVersionAnnotation = "poison-pill.medik8s.io/fencing-agent-version"
+	// OperatorVersionAnnotation is the version of the operator which created the remediation from a template, or
+	// which deployed the agent that started the remediation, if it's known
+	OperatorVersionAnnotation = "poison-pill.medik8s.io/operator-version"
+)
+
+// RemediationStrategyType is the way the workloads of the fenced node are moved to other nodes
+// +kubebuilder:validation:Enum=NodeDeletion;ResourceDeletion;OutOfServiceTaint
+type RemediationStrategyType string
+
+const (
+	// NodeDeletionRemediationStrategy deletes the fenced node and restores it afterwards, which deletes all of its
+	// pods and volume attachments
+	NodeDeletionRemediationStrategy RemediationStrategyType = "NodeDeletion"
+	// ResourceDeletionRemediationStrategy force deletes the pods of the fenced node, without deleting the node
+	ResourceDeletionRemediationStrategy RemediationStrategyType = "ResourceDeletion"
+	// OutOfServiceTaintRemediationStrategy adds the out-of-service taint to the fenced node, so that the pod garbage
+	// collector deletes its pods and detaches its volumes. It needs Kubernetes 1.26 or newer, older clusters fall back
+	// to ResourceDeletion.
+	OutOfServiceTaintRemediationStrategy RemediationStrategyType = "OutOfServiceTaint"
+)
+
+// PeerResponse is the response of a peer which was asked for the health of the unhealthy node
+// +kubebuilder:validation:Enum=Healthy;Unhealthy;ApiError;Timeout;AuthFailed;ClockSkewed;Throttled
+type PeerResponse string
+
+const (
+	// PeerResponseHealthy is used when the peer reported the node as healthy
+	PeerResponseHealthy PeerResponse = "Healthy"
+	// PeerResponseUnhealthy is used when the peer reported the node as unhealthy
+	PeerResponseUnhealthy PeerResponse = "Unhealthy"
+	// PeerResponseApiError is used when the peer couldn't access the api server either
+	PeerResponseApiError PeerResponse = "ApiError"
+	// PeerResponseTimeout is used when the peer didn't respond
+	PeerResponseTimeout PeerResponse = "Timeout"
+	// PeerResponseAuthFailed is used when the TLS authentication with the peer failed
+	PeerResponseAuthFailed PeerResponse = "AuthFailed"
+	// PeerResponseClockSkewed is used when the response of the peer was ignored, because its clock deviated too much
+	PeerResponseClockSkewed PeerResponse = "ClockSkewed"
+	// PeerResponseThrottled is used when the peer dropped the request, because it was busy with other requests
+	PeerResponseThrottled PeerResponse = "Throttled"
+)
+
+// PeerResult is the response of a peer which was consulted by the unhealthy node before it rebooted itself
+type PeerResult struct {
+	// NodeName is the name of the peer node, or its IP address if the name is unknown
+	NodeName string `json:"nodeName"`
+	// Response is the response of the peer
+	Response PeerResponse `json:"response"`
+	// Time is the time the response was received
+	Time metav1.Time `json:"time"`
+}
+
+// DisruptedPodDisruptionBudget is a PodDisruptionBudget which protected pods of a fenced node, which were deleted
+// without honoring it
+type DisruptedPodDisruptionBudget struct {
+	// Namespace is the namespace of the PodDisruptionBudget and its pods
+	Namespace string `json:"namespace"`
+	// Name is the name of the PodDisruptionBudget
+	Name string `json:"name"`
+	// Pods are the names of the deleted pods which were protected by the PodDisruptionBudget
+	Pods []string `json:"pods"`
+	// DisruptionsAllowed is the number of disruptions the PodDisruptionBudget allowed when the pods were deleted.
+	// The budget was violated when more pods were deleted.
+	DisruptionsAllowed int32 `json:"disruptionsAllowed"`
+}
+
+// RemediationErrorReason is the reason of a remediation error
+// +kubebuilder:validation:Enum=WatchdogUnavailable;PeerQuorumNotReached;NodeRestoreTimeout;APIUnreachable;RemediationTimeout;NodeNotFound
+type RemediationErrorReason string
+
+const (
+	// RemediationErrorWatchdogUnavailable is used when the unhealthy node failed to trigger its reboot
+	RemediationErrorWatchdogUnavailable RemediationErrorReason = "WatchdogUnavailable"
+	// RemediationErrorPeerQuorumNotReached is used when the unhealthy node wasn't rebooted by the time it was assumed
+	// to be rebooted, because its peers didn't confirm that it's unhealthy
+	RemediationErrorPeerQuorumNotReached RemediationErrorReason = "PeerQuorumNotReached"
+	// RemediationErrorNodeRestoreTimeout is used when the remediated node didn't become ready in time
+	RemediationErrorNodeRestoreTimeout RemediationErrorReason = "NodeRestoreTimeout"
+	// RemediationErrorAPIUnreachable is used when the node couldn't be deleted or restored through the api server
+	RemediationErrorAPIUnreachable RemediationErrorReason = "APIUnreachable"
+	// RemediationErrorRemediationTimeout is used when the remediation didn't complete within the max remediation
+	// duration
+	RemediationErrorRemediationTimeout RemediationErrorReason = "RemediationTimeout"
+	// RemediationErrorNodeNotFound is used when the unhealthy node doesn't exist and there is no backup to restore it
+	// from, so the remediation failed
+	RemediationErrorNodeNotFound RemediationErrorReason = "NodeNotFound"
+)
+
+// RemediationError is an error which occurred during the remediation
+type RemediationError struct {
+	// Reason is the reason of the error, e.g. for deciding whether to escalate to another remediation
+	Reason RemediationErrorReason `json:"reason"`
+	// Message is a human readable description of the error
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Time is the time the error occurred
+	Time metav1.Time `json:"time"`
+}
+
 // PoisonPillRemediationSpec defines the desired state of PoisonPillRemediation
 type PoisonPillRemediationSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// NodeName is the name of the unhealthy node. It allows names of remediations which differ from the node name,
+	// e.g. when they are generated by external controllers. When not set, the remediation is named after the node,
+	// or it's owned by the Machine of the node.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// RemediationStrategy is the way the workloads of the fenced node are moved to other nodes.
+	// One of NodeDeletion, ResourceDeletion and OutOfServiceTaint, defaults to NodeDeletion.
+	// +kubebuilder:default=NodeDeletion
+	// +optional
+	RemediationStrategy RemediationStrategyType `json:"remediationStrategy,omitempty"`
+
+	// SkipPeerVerification fences the node without waiting for the unhealthy node to detect its api server failure
+	// and to consult its peers. The node is still rebooted by its agent, when the agent sees the remediation, and it's
+	// assumed to be down once its watchdog timeout elapsed. It must only be set when the node is known to be down,
+	// e.g. because it was powered off, otherwise its workloads might run twice. It's ignored when the watchdog
+	// timeout is unknown.
+	// +optional
+	SkipPeerVerification bool `json:"skipPeerVerification,omitempty"`
 }
 
 // PoisonPillRemediationStatus defines the observed state of PoisonPillRemediation
@@ -40,14 +221,48 @@ type PoisonPillRemediationStatus struct {
 	// +nullable
 	NodeBackup *v1.Node `json:"nodeBackup,omitempty"`
 
+	// NodeWasUnschedulable is true when the node was already marked as unschedulable before the remediation started,
+	// e.g. because it was cordoned. In that case the node stays unschedulable when it is restored.
+	// +optional
+	NodeWasUnschedulable *bool `json:"nodeWasUnschedulable,omitempty"`
+
 	//TimeAssumedRebooted is the time by then the unhealthy node assumed to be rebooted
 	// +optional
 	TimeAssumedRebooted *metav1.Time `json:"timeAssumedRebooted,omitempty"`
 
+	// LastRemediationTime is the time the previous remediation of the node completed. It's set when this remediation
+	// is deferred because the node was remediated recently.
+	// +optional
+	LastRemediationTime *metav1.Time `json:"lastRemediationTime,omitempty"`
+
+	// PeerResults are the responses of the peers which were consulted by the unhealthy node before it decided to
+	// reboot itself. They are attached by the node's agent after the reboot.
+	// +optional
+	PeerResults []PeerResult `json:"peerResults,omitempty"`
+
+	// DisruptedPodDisruptionBudgets are the PodDisruptionBudgets which protected pods of the fenced node, when the
+	// agents force deleted the pods without honoring them. They are recorded for assessing the impact of the
+	// remediation, the pods are deleted regardless.
+	// +optional
+	DisruptedPodDisruptionBudgets []DisruptedPodDisruptionBudget `json:"disruptedPodDisruptionBudgets,omitempty"`
+
+	// LastError is the last error which occurred during the remediation
+	// +optional
+	LastError *RemediationError `json:"lastError,omitempty"`
+
 	// Phase represents the current phase of remediation,
 	// One of: TBD
 	// +optional
 	Phase *string `json:"phase,omitempty"`
+
+	// Conditions represents the observations of the remediation's current state.
+	// Known condition types are Processing, FencingCompleted, Succeeded, DryRun, Deferred and NodeReadyVerified.
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
@@ -72,6 +287,14 @@ type PoisonPillRemediationList struct {
 	Items           []PoisonPillRemediation `json:"items"`
 }
 
+// GetNodeName returns the name of the unhealthy node, which is the name of the remediation unless NodeName is set
+func (ppr *PoisonPillRemediation) GetNodeName() string {
+	if ppr.Spec.NodeName != "" {
+		return ppr.Spec.NodeName
+	}
+	return ppr.Name
+}
+
 func init() {
 	SchemeBuilder.Register(&PoisonPillRemediation{}, &PoisonPillRemediationList{})
 }
@@ -0,0 +1,32 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("PoisonPillRemediation", func() {
+
+	It("should target the node named after the remediation by default", func() {
+		ppr := &PoisonPillRemediation{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}}
+		Expect(ppr.GetNodeName()).To(Equal("worker-0"))
+	})
+
+	It("should target spec.nodeName when set", func() {
+		ppr := &PoisonPillRemediation{
+			ObjectMeta: metav1.ObjectMeta{Name: "remediation-abc"},
+			Spec:       PoisonPillRemediationSpec{NodeName: "worker-1"},
+		}
+		Expect(ppr.GetNodeName()).To(Equal("worker-1"))
+	})
+
+	It("should set the node name of remediations created from templates", func() {
+		template := &PoisonPillRemediationTemplate{ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: "ns"}}
+		ppr := template.NewRemediation("worker-2")
+		Expect(ppr.Name).To(Equal("worker-2"))
+		Expect(ppr.Spec.NodeName).To(Equal("worker-2"))
+		Expect(ppr.GetNodeName()).To(Equal("worker-2"))
+	})
+})
@@ -23,6 +23,10 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// RemediationTemplateLabel is the label of remediations which were created from a template, its value is the name of
+// the template
+const RemediationTemplateLabel = "poison-pill.medik8s.io/remediation-template"
+
 type PoisonPillRemediationTemplateResource struct {
 	Spec PoisonPillRemediationSpec `json:"spec"`
 }
@@ -63,6 +67,21 @@ type PoisonPillRemediationTemplateList struct {
 	Items           []PoisonPillRemediationTemplate `json:"items"`
 }
 
+// NewRemediation returns a remediation of the given node in the namespace of the template, with the spec of the
+// template. Remediations are named after the node they remediate.
+func (t *PoisonPillRemediationTemplate) NewRemediation(nodeName string) *PoisonPillRemediation {
+	ppr := &PoisonPillRemediation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeName,
+			Namespace: t.Namespace,
+			Labels:    map[string]string{RemediationTemplateLabel: t.Name},
+		},
+		Spec: *t.Spec.Template.Spec.DeepCopy(),
+	}
+	ppr.Spec.NodeName = nodeName
+	return ppr
+}
+
 func init() {
 	SchemeBuilder.Register(&PoisonPillRemediationTemplate{}, &PoisonPillRemediationTemplateList{})
 }
@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -22,16 +23,53 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptedPodDisruptionBudget) DeepCopyInto(out *DisruptedPodDisruptionBudget) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptedPodDisruptionBudget.
+func (in *DisruptedPodDisruptionBudget) DeepCopy() *DisruptedPodDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptedPodDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerResult) DeepCopyInto(out *PeerResult) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerResult.
+func (in *PeerResult) DeepCopy() *PeerResult {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PoisonPillConfig) DeepCopyInto(out *PoisonPillConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoisonPillConfig.
@@ -87,6 +125,42 @@ func (in *PoisonPillConfigList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PoisonPillConfigSpec) DeepCopyInto(out *PoisonPillConfigSpec) {
 	*out = *in
+	if in.WatchdogFilePaths != nil {
+		in, out := &in.WatchdogFilePaths, &out.WatchdogFilePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GracefulDeletionNamespaces != nil {
+		in, out := &in.GracefulDeletionNamespaces, &out.GracefulDeletionNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PeerTLSCipherSuites != nil {
+		in, out := &in.PeerTLSCipherSuites, &out.PeerTLSCipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApiServerEndpoints != nil {
+		in, out := &in.ApiServerEndpoints, &out.ApiServerEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExternalProbeEndpoints != nil {
+		in, out := &in.ExternalProbeEndpoints, &out.ExternalProbeEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PeerNodeSelector != nil {
+		in, out := &in.PeerNodeSelector, &out.PeerNodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeDeletingTaint != nil {
+		in, out := &in.NodeDeletingTaint, &out.NodeDeletingTaint
+		*out = new(v1.Taint)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoisonPillConfigSpec.
@@ -102,6 +176,13 @@ func (in *PoisonPillConfigSpec) DeepCopy() *PoisonPillConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PoisonPillConfigStatus) DeepCopyInto(out *PoisonPillConfigStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoisonPillConfigStatus.
@@ -196,15 +277,50 @@ func (in *PoisonPillRemediationStatus) DeepCopyInto(out *PoisonPillRemediationSt
 		*out = new(v1.Node)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.NodeWasUnschedulable != nil {
+		in, out := &in.NodeWasUnschedulable, &out.NodeWasUnschedulable
+		*out = new(bool)
+		**out = **in
+	}
 	if in.TimeAssumedRebooted != nil {
 		in, out := &in.TimeAssumedRebooted, &out.TimeAssumedRebooted
 		*out = (*in).DeepCopy()
 	}
+	if in.LastRemediationTime != nil {
+		in, out := &in.LastRemediationTime, &out.LastRemediationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PeerResults != nil {
+		in, out := &in.PeerResults, &out.PeerResults
+		*out = make([]PeerResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DisruptedPodDisruptionBudgets != nil {
+		in, out := &in.DisruptedPodDisruptionBudgets, &out.DisruptedPodDisruptionBudgets
+		*out = make([]DisruptedPodDisruptionBudget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastError != nil {
+		in, out := &in.LastError, &out.LastError
+		*out = new(RemediationError)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Phase != nil {
 		in, out := &in.Phase, &out.Phase
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoisonPillRemediationStatus.
@@ -322,3 +438,19 @@ func (in *PoisonPillRemediationTemplateStatus) DeepCopy() *PoisonPillRemediation
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemediationError) DeepCopyInto(out *RemediationError) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemediationError.
+func (in *RemediationError) DeepCopy() *RemediationError {
+	if in == nil {
+		return nil
+	}
+	out := new(RemediationError)
+	in.DeepCopyInto(out)
+	return out
+}
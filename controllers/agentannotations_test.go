@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+var _ = Describe("Agent annotations", func() {
+
+	var r *PoisonPillRemediationReconciler
+
+	BeforeEach(func() {
+		r = &PoisonPillRemediationReconciler{
+			MyNodeName:      "worker-1",
+			MyPodName:       "poison-pill-ds-abcde",
+			Version:         "0.2.0",
+			OperatorVersion: "0.2.1",
+		}
+	})
+
+	It("identifies the agent which started the remediation", func() {
+		ppr := &v1alpha1.PoisonPillRemediation{}
+		r.setAgentAnnotations(ppr)
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.AgentPodAnnotation, "poison-pill-ds-abcde"))
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.AgentNodeAnnotation, "worker-1"))
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.AgentVersionAnnotation, "0.2.0"))
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.OperatorVersionAnnotation, "0.2.1"))
+	})
+
+	It("keeps the operator version of the operator which created the remediation", func() {
+		ppr := &v1alpha1.PoisonPillRemediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{v1alpha1.OperatorVersionAnnotation: "0.1.0"},
+			},
+		}
+		r.setAgentAnnotations(ppr)
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.OperatorVersionAnnotation, "0.1.0"))
+	})
+
+	It("skips unknown identities", func() {
+		r.MyPodName = ""
+		r.OperatorVersion = ""
+		ppr := &v1alpha1.PoisonPillRemediation{}
+		r.setAgentAnnotations(ppr)
+		Expect(ppr.Annotations).ToNot(HaveKey(v1alpha1.AgentPodAnnotation))
+		Expect(ppr.Annotations).ToNot(HaveKey(v1alpha1.OperatorVersionAnnotation))
+		Expect(ppr.Annotations).To(HaveKeyWithValue(v1alpha1.AgentNodeAnnotation, "worker-1"))
+	})
+})
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Error backoff", func() {
+
+	request := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "node1"}}
+
+	It("increases the requeue delay on repeated failures up to the max", func() {
+		backoff := newErrorBackoff(time.Second, 10*time.Second)
+		var delays []time.Duration
+		for i := 0; i < 6; i++ {
+			delays = append(delays, backoff.When(request))
+		}
+		Expect(delays).To(Equal([]time.Duration{
+			1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+		}))
+		Expect(backoff.NumRequeues(request)).To(Equal(6))
+	})
+
+	It("starts over after a successful reconcile", func() {
+		backoff := newErrorBackoff(time.Second, 10*time.Second)
+		backoff.When(request)
+		backoff.When(request)
+		backoff.Forget(request)
+		Expect(backoff.When(request)).To(Equal(time.Second))
+	})
+
+	It("backs off every remediation on its own", func() {
+		backoff := newErrorBackoff(time.Second, 10*time.Second)
+		backoff.When(request)
+		other := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "node2"}}
+		Expect(backoff.When(other)).To(Equal(time.Second))
+	})
+
+	It("uses the defaults when not configured", func() {
+		backoff := newErrorBackoff(0, 0)
+		Expect(backoff.When(request)).To(Equal(defaultErrorBackoffBase))
+		for i := 0; i < 20; i++ {
+			backoff.When(request)
+		}
+		Expect(backoff.When(request)).To(Equal(defaultErrorBackoffMax))
+	})
+})
@@ -2,18 +2,24 @@ package controllers_test
 
 import (
 	"context"
+	"fmt"
 	"github.com/medik8s/poison-pill/controllers"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	poisonpillv1alpha1 "github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/utils"
 )
 
 const (
@@ -61,6 +67,29 @@ var _ = Describe("ppr Controller", func() {
 
 		beforePPR := time.Now()
 
+		It("Simulate conflicts on node updates", func() {
+			atomic.StoreInt32(&k8sClient.SimulatedNodeConflicts, 3)
+		})
+
+		newVolumeAttachment := func(nodeName string) *storagev1.VolumeAttachment {
+			pvName := "pv-" + nodeName
+			return &storagev1.VolumeAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "va-" + nodeName},
+				Spec: storagev1.VolumeAttachmentSpec{
+					Attacher: "test.csi.driver",
+					NodeName: nodeName,
+					Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+				},
+			}
+		}
+		unhealthyNodeVa := newVolumeAttachment(unhealthyNodeName)
+		peerNodeVa := newVolumeAttachment(peerNodeName)
+
+		It("Create volume attachments", func() {
+			Expect(k8sClient.Create(context.TODO(), unhealthyNodeVa)).To(Succeed())
+			Expect(k8sClient.Create(context.TODO(), peerNodeVa)).To(Succeed())
+		})
+
 		It("Create ppr for unhealthy node", func() {
 			ppr.Name = unhealthyNodeName
 			ppr.Namespace = pprNamespace
@@ -75,6 +104,10 @@ var _ = Describe("ppr Controller", func() {
 			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
 		})
 
+		It("Verify that the conflicting node updates were retried", func() {
+			Expect(atomic.LoadInt32(&k8sClient.SimulatedNodeConflicts)).To(BeZero())
+		})
+
 		It("Add unschedulable taint to node to simulate node controller", func() {
 			node.Spec.Taints = append(node.Spec.Taints, *controllers.NodeUnschedulableTaint)
 			Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
@@ -90,6 +123,11 @@ var _ = Describe("ppr Controller", func() {
 			}, 5*time.Second, 250*time.Millisecond).ShouldNot(BeZero())
 		})
 
+		It("Verify that node was labeled as fenced", func() {
+			Expect(k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, node)).To(Succeed())
+			Expect(node.Labels).To(HaveKeyWithValue(controllers.DefaultFencedNodeLabelKey, controllers.DefaultFencedNodeLabelValue))
+		})
+
 		newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
 		It("Verify that node backup annotation matches the node", func() {
 			pprNamespacedName := client.ObjectKey{Name: unhealthyNodeName, Namespace: pprNamespace}
@@ -111,6 +149,11 @@ var _ = Describe("ppr Controller", func() {
 			Expect(controllerutil.ContainsFinalizer(newPpr, controllers.PPRFinalizer)).Should(BeTrue(), "finalizer should be added")
 		})
 
+		It("Verify that remediation is processing", func() {
+			Expect(meta.IsStatusConditionTrue(newPpr.Status.Conditions, poisonpillv1alpha1.ProcessingConditionType)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(newPpr.Status.Conditions, poisonpillv1alpha1.SucceededConditionType)).To(BeTrue())
+		})
+
 		It("Verify that watchdog is not receiving food", func() {
 			currentLastFoodTime := dummyDog.LastFoodTime()
 			Consistently(func() time.Time {
@@ -138,6 +181,26 @@ var _ = Describe("ppr Controller", func() {
 			}, 100*time.Second, 250*time.Millisecond).Should(BeTemporally(">", beforePPR))
 		})
 
+		It("Verify that only the volume attachment of the unhealthy node was deleted", func() {
+			Eventually(func() bool {
+				err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(unhealthyNodeVa), &storagev1.VolumeAttachment{})
+				return apiErrors.IsNotFound(err)
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+			Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(peerNodeVa), &storagev1.VolumeAttachment{})).To(Succeed())
+			Expect(k8sClient.Delete(context.TODO(), peerNodeVa)).To(Succeed())
+		})
+
+		It("Verify that remediation succeeded", func() {
+			Eventually(func() bool {
+				pprNamespacedName := client.ObjectKey{Name: unhealthyNodeName, Namespace: pprNamespace}
+				newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+				Expect(k8sClient.Get(context.TODO(), pprNamespacedName, newPpr)).To(Succeed())
+				return meta.IsStatusConditionTrue(newPpr.Status.Conditions, poisonpillv1alpha1.FencingCompletedConditionType) &&
+					meta.IsStatusConditionTrue(newPpr.Status.Conditions, poisonpillv1alpha1.SucceededConditionType) &&
+					meta.IsStatusConditionFalse(newPpr.Status.Conditions, poisonpillv1alpha1.ProcessingConditionType)
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
 		It("Verify that node is not marked as unschedulable", func() {
 			Eventually(func() bool {
 				err := k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, node)
@@ -148,6 +211,10 @@ var _ = Describe("ppr Controller", func() {
 			}, 5*time.Second, 250*time.Millisecond).Should(BeFalse())
 		})
 
+		It("Verify that node is not labeled as fenced", func() {
+			Expect(node.Labels).ToNot(HaveKey(controllers.DefaultFencedNodeLabelKey))
+		})
+
 		It("Verify that finalizer exists until node updates status", func() {
 			Consistently(func() bool {
 				pprNamespacedName := client.ObjectKey{Name: unhealthyNodeName, Namespace: pprNamespace}
@@ -157,6 +224,16 @@ var _ = Describe("ppr Controller", func() {
 			}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
 		})
 
+		It("Verify that the last remediation time was recorded on the node", func() {
+			Eventually(func() map[string]string {
+				Expect(k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, node)).To(Succeed())
+				return node.Annotations
+			}, 5*time.Second, 250*time.Millisecond).Should(HaveKey(controllers.LastRemediationAnnotation))
+			lastRemediation, err := time.Parse(time.RFC3339, node.Annotations[controllers.LastRemediationAnnotation])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastRemediation).To(BeTemporally(">=", beforePPR.Truncate(time.Second)))
+		})
+
 		It("Update node's last hearbeat time", func() {
 			//we simulate kubelet coming up, this is required to remove the finalizer
 			node.Status.Conditions = make([]v1.NodeCondition, 1)
@@ -176,6 +253,315 @@ var _ = Describe("ppr Controller", func() {
 
 	})
 
+	Context("Remediation CR deleted while waiting for reboot", func() {
+
+		pprNamespacedName := client.ObjectKey{Name: unhealthyNodeName, Namespace: pprNamespace}
+
+		It("Delete ppr of previous remediation", func() {
+			oldPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			Expect(k8sClient.Get(context.TODO(), pprNamespacedName, oldPpr)).To(Succeed())
+			Expect(k8sClient.Delete(context.TODO(), oldPpr)).To(Succeed())
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), pprNamespacedName, oldPpr))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Create ppr for unhealthy node", func() {
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			newPpr.Name = unhealthyNodeName
+			newPpr.Namespace = pprNamespace
+			Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed(), "failed to create ppr CR")
+		})
+
+		node := &v1.Node{}
+		It("Verify that node was marked as unschedulable", func() {
+			Eventually(func() bool {
+				Expect(k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, node)).To(Succeed())
+				return node.Spec.Unschedulable
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Add unschedulable taint to node to simulate node controller", func() {
+			node.Spec.Taints = append(node.Spec.Taints, *controllers.NodeUnschedulableTaint)
+			Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
+		})
+
+		It("Delete ppr while waiting for reboot", func() {
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			Eventually(func() *metav1.Time {
+				Expect(k8sClient.Get(context.TODO(), pprNamespacedName, newPpr)).To(Succeed())
+				return newPpr.Status.TimeAssumedRebooted
+			}, 5*time.Second, 100*time.Millisecond).ShouldNot(BeZero())
+			Expect(k8sClient.Delete(context.TODO(), newPpr)).To(Succeed())
+		})
+
+		It("Verify that ppr is deleted and node is fully restored", func() {
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), pprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+
+			restoredNode := &v1.Node{}
+			Expect(k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, restoredNode)).To(Succeed())
+			Expect(restoredNode.UID).To(Equal(node.UID), "node should not have been deleted")
+			Expect(restoredNode.Spec.Unschedulable).To(BeFalse())
+			Expect(utils.TaintExists(restoredNode.Spec.Taints, controllers.NodeUnschedulableTaint)).To(BeFalse())
+			Expect(restoredNode.Labels).ToNot(HaveKey(controllers.DefaultFencedNodeLabelKey))
+		})
+	})
+
+	Context("Unhealthy node with skipped peer verification", func() {
+
+		pprNamespacedName := client.ObjectKey{Name: unhealthyNodeName, Namespace: pprNamespace}
+		var rebootsBefore int32
+		var created time.Time
+
+		It("Create ppr which skips peer verification", func() {
+			rebootsBefore = unhealthyNodeRebooter.RebootCount()
+			created = time.Now()
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			newPpr.Name = unhealthyNodeName
+			newPpr.Namespace = pprNamespace
+			newPpr.Spec.SkipPeerVerification = true
+			Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed(), "failed to create ppr CR")
+		})
+
+		node := &v1.Node{}
+		It("Verify that node was marked as unschedulable", func() {
+			Eventually(func() bool {
+				Expect(k8sClient.Get(context.TODO(), unhealthyNodeNamespacedName, node)).To(Succeed())
+				return node.Spec.Unschedulable
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Add unschedulable taint to node to simulate node controller", func() {
+			node.Spec.Taints = append(node.Spec.Taints, *controllers.NodeUnschedulableTaint)
+			Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
+		})
+
+		It("Verify that the node is assumed to be rebooted after its watchdog timeout only", func() {
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			Eventually(func() *metav1.Time {
+				Expect(k8sClient.Get(context.TODO(), pprNamespacedName, newPpr)).To(Succeed())
+				return newPpr.Status.TimeAssumedRebooted
+			}, 5*time.Second, 250*time.Millisecond).ShouldNot(BeZero())
+			Expect(newPpr.Status.TimeAssumedRebooted.Time).To(BeTemporally(">", created.Add(dummyDog.GetTimeout())))
+		})
+
+		It("Verify that the unhealthy node reboots itself", func() {
+			Eventually(func() int32 {
+				return unhealthyNodeRebooter.RebootCount()
+			}, 5*time.Second, 250*time.Millisecond).Should(BeNumerically(">", rebootsBefore))
+		})
+
+		It("Delete ppr", func() {
+			Expect(k8sClient.Delete(context.TODO(), &poisonpillv1alpha1.PoisonPillRemediation{
+				ObjectMeta: metav1.ObjectMeta{Name: pprNamespacedName.Name, Namespace: pprNamespacedName.Namespace},
+			})).To(Succeed())
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), pprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+	})
+
+	Context("Many unhealthy nodes at once", func() {
+
+		const nrNodes = 20
+		nodeName := func(i int) string {
+			return fmt.Sprintf("parallel-node-%d", i)
+		}
+
+		It("Create nodes and pprs", func() {
+			for i := 0; i < nrNodes; i++ {
+				node := &v1.Node{}
+				node.Name = nodeName(i)
+				node.Labels = map[string]string{"kubernetes.io/hostname": node.Name}
+				Expect(k8sClient.Create(context.TODO(), node)).To(Succeed())
+			}
+			for i := 0; i < nrNodes; i++ {
+				newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+				newPpr.Name = nodeName(i)
+				newPpr.Namespace = pprNamespace
+				Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed())
+			}
+		})
+
+		It("Verify that all remediations progress in parallel", func() {
+			// all remediations are started by concurrent workers, none of them waits for another one to finish
+			Eventually(func() int {
+				unschedulable := 0
+				for i := 0; i < nrNodes; i++ {
+					node := &v1.Node{}
+					Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Name: nodeName(i)}, node)).To(Succeed())
+					if node.Spec.Unschedulable {
+						unschedulable++
+					}
+				}
+				return unschedulable
+			}, 5*time.Second, 250*time.Millisecond).Should(Equal(nrNodes))
+		})
+
+		It("Delete pprs and nodes", func() {
+			for i := 0; i < nrNodes; i++ {
+				pprNamespacedName := client.ObjectKey{Name: nodeName(i), Namespace: pprNamespace}
+				Expect(k8sClient.Delete(context.TODO(), &poisonpillv1alpha1.PoisonPillRemediation{
+					ObjectMeta: metav1.ObjectMeta{Name: pprNamespacedName.Name, Namespace: pprNamespacedName.Namespace},
+				})).To(Succeed())
+				Eventually(func() bool {
+					return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), pprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+				}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+				Expect(k8sClient.Delete(context.TODO(), &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName(i)}})).To(Succeed())
+			}
+		})
+	})
+
+	Context("Node cordoned before remediation", func() {
+
+		cordonedNodeNamespacedName := client.ObjectKey{Name: "cordoned-node"}
+		cordonedPprNamespacedName := client.ObjectKey{Name: "cordoned-node", Namespace: pprNamespace}
+
+		It("Create cordoned node and ppr", func() {
+			node := &v1.Node{}
+			node.Name = cordonedNodeNamespacedName.Name
+			node.Labels = map[string]string{"kubernetes.io/hostname": node.Name}
+			node.Spec.Unschedulable = true
+			Expect(k8sClient.Create(context.TODO(), node)).To(Succeed())
+
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			newPpr.Name = cordonedPprNamespacedName.Name
+			newPpr.Namespace = cordonedPprNamespacedName.Namespace
+			Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed())
+		})
+
+		It("Verify that the node was recorded as unschedulable", func() {
+			Eventually(func() bool {
+				cordonedPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+				Expect(k8sClient.Get(context.TODO(), cordonedPprNamespacedName, cordonedPpr)).To(Succeed())
+				return cordonedPpr.Status.NodeWasUnschedulable != nil && *cordonedPpr.Status.NodeWasUnschedulable
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Delete ppr during remediation", func() {
+			Expect(k8sClient.Delete(context.TODO(), &poisonpillv1alpha1.PoisonPillRemediation{
+				ObjectMeta: metav1.ObjectMeta{Name: cordonedPprNamespacedName.Name, Namespace: cordonedPprNamespacedName.Namespace},
+			})).To(Succeed())
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), cordonedPprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Verify that the node is still unschedulable", func() {
+			node := &v1.Node{}
+			Expect(k8sClient.Get(context.TODO(), cordonedNodeNamespacedName, node)).To(Succeed())
+			Expect(node.Spec.Unschedulable).To(BeTrue())
+			Expect(k8sClient.Delete(context.TODO(), node)).To(Succeed())
+		})
+	})
+
+	Context("Unhealthy node with disabled remediation", func() {
+
+		disabledNodeNamespacedName := client.ObjectKey{Name: "disabled-node"}
+		disabledPprNamespacedName := client.ObjectKey{Name: "disabled-node", Namespace: pprNamespace}
+
+		It("Create annotated node and ppr", func() {
+			node := &v1.Node{}
+			node.Name = disabledNodeNamespacedName.Name
+			node.Labels = map[string]string{"kubernetes.io/hostname": node.Name}
+			node.Annotations = map[string]string{utils.DisabledAnnotation: ""}
+			Expect(k8sClient.Create(context.TODO(), node)).To(Succeed())
+
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			newPpr.Name = disabledPprNamespacedName.Name
+			newPpr.Namespace = disabledPprNamespacedName.Namespace
+			Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed())
+		})
+
+		It("Verify that remediation is deferred", func() {
+			Eventually(func() string {
+				disabledPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+				Expect(k8sClient.Get(context.TODO(), disabledPprNamespacedName, disabledPpr)).To(Succeed())
+				deferred := meta.FindStatusCondition(disabledPpr.Status.Conditions, poisonpillv1alpha1.DeferredConditionType)
+				if deferred == nil || deferred.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return deferred.Reason
+			}, 5*time.Second, 250*time.Millisecond).Should(Equal(poisonpillv1alpha1.RemediationDisabledReason))
+
+			node := &v1.Node{}
+			Expect(k8sClient.Get(context.TODO(), disabledNodeNamespacedName, node)).To(Succeed())
+			Expect(node.Spec.Unschedulable).To(BeFalse())
+		})
+
+		It("Delete ppr and node", func() {
+			Expect(k8sClient.Delete(context.TODO(), &poisonpillv1alpha1.PoisonPillRemediation{
+				ObjectMeta: metav1.ObjectMeta{Name: disabledPprNamespacedName.Name, Namespace: disabledPprNamespacedName.Namespace},
+			})).To(Succeed())
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), disabledPprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+			Expect(k8sClient.Delete(context.TODO(), &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: disabledNodeNamespacedName.Name}})).To(Succeed())
+		})
+	})
+
+	Context("Remediation disabled during remediation", func() {
+
+		disablingNodeNamespacedName := client.ObjectKey{Name: "disabling-node"}
+		disablingPprNamespacedName := client.ObjectKey{Name: "disabling-node", Namespace: pprNamespace}
+
+		It("Create node and ppr", func() {
+			node := &v1.Node{}
+			node.Name = disablingNodeNamespacedName.Name
+			node.Labels = map[string]string{"kubernetes.io/hostname": node.Name}
+			Expect(k8sClient.Create(context.TODO(), node)).To(Succeed())
+
+			newPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+			newPpr.Name = disablingPprNamespacedName.Name
+			newPpr.Namespace = disablingPprNamespacedName.Namespace
+			Expect(k8sClient.Create(context.TODO(), newPpr)).To(Succeed())
+		})
+
+		node := &v1.Node{}
+		It("Verify that node was marked as unschedulable", func() {
+			Eventually(func() bool {
+				Expect(k8sClient.Get(context.TODO(), disablingNodeNamespacedName, node)).To(Succeed())
+				return node.Spec.Unschedulable
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+
+		It("Disable remediation of the node", func() {
+			node.Annotations = map[string]string{utils.DisabledAnnotation: ""}
+			Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
+		})
+
+		It("Verify that remediation is aborted and deferred", func() {
+			Eventually(func() string {
+				disablingPpr := &poisonpillv1alpha1.PoisonPillRemediation{}
+				Expect(k8sClient.Get(context.TODO(), disablingPprNamespacedName, disablingPpr)).To(Succeed())
+				if controllerutil.ContainsFinalizer(disablingPpr, controllers.PPRFinalizer) {
+					return ""
+				}
+				deferred := meta.FindStatusCondition(disablingPpr.Status.Conditions, poisonpillv1alpha1.DeferredConditionType)
+				if deferred == nil || deferred.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return deferred.Reason
+			}, 5*time.Second, 250*time.Millisecond).Should(Equal(poisonpillv1alpha1.RemediationDisabledReason))
+
+			Expect(k8sClient.Get(context.TODO(), disablingNodeNamespacedName, node)).To(Succeed())
+			Expect(node.Spec.Unschedulable).To(BeFalse())
+			Expect(node.Labels).ToNot(HaveKey(controllers.DefaultFencedNodeLabelKey))
+		})
+
+		It("Delete ppr and node", func() {
+			Expect(k8sClient.Delete(context.TODO(), &poisonpillv1alpha1.PoisonPillRemediation{
+				ObjectMeta: metav1.ObjectMeta{Name: disablingPprNamespacedName.Name, Namespace: disablingPprNamespacedName.Namespace},
+			})).To(Succeed())
+			Eventually(func() bool {
+				return apiErrors.IsNotFound(k8sClient.Get(context.TODO(), disablingPprNamespacedName, &poisonpillv1alpha1.PoisonPillRemediation{}))
+			}, 5*time.Second, 250*time.Millisecond).Should(BeTrue())
+			Expect(k8sClient.Delete(context.TODO(), &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: disablingNodeNamespacedName.Name}})).To(Succeed())
+		})
+	})
+
 	Context("Unhealthy node without api-server access", func() {
 
 		// this is not a controller test anymore... it's testing peers. But keep it here for now...
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/utils"
+)
+
+var _ = Describe("Etcd member removal prompt", func() {
+
+	var reconciler *PoisonPillRemediationReconciler
+	var recorder *record.FakeRecorder
+	var node *v1.Node
+	var ppr *v1alpha1.PoisonPillRemediation
+
+	BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &PoisonPillRemediationReconciler{
+			Log:                     ctrl.Log.WithName("etcd-test"),
+			Recorder:                recorder,
+			PromptEtcdMemberRemoval: true,
+		}
+		node = &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master-0", Labels: map[string]string{utils.MasterLabel: ""}}}
+		ppr = &v1alpha1.PoisonPillRemediation{ObjectMeta: metav1.ObjectMeta{Name: "master-0"}}
+		reconciler.setCondition(ppr, v1alpha1.FencingCompletedConditionType, metav1.ConditionTrue, v1alpha1.NodeRebootedReason, "")
+	})
+
+	isPrompted := func() bool {
+		return meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.EtcdMemberRemovalRequiredConditionType)
+	}
+
+	It("prompts the removal of the member of a fenced control plane node once", func() {
+		Expect(reconciler.promptEtcdMemberRemoval(reconciler.Log, node, ppr)).To(BeTrue())
+		Expect(isPrompted()).To(BeTrue())
+		Expect(recorder.Events).To(HaveLen(2))
+
+		Expect(reconciler.promptEtcdMemberRemoval(reconciler.Log, node, ppr)).To(BeFalse())
+		Expect(recorder.Events).To(HaveLen(2))
+	})
+
+	It("doesn't prompt when not enabled", func() {
+		reconciler.PromptEtcdMemberRemoval = false
+		Expect(reconciler.promptEtcdMemberRemoval(reconciler.Log, node, ppr)).To(BeFalse())
+		Expect(isPrompted()).To(BeFalse())
+	})
+
+	It("doesn't prompt for worker nodes", func() {
+		node.Labels = map[string]string{"node-role.kubernetes.io/worker": ""}
+		Expect(reconciler.promptEtcdMemberRemoval(reconciler.Log, node, ppr)).To(BeFalse())
+		Expect(isPrompted()).To(BeFalse())
+	})
+
+	It("doesn't prompt before the fencing completed", func() {
+		ppr.Status.Conditions = nil
+		Expect(reconciler.promptEtcdMemberRemoval(reconciler.Log, node, ppr)).To(BeFalse())
+		Expect(isPrompted()).To(BeFalse())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
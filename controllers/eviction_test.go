@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Pod eviction before reboot", func() {
+
+	newPod := func(name string, ownerKind string, ownerName string) *v1.Pod {
+		pod := &v1.Pod{}
+		pod.Name = name
+		pod.Status.Phase = v1.PodRunning
+		if ownerKind != "" {
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName}}
+		}
+		return pod
+	}
+
+	mirrorPod := newPod("static-pod", "Node", "node1")
+	mirrorPod.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	daemonSetPod := newPod("ds-pod", "DaemonSet", "some-ds")
+	agentPod := newPod("agent-pod", "DaemonSet", agentDaemonSetName)
+	replicaSetPod := newPod("rs-pod", "ReplicaSet", "some-rs")
+	completedPod := newPod("completed-pod", "Job", "some-job")
+	completedPod.Status.Phase = v1.PodSucceeded
+
+	Context("with default settings", func() {
+		r := &PoisonPillRemediationReconciler{}
+
+		It("skips DaemonSet and mirror pods", func() {
+			Expect(r.needsEviction(daemonSetPod)).To(BeFalse())
+			Expect(r.needsEviction(agentPod)).To(BeFalse())
+			Expect(r.needsEviction(mirrorPod)).To(BeFalse())
+		})
+
+		It("evicts other running pods only", func() {
+			Expect(r.needsEviction(replicaSetPod)).To(BeTrue())
+			Expect(r.needsEviction(completedPod)).To(BeFalse())
+		})
+	})
+
+	Context("with DeleteDaemonSetPods", func() {
+		r := &PoisonPillRemediationReconciler{DeleteDaemonSetPods: true}
+
+		It("evicts DaemonSet pods except the agent", func() {
+			Expect(r.needsEviction(daemonSetPod)).To(BeTrue())
+			Expect(r.needsEviction(agentPod)).To(BeFalse())
+		})
+
+		It("still skips mirror pods", func() {
+			Expect(r.needsEviction(mirrorPod)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// the outcomes of finished remediations, every remediation is counted once, by the agent which recorded its outcome
+// in the status
+const (
+	// outcomeSucceeded is used when the node has been restored, or its workloads were deleted
+	outcomeSucceeded = "succeeded"
+	// outcomeTimedOut is used when the agents gave up on the remediation after the max remediation duration
+	outcomeTimedOut = "timed_out"
+	// outcomeFailed is used when the node doesn't exist and can't be restored
+	outcomeFailed = "failed"
+)
+
+var (
+	remediationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "poison_pill_remediation_duration_seconds",
+		Help:    "Time from the creation of a remediation until the node has been restored",
+		Buckets: []float64{10, 30, 60, 90, 120, 180, 240, 300, 450, 600},
+	})
+	remediations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_remediations_total",
+		Help: "Number of finished remediations by outcome",
+	}, []string{"outcome"})
+	remediationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_remediation_errors_total",
+		Help: "Number of remediation errors by reason",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(remediationDuration, remediations, remediationErrors)
+}
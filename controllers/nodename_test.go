@@ -0,0 +1,26 @@
+package controllers_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/medik8s/poison-pill/pkg/utils"
+)
+
+var _ = Describe("Resolving the node name by hostname", func() {
+
+	It("should find the node with the matching hostname label", func() {
+		Expect(utils.ResolveNodeName(context.Background(), k8sClient, peerNodeName)).To(Equal(peerNodeName))
+		Expect(utils.ResolveNodeName(context.Background(), k8sClient, strings.ToUpper(peerNodeName))).To(Equal(peerNodeName))
+	})
+
+	It("should fail for unknown hostnames", func() {
+		_, err := utils.ResolveNodeName(context.Background(), k8sClient, "unknown-host")
+		Expect(err).To(HaveOccurred())
+		_, err = utils.ResolveNodeName(context.Background(), k8sClient, "")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+var _ = Describe("DaemonSet rendering", func() {
+
+	renderConfig := func(isOpenShift bool, config *v1alpha1.PoisonPillConfig) *appsv1.DaemonSet {
+		reconciler := &PoisonPillConfigReconciler{
+			Log:               ctrl.Log.WithName("openshift-test"),
+			InstallFileFolder: "../install/",
+			IsOpenShift:       isOpenShift,
+		}
+		objs, err := reconciler.renderDaemonSet(reconciler.Log, config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+		ds := &appsv1.DaemonSet{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(objs[0].Object, ds)).To(Succeed())
+		return ds
+	}
+
+	renderDaemonSet := func(isOpenShift bool) *appsv1.DaemonSet {
+		config := v1alpha1.NewDefaultPoisonPillConfig()
+		return renderConfig(isOpenShift, &config)
+	}
+
+	It("requires the privileged SCC on OpenShift", func() {
+		ds := renderDaemonSet(true)
+		Expect(ds.Spec.Template.Annotations).To(HaveKeyWithValue("openshift.io/required-scc", "privileged"))
+		securityContext := ds.Spec.Template.Spec.Containers[0].SecurityContext
+		Expect(*securityContext.Privileged).To(BeTrue())
+		Expect(*securityContext.RunAsUser).To(BeZero())
+		Expect(securityContext.SELinuxOptions.Type).To(Equal("spc_t"))
+	})
+
+	It("doesn't change the DaemonSet on other clusters", func() {
+		ds := renderDaemonSet(false)
+		Expect(ds.Spec.Template.Annotations).To(BeEmpty())
+		securityContext := ds.Spec.Template.Spec.Containers[0].SecurityContext
+		Expect(*securityContext.Privileged).To(BeTrue())
+		Expect(securityContext.RunAsUser).To(BeNil())
+		Expect(securityContext.SELinuxOptions).To(BeNil())
+	})
+
+	It("mounts the directory of the audit log", func() {
+		config := v1alpha1.NewDefaultPoisonPillConfig()
+		config.Spec.AuditLogPath = "/var/log/poison-pill/audit.log"
+		config.Spec.AuditLogMaxSizeMegabytes = 5
+		ds := renderConfig(false, &config)
+		container := ds.Spec.Template.Spec.Containers[0]
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "AUDIT_LOG_FILE", Value: "/var/log/poison-pill/audit.log"}))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "AUDIT_LOG_MAX_SIZE", Value: "5"}))
+		Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: "audit-log", MountPath: "/var/log/poison-pill"}))
+		Expect(ds.Spec.Template.Spec.Volumes).To(HaveLen(3))
+	})
+
+	It("doesn't mount the state directory twice for the audit log", func() {
+		config := v1alpha1.NewDefaultPoisonPillConfig()
+		config.Spec.AuditLogPath = "/var/lib/poison-pill/state/audit.log"
+		ds := renderConfig(false, &config)
+		Expect(ds.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "AUDIT_LOG_FILE", Value: "/var/lib/poison-pill/state/audit.log"}))
+		Expect(ds.Spec.Template.Spec.Volumes).To(HaveLen(2))
+	})
+})
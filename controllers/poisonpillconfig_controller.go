@@ -17,13 +17,23 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,6 +45,16 @@ import (
 	"github.com/medik8s/poison-pill/pkg/apply"
 	"github.com/medik8s/poison-pill/pkg/certificates"
 	"github.com/medik8s/poison-pill/pkg/render"
+	"github.com/medik8s/poison-pill/pkg/version"
+)
+
+// maxCertRotationCheckInterval is the max interval for checking if the certificates need to be rotated
+const maxCertRotationCheckInterval = 24 * time.Hour
+
+// agentStateDir and agentCertsDir are the host directories which are always mounted into the agents
+const (
+	agentStateDir = "/var/lib/poison-pill/state"
+	agentCertsDir = "/var/lib/poison-pill/certs"
 )
 
 // PoisonPillConfigReconciler reconciles a PoisonPillConfig object
@@ -43,7 +63,17 @@ type PoisonPillConfigReconciler struct {
 	Log               logr.Logger
 	Scheme            *runtime.Scheme
 	InstallFileFolder string
+	// Namespace is the namespace of the operator. The agents, their certificates and the config they read live in
+	// it, so configs in other namespaces are ignored. Empty accepts configs in all namespaces.
+	Namespace         string
 	DefaultPpcCreator func(c client.Client) error
+	// CertFileStorage is optional, if set the content of the certificate secret is synced to it on each reconcile
+	CertFileStorage certificates.CertStorageWriter
+	// CertRotationWindow is the time before the expiry of the peer certificate at which it gets rotated
+	CertRotationWindow time.Duration
+	// IsOpenShift renders the agents' DaemonSet for OpenShift, where the agents need the privileged
+	// SecurityContextConstraints for accessing the watchdog device. Other clusters get the plain DaemonSet.
+	IsOpenShift bool
 }
 
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillconfigs,verbs=get;list;watch;create;update;patch;delete
@@ -58,6 +88,11 @@ type PoisonPillConfigReconciler struct {
 func (r *PoisonPillConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.Log.WithValues("poisonpillconfig", req.NamespacedName)
 
+	if r.Namespace != "" && req.Namespace != r.Namespace {
+		logger.Info("ignoring config outside of the operator namespace", "operator namespace", r.Namespace)
+		return ctrl.Result{}, nil
+	}
+
 	config := &poisonpillv1alpha1.PoisonPillConfig{}
 	if err := r.Client.Get(context.Background(), req.NamespacedName, config); err != nil {
 		if errors.IsNotFound(err) {
@@ -73,12 +108,29 @@ func (r *PoisonPillConfigReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	certRotationRequeue, err := r.rotateCerts(config)
+	if err != nil {
+		if errors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "error rotating certs")
+		return ctrl.Result{}, err
+	}
+
 	if err := r.syncConfigDaemonSet(config); err != nil {
 		logger.Error(err, "error syncing DS")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	if err := r.syncWatchdogTimeoutCondition(config); err != nil {
+		if errors.IsConflict(err) {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		logger.Error(err, "error syncing watchdog timeout condition")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: certRotationRequeue}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -93,36 +145,178 @@ func (r *PoisonPillConfigReconciler) syncConfigDaemonSet(ppc *poisonpillv1alpha1
 	logger := r.Log.WithName("syncConfigDaemonset")
 	logger.Info("Start to sync config daemonset")
 
+	objs, err := r.renderDaemonSet(logger, ppc)
+	if err != nil {
+		return err
+	}
+	// Sync DaemonSets
+	for _, obj := range objs {
+		err = r.syncK8sResource(ppc, obj)
+		if err != nil {
+			logger.Error(err, "Couldn't sync poison-pill daemons objects")
+			return err
+		}
+	}
+	return nil
+}
+
+// renderDaemonSet renders the manifests of the agents for the given config
+func (r *PoisonPillConfigReconciler) renderDaemonSet(logger logr.Logger, ppc *poisonpillv1alpha1.PoisonPillConfig) ([]*unstructured.Unstructured, error) {
 	data := render.MakeRenderData()
+	data.Data["IsOpenShift"] = r.IsOpenShift
 	data.Data["Image"] = os.Getenv("POISON_PILL_IMAGE")
+	data.Data["OperatorVersion"] = fmt.Sprintf("\"%s\"", version.Version)
 	data.Data["Namespace"] = ppc.Namespace
 
-	watchdogPath := ppc.Spec.WatchdogFilePath
-	if watchdogPath == "" {
-		watchdogPath = "/dev/watchdog1"
-	}
-	data.Data["WatchdogPath"] = watchdogPath
+	// an empty path makes the agents auto detect the watchdog device
+	data.Data["WatchdogPath"] = strconv.Quote(ppc.Spec.WatchdogFilePath)
+	data.Data["WatchdogPaths"] = strconv.Quote(strings.Join(ppc.Spec.WatchdogFilePaths, ","))
 
 	timeToAssumeNodeRebooted := ppc.Spec.SafeTimeToAssumeNodeRebootedSeconds
 	if timeToAssumeNodeRebooted == 0 {
 		timeToAssumeNodeRebooted = 180
 	}
 	data.Data["TimeToAssumeNodeRebooted"] = fmt.Sprintf("\"%d\"", timeToAssumeNodeRebooted)
+	data.Data["WatchdogTimeout"] = fmt.Sprintf("\"%d\"", ppc.Spec.WatchdogTimeoutSeconds)
+	// the other intervals are passed in seconds, this one needs a finer resolution, so it's passed as duration string
+	keepaliveInterval := time.Duration(ppc.Spec.WatchdogKeepaliveIntervalMilliseconds) * time.Millisecond
+	data.Data["WatchdogKeepaliveInterval"] = fmt.Sprintf("\"%s\"", keepaliveInterval)
+	data.Data["WatchdogArmDelay"] = fmt.Sprintf("\"%d\"", ppc.Spec.WatchdogArmDelaySeconds)
+	data.Data["RebootMethod"] = fmt.Sprintf("\"%s\"", ppc.Spec.RebootMethod)
+	data.Data["ConfigName"] = ppc.Name
+	data.Data["DryRun"] = fmt.Sprintf("\"%t\"", ppc.Spec.DryRun)
+	data.Data["ExternalFencing"] = fmt.Sprintf("\"%t\"", ppc.Spec.ExternalFencing)
+	data.Data["SoftwareRebootFallback"] = fmt.Sprintf("\"%t\"", ppc.Spec.SoftwareRebootFallback)
+	data.Data["AnnotateMachines"] = fmt.Sprintf("\"%t\"", ppc.Spec.AnnotateMachines)
+	data.Data["PromptEtcdMemberRemoval"] = fmt.Sprintf("\"%t\"", ppc.Spec.PromptEtcdMemberRemoval)
+	data.Data["ForceDeletePods"] = fmt.Sprintf("\"%t\"", ppc.Spec.ForceDeletePods)
+	data.Data["PodDeletionConcurrency"] = fmt.Sprintf("\"%d\"", ppc.Spec.PodDeletionConcurrency)
+	data.Data["DeleteCriticalPodsLast"] = fmt.Sprintf("\"%t\"", ppc.Spec.DeleteCriticalPodsLast)
+	data.Data["GracefulDeletionNamespaces"] = strconv.Quote(strings.Join(ppc.Spec.GracefulDeletionNamespaces, ","))
+	data.Data["GracefulDeletionTimeout"] = fmt.Sprintf("\"%d\"", ppc.Spec.GracefulDeletionTimeoutSeconds)
+	data.Data["MinPeersForQuorum"] = fmt.Sprintf("\"%d\"", ppc.Spec.MinPeersForQuorum)
+	data.Data["FenceOnIndeterminate"] = fmt.Sprintf("\"%t\"", ppc.Spec.FenceOnIndeterminate)
+	data.Data["PeerSampleSize"] = fmt.Sprintf("\"%d\"", ppc.Spec.PeerSampleSize)
+	data.Data["MinClusterSizeForFencing"] = fmt.Sprintf("\"%d\"", ppc.Spec.MinClusterSizeForFencing)
+	data.Data["GracefulRebootTimeout"] = fmt.Sprintf("\"%d\"", ppc.Spec.GracefulRebootTimeoutSeconds)
+	data.Data["DeleteDaemonSetPods"] = fmt.Sprintf("\"%t\"", ppc.Spec.DeleteDaemonSetPods)
+	data.Data["MaxConcurrentReboots"] = fmt.Sprintf("\"%d\"", ppc.Spec.MaxConcurrentReboots)
+	data.Data["MaxConcurrentRemediations"] = fmt.Sprintf("\"%d\"", ppc.Spec.MaxConcurrentRemediations)
+	data.Data["RemediationCooldown"] = fmt.Sprintf("\"%d\"", ppc.Spec.RemediationCooldownSeconds)
+	data.Data["MaxRemediationDuration"] = fmt.Sprintf("\"%d\"", ppc.Spec.MaxRemediationDurationSeconds)
+	data.Data["NodeReadyGracePeriod"] = fmt.Sprintf("\"%d\"", ppc.Spec.NodeReadyGracePeriodSeconds)
+	data.Data["StatusBindAddress"] = fmt.Sprintf("\"%s\"", ppc.Spec.StatusBindAddress)
+	data.Data["ApiFailureSimulation"] = fmt.Sprintf("\"%t\"", ppc.Spec.ApiFailureSimulation)
+	data.Data["ApiFailureSimulationFencing"] = fmt.Sprintf("\"%t\"", ppc.Spec.ApiFailureSimulationFencing)
+	data.Data["ApiCheckInterval"] = fmt.Sprintf("\"%d\"", ppc.Spec.ApiCheckIntervalSeconds)
+	data.Data["ApiCheckStartupGracePeriod"] = fmt.Sprintf("\"%d\"", ppc.Spec.ApiCheckStartupGracePeriodSeconds)
+	data.Data["ApiServerTimeout"] = fmt.Sprintf("\"%d\"", ppc.Spec.ApiServerTimeoutSeconds)
+	data.Data["ApiCheckProbeMode"] = fmt.Sprintf("\"%s\"", ppc.Spec.ApiCheckProbeMode)
+	data.Data["ApiServerEndpoints"] = strconv.Quote(strings.Join(ppc.Spec.ApiServerEndpoints, ","))
+	data.Data["ExternalProbeEndpoints"] = strconv.Quote(strings.Join(ppc.Spec.ExternalProbeEndpoints, ","))
+	data.Data["ProbeKubelet"] = fmt.Sprintf("\"%t\"", ppc.Spec.ProbeKubelet)
+	data.Data["AuditLogPath"] = ""
+	data.Data["AuditLogDir"] = ""
+	if ppc.Spec.AuditLogPath != "" {
+		auditLogPath := filepath.Clean(ppc.Spec.AuditLogPath)
+		data.Data["AuditLogPath"] = fmt.Sprintf("\"%s\"", auditLogPath)
+		// the directories of the agent's state are mounted already
+		if auditLogDir := filepath.Dir(auditLogPath); auditLogDir != agentStateDir && auditLogDir != agentCertsDir {
+			data.Data["AuditLogDir"] = fmt.Sprintf("\"%s\"", auditLogDir)
+		}
+	}
+	data.Data["AuditLogMaxSize"] = fmt.Sprintf("\"%d\"", ppc.Spec.AuditLogMaxSizeMegabytes)
+
+	nodeDeletingTaint := ""
+	if ppc.Spec.NodeDeletingTaint != nil {
+		taintJson, err := json.Marshal(ppc.Spec.NodeDeletingTaint)
+		if err != nil {
+			logger.Error(err, "failed to marshal node deleting taint")
+			return nil, err
+		}
+		nodeDeletingTaint = string(taintJson)
+	}
+	data.Data["NodeDeletingTaint"] = strconv.Quote(nodeDeletingTaint)
+	data.Data["FencedNodeLabelKey"] = strconv.Quote(ppc.Spec.FencedNodeLabelKey)
+	data.Data["FencedNodeLabelValue"] = strconv.Quote(ppc.Spec.FencedNodeLabelValue)
+
+	peerNodeSelector := ""
+	if ppc.Spec.PeerNodeSelector != nil {
+		selectorJson, err := json.Marshal(ppc.Spec.PeerNodeSelector)
+		if err != nil {
+			logger.Error(err, "failed to marshal peer node selector")
+			return nil, err
+		}
+		peerNodeSelector = string(selectorJson)
+	}
+	data.Data["PeerNodeSelector"] = strconv.Quote(peerNodeSelector)
+	data.Data["PeerSelectionStrategy"] = fmt.Sprintf("\"%s\"", ppc.Spec.PeerSelectionStrategy)
+
+	resources := ppc.Spec.Resources
+	if len(resources.Requests) == 0 && len(resources.Limits) == 0 {
+		resources = defaultAgentResources()
+	}
+	resourcesJson, err := json.Marshal(resources)
+	if err != nil {
+		logger.Error(err, "failed to marshal agent resources")
+		return nil, err
+	}
+	data.Data["Resources"] = string(resourcesJson)
+
+	peerPort := ppc.Spec.PeerPort
+	if peerPort == 0 {
+		peerPort = 30001
+	}
+	data.Data["PeerPort"] = peerPort
+	data.Data["PeerNetworkInterface"] = fmt.Sprintf("\"%s\"", ppc.Spec.PeerNetworkInterface)
+	data.Data["MaxPeerClockSkew"] = fmt.Sprintf("\"%d\"", ppc.Spec.MaxPeerClockSkewSeconds)
+
+	peerMinTLSVersion := ppc.Spec.PeerMinTLSVersion
+	if peerMinTLSVersion == "" {
+		peerMinTLSVersion = "1.2"
+	}
+	data.Data["PeerMinTLSVersion"] = fmt.Sprintf("\"%s\"", peerMinTLSVersion)
+	data.Data["PeerTLSCipherSuites"] = strconv.Quote(strings.Join(ppc.Spec.PeerTLSCipherSuites, ","))
+
+	peerClientAuth := ppc.Spec.PeerClientAuth
+	if peerClientAuth == "" {
+		peerClientAuth = certificates.ClientAuthRequired
+	}
+	data.Data["PeerClientAuth"] = fmt.Sprintf("\"%s\"", peerClientAuth)
 
 	objs, err := render.RenderDir(r.InstallFileFolder, &data)
 	if err != nil {
 		logger.Error(err, "Fail to render config daemon manifests")
-		return err
+		return nil, err
 	}
-	// Sync DaemonSets
-	for _, obj := range objs {
-		err = r.syncK8sResource(ppc, obj)
-		if err != nil {
-			logger.Error(err, "Couldn't sync poison-pill daemons objects")
-			return err
-		}
+	return objs, nil
+}
+
+// defaultAgentResources returns the resources of the agent container when the config doesn't set any
+func defaultAgentResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("20m"),
+			corev1.ResourceMemory: resource.MustParse("60Mi"),
+		},
 	}
-	return nil
+}
+
+// syncWatchdogTimeoutCondition accepts the watchdog timeout of a new config generation.
+// Agents will set the condition to false if their watchdog device doesn't support the timeout.
+func (r *PoisonPillConfigReconciler) syncWatchdogTimeoutCondition(ppc *poisonpillv1alpha1.PoisonPillConfig) error {
+	cond := meta.FindStatusCondition(ppc.Status.Conditions, poisonpillv1alpha1.WatchdogTimeoutValidConditionType)
+	if cond != nil && cond.ObservedGeneration == ppc.Generation {
+		return nil
+	}
+
+	meta.SetStatusCondition(&ppc.Status.Conditions, metav1.Condition{
+		Type:               poisonpillv1alpha1.WatchdogTimeoutValidConditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             poisonpillv1alpha1.WatchdogTimeoutAcceptedReason,
+		ObservedGeneration: ppc.Generation,
+	})
+	return r.Client.Status().Update(context.Background(), ppc)
 }
 
 func (r *PoisonPillConfigReconciler) syncK8sResource(cr *poisonpillv1alpha1.PoisonPillConfig, in *unstructured.Unstructured) error {
@@ -144,15 +338,14 @@ func (r *PoisonPillConfigReconciler) syncCerts(cr *poisonpillv1alpha1.PoisonPill
 	r.Log.Info("Syncing certs")
 	// check if certs exists already
 	st := certificates.NewSecretCertStorage(r.Client, r.Log.WithName("SecretCertStorage"), cr.Namespace)
-	pem, _, _, err := st.GetCerts()
+	pem, certPem, keyPem, err := st.GetCerts()
 	if err != nil && !errors.IsNotFound(err) {
 		r.Log.Error(err, "Failed to get cert secret")
 		return err
 	}
 	if pem != nil {
 		r.Log.Info("Cert secret already exists")
-		// TODO check validity: if expired, create new, restart daemonset!
-		return nil
+		return r.syncCertFiles(pem, certPem, keyPem)
 	}
 	// create certs
 	r.Log.Info("Creating new certs")
@@ -168,5 +361,32 @@ func (r *PoisonPillConfigReconciler) syncCerts(cr *poisonpillv1alpha1.PoisonPill
 		r.Log.Error(err, "Failed to store certs in secret")
 		return err
 	}
+	return r.syncCertFiles(ca, cert, key)
+}
+
+// rotateCerts rotates the certificates if needed, and returns after which time this needs to be checked again
+func (r *PoisonPillConfigReconciler) rotateCerts(cr *poisonpillv1alpha1.PoisonPillConfig) (time.Duration, error) {
+	if r.CertRotationWindow == 0 {
+		return 0, nil
+	}
+	st := certificates.NewSecretCertStorage(r.Client, r.Log.WithName("SecretCertStorage"), cr.Namespace)
+	requeueAfter, err := st.RotateCerts(r.CertRotationWindow)
+	if err != nil {
+		return 0, err
+	}
+	if requeueAfter > maxCertRotationCheckInterval {
+		requeueAfter = maxCertRotationCheckInterval
+	}
+	return requeueAfter, nil
+}
+
+func (r *PoisonPillConfigReconciler) syncCertFiles(caPem, certPem, keyPem *bytes.Buffer) error {
+	if r.CertFileStorage == nil {
+		return nil
+	}
+	if err := r.CertFileStorage.StoreCerts(caPem, certPem, keyPem); err != nil {
+		r.Log.Error(err, "Failed to store certs in files")
+		return err
+	}
 	return nil
 }
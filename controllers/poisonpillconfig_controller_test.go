@@ -10,6 +10,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -41,6 +42,7 @@ var _ = Describe("ppc controller Test", func() {
 		config.APIVersion = "poison-pill.medik8s.io/v1alpha1"
 		config.Spec.WatchdogFilePath = "/dev/foo"
 		config.Spec.SafeTimeToAssumeNodeRebootedSeconds = 123
+		config.Spec.WatchdogTimeoutSeconds = 30
 		config.Name = "config-sample"
 		config.Namespace = namespace
 
@@ -82,12 +84,73 @@ var _ = Describe("ppc controller Test", func() {
 			Expect(container.Image).To(Equal(dummyPoisonPillImage))
 			envVars := getEnvVarMap(container.Env)
 			Expect(envVars["WATCHDOG_PATH"].Value).To(Equal(config.Spec.WatchdogFilePath))
+			Expect(envVars["WATCHDOG_PATHS"].Value).To(BeEmpty())
 			Expect(envVars["TIME_TO_ASSUME_NODE_REBOOTED"].Value).To(Equal("123"))
+			Expect(envVars["WATCHDOG_TIMEOUT"].Value).To(Equal("30"))
+			Expect(envVars["WATCHDOG_KEEPALIVE_INTERVAL"].Value).To(Equal("0s"))
+			Expect(envVars["WATCHDOG_ARM_DELAY"].Value).To(Equal("0"))
+			Expect(envVars["REBOOT_METHOD"].Value).To(BeEmpty())
+			Expect(envVars["POISON_PILL_CONFIG_NAME"].Value).To(Equal(config.Name))
+			Expect(envVars["DRY_RUN"].Value).To(Equal("false"))
+			Expect(envVars["EXTERNAL_FENCING"].Value).To(Equal("false"))
+			Expect(envVars["SOFTWARE_REBOOT_FALLBACK"].Value).To(Equal("false"))
+			Expect(envVars["ANNOTATE_MACHINES"].Value).To(Equal("false"))
+			Expect(envVars["PROMPT_ETCD_MEMBER_REMOVAL"].Value).To(Equal("false"))
+			Expect(envVars["FORCE_DELETE_PODS"].Value).To(Equal("false"))
+			Expect(envVars["POD_DELETION_CONCURRENCY"].Value).To(Equal("0"))
+			Expect(envVars["DELETE_CRITICAL_PODS_LAST"].Value).To(Equal("false"))
+			Expect(envVars["GRACEFUL_DELETION_NAMESPACES"].Value).To(BeEmpty())
+			Expect(envVars["GRACEFUL_DELETION_TIMEOUT"].Value).To(Equal("0"))
+			Expect(envVars).ToNot(HaveKey("AUDIT_LOG_FILE"))
+			Expect(envVars["DELETE_DAEMONSET_PODS"].Value).To(Equal("false"))
+			Expect(envVars["MAX_CONCURRENT_REBOOTS"].Value).To(Equal("0"))
+			Expect(envVars["NODE_DELETING_TAINT"].Value).To(BeEmpty())
+			Expect(envVars["FENCED_NODE_LABEL_KEY"].Value).To(BeEmpty())
+			Expect(envVars["FENCED_NODE_LABEL_VALUE"].Value).To(BeEmpty())
+			Expect(envVars["PEER_NODE_SELECTOR"].Value).To(BeEmpty())
+			Expect(envVars["PEER_SELECTION_STRATEGY"].Value).To(BeEmpty())
+			Expect(envVars["STATUS_BIND_ADDRESS"].Value).To(BeEmpty())
+			Expect(envVars["API_FAILURE_SIMULATION"].Value).To(Equal("false"))
+			Expect(envVars["API_FAILURE_SIMULATION_FENCING"].Value).To(Equal("false"))
+			Expect(envVars["API_CHECK_PROBE_MODE"].Value).To(BeEmpty())
+			Expect(envVars["API_SERVER_ENDPOINTS"].Value).To(BeEmpty())
+			Expect(envVars["EXTERNAL_PROBE_ENDPOINTS"].Value).To(BeEmpty())
+			Expect(envVars["PROBE_KUBELET"].Value).To(Equal("false"))
+			Expect(envVars["API_CHECK_INTERVAL"].Value).To(Equal("0"))
+			Expect(envVars["API_CHECK_STARTUP_GRACE_PERIOD"].Value).To(Equal("0"))
+			Expect(envVars["API_SERVER_TIMEOUT"].Value).To(Equal("0"))
+			Expect(envVars["GRACEFUL_REBOOT_TIMEOUT"].Value).To(Equal("0"))
+			Expect(envVars["REMEDIATION_COOLDOWN"].Value).To(Equal("0"))
+			Expect(envVars["MAX_REMEDIATION_DURATION"].Value).To(Equal("0"))
+			Expect(envVars["NODE_READY_GRACE_PERIOD"].Value).To(Equal("0"))
+			Expect(envVars["MAX_CONCURRENT_REMEDIATIONS"].Value).To(Equal("0"))
+			Expect(envVars["MIN_PEERS_FOR_QUORUM"].Value).To(Equal("0"))
+			Expect(envVars["FENCE_ON_INDETERMINATE"].Value).To(Equal("false"))
+			Expect(envVars["PEER_SAMPLE_SIZE"].Value).To(Equal("0"))
+			Expect(envVars["MIN_CLUSTER_SIZE_FOR_FENCING"].Value).To(Equal("0"))
+			Expect(envVars["PEER_PORT"].Value).To(Equal("30001"))
+			// the peer update interval is read from the config by the running agents
+			Expect(envVars).ToNot(HaveKey("PEER_UPDATE_INTERVAL"))
+			Expect(envVars["PEER_MIN_TLS_VERSION"].Value).To(Equal("1.2"))
+			Expect(envVars["PEER_TLS_CIPHER_SUITES"].Value).To(BeEmpty())
+			Expect(envVars["PEER_NETWORK_INTERFACE"].Value).To(BeEmpty())
+			Expect(envVars["MAX_PEER_CLOCK_SKEW"].Value).To(Equal("0"))
+			Expect(envVars["PEER_CLIENT_AUTH"].Value).To(Equal("Required"))
+			Expect(container.Resources.Requests.Memory().String()).To(Equal("60Mi"))
+			Expect(container.Resources.Limits).To(BeEmpty())
 
 			Expect(len(ds.OwnerReferences)).To(Equal(1))
 			Expect(ds.OwnerReferences[0].Name).To(Equal(config.Name))
 			Expect(ds.OwnerReferences[0].Kind).To(Equal("PoisonPillConfig"))
 		})
+
+		It("Watchdog timeout should be accepted", func() {
+			Eventually(func() bool {
+				createdConfig := &poisonpillv1alpha1.PoisonPillConfig{}
+				Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(config), createdConfig)).To(Succeed())
+				return meta.IsStatusConditionTrue(createdConfig.Status.Conditions, poisonpillv1alpha1.WatchdogTimeoutValidConditionType)
+			}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
 	})
 
 	Context("PPC defaults", func() {
@@ -108,7 +171,7 @@ var _ = Describe("ppc controller Test", func() {
 				return k8sClient.Get(context.Background(), configKey, createdConfig)
 			}, 5*time.Second, 250*time.Millisecond).Should(BeNil())
 
-			Expect(createdConfig.Spec.WatchdogFilePath).To(Equal("/dev/watchdog1"))
+			Expect(createdConfig.Spec.WatchdogFilePath).To(BeEmpty())
 			Expect(createdConfig.Spec.SafeTimeToAssumeNodeRebootedSeconds).To(Equal(180))
 		})
 	})
@@ -19,27 +19,103 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 
 	machinev1beta1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/audit"
+	"github.com/medik8s/poison-pill/pkg/peerresults"
 	"github.com/medik8s/poison-pill/pkg/reboot"
+	"github.com/medik8s/poison-pill/pkg/rebootbudget"
 	"github.com/medik8s/poison-pill/pkg/utils"
 )
 
 const (
 	PPRFinalizer = "poison-pill.medik8s.io/ppr-finalizer"
+	// LastRemediationAnnotation is the node annotation holding the time the last remediation of the node completed
+	LastRemediationAnnotation = "poison-pill.medik8s.io/last-remediation"
+	// DefaultFencedNodeLabelKey and DefaultFencedNodeLabelValue make up the default label of nodes under remediation
+	DefaultFencedNodeLabelKey   = "poison-pill.medik8s.io/remediation"
+	DefaultFencedNodeLabelValue = "in-progress"
+
+	// event reasons
+	eventReasonRemediationStarted  = "RemediationStarted"
+	eventReasonNodeTainted         = "NodeTainted"
+	eventReasonRebootTriggered     = "RebootTriggered"
+	eventReasonNodeRestored        = "NodeRestored"
+	eventReasonRemediationFailed   = "RemediationFailed"
+	eventReasonDryRun              = "DryRun"
+	eventReasonPodsEvicted         = "PodsEvicted"
+	eventReasonRemediationAborted  = "RemediationAborted"
+	eventReasonRemediationDeferred = "RemediationDeferred"
+	eventReasonNodeNotReady        = "NodeNotReady"
+	eventReasonResourcesDeleted    = "ResourcesDeleted"
+	eventReasonPeerCheckSkipped    = "PeerVerificationSkipped"
+	eventReasonEtcdMemberRemoval   = "EtcdMemberRemovalRequired"
+	eventReasonPodsDeleted         = "PodsDeleted"
+	eventReasonPDBsDisrupted       = "PodDisruptionBudgetsDisrupted"
+
+	// podNodeNameField is the field index for looking up the pods of a node
+	podNodeNameField = "spec.nodeName"
+	// pprNodeNameField is the field index for looking up the remediations of a node, also the ones which aren't named
+	// after it
+	pprNodeNameField = "spec.nodeName"
+	// podEvictionRetryInterval is the interval for retrying evictions, e.g. when they are blocked by a PodDisruptionBudget
+	podEvictionRetryInterval = 5 * time.Second
+	mirrorPodAnnotation      = "kubernetes.io/config.mirror"
+	// rebootBudgetRetryInterval is the interval for checking if the reboot budget allows the reboot of a node
+	rebootBudgetRetryInterval = 5 * time.Second
+	// maxRebootBudgetWait is the max time a node waits for the reboot budget before it reboots anyway. The time to
+	// assume that the node has been rebooted is extended by it, so that it is never exceeded.
+	maxRebootBudgetWait = 5 * time.Minute
+	// agentDaemonSetName is the name of the DaemonSet running the poison pill agents
+	agentDaemonSetName = "poison-pill-ds"
+	// disabledNodeCheckInterval is the interval for checking if the remediation of a node is still disabled
+	disabledNodeCheckInterval = 30 * time.Second
+	// restoredNodeReadyTimeout is the max time we wait for a restored node to become ready, before we report it as
+	// remediation error
+	restoredNodeReadyTimeout = 10 * time.Minute
+	// maxNodeHeartbeatAge is the max age of the last heartbeat of a ready node, the kubelet reports its status at
+	// least every 5 minutes
+	maxNodeHeartbeatAge = 5 * time.Minute
+	// rebootBuffer is added to the watchdog timeout of a node, whose peer verification is skipped, for the agent of
+	// the node to notice its remediation and to trigger the reboot
+	rebootBuffer = 15 * time.Second
+	// recreatedNodeCheckInterval is the interval for checking if a deleted node was recreated, with external fencing
+	recreatedNodeCheckInterval = 15 * time.Second
+	// podDeletionCheckInterval is the interval for checking if the pods of a fenced node are deleted, when the node
+	// isn't deleted
+	podDeletionCheckInterval = 5 * time.Second
+	// defaultPodDeletionConcurrency is the default number of pods of a fenced node which are deleted at the same time
+	defaultPodDeletionConcurrency = 10
+	// defaultGracefulDeletionTimeout is the default time for evicting the pods of GracefulDeletionNamespaces
+	defaultGracefulDeletionTimeout = 5 * time.Minute
+
+	defaultErrorBackoffBase = 1 * time.Second
+	defaultErrorBackoffMax  = 2 * time.Minute
 )
 
 var (
@@ -48,6 +124,17 @@ var (
 		Effect: v1.TaintEffectNoSchedule,
 	}
 
+	// OutOfServiceTaint makes the pod garbage collector delete the pods of the node and detach its volumes
+	OutOfServiceTaint = &v1.Taint{
+		Key:    "node.kubernetes.io/out-of-service",
+		Value:  "nodeshutdown",
+		Effect: v1.TaintEffectNoExecute,
+	}
+
+	// criticalNamespacePrefixes are the prefixes of the namespaces of the cluster's own components, whose pods are
+	// deleted last with DeleteCriticalPodsLast
+	criticalNamespacePrefixes = []string{"kube-", "openshift-"}
+
 	lastSeenPprNamespace  string
 	wasLastSeenPprMachine bool
 )
@@ -67,28 +154,191 @@ func (r *PoisonPillRemediationReconciler) WasLastSeenPprMachine() bool {
 	return wasLastSeenPprMachine
 }
 
+// ListPprsOfNode returns the remediations of the given node in the given namespace from the cache
+func (r *PoisonPillRemediationReconciler) ListPprsOfNode(ctx context.Context, nodeName string, namespace string) ([]v1alpha1.PoisonPillRemediation, error) {
+	pprs := &v1alpha1.PoisonPillRemediationList{}
+	if err := r.List(ctx, pprs, client.InNamespace(namespace), client.MatchingFields{pprNodeNameField: nodeName}); err != nil {
+		return nil, err
+	}
+	return pprs.Items, nil
+}
+
 // PoisonPillRemediationReconciler reconciles a PoisonPillRemediation object
 type PoisonPillRemediationReconciler struct {
 	client.Client
-	Log logr.Logger
-	//logger is a logger that holds the CR name being reconciled
-	logger   logr.Logger
-	Scheme   *runtime.Scheme
-	Rebooter reboot.Rebooter
+	// APIReader reads from the api server without cache, e.g. the latest version of a node after a conflicting update.
+	// Optional, the Client is used by default.
+	APIReader client.Reader
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Rebooter  reboot.Rebooter
+	// Recorder is used for emitting events on the remediated node
+	Recorder record.EventRecorder
 	// note that this time must include the time for a unhealthy node without api-server access to reach the conclusion that it's unhealthy
 	// this should be at least worst-case time to reach a conclusion from the other peers * request context timeout + watchdog interval + maxFailuresThreshold * reconcileInterval + padding
 	SafeTimeToAssumeNodeRebooted time.Duration
-	MyNodeName                   string
-	mutex                        sync.Mutex
+	// CheckInterval, MaxErrorsThreshold and WatchdogTimeout are the settings of the api check and the watchdog of the
+	// agents. When CheckInterval is set, SafeTimeToAssumeNodeRebooted is validated against them on setup.
+	CheckInterval      time.Duration
+	MaxErrorsThreshold int
+	WatchdogTimeout    time.Duration
+	MyNodeName         string
+	// DryRun only records the remediation actions which would be taken, without rebooting or modifying the node
+	DryRun bool
+	// ExternalFencing is used when nodes aren't rebooted by the agents. Instead of restoring the deleted node, the
+	// reconciler waits for the node to be recreated by the cloud provider.
+	ExternalFencing bool
+	// GracefulRebootTimeout is the max time for evicting the pods of the unhealthy node before it reboots, honoring
+	// PodDisruptionBudgets. When it elapses the node reboots anyway. Zero disables eviction.
+	GracefulRebootTimeout time.Duration
+	// DeleteDaemonSetPods evicts DaemonSet pods before the reboot as well, except the poison pill agent. By default
+	// DaemonSet pods are skipped, because the DaemonSet controller would recreate them on the node anyway.
+	DeleteDaemonSetPods bool
+	// KubeClient is used for pod evictions, it's required when GracefulRebootTimeout is set
+	KubeClient kubernetes.Interface
+	// NodeDeletingTaint is the taint which marks nodes under remediation. It defaults to the unschedulable taint,
+	// which is added by the node controller. Any other taint is added by the reconciler itself.
+	NodeDeletingTaint v1.Taint
+	// FencedNodeLabelKey and FencedNodeLabelValue make up the label which marks nodes under remediation, so that
+	// fenced nodes can be found easily. The label is removed when the node is restored, even when it doesn't become
+	// ready in time, and when the remediation is aborted. They default to DefaultFencedNodeLabelKey and
+	// DefaultFencedNodeLabelValue.
+	FencedNodeLabelKey   string
+	FencedNodeLabelValue string
+	// RemediationCooldown is the time after a completed remediation of a node in which no new remediation of that
+	// node is started, in order to prevent reboot loops. Zero disables the cooldown.
+	RemediationCooldown time.Duration
+	// MaxRemediationDuration is the max time from the creation of a started remediation until it completes. When it's
+	// exceeded, the reconciler sets the Failed condition and stops reconciling the remediation, so that it can be
+	// escalated, e.g. by NHC. The node is left as it is, until the remediation is deleted. Zero never gives up.
+	MaxRemediationDuration time.Duration
+	// MaxConcurrentReconciles is the max number of remediations which are reconciled at the same time, defaults to 1.
+	// Each remediation targets another node, so they can safely be reconciled in parallel.
+	MaxConcurrentReconciles int
+	// RebootBudget limits the number of nodes which reboot themselves at the same time, if configured. It's only
+	// consulted when the unhealthy node reboots itself while it has api server access.
+	RebootBudget *rebootbudget.Budget
+	// PeerResults holds the peer responses which led to the last reboot of this node, if configured. They are
+	// attached to the remediation of this node after the reboot.
+	PeerResults *peerresults.Store
+	// NodeReadyGracePeriod is the time a restored node needs to be ready, before it's marked as schedulable again.
+	// The node is restored unschedulable and tainted, and made schedulable anyway when it doesn't become ready within
+	// the grace period plus 10 minutes. Zero restores the node as schedulable right away.
+	NodeReadyGracePeriod time.Duration
+	// OutOfServiceTaintSupported is set when the cluster supports the out-of-service taint. Otherwise remediations
+	// with the OutOfServiceTaint strategy fall back to the ResourceDeletion strategy.
+	OutOfServiceTaintSupported bool
+	// AnnotateMachines annotates the Machine of a fenced node with the FencedAnnotation, so that the machine health
+	// check flow is aware of the fencing. It must only be set when the machine API is installed.
+	AnnotateMachines bool
+	// PromptEtcdMemberRemoval prompts the cluster admin to remove the etcd member of a fenced control plane node,
+	// when the node doesn't become ready again after its reboot. The etcd member is never removed by the reconciler,
+	// since removing the member of a node which recovers later is catastrophic. It must only be set when the etcd
+	// operator API is installed.
+	PromptEtcdMemberRemoval bool
+	// ForceDeletePods deletes the pods of a fenced node with a zero grace period before the node is deleted, so that
+	// their controllers recreate them right away. It's only done after the fencing completed, since the pods of a
+	// node which is still running might keep writing to their volumes.
+	ForceDeletePods bool
+	// ErrorBackoffBase and ErrorBackoffMax define the exponential backoff for requeueing remediations whose reconcile
+	// failed, so that the agents don't hammer the api server during a broad outage. The delay starts at the base and
+	// doubles with every consecutive failure of the same remediation, up to the max. They default to 1 second and
+	// 2 minutes.
+	ErrorBackoffBase time.Duration
+	ErrorBackoffMax  time.Duration
+	// MyPodName and Version identify this agent in the annotations of the remediations it starts, or which it reboots
+	// its node for. OperatorVersion is the version of the operator which deployed the agent, if it's known.
+	MyPodName       string
+	Version         string
+	OperatorVersion string
+	// PodDeletionConcurrency is the max number of pods of a fenced node which are deleted at the same time, it
+	// defaults to 10. DeleteCriticalPodsLast deletes the pods of the cluster's own components, in the kube-* and
+	// openshift-* namespaces, after the pods of all other namespaces.
+	PodDeletionConcurrency int
+	DeleteCriticalPodsLast bool
+	// GracefulDeletionNamespaces are the namespaces whose pods aren't force deleted from fenced nodes, but evicted,
+	// honoring their termination grace periods and PodDisruptionBudgets, e.g. in order to keep monitoring and logging
+	// data. When GracefulDeletionTimeout elapsed since the node was assumed to be rebooted, their pods are force
+	// deleted as well. It defaults to 5 minutes.
+	GracefulDeletionNamespaces []string
+	GracefulDeletionTimeout    time.Duration
+	// AuditLog records the decision to reboot this node for a remediation on the host before the reboot, if configured
+	AuditLog *audit.Logger
+	// delayedRebootRequested is set when this node requested a delayed reboot for the eviction of its pods
+	delayedRebootRequested bool
+	// startTime is the time the reconciler was set up, it tells if this node rebooted since a remediation started
+	startTime time.Time
+	mutex     sync.Mutex
+}
+
+// MinSafeTimeToAssumeNodeRebooted returns the least time in which an unhealthy node without api server access
+// detects its issue and gets rebooted by the watchdog. A shorter SafeTimeToAssumeNodeRebooted risks restoring the
+// node before it actually rebooted.
+func MinSafeTimeToAssumeNodeRebooted(maxErrorsThreshold int, checkInterval time.Duration, watchdogTimeout time.Duration) time.Duration {
+	return time.Duration(maxErrorsThreshold)*checkInterval + watchdogTimeout
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PoisonPillRemediationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.NodeDeletingTaint.Key == "" {
+		r.NodeDeletingTaint = *NodeUnschedulableTaint
+	}
+	if r.NodeDeletingTaint.Effect != v1.TaintEffectNoSchedule && r.NodeDeletingTaint.Effect != v1.TaintEffectNoExecute {
+		return fmt.Errorf("invalid node deleting taint effect %q, only %s and %s are supported",
+			r.NodeDeletingTaint.Effect, v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute)
+	}
+	if r.FencedNodeLabelKey == "" {
+		r.FencedNodeLabelKey = DefaultFencedNodeLabelKey
+	}
+	if r.FencedNodeLabelValue == "" {
+		r.FencedNodeLabelValue = DefaultFencedNodeLabelValue
+	}
+	r.startTime = time.Now()
+	if r.CheckInterval > 0 {
+		minTime := MinSafeTimeToAssumeNodeRebooted(r.MaxErrorsThreshold, r.CheckInterval, r.WatchdogTimeout)
+		if r.SafeTimeToAssumeNodeRebooted < minTime {
+			return fmt.Errorf("safe time to assume node rebooted %s is too low, it needs to be at least %s, otherwise unhealthy nodes might be restored before they rebooted",
+				r.SafeTimeToAssumeNodeRebooted, minTime)
+		}
+	}
+
+	// the pods of a node are looked up for evictions and for remediations which don't delete the node. The pods are
+	// only cached when they are looked up the first time.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1.Pod{}, podNodeNameField, func(o client.Object) []string {
+		return []string{o.(*v1.Pod).Spec.NodeName}
+	}); err != nil {
+		return err
+	}
+	// the peer health server looks up the remediations of nodes which ask for their health
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.PoisonPillRemediation{}, pprNodeNameField, func(o client.Object) []string {
+		return []string{o.(*v1alpha1.PoisonPillRemediation).GetNodeName()}
+	}); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.PoisonPillRemediation{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             newErrorBackoff(r.ErrorBackoffBase, r.ErrorBackoffMax),
+		}).
 		Complete(r)
 }
 
+// newErrorBackoff returns the rate limiter for requeueing failed reconciles. Unlike the default rate limiter of
+// controller-runtime, it has no overall rate limit, since the remediations are independent of each other.
+func newErrorBackoff(base time.Duration, max time.Duration) workqueue.RateLimiter {
+	if base <= 0 {
+		base = defaultErrorBackoffBase
+	}
+	if max <= 0 {
+		max = defaultErrorBackoffMax
+	}
+	if max < base {
+		max = base
+	}
+	return workqueue.NewItemExponentialFailureRateLimiter(base, max)
+}
+
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillremediationtemplates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillremediationtemplates/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillremediationtemplates/finalizers,verbs=update
@@ -96,19 +346,24 @@ func (r *PoisonPillRemediationReconciler) SetupWithManager(mgr ctrl.Manager) err
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillremediations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=poison-pill.medik8s.io,resources=poisonpillremediations/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+//+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattachments,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
 
 func (r *PoisonPillRemediationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	r.logger = r.Log.WithValues("poisonpillremediation", req.NamespacedName)
+	logger := r.Log.WithValues("remediation", req.NamespacedName)
 
 	ppr := &v1alpha1.PoisonPillRemediation{}
 	if err := r.Get(ctx, req.NamespacedName, ppr); err != nil {
 		if apiErrors.IsNotFound(err) {
 			// PPR is deleted, stop reconciling
-			r.logger.Info("PPR already deleted")
+			logger.Info("PPR already deleted")
 			return ctrl.Result{}, nil
 		}
-		r.logger.Error(err, "failed to get PPR")
+		logger.Error(err, "failed to get PPR")
 		return ctrl.Result{}, err
 	}
 
@@ -116,106 +371,227 @@ func (r *PoisonPillRemediationReconciler) Reconcile(ctx context.Context, req ctr
 	lastSeenPprNamespace = req.Namespace
 	r.mutex.Unlock()
 
-	node, err := r.getNodeFromPpr(ppr)
+	node, err := r.getNodeFromPpr(ctx, logger, ppr)
 	if err != nil {
 		if apiErrors.IsNotFound(err) {
 			//as part of the remediation flow, we delete the node, and then we need to restore it
-			return r.handleDeletedNode(ppr)
+			return r.handleDeletedNode(ctx, logger, ppr)
 		}
-		r.logger.Error(err, "failed to get node", "node name", ppr.Name)
+		logger.Error(err, "failed to get node", "node", ppr.GetNodeName())
 		return ctrl.Result{}, err
 	}
+	logger = logger.WithValues("node", node.Name)
+
+	if r.PeerResults != nil && r.MyNodeName == node.Name && len(ppr.Status.PeerResults) == 0 {
+		if peerResults := r.loadPeerResults(logger, ppr); len(peerResults) > 0 {
+			return r.updatePeerResults(ctx, logger, ppr, peerResults)
+		}
+	}
 
 	if node.CreationTimestamp.After(ppr.CreationTimestamp.Time) {
 		//this node was created after the node was reported as unhealthy
 		//we assume this is the new node after remediation and take no-op expecting the ppr to be deleted
-		if ppr.Status.NodeBackup != nil {
+		succeeded := meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.SucceededConditionType)
+		if ppr.Status.NodeBackup != nil || !succeeded {
 			//TODO: this is an ugly hack. without it the api-server complains about
 			//missing apiVersion and Kind for the nodeBackup.
 			ppr.Status.NodeBackup = nil
-			if err := r.Client.Status().Update(context.Background(), ppr); err != nil {
+			r.setCondition(ppr, v1alpha1.ProcessingConditionType, metav1.ConditionFalse, v1alpha1.NodeRestoredReason, "")
+			r.setCondition(ppr, v1alpha1.SucceededConditionType, metav1.ConditionTrue, v1alpha1.NodeRestoredReason, "node has been restored")
+			if err := r.Client.Status().Update(ctx, ppr); err != nil {
 				if apiErrors.IsConflict(err) {
 					// conflicts are expected since all poison pill deamonset pods are competing on the same requests
 					return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 				}
-				r.logger.Error(err, "failed to remove node backup from ppr")
+				logger.Error(err, "failed to remove node backup from ppr")
 				return ctrl.Result{}, err
 			}
+			// only the agent which won the status update records the metrics, and only once per remediation
+			if !succeeded {
+				remediationDuration.Observe(time.Since(ppr.CreationTimestamp.Time).Seconds())
+				remediations.WithLabelValues(outcomeSucceeded).Inc()
+			}
 			return ctrl.Result{Requeue: true}, nil
 		}
 
 		if controllerutil.ContainsFinalizer(ppr, PPRFinalizer) {
+			if !isLastRemediationRecorded(node, ppr) {
+				return r.recordLastRemediation(ctx, logger, node)
+			}
+
+			if r.NodeReadyGracePeriod > 0 && meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.NodeReadyVerifiedConditionType) == nil {
+				return r.verifyRestoredNodeReady(ctx, logger, node, ppr)
+			}
+
 			readyCond := r.getReadyCond(node)
 			if readyCond == nil || readyCond.Status != v1.ConditionTrue {
 				//don't remove finalizer until the node is back online
 				//this helps to prevent remediation loops
 				//note that it means we block ppr deletion forever for node that were not remediated
-				//todo consider add some timeout, should be quite long to allow bm reboot (at least 10 minutes)
-				r.logger.Info("waiting for node to become ready before removing ppr finalizer")
+				if time.Since(node.CreationTimestamp.Time) > restoredNodeReadyTimeout {
+					// the timeout is long enough to allow bm reboots, report the node, but keep waiting for it
+					if r.promptEtcdMemberRemoval(logger, node, ppr) {
+						if err := r.Client.Status().Update(ctx, ppr); err != nil {
+							if apiErrors.IsConflict(err) {
+								return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+							}
+							logger.Error(err, "failed to update etcd member removal condition")
+							return ctrl.Result{}, err
+						}
+					}
+					r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorNodeRestoreTimeout,
+						fmt.Sprintf("restored node didn't become ready within %s", restoredNodeReadyTimeout))
+				}
+				logger.Info("waiting for node to become ready before removing ppr finalizer")
 				return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
 			}
+			r.removeMachineAnnotation(ctx, logger, node)
 
 			controllerutil.RemoveFinalizer(ppr, PPRFinalizer)
-			if err := r.Client.Update(context.Background(), ppr); err != nil {
+			if err := r.Client.Update(ctx, ppr); err != nil {
 				if apiErrors.IsConflict(err) {
 					return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 				}
-				r.logger.Error(err, "failed to remove finalizer from ppr")
+				logger.Error(err, "failed to remove finalizer from ppr")
 				return ctrl.Result{}, err
 			}
 		}
 
-		r.logger.Info("node has been restored", "node name", node.Name)
+		logger.Info("node has been restored")
 
 		//todo this means we only allow one remediation attempt per ppr. we could add some config to
 		//ppr which states max remediation attempts, and the timeout to consider a remediation failed.
 		return ctrl.Result{}, nil
 	}
 
+	if meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.SucceededConditionType) && !controllerutil.ContainsFinalizer(ppr, PPRFinalizer) {
+		// the remediation completed without deleting the node
+		return ctrl.Result{}, nil
+	}
+
+	if !ppr.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(ppr, PPRFinalizer) {
+		//ppr was deleted before the node was deleted and restored, e.g. because the node is healthy again
+		return r.abortRemediation(ctx, logger, node, ppr, "ppr was deleted during remediation")
+	}
+
+	if controllerutil.ContainsFinalizer(ppr, PPRFinalizer) && utils.IsRemediationDisabled(node) {
+		//remediation was disabled after it started, it's deferred like a remediation of a disabled node once aborted
+		return r.abortRemediation(ctx, logger, node, ppr, "remediation was disabled by the node annotation")
+	}
+
+	if controllerutil.ContainsFinalizer(ppr, PPRFinalizer) {
+		if meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.FailedConditionType) {
+			// we gave up, the remediation needs to be deleted or escalated
+			return ctrl.Result{}, nil
+		}
+		if elapsed, timedOut := r.remediationTimedOut(ppr); timedOut {
+			return r.failRemediation(ctx, logger, ppr, elapsed)
+		}
+	}
+
+	if r.DryRun {
+		return r.dryRunRemediation(ctx, logger, node, ppr)
+	}
+
 	if !controllerutil.ContainsFinalizer(ppr, PPRFinalizer) {
 		if !ppr.DeletionTimestamp.IsZero() {
 			//ppr is going to be deleted before we started any remediation action, so taking no-op
-			//otherwise we continue the remediation even if the deletionTimestamp is not zero
-			r.logger.Info("ppr is about to be deleted, which means the resource is healthy again. taking no-op")
+			logger.Info("ppr is about to be deleted, which means the resource is healthy again. taking no-op")
 			return ctrl.Result{}, nil
 		}
 
+		if utils.IsRemediationDisabled(node) {
+			message := fmt.Sprintf("remediation of the node is disabled by the %s annotation", utils.DisabledAnnotation)
+			return r.deferRemediation(ctx, logger, node, ppr, v1alpha1.RemediationDisabledReason, message, disabledNodeCheckInterval)
+		}
+		if lastRemediation := getLastRemediation(node); lastRemediation != nil && r.RemediationCooldown > 0 {
+			if cooldownEnd := lastRemediation.Add(r.RemediationCooldown); cooldownEnd.After(time.Now()) {
+				message := fmt.Sprintf("node was remediated at %s, no new remediation is started before %s", lastRemediation.UTC().Format(time.RFC3339), cooldownEnd.UTC().Format(time.RFC3339))
+				ppr.Status.LastRemediationTime = lastRemediation
+				return r.deferRemediation(ctx, logger, node, ppr, v1alpha1.RemediationCooldownReason, message, time.Until(cooldownEnd)+time.Second)
+			}
+		}
+		if deferred := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.DeferredConditionType); deferred != nil && deferred.Status == metav1.ConditionTrue {
+			reason := v1alpha1.CooldownElapsedReason
+			if deferred.Reason == v1alpha1.RemediationDisabledReason {
+				reason = v1alpha1.RemediationEnabledReason
+			}
+			r.setCondition(ppr, v1alpha1.DeferredConditionType, metav1.ConditionFalse, reason, "")
+			if err := r.Client.Status().Update(ctx, ppr); err != nil {
+				if apiErrors.IsConflict(err) {
+					return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+				}
+				logger.Error(err, "failed to update deferred condition")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil
+		}
+
 		controllerutil.AddFinalizer(ppr, PPRFinalizer)
-		if err := r.Client.Update(context.Background(), ppr); err != nil {
+		r.setAgentAnnotations(ppr)
+		if err := r.Client.Update(ctx, ppr); err != nil {
 			if apiErrors.IsConflict(err) {
 				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 			}
-			r.logger.Error(err, "failed to add finalizer to ppr")
+			logger.Error(err, "failed to add finalizer to ppr")
 			return ctrl.Result{}, err
 		}
+		r.recordEvent(node, v1.EventTypeNormal, eventReasonRemediationStarted, "Remediation started by poison pill")
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	if ppr.Status.NodeWasUnschedulable == nil && ppr.Status.NodeBackup == nil {
+		return r.recordNodeWasUnschedulable(ctx, logger, node, ppr)
+	}
+
 	if !node.Spec.Unschedulable {
 		//the unhealthy node might reboot itself and take new workloads
 		//since we're going to delete the node eventually, we must make sure the node is deleted
 		//when there's no running workload there. Hence we mark it as unschedulable.
-		return r.markNodeAsUnschedulable(node)
+		return r.markNodeAsUnschedulable(ctx, logger, node)
 	}
 
-	if !utils.TaintExists(node.Spec.Taints, NodeUnschedulableTaint) {
-		r.logger.Info("waiting for unschedulable taint to appear", "node name", node.Name)
-		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	if !utils.TaintExists(node.Spec.Taints, &r.NodeDeletingTaint) {
+		if r.NodeDeletingTaint.MatchTaint(NodeUnschedulableTaint) {
+			logger.Info("waiting for unschedulable taint to appear")
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		return r.addNodeDeletingTaint(ctx, logger, node)
+	}
+
+	if node.Labels[r.FencedNodeLabelKey] != r.FencedNodeLabelValue {
+		return r.addFencedNodeLabel(ctx, logger, node)
 	}
 
 	if ppr.Status.NodeBackup == nil || ppr.Status.TimeAssumedRebooted.IsZero() {
-		return r.updatePprStatus(node, ppr)
+		return r.updatePprStatus(ctx, logger, node, ppr)
 	}
 
 	maxNodeRebootTime := ppr.Status.TimeAssumedRebooted
 
 	if maxNodeRebootTime.After(time.Now()) {
 		if r.MyNodeName == node.Name {
+			if r.GracefulRebootTimeout > 0 {
+				r.requestDelayedReboot(logger, ppr)
+				if done, requeueAfter := r.evictPods(ctx, logger, node, ppr); !done {
+					return ctrl.Result{RequeueAfter: requeueAfter}, nil
+				}
+			}
+			if r.RebootBudget != nil {
+				if acquired, requeueAfter := r.acquireRebootBudget(ctx, logger, node, ppr); !acquired {
+					return ctrl.Result{RequeueAfter: requeueAfter}, nil
+				}
+			}
 			// we have a problem on this node
+			r.annotateFencingAgent(ctx, logger, ppr)
+			r.recordFencingDecision(logger, node, ppr)
 			if err := r.Rebooter.Reboot(); err != nil {
+				r.recordEvent(node, v1.EventTypeWarning, eventReasonRemediationFailed, "Failed to trigger reboot: "+err.Error())
+				r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorWatchdogUnavailable, "failed to trigger reboot: "+err.Error())
 				// re-queue
 				return ctrl.Result{}, err
 			} else {
+				r.recordEvent(node, v1.EventTypeNormal, eventReasonRebootTriggered, "Node reboot has been triggered")
 				// we are done for now, node will reboot
 				return ctrl.Result{}, nil
 			}
@@ -223,16 +599,58 @@ func (r *PoisonPillRemediationReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{RequeueAfter: maxNodeRebootTime.Sub(time.Now()) + time.Second}, nil
 	}
 
-	r.logger.Info("TimeAssumedRebooted is old. The unhealthy node assumed to been rebooted", "node name", node.Name)
+	logger.Info("TimeAssumedRebooted is old. The unhealthy node assumed to been rebooted")
+
+	if r.MyNodeName == node.Name && !r.startTime.IsZero() && r.startTime.Before(ppr.CreationTimestamp.Time) {
+		// this agent is running since before the remediation started, so the node didn't reboot. It only doesn't
+		// reboot itself without api server access when its peers didn't confirm that it's unhealthy.
+		r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorPeerQuorumNotReached,
+			"node didn't reboot by the time it was assumed to be rebooted")
+	}
+
+	if !meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.FencingCompletedConditionType) {
+		r.setCondition(ppr, v1alpha1.FencingCompletedConditionType, metav1.ConditionTrue, v1alpha1.NodeRebootedReason, "node is assumed to be rebooted")
+		if err := r.Client.Status().Update(ctx, ppr); err != nil {
+			if apiErrors.IsConflict(err) {
+				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+			}
+			logger.Error(err, "failed to update fencing completed condition")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if r.AnnotateMachines {
+		fencingCompleted := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.FencingCompletedConditionType)
+		if err := r.annotateMachine(ctx, logger, node, fencingCompleted.LastTransitionTime); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if strategy := r.remediationStrategy(ppr); strategy != v1alpha1.NodeDeletionRemediationStrategy {
+		return r.remediateWithoutNodeDeletion(ctx, logger, node, ppr, strategy)
+	}
+
+	if r.ForceDeletePods {
+		podsDeleted, err := r.forceDeletePods(ctx, logger, node, ppr)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !podsDeleted {
+			return ctrl.Result{RequeueAfter: podEvictionRetryInterval}, nil
+		}
+	}
 
 	if !node.DeletionTimestamp.IsZero() {
 		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 	}
 
-	r.logger.Info("deleting unhealthy node", "node name", node.Name)
-	if err := r.Client.Delete(context.TODO(), node); err != nil {
+	logger.Info("deleting unhealthy node")
+	if err := r.Client.Delete(ctx, node); err != nil {
 		if !apiErrors.IsNotFound(err) {
-			r.logger.Error(err, "failed to delete the unhealthy node")
+			logger.Error(err, "failed to delete the unhealthy node")
+			r.recordEvent(node, v1.EventTypeWarning, eventReasonRemediationFailed, "Failed to delete node: "+err.Error())
+			r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorAPIUnreachable, "failed to delete node: "+err.Error())
 			return ctrl.Result{}, err
 		}
 	}
@@ -258,29 +676,539 @@ func (r *PoisonPillRemediationReconciler) getReadyCond(node *v1.Node) *v1.NodeCo
 	return nil
 }
 
-func (r *PoisonPillRemediationReconciler) updatePprStatus(node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
-	r.logger.Info("updating ppr with node backup and updating time to assume node has been rebooted", "node name", node.Name)
+// nodeReadyFor returns if the node has been ready for at least the given duration, with a recent heartbeat. If it
+// isn't, the time after which it should be checked again is returned.
+func (r *PoisonPillRemediationReconciler) nodeReadyFor(node *v1.Node, duration time.Duration) (bool, time.Duration) {
+	readyCond := r.getReadyCond(node)
+	if readyCond == nil || readyCond.Status != v1.ConditionTrue || time.Since(readyCond.LastHeartbeatTime.Time) > maxNodeHeartbeatAge {
+		return false, 15 * time.Second
+	}
+	if readyFor := time.Since(readyCond.LastTransitionTime.Time); readyFor < duration {
+		return false, duration - readyFor + time.Second
+	}
+	return true, 0
+}
+
+// verifyRestoredNodeReady waits until the restored node has been ready for the grace period, and marks it as
+// schedulable afterwards. When the node doesn't become ready in time, it's marked as schedulable anyway, and the
+// timeout is recorded with a warning.
+func (r *PoisonPillRemediationReconciler) verifyRestoredNodeReady(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
+	ready, requeueAfter := r.nodeReadyFor(node, r.NodeReadyGracePeriod)
+	timedOut := time.Since(node.CreationTimestamp.Time) > r.NodeReadyGracePeriod+restoredNodeReadyTimeout
+	if !ready && !timedOut {
+		logger.Info("waiting for restored node to be ready for the grace period before marking it as schedulable", "grace period", r.NodeReadyGracePeriod)
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	keepUnschedulable := nodeWasUnschedulable(ppr)
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		node.Spec.Unschedulable = keepUnschedulable
+		node.Spec.Taints, _ = r.deleteRemediationTaints(node.Spec.Taints)
+		r.deleteFencedNodeLabel(node)
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to mark restored node as schedulable")
+		return ctrl.Result{}, err
+	}
+
+	errorRecorded := false
+	if ready {
+		r.setCondition(ppr, v1alpha1.NodeReadyVerifiedConditionType, metav1.ConditionTrue, v1alpha1.NodeReadyReason, "node was ready for the grace period")
+	} else {
+		message := fmt.Sprintf("node didn't become ready within %s, marked it as schedulable anyway", r.NodeReadyGracePeriod+restoredNodeReadyTimeout)
+		logger.Info(message)
+		r.setCondition(ppr, v1alpha1.NodeReadyVerifiedConditionType, metav1.ConditionFalse, v1alpha1.NodeReadyTimeoutReason, message)
+		r.recordEvent(node, v1.EventTypeWarning, eventReasonNodeNotReady, "Restored node "+message)
+		r.promptEtcdMemberRemoval(logger, node, ppr)
+		errorRecorded = setLastError(ppr, v1alpha1.RemediationErrorNodeRestoreTimeout, message)
+	}
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update node ready verified condition")
+		return ctrl.Result{}, err
+	}
+	if errorRecorded {
+		remediationErrors.WithLabelValues(string(v1alpha1.RemediationErrorNodeRestoreTimeout)).Inc()
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+func (r *PoisonPillRemediationReconciler) updatePprStatus(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
+	logger.Info("updating ppr with node backup and updating time to assume node has been rebooted")
 	//we assume the unhealthy node will be rebooted by maxTimeNodeHasRebooted
-	maxTimeNodeHasRebooted := metav1.NewTime(metav1.Now().Add(r.SafeTimeToAssumeNodeRebooted))
+	maxTimeNodeHasRebooted := metav1.NewTime(metav1.Now().Add(r.timeToAssumeNodeRebooted(ppr)))
+	if ppr.Spec.SkipPeerVerification {
+		if r.WatchdogTimeout > 0 {
+			// this bypasses the most important safety check, so make it visible. The node still reboots itself when
+			// its agent sees the remediation, only the time for detecting the api server failure and asking the peers
+			// is skipped.
+			logger.Info("WARNING: skipping peer verification, the node is assumed to be down once its watchdog timeout elapsed")
+			r.recordEvent(node, v1.EventTypeWarning, eventReasonPeerCheckSkipped, "Peer verification skipped, the node is fenced without waiting for its peers")
+		} else {
+			logger.Info("WARNING: the watchdog timeout is unknown, peer verification can't be skipped")
+			r.recordEvent(node, v1.EventTypeWarning, eventReasonPeerCheckSkipped, "Peer verification can't be skipped, the watchdog timeout is unknown")
+		}
+	}
 	ppr.Status.TimeAssumedRebooted = &maxTimeNodeHasRebooted
 	ppr.Status.NodeBackup = node
 	ppr.Status.NodeBackup.Kind = node.GetObjectKind().GroupVersionKind().Kind
 	ppr.Status.NodeBackup.APIVersion = node.APIVersion
+	r.setCondition(ppr, v1alpha1.ProcessingConditionType, metav1.ConditionTrue, v1alpha1.RemediationStartedReason, "waiting for the node to be rebooted")
+	r.setCondition(ppr, v1alpha1.FencingCompletedConditionType, metav1.ConditionFalse, v1alpha1.RemediationStartedReason, "")
+	r.setCondition(ppr, v1alpha1.SucceededConditionType, metav1.ConditionFalse, v1alpha1.RemediationStartedReason, "")
 
-	err := r.Client.Status().Update(context.Background(), ppr)
+	err := r.Client.Status().Update(ctx, ppr)
 	if err != nil {
 		if apiErrors.IsConflict(err) {
 			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 		}
-		r.logger.Error(err, "failed to update status with 'node back up' and 'time to assume node has rebooted'")
+		logger.Error(err, "failed to update status with 'node back up' and 'time to assume node has rebooted'")
 		return ctrl.Result{}, err
 	}
 
 	return ctrl.Result{Requeue: true}, nil
 }
 
+// abortRemediation reverts the changes made to the node, and removes the finalizer from the ppr afterwards
+func (r *PoisonPillRemediationReconciler) abortRemediation(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation, reason string) (ctrl.Result, error) {
+	keepUnschedulable := nodeWasUnschedulable(ppr)
+	_, taintRemoved := r.deleteRemediationTaints(node.Spec.Taints)
+	_, labeled := node.Labels[r.FencedNodeLabelKey]
+	if (node.Spec.Unschedulable && !keepUnschedulable) || taintRemoved || labeled {
+		logger.Info(reason+", reverting node changes", "keep unschedulable", keepUnschedulable)
+		if err := r.updateNode(ctx, node, func(node *v1.Node) {
+			node.Spec.Unschedulable = keepUnschedulable
+			node.Spec.Taints, _ = r.deleteRemediationTaints(node.Spec.Taints)
+			r.deleteFencedNodeLabel(node)
+		}); err != nil {
+			if apiErrors.IsConflict(err) {
+				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+			}
+			logger.Error(err, "failed to mark node as schedulable")
+			return ctrl.Result{}, err
+		}
+		message := "Remediation was aborted, node marked as schedulable"
+		if keepUnschedulable {
+			message = "Remediation was aborted, node stays unschedulable because it was unschedulable before"
+		}
+		r.recordEvent(node, v1.EventTypeNormal, eventReasonRemediationAborted, message)
+	}
+
+	controllerutil.RemoveFinalizer(ppr, PPRFinalizer)
+	if err := r.Client.Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to remove finalizer from ppr")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// dryRunRemediation records the remediation actions which would be taken for the given node, without executing them
+func (r *PoisonPillRemediationReconciler) dryRunRemediation(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
+	if meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.DryRunConditionType) {
+		// already done
+		return ctrl.Result{}, nil
+	}
+	if !ppr.DeletionTimestamp.IsZero() {
+		logger.Info("ppr is about to be deleted, which means the resource is healthy again. taking no-op")
+		return ctrl.Result{}, nil
+	}
+
+	r.setCondition(ppr, v1alpha1.DryRunConditionType, metav1.ConditionTrue, v1alpha1.DryRunCompletedReason, "dry run, the node was neither rebooted nor modified")
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update dry run condition")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("dry run: skipping marking node as unschedulable")
+	r.recordEvent(node, v1.EventTypeNormal, eventReasonDryRun, "Dry run: node would be marked as unschedulable")
+	logger.Info("dry run: skipping node reboot")
+	r.recordEvent(node, v1.EventTypeNormal, eventReasonDryRun, fmt.Sprintf("Dry run: node would be rebooted, and deleted and restored after %s", r.SafeTimeToAssumeNodeRebooted))
+	return ctrl.Result{}, nil
+}
+
+// deferRemediation postpones the remediation of the given node, e.g. until the cooldown after its last remediation
+// ended, and checks again after the given interval
+func (r *PoisonPillRemediationReconciler) deferRemediation(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation, reason string, message string, requeueAfter time.Duration) (ctrl.Result, error) {
+	if deferred := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.DeferredConditionType); deferred != nil && deferred.Status == metav1.ConditionTrue && deferred.Reason == reason {
+		// already reported
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	logger.Info("deferring remediation", "reason", reason, "message", message)
+	r.setCondition(ppr, v1alpha1.DeferredConditionType, metav1.ConditionTrue, reason, message)
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update deferred condition")
+		return ctrl.Result{}, err
+	}
+	r.recordEvent(node, v1.EventTypeWarning, eventReasonRemediationDeferred, "Remediation deferred: "+message)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// getLastRemediation returns the time of the last completed remediation of the given node, or nil if unknown
+func getLastRemediation(node *v1.Node) *metav1.Time {
+	value, exists := node.Annotations[LastRemediationAnnotation]
+	if !exists {
+		return nil
+	}
+	lastRemediation, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// ignore invalid values, they must not block remediations
+		return nil
+	}
+	return &metav1.Time{Time: lastRemediation}
+}
+
+// isLastRemediationRecorded returns if the node was annotated with the completion of the remediation of the given ppr.
+// The annotation of the restored node might be a copy of the annotation of a previous remediation.
+func isLastRemediationRecorded(node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) bool {
+	lastRemediation := getLastRemediation(node)
+	return lastRemediation != nil && !lastRemediation.Before(&ppr.CreationTimestamp)
+}
+
+// recordLastRemediation annotates the given node with the current time as completion time of its last remediation
+func (r *PoisonPillRemediationReconciler) recordLastRemediation(ctx context.Context, logger logr.Logger, node *v1.Node) (ctrl.Result, error) {
+	lastRemediation := time.Now().UTC().Format(time.RFC3339)
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[LastRemediationAnnotation] = lastRemediation
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to record last remediation time on node")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// loadPeerResults returns the stored peer results if they belong to the given ppr, and removes them otherwise
+func (r *PoisonPillRemediationReconciler) loadPeerResults(logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation) []v1alpha1.PeerResult {
+	peerResults, err := r.PeerResults.Load()
+	if err != nil {
+		logger.Error(err, "failed to load peer results")
+		return nil
+	}
+	if len(peerResults) == 0 {
+		return nil
+	}
+	// the node might have decided to reboot before the ppr was created, but not much earlier
+	oldestRelevant := ppr.CreationTimestamp.Add(-r.SafeTimeToAssumeNodeRebooted)
+	if peerResults[len(peerResults)-1].Time.Time.Before(oldestRelevant) {
+		logger.Info("removing peer results of an earlier reboot")
+		if err := r.PeerResults.Remove(); err != nil {
+			logger.Error(err, "failed to remove peer results")
+		}
+		return nil
+	}
+	return peerResults
+}
+
+// updatePeerResults attaches the given peer results to the ppr's status, and removes them from the store afterwards
+func (r *PoisonPillRemediationReconciler) updatePeerResults(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation, peerResults []v1alpha1.PeerResult) (ctrl.Result, error) {
+	logger.Info("attaching peer results of the reboot decision to ppr", "peers", len(peerResults))
+	ppr.Status.PeerResults = peerResults
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update peer results")
+		return ctrl.Result{}, err
+	}
+	if err := r.PeerResults.Remove(); err != nil {
+		logger.Error(err, "failed to remove peer results")
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// evictPods evicts the pods of the given node honoring PodDisruptionBudgets, until all pods are gone or the
+// GracefulRebootTimeout elapsed. It returns if the node can be rebooted, and otherwise after which time to check again.
+func (r *PoisonPillRemediationReconciler) evictPods(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (bool, time.Duration) {
+	// the eviction starts when the remediation starts processing
+	processing := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.ProcessingConditionType)
+	if processing == nil {
+		return true, 0
+	}
+	remaining := time.Until(processing.LastTransitionTime.Add(r.GracefulRebootTimeout))
+	if remaining <= 0 {
+		logger.Info("graceful reboot timeout elapsed, rebooting without waiting for remaining pods")
+		return true, 0
+	}
+	requeueAfter := podEvictionRetryInterval
+	if remaining < requeueAfter {
+		requeueAfter = remaining
+	}
+
+	// never wait longer than the timeout, so that hanging api calls can't prevent the reboot
+	ctx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	pods := &v1.PodList{}
+	if err := r.List(ctx, pods, client.MatchingFields{podNodeNameField: node.Name}); err != nil {
+		logger.Error(err, "failed to list pods for eviction")
+		return false, requeueAfter
+	}
+
+	pendingPods := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !r.needsEviction(pod) {
+			continue
+		}
+		pendingPods++
+		if !pod.DeletionTimestamp.IsZero() {
+			// already evicted, waiting for termination
+			continue
+		}
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+		}
+		if err := r.KubeClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction); err != nil && !apiErrors.IsNotFound(err) {
+			// TooManyRequests means that a PodDisruptionBudget blocks the eviction, retry until the timeout elapsed
+			logger.Info("failed to evict pod, will retry", "pod", pod.Name, "namespace", pod.Namespace, "reason", err.Error())
+		}
+	}
+
+	if pendingPods > 0 {
+		logger.Info("waiting for pods to be evicted before reboot", "pods", pendingPods)
+		return false, requeueAfter
+	}
+	logger.Info("all pods evicted")
+	r.recordEvent(node, v1.EventTypeNormal, eventReasonPodsEvicted, "All pods have been evicted before reboot")
+	return true, 0
+}
+
+// requestDelayedReboot lets the node reboot when the GracefulRebootTimeout elapsed, as soon as the eviction of its pods
+// starts. That way the node reboots in time even when this agent stops during the eviction, e.g. because its pod is
+// deleted and disarms the watchdog on shutdown. It's skipped when the reboot needs to wait for the reboot budget.
+func (r *PoisonPillRemediationReconciler) requestDelayedReboot(logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation) {
+	if r.RebootBudget != nil {
+		return
+	}
+	delayedRebooter, isDelayedRebooter := r.Rebooter.(reboot.DelayedRebooter)
+	if !isDelayedRebooter {
+		return
+	}
+	processing := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.ProcessingConditionType)
+	if processing == nil {
+		return
+	}
+	remaining := time.Until(processing.LastTransitionTime.Add(r.GracefulRebootTimeout))
+	if remaining <= 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.delayedRebootRequested {
+		return
+	}
+	r.delayedRebootRequested = true
+	logger.Info("requesting reboot after pod eviction", "delay", remaining)
+	if err := delayedRebooter.RebootAfter(remaining); err != nil {
+		logger.Error(err, "failed to request delayed reboot")
+	}
+}
+
+// acquireRebootBudget reserves the reboot of the given node in the reboot budget, until rebootBudgetTimeout elapsed.
+// It returns if the node can be rebooted, and otherwise after which time to check again.
+func (r *PoisonPillRemediationReconciler) acquireRebootBudget(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (bool, time.Duration) {
+	// like the eviction, waiting for the budget starts when the remediation starts processing
+	processing := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.ProcessingConditionType)
+	if processing == nil {
+		return true, 0
+	}
+	remaining := time.Until(processing.LastTransitionTime.Add(r.GracefulRebootTimeout + r.rebootBudgetTimeout()))
+	if remaining <= 0 {
+		logger.Info("reboot budget timeout elapsed, rebooting without reservation")
+		return true, 0
+	}
+	requeueAfter := rebootBudgetRetryInterval
+	if remaining < requeueAfter {
+		requeueAfter = remaining
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+	acquired, err := r.RebootBudget.Acquire(ctx, node.Name, ppr.Status.TimeAssumedRebooted.Time)
+	if err != nil {
+		if apiErrors.IsConflict(err) || apiErrors.IsAlreadyExists(err) {
+			// another node updated the budget at the same time
+			return false, 1 * time.Second
+		}
+		// the budget only protects from correlated failures while the api server is available
+		logger.Error(err, "failed to reserve reboot, rebooting without reservation")
+		return true, 0
+	}
+	if !acquired {
+		logger.Info("waiting for reboot budget before reboot")
+		return false, requeueAfter
+	}
+	return true, 0
+}
+
+// timeToAssumeNodeRebooted returns the time from now, after which the node of the given remediation is assumed to be
+// rebooted. The node might evict its pods and wait for the reboot budget before rebooting, so that's included.
+// Skipping the peer verification only shortens the time when the watchdog timeout is known, otherwise the node might
+// still be running when its workloads are rescheduled.
+func (r *PoisonPillRemediationReconciler) timeToAssumeNodeRebooted(ppr *v1alpha1.PoisonPillRemediation) time.Duration {
+	rebootDelay := r.GracefulRebootTimeout + r.rebootBudgetTimeout()
+	if ppr.Spec.SkipPeerVerification && r.WatchdogTimeout > 0 {
+		return rebootDelay + r.WatchdogTimeout + rebootBuffer
+	}
+	return rebootDelay + r.SafeTimeToAssumeNodeRebooted
+}
+
+// rebootBudgetTimeout returns the max time a node waits for the reboot budget before it reboots anyway
+func (r *PoisonPillRemediationReconciler) rebootBudgetTimeout() time.Duration {
+	if r.RebootBudget == nil {
+		return 0
+	}
+	return maxRebootBudgetWait
+}
+
+// needsEviction returns false for pods which are done or which would be recreated on the node anyway, like kubectl
+// drain does. DaemonSet pods are only evicted when DeleteDaemonSetPods is set.
+func (r *PoisonPillRemediationReconciler) needsEviction(pod *v1.Pod) bool {
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	// static pods can't be evicted through the api server
+	if _, isMirrorPod := pod.Annotations[mirrorPodAnnotation]; isMirrorPod {
+		return false
+	}
+	for _, ownerRef := range pod.OwnerReferences {
+		if ownerRef.Kind != "DaemonSet" {
+			continue
+		}
+		// never evict our own pod, it needs to reboot the node
+		if !r.DeleteDaemonSetPods || ownerRef.Name == agentDaemonSetName {
+			return false
+		}
+	}
+	return true
+}
+
+// recordEvent emits an event on the given object, if a recorder is configured
+func (r *PoisonPillRemediationReconciler) recordEvent(object runtime.Object, eventType string, reason string, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(object, eventType, reason, message)
+}
+
+// setCondition sets the given condition on the ppr, the caller is responsible for updating the ppr's status
+func (r *PoisonPillRemediationReconciler) setCondition(ppr *v1alpha1.PoisonPillRemediation, conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&ppr.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: ppr.Generation,
+	})
+}
+
+// promptEtcdMemberRemoval prompts the cluster admin to remove the etcd member of the given node, when it's a control
+// plane node which was fenced and didn't become ready again in time. A lingering member of a node which is gone for
+// good can stall the etcd quorum. The member is never removed here: the node might still recover, and removing the
+// member of a recovering node breaks etcd. The prompt is recorded as condition of the ppr, it returns whether the
+// condition was set, the caller has to persist the status of the ppr then.
+func (r *PoisonPillRemediationReconciler) promptEtcdMemberRemoval(logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) bool {
+	if !r.PromptEtcdMemberRemoval || !utils.IsControlPlaneNode(node) {
+		return false
+	}
+	if !meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.FencingCompletedConditionType) {
+		// without a confirmed reboot the node might still run its etcd member
+		logger.Info("control plane node didn't become ready, but its fencing didn't complete, not prompting etcd member removal")
+		return false
+	}
+	if meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.EtcdMemberRemovalRequiredConditionType) != nil {
+		// already prompted
+		return false
+	}
+	message := fmt.Sprintf("fenced control plane node %s didn't become ready again, its etcd member might stall the etcd quorum. "+
+		"Remove the etcd member manually only after verifying that the node is gone for good, removing the member of a node which recovers breaks etcd", node.Name)
+	logger.Info("WARNING: " + message)
+	r.recordEvent(node, v1.EventTypeWarning, eventReasonEtcdMemberRemoval, message)
+	r.recordEvent(ppr, v1.EventTypeWarning, eventReasonEtcdMemberRemoval, message)
+	r.setCondition(ppr, v1alpha1.EtcdMemberRemovalRequiredConditionType, metav1.ConditionTrue, v1alpha1.ControlPlaneNodeNotRecoveredReason, message)
+	return true
+}
+
+// recordRemediationError stores the given error as the last error of the ppr and counts it. Errors are recorded on a
+// best effort basis, so a failed status update is only logged.
+func (r *PoisonPillRemediationReconciler) recordRemediationError(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation, reason v1alpha1.RemediationErrorReason, message string) {
+	if !setLastError(ppr, reason, message) {
+		return
+	}
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		logger.Error(err, "failed to record remediation error", "reason", reason)
+		return
+	}
+	remediationErrors.WithLabelValues(string(reason)).Inc()
+}
+
+// remediationTimedOut returns the time since the creation of the ppr, and if it exceeds the MaxRemediationDuration
+func (r *PoisonPillRemediationReconciler) remediationTimedOut(ppr *v1alpha1.PoisonPillRemediation) (time.Duration, bool) {
+	elapsed := time.Since(ppr.CreationTimestamp.Time)
+	return elapsed, r.MaxRemediationDuration > 0 && elapsed > r.MaxRemediationDuration
+}
+
+// failRemediation gives up on the remediation after it exceeded the MaxRemediationDuration. It doesn't requeue the
+// remediation, it's up to its creator to delete or escalate it.
+func (r *PoisonPillRemediationReconciler) failRemediation(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation, elapsed time.Duration) (ctrl.Result, error) {
+	message := fmt.Sprintf("remediation didn't complete within %s, giving up after %s", r.MaxRemediationDuration, elapsed.Round(time.Second))
+	logger.Info(message)
+	r.setCondition(ppr, v1alpha1.ProcessingConditionType, metav1.ConditionFalse, v1alpha1.RemediationTimedOutReason, "")
+	r.setCondition(ppr, v1alpha1.FailedConditionType, metav1.ConditionTrue, v1alpha1.RemediationTimedOutReason, message)
+	setLastError(ppr, v1alpha1.RemediationErrorRemediationTimeout, message)
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update failed condition")
+		return ctrl.Result{}, err
+	}
+	// only the agent which won the status update records the event and the metrics
+	r.recordEvent(ppr, v1.EventTypeWarning, eventReasonRemediationFailed, message)
+	remediationErrors.WithLabelValues(string(v1alpha1.RemediationErrorRemediationTimeout)).Inc()
+	remediations.WithLabelValues(outcomeTimedOut).Inc()
+	return ctrl.Result{}, nil
+}
+
+// setLastError sets the last error of the ppr, it returns false when the last error already has the given reason,
+// so that retries of the same failing step don't overwrite the time of its first occurrence
+func setLastError(ppr *v1alpha1.PoisonPillRemediation, reason v1alpha1.RemediationErrorReason, message string) bool {
+	if ppr.Status.LastError != nil && ppr.Status.LastError.Reason == reason {
+		return false
+	}
+	ppr.Status.LastError = &v1alpha1.RemediationError{
+		Reason:  reason,
+		Message: message,
+		Time:    metav1.Now(),
+	}
+	return true
+}
+
 // getNodeFromPpr returns the unhealthy node reported in the given ppr
-func (r *PoisonPillRemediationReconciler) getNodeFromPpr(ppr *v1alpha1.PoisonPillRemediation) (*v1.Node, error) {
+func (r *PoisonPillRemediationReconciler) getNodeFromPpr(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation) (*v1.Node, error) {
 	//PPR could be created by either machine based controller (e.g. MHC) or
 	//by a node based controller (e.g. NHC). This assumes that machine based controller
 	//will create the ppr with machine owner reference
@@ -290,40 +1218,40 @@ func (r *PoisonPillRemediationReconciler) getNodeFromPpr(ppr *v1alpha1.PoisonPil
 			r.mutex.Lock()
 			wasLastSeenPprMachine = true
 			r.mutex.Unlock()
-			return r.getNodeFromMachine(ownerRef, ppr.Namespace)
+			return r.getNodeFromMachine(ctx, logger, ownerRef, ppr.Namespace)
 		}
 	}
 
-	//since we didn't find a machine owner ref, we assume that ppr name is the unhealthy node name
+	//since we didn't find a machine owner ref, the node is referenced by spec.nodeName, or by the ppr name
 	node := &v1.Node{}
 	key := client.ObjectKey{
-		Name:      ppr.Name,
+		Name:      ppr.GetNodeName(),
 		Namespace: "",
 	}
 
-	if err := r.Get(context.TODO(), key, node); err != nil {
+	if err := r.Get(ctx, key, node); err != nil {
 		return nil, err
 	}
 
 	return node, nil
 }
 
-func (r *PoisonPillRemediationReconciler) getNodeFromMachine(ref metav1.OwnerReference, ns string) (*v1.Node, error) {
+func (r *PoisonPillRemediationReconciler) getNodeFromMachine(ctx context.Context, logger logr.Logger, ref metav1.OwnerReference, ns string) (*v1.Node, error) {
 	machine := &machinev1beta1.Machine{}
 	machineKey := client.ObjectKey{
 		Name:      ref.Name,
 		Namespace: ns,
 	}
 
-	if err := r.Client.Get(context.Background(), machineKey, machine); err != nil {
-		r.logger.Error(err, "failed to get machine from PoisonPillRemediation CR owner ref",
-			"machine name", machineKey.Name, "namespace", machineKey.Namespace)
+	if err := r.Client.Get(ctx, machineKey, machine); err != nil {
+		logger.Error(err, "failed to get machine from PoisonPillRemediation CR owner ref",
+			"machine", machineKey.Name, "namespace", machineKey.Namespace)
 		return nil, err
 	}
 
 	if machine.Status.NodeRef == nil {
 		err := errors.New("nodeRef is nil")
-		r.logger.Error(err, "failed to retrieve node from the unhealthy machine")
+		logger.Error(err, "failed to retrieve node from the unhealthy machine")
 		return nil, err
 	}
 
@@ -333,59 +1261,732 @@ func (r *PoisonPillRemediationReconciler) getNodeFromMachine(ref metav1.OwnerRef
 		Namespace: machine.Status.NodeRef.Namespace,
 	}
 
-	if err := r.Get(context.Background(), key, node); err != nil {
-		r.logger.Error(err, "failed to retrieve node from the unhealthy machine",
-			"node name", node.Name, "machine name", machine.Name)
+	if err := r.Get(ctx, key, node); err != nil {
+		logger.Error(err, "failed to retrieve node from the unhealthy machine",
+			"node", key.Name, "machine", machine.Name)
 		return nil, err
 	}
 
 	return node, nil
 }
 
-func (r *PoisonPillRemediationReconciler) markNodeAsUnschedulable(node *v1.Node) (ctrl.Result, error) {
-	node.Spec.Unschedulable = true
-	r.logger.Info("Marking node as unschedulable", "node name", node.Name)
-	if err := r.Client.Update(context.Background(), node); err != nil {
+// getMachineOfNode returns the Machine referenced by the MachineAnnotation of the given node, or nil if the node has
+// no machine
+func (r *PoisonPillRemediationReconciler) getMachineOfNode(ctx context.Context, node *v1.Node) (*machinev1beta1.Machine, error) {
+	namespacedMachine, exists := node.Annotations[utils.MachineAnnotation]
+	if !exists {
+		return nil, nil
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(namespacedMachine)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation %q: %v", utils.MachineAnnotation, namespacedMachine, err)
+	}
+	machine := &machinev1beta1.Machine{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, machine); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return machine, nil
+}
+
+// annotateMachine sets the FencedAnnotation on the Machine of the given fenced node, if it has one
+func (r *PoisonPillRemediationReconciler) annotateMachine(ctx context.Context, logger logr.Logger, node *v1.Node, fencedAt metav1.Time) error {
+	machine, err := r.getMachineOfNode(ctx, node)
+	if err != nil {
+		logger.Error(err, "failed to get machine of fenced node")
+		return err
+	}
+	if machine == nil {
+		return nil
+	}
+	if _, exists := machine.Annotations[utils.FencedAnnotation]; exists {
+		return nil
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+	machine.Annotations[utils.FencedAnnotation] = fencedAt.UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, machine, patch); err != nil {
+		logger.Error(err, "failed to annotate machine of fenced node", "machine", machine.Name)
+		return err
+	}
+	logger.Info("annotated machine of fenced node", "machine", machine.Name)
+	return nil
+}
+
+// removeMachineAnnotation removes the FencedAnnotation from the Machine of the given remediated node. Failures are
+// only logged, the annotation doesn't block the end of the remediation.
+func (r *PoisonPillRemediationReconciler) removeMachineAnnotation(ctx context.Context, logger logr.Logger, node *v1.Node) {
+	if !r.AnnotateMachines {
+		return
+	}
+	machine, err := r.getMachineOfNode(ctx, node)
+	if err != nil {
+		logger.Error(err, "failed to get machine of remediated node")
+		return
+	}
+	if machine == nil {
+		return
+	}
+	if _, exists := machine.Annotations[utils.FencedAnnotation]; !exists {
+		return
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+	delete(machine.Annotations, utils.FencedAnnotation)
+	if err := r.Patch(ctx, machine, patch); err != nil {
+		logger.Error(err, "failed to remove annotation from machine of remediated node", "machine", machine.Name)
+	}
+}
+
+// recordFencingDecision records the decision to reboot this node for the given remediation in the audit log
+func (r *PoisonPillRemediationReconciler) recordFencingDecision(logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) {
+	if r.AuditLog == nil {
+		return
+	}
+	entry := audit.Entry{
+		Node:    node.Name,
+		Outcome: audit.OutcomeRemediated,
+		Reason:  fmt.Sprintf("remediation %s/%s", ppr.Namespace, ppr.Name),
+	}
+	if err := r.AuditLog.Record(entry); err != nil {
+		logger.Error(err, "failed to record fencing decision in the audit log")
+	}
+}
+
+// setAgentAnnotations identifies this agent as the one which started the remediation in the annotations of the ppr.
+// The operator version is kept when the ppr was annotated by the operator already.
+func (r *PoisonPillRemediationReconciler) setAgentAnnotations(ppr *v1alpha1.PoisonPillRemediation) {
+	if r.MyPodName != "" {
+		metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.AgentPodAnnotation, r.MyPodName)
+	}
+	metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.AgentNodeAnnotation, r.MyNodeName)
+	if r.Version != "" {
+		metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.AgentVersionAnnotation, r.Version)
+	}
+	if _, exists := ppr.Annotations[v1alpha1.OperatorVersionAnnotation]; !exists && r.OperatorVersion != "" {
+		metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.OperatorVersionAnnotation, r.OperatorVersion)
+	}
+}
+
+// annotateFencingAgent identifies this agent as the one which reboots the unhealthy node in the annotations of the
+// ppr. Failures are only logged, the annotations mustn't delay the reboot.
+func (r *PoisonPillRemediationReconciler) annotateFencingAgent(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation) {
+	if r.MyPodName == "" && r.Version == "" {
+		return
+	}
+	if ppr.Annotations[v1alpha1.FencingAgentPodAnnotation] == r.MyPodName && ppr.Annotations[v1alpha1.FencingAgentVersionAnnotation] == r.Version {
+		return
+	}
+
+	patch := client.MergeFrom(ppr.DeepCopy())
+	metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.FencingAgentPodAnnotation, r.MyPodName)
+	metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.FencingAgentVersionAnnotation, r.Version)
+	if err := r.Patch(ctx, ppr, patch); err != nil {
+		logger.Error(err, "failed to annotate ppr with the fencing agent")
+	}
+}
+
+// recordNodeWasUnschedulable records in the ppr status if the node was unschedulable before the remediation started,
+// e.g. because it was cordoned by an admin, so that it isn't marked as schedulable when the remediation ends
+func (r *PoisonPillRemediationReconciler) recordNodeWasUnschedulable(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
+	wasUnschedulable := node.Spec.Unschedulable
+	ppr.Status.NodeWasUnschedulable = &wasUnschedulable
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
 		if apiErrors.IsConflict(err) {
 			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 		}
-		r.logger.Error(err, "failed to mark node as unschedulable")
+		logger.Error(err, "failed to record if node was unschedulable")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// nodeWasUnschedulable returns if the node of the given ppr was unschedulable before the remediation started
+func nodeWasUnschedulable(ppr *v1alpha1.PoisonPillRemediation) bool {
+	return ppr.Status.NodeWasUnschedulable != nil && *ppr.Status.NodeWasUnschedulable
+}
+
+func (r *PoisonPillRemediationReconciler) markNodeAsUnschedulable(ctx context.Context, logger logr.Logger, node *v1.Node) (ctrl.Result, error) {
+	logger.Info("Marking node as unschedulable")
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		node.Spec.Unschedulable = true
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to mark node as unschedulable")
+		r.recordEvent(node, v1.EventTypeWarning, eventReasonRemediationFailed, "Failed to mark node as unschedulable: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	r.recordEvent(node, v1.EventTypeNormal, eventReasonNodeTainted, "Node marked as unschedulable")
+	return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+}
+
+func (r *PoisonPillRemediationReconciler) addNodeDeletingTaint(ctx context.Context, logger logr.Logger, node *v1.Node) (ctrl.Result, error) {
+	logger.Info("Adding node deleting taint", "taint", r.NodeDeletingTaint.Key)
+	taint := r.NodeDeletingTaint
+	taint.TimeAdded = &metav1.Time{Time: time.Now()}
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		if !utils.TaintExists(node.Spec.Taints, &taint) {
+			node.Spec.Taints = append(node.Spec.Taints, taint)
+		}
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to add node deleting taint")
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 }
 
-func (r *PoisonPillRemediationReconciler) handleDeletedNode(ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
+func (r *PoisonPillRemediationReconciler) addFencedNodeLabel(ctx context.Context, logger logr.Logger, node *v1.Node) (ctrl.Result, error) {
+	logger.Info("Adding fenced node label", "label", r.FencedNodeLabelKey)
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		if node.Labels == nil {
+			node.Labels = map[string]string{}
+		}
+		node.Labels[r.FencedNodeLabelKey] = r.FencedNodeLabelValue
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to add fenced node label")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+}
+
+// deleteFencedNodeLabel removes the fenced node label from the given node
+func (r *PoisonPillRemediationReconciler) deleteFencedNodeLabel(node *v1.Node) {
+	delete(node.Labels, r.FencedNodeLabelKey)
+}
+
+// updateNode applies the given change to the node and updates it. On conflicts the latest version of the node is
+// read from the api server and the change is applied again, until the update succeeds, the retries are exhausted or
+// the context is done.
+func (r *PoisonPillRemediationReconciler) updateNode(ctx context.Context, node *v1.Node, change func(node *v1.Node)) error {
+	return utils.RetryOnConflict(ctx, utils.ConflictBackoff, func(isRetry bool) error {
+		if isRetry {
+			// the cache might not have caught up with the conflicting update yet
+			if err := r.apiReader().Get(ctx, client.ObjectKeyFromObject(node), node); err != nil {
+				return err
+			}
+		}
+		change(node)
+		return r.Client.Update(ctx, node)
+	})
+}
+
+// apiReader returns the APIReader, or the Client when it isn't set
+func (r *PoisonPillRemediationReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
+// deleteRemediationTaints removes the unschedulable taint, the node deleting taint and the out-of-service taint from
+// the given taints
+func (r *PoisonPillRemediationReconciler) deleteRemediationTaints(taints []v1.Taint) ([]v1.Taint, bool) {
+	taints, unschedulableDeleted := utils.DeleteTaint(taints, NodeUnschedulableTaint)
+	taints, nodeDeletingDeleted := utils.DeleteTaint(taints, &r.NodeDeletingTaint)
+	taints, outOfServiceDeleted := utils.DeleteTaint(taints, OutOfServiceTaint)
+	return taints, unschedulableDeleted || nodeDeletingDeleted || outOfServiceDeleted
+}
+
+// remediationStrategy returns the strategy of the given ppr. Externally fenced nodes are always deleted, because
+// deleting them triggers their recreation, and clusters without support for the out-of-service taint fall back to
+// deleting the pods of the node.
+func (r *PoisonPillRemediationReconciler) remediationStrategy(ppr *v1alpha1.PoisonPillRemediation) v1alpha1.RemediationStrategyType {
+	strategy := ppr.Spec.RemediationStrategy
+	if strategy == "" || r.ExternalFencing {
+		return v1alpha1.NodeDeletionRemediationStrategy
+	}
+	if strategy == v1alpha1.OutOfServiceTaintRemediationStrategy && !r.OutOfServiceTaintSupported {
+		return v1alpha1.ResourceDeletionRemediationStrategy
+	}
+	return strategy
+}
+
+// remediateWithoutNodeDeletion moves the workloads of the fenced node to other nodes without deleting the node.
+// Afterwards it waits until the rebooted node is ready again, and reverts the node changes.
+func (r *PoisonPillRemediationReconciler) remediateWithoutNodeDeletion(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation, strategy v1alpha1.RemediationStrategyType) (ctrl.Result, error) {
+	logger = logger.WithValues("strategy", strategy)
+	succeeded := meta.FindStatusCondition(ppr.Status.Conditions, v1alpha1.SucceededConditionType)
+	if succeeded == nil || succeeded.Status != metav1.ConditionTrue {
+		return r.deleteNodeResources(ctx, logger, node, ppr, strategy)
+	}
+
+	if !isLastRemediationRecorded(node, ppr) {
+		return r.recordLastRemediation(ctx, logger, node)
+	}
+
+	ready, requeueAfter := r.nodeReadyFor(node, r.NodeReadyGracePeriod)
+	timedOut := time.Since(succeeded.LastTransitionTime.Time) > r.NodeReadyGracePeriod+restoredNodeReadyTimeout
+	if !ready && !timedOut {
+		logger.Info("waiting for rebooted node to become ready before marking it as schedulable")
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	keepUnschedulable := nodeWasUnschedulable(ppr)
+	if err := r.updateNode(ctx, node, func(node *v1.Node) {
+		node.Spec.Unschedulable = keepUnschedulable
+		node.Spec.Taints, _ = r.deleteRemediationTaints(node.Spec.Taints)
+		r.deleteFencedNodeLabel(node)
+	}); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to mark rebooted node as schedulable")
+		return ctrl.Result{}, err
+	}
+	if !ready {
+		message := fmt.Sprintf("node didn't become ready within %s, marked it as schedulable anyway", r.NodeReadyGracePeriod+restoredNodeReadyTimeout)
+		logger.Info(message)
+		r.recordEvent(node, v1.EventTypeWarning, eventReasonNodeNotReady, "Rebooted "+message)
+		if r.promptEtcdMemberRemoval(logger, node, ppr) {
+			if err := r.Client.Status().Update(ctx, ppr); err != nil {
+				if apiErrors.IsConflict(err) {
+					return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+				}
+				logger.Error(err, "failed to update etcd member removal condition")
+				return ctrl.Result{}, err
+			}
+		}
+		r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorNodeRestoreTimeout, message)
+	}
+	r.removeMachineAnnotation(ctx, logger, node)
+
+	controllerutil.RemoveFinalizer(ppr, PPRFinalizer)
+	if err := r.Client.Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to remove finalizer from ppr")
+		return ctrl.Result{}, err
+	}
+	logger.Info("node has been remediated")
+	return ctrl.Result{}, nil
+}
+
+// deleteNodeResources deletes the pods of the fenced node, either by itself or by adding the out-of-service taint,
+// and marks the remediation as succeeded when they are gone
+func (r *PoisonPillRemediationReconciler) deleteNodeResources(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation, strategy v1alpha1.RemediationStrategyType) (ctrl.Result, error) {
+	if strategy == v1alpha1.OutOfServiceTaintRemediationStrategy && !utils.TaintExists(node.Spec.Taints, OutOfServiceTaint) {
+		logger.Info("Adding out-of-service taint")
+		taint := *OutOfServiceTaint
+		taint.TimeAdded = &metav1.Time{Time: time.Now()}
+		if err := r.updateNode(ctx, node, func(node *v1.Node) {
+			if !utils.TaintExists(node.Spec.Taints, &taint) {
+				node.Spec.Taints = append(node.Spec.Taints, taint)
+			}
+		}); err != nil {
+			if apiErrors.IsConflict(err) {
+				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+			}
+			logger.Error(err, "failed to add out-of-service taint")
+			return ctrl.Result{}, err
+		}
+		r.recordEvent(node, v1.EventTypeNormal, eventReasonNodeTainted, "Node tainted as out of service")
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	pods := &v1.PodList{}
+	if err := r.List(ctx, pods, client.MatchingFields{podNodeNameField: node.Name}); err != nil {
+		logger.Error(err, "failed to list pods of fenced node")
+		return ctrl.Result{}, err
+	}
+	pendingPods := 0
+	var podsToDelete []*v1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !needsDeletion(pod, strategy) {
+			continue
+		}
+		pendingPods++
+		// with the out-of-service taint the pod garbage collector deletes the pod
+		if strategy == v1alpha1.ResourceDeletionRemediationStrategy {
+			podsToDelete = append(podsToDelete, pod)
+		}
+	}
+	podsToDelete, podsToEvict := r.splitGracefulDeletion(ppr, podsToDelete)
+	r.recordDisruptedPodDisruptionBudgets(ctx, logger, ppr, podsToDelete)
+	// pods which failed to be deleted or evicted are still pending, so they are retried with the next check
+	r.deletePods(ctx, logger, podsToDelete)
+	r.evictFencedPods(ctx, logger, podsToEvict)
+	if pendingPods > 0 {
+		logger.Info("waiting for pods of fenced node to be deleted", "pods", pendingPods)
+		return ctrl.Result{RequeueAfter: podDeletionCheckInterval}, nil
+	}
+
+	// the attach/detach controller detaches the volumes of nodes with the out-of-service taint by itself
+	if strategy == v1alpha1.ResourceDeletionRemediationStrategy {
+		if err := r.deleteVolumeAttachments(ctx, logger, node.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	message := "workloads of the node have been deleted"
+	if ppr.Spec.RemediationStrategy != strategy {
+		message += fmt.Sprintf(", the cluster doesn't support the %s strategy", ppr.Spec.RemediationStrategy)
+	}
+	r.setCondition(ppr, v1alpha1.ProcessingConditionType, metav1.ConditionFalse, v1alpha1.ResourcesDeletedReason, "")
+	r.setCondition(ppr, v1alpha1.SucceededConditionType, metav1.ConditionTrue, v1alpha1.ResourcesDeletedReason, message)
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		if apiErrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		logger.Error(err, "failed to update succeeded condition")
+		return ctrl.Result{}, err
+	}
+	// only the agent which won the status update records the metrics
+	remediationDuration.Observe(time.Since(ppr.CreationTimestamp.Time).Seconds())
+	remediations.WithLabelValues(outcomeSucceeded).Inc()
+	r.recordEvent(node, v1.EventTypeNormal, eventReasonResourcesDeleted, "Workloads of the fenced node have been deleted")
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// forceDeletePods deletes the pods of the given fenced node with a zero grace period, so that they are removed right
+// away instead of waiting for the kubelet of the rebooted node, which can take long for pods with long termination
+// grace periods. It never deletes anything before the fencing completed. The pods of GracefulDeletionNamespaces are
+// evicted instead, it returns if they are gone, so that the node can be deleted.
+func (r *PoisonPillRemediationReconciler) forceDeletePods(ctx context.Context, logger logr.Logger, node *v1.Node, ppr *v1alpha1.PoisonPillRemediation) (bool, error) {
+	if !meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.FencingCompletedConditionType) {
+		logger.Info("not deleting pods of node which isn't fenced yet")
+		return true, nil
+	}
+	pods := &v1.PodList{}
+	if err := r.List(ctx, pods, client.MatchingFields{podNodeNameField: node.Name}); err != nil {
+		logger.Error(err, "failed to list pods of fenced node")
+		return false, err
+	}
+	var podsToDelete []*v1.Pod
+	for i := range pods.Items {
+		if needsDeletion(&pods.Items[i], v1alpha1.NodeDeletionRemediationStrategy) {
+			podsToDelete = append(podsToDelete, &pods.Items[i])
+		}
+	}
+	podsToDelete, podsToEvict := r.splitGracefulDeletion(ppr, podsToDelete)
+	r.recordDisruptedPodDisruptionBudgets(ctx, logger, ppr, podsToDelete)
+
+	deletedPods, failedPods := r.deletePods(ctx, logger, podsToDelete)
+	if deletedPods > 0 {
+		r.recordEvent(node, v1.EventTypeNormal, eventReasonPodsDeleted, fmt.Sprintf("Force deleted %d pods of the fenced node", deletedPods))
+	}
+	if failedPods > 0 {
+		// the node isn't deleted before its pods are, the failed ones are retried with the next reconcile
+		return false, fmt.Errorf("failed to delete %d pods of fenced node %s", failedPods, node.Name)
+	}
+	if len(podsToEvict) > 0 {
+		r.evictFencedPods(ctx, logger, podsToEvict)
+		logger.Info("waiting for pods of fenced node to be evicted", "pods", len(podsToEvict))
+		return false, nil
+	}
+	return true, nil
+}
+
+// splitGracefulDeletion splits the given pods of a fenced node into the pods which are force deleted, and the pods of
+// GracefulDeletionNamespaces which are evicted. When the GracefulDeletionTimeout elapsed since the node was assumed to
+// be rebooted, all pods are force deleted.
+func (r *PoisonPillRemediationReconciler) splitGracefulDeletion(ppr *v1alpha1.PoisonPillRemediation, pods []*v1.Pod) ([]*v1.Pod, []*v1.Pod) {
+	if len(r.GracefulDeletionNamespaces) == 0 {
+		return pods, nil
+	}
+	timeout := r.GracefulDeletionTimeout
+	if timeout <= 0 {
+		timeout = defaultGracefulDeletionTimeout
+	}
+	if ppr.Status.TimeAssumedRebooted != nil && time.Since(ppr.Status.TimeAssumedRebooted.Time) > timeout {
+		return pods, nil
+	}
+	var podsToDelete, podsToEvict []*v1.Pod
+	for _, pod := range pods {
+		if r.isGracefulDeletionNamespace(pod.Namespace) {
+			podsToEvict = append(podsToEvict, pod)
+		} else {
+			podsToDelete = append(podsToDelete, pod)
+		}
+	}
+	return podsToDelete, podsToEvict
+}
+
+// isGracefulDeletionNamespace returns if the pods of the given namespace are evicted instead of force deleted
+func (r *PoisonPillRemediationReconciler) isGracefulDeletionNamespace(namespace string) bool {
+	for _, gracefulDeletionNamespace := range r.GracefulDeletionNamespaces {
+		if namespace == gracefulDeletionNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// evictFencedPods evicts the given pods of a fenced node, which aren't terminating yet. Failed evictions, e.g. when a
+// PodDisruptionBudget blocks them, are only logged, since the pods are evicted again with the next check.
+func (r *PoisonPillRemediationReconciler) evictFencedPods(ctx context.Context, logger logr.Logger, pods []*v1.Pod) {
+	for _, pod := range pods {
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		logger.Info("evicting pod of fenced node", "pod", pod.Name, "namespace", pod.Namespace)
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+		}
+		if err := r.KubeClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction); err != nil && !apiErrors.IsNotFound(err) {
+			logger.Info("failed to evict pod of fenced node, will retry", "pod", pod.Name, "namespace", pod.Namespace, "reason", err.Error())
+		}
+	}
+}
+
+// deletePods deletes the given pods of a fenced node with a zero grace period, since its kubelet won't confirm the
+// deletion. Up to PodDeletionConcurrency pods are deleted at the same time, and with DeleteCriticalPodsLast the pods
+// of critical namespaces are only deleted after all others. A failed deletion doesn't stop the deletion of the other
+// pods, it's only logged. It returns the number of deleted and of failed pods, pods which are gone already count as
+// neither.
+func (r *PoisonPillRemediationReconciler) deletePods(ctx context.Context, logger logr.Logger, pods []*v1.Pod) (int, int) {
+	concurrency := r.PodDeletionConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultPodDeletionConcurrency
+	}
+	var deleted, failed int32
+	for _, batch := range r.podDeletionBatches(pods) {
+		workqueue.ParallelizeUntil(ctx, concurrency, len(batch), func(i int) {
+			pod := batch[i]
+			logger.Info("force deleting pod of fenced node", "pod", pod.Name, "namespace", pod.Namespace)
+			if err := r.Delete(ctx, pod, client.GracePeriodSeconds(0)); err != nil {
+				if apiErrors.IsNotFound(err) {
+					return
+				}
+				logger.Error(err, "failed to delete pod of fenced node", "pod", pod.Name, "namespace", pod.Namespace)
+				atomic.AddInt32(&failed, 1)
+				return
+			}
+			atomic.AddInt32(&deleted, 1)
+		})
+	}
+	return int(deleted), int(failed)
+}
+
+// podDeletionBatches returns the given pods in the order of their deletion. With DeleteCriticalPodsLast the pods of
+// critical namespaces are in a second batch, otherwise all pods are deleted in a single batch.
+func (r *PoisonPillRemediationReconciler) podDeletionBatches(pods []*v1.Pod) [][]*v1.Pod {
+	if !r.DeleteCriticalPodsLast {
+		return [][]*v1.Pod{pods}
+	}
+	var workloadPods, criticalPods []*v1.Pod
+	for _, pod := range pods {
+		if isCriticalNamespace(pod.Namespace) {
+			criticalPods = append(criticalPods, pod)
+		} else {
+			workloadPods = append(workloadPods, pod)
+		}
+	}
+	return [][]*v1.Pod{workloadPods, criticalPods}
+}
+
+// isCriticalNamespace returns if the given namespace belongs to the cluster's own components
+func isCriticalNamespace(namespace string) bool {
+	for _, prefix := range criticalNamespacePrefixes {
+		if strings.HasPrefix(namespace, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDisruptedPodDisruptionBudgets records the PodDisruptionBudgets which protect the given pods of the fenced
+// node in the status of the ppr and with an event, before the pods are force deleted. It's only done once per
+// remediation. It's informational only, so failures don't block the deletion of the pods.
+func (r *PoisonPillRemediationReconciler) recordDisruptedPodDisruptionBudgets(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation, pods []*v1.Pod) {
+	if len(pods) == 0 || ppr.Status.DisruptedPodDisruptionBudgets != nil {
+		return
+	}
+	pdbs := &policyv1beta1.PodDisruptionBudgetList{}
+	if err := r.List(ctx, pdbs); err != nil {
+		logger.Error(err, "failed to list pod disruption budgets, not recording the disrupted ones")
+		return
+	}
+	disrupted := disruptedPodDisruptionBudgets(pdbs.Items, pods)
+	if len(disrupted) == 0 {
+		return
+	}
+
+	eventType := v1.EventTypeNormal
+	summaries := make([]string, 0, len(disrupted))
+	for _, pdb := range disrupted {
+		if int32(len(pdb.Pods)) > pdb.DisruptionsAllowed {
+			eventType = v1.EventTypeWarning
+		}
+		summaries = append(summaries, fmt.Sprintf("%s/%s (%d pods, %d disruptions allowed)", pdb.Namespace, pdb.Name, len(pdb.Pods), pdb.DisruptionsAllowed))
+	}
+	message := "Force deleting pods protected by PodDisruptionBudgets: " + strings.Join(summaries, ", ")
+	logger.Info(message)
+	r.recordEvent(ppr, eventType, eventReasonPDBsDisrupted, message)
+
+	ppr.Status.DisruptedPodDisruptionBudgets = disrupted
+	if err := r.Client.Status().Update(ctx, ppr); err != nil {
+		logger.Error(err, "failed to record disrupted pod disruption budgets")
+	}
+}
+
+// disruptedPodDisruptionBudgets returns the PodDisruptionBudgets which protect any of the given pods, with the
+// protected pods
+func disruptedPodDisruptionBudgets(pdbs []policyv1beta1.PodDisruptionBudget, pods []*v1.Pod) []v1alpha1.DisruptedPodDisruptionBudget {
+	var disrupted []v1alpha1.DisruptedPodDisruptionBudget
+	for i := range pdbs {
+		pdb := &pdbs[i]
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		// a PodDisruptionBudget with an empty selector doesn't protect any pod
+		if err != nil || selector.Empty() {
+			continue
+		}
+		var protectedPods []string
+		for _, pod := range pods {
+			if pod.Namespace == pdb.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				protectedPods = append(protectedPods, pod.Name)
+			}
+		}
+		if len(protectedPods) > 0 {
+			disrupted = append(disrupted, v1alpha1.DisruptedPodDisruptionBudget{
+				Namespace:          pdb.Namespace,
+				Name:               pdb.Name,
+				Pods:               protectedPods,
+				DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+			})
+		}
+	}
+	return disrupted
+}
+
+// deleteVolumeAttachments deletes the volume attachments of the given fenced node, so that the attach/detach controller
+// can attach their volumes to other nodes. Stateful workloads can't start on other nodes as long as they exist.
+func (r *PoisonPillRemediationReconciler) deleteVolumeAttachments(ctx context.Context, logger logr.Logger, nodeName string) error {
+	volumeAttachments := &storagev1.VolumeAttachmentList{}
+	if err := r.List(ctx, volumeAttachments); err != nil {
+		logger.Error(err, "failed to list volume attachments")
+		return err
+	}
+	for i := range volumeAttachments.Items {
+		va := &volumeAttachments.Items[i]
+		if va.Spec.NodeName != nodeName || !va.DeletionTimestamp.IsZero() {
+			continue
+		}
+		logger.Info("deleting volume attachment of fenced node", "volume attachment", va.Name)
+		if err := r.Delete(ctx, va); err != nil && !apiErrors.IsNotFound(err) {
+			logger.Error(err, "failed to delete volume attachment", "volume attachment", va.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// needsDeletion returns false for pods which don't need to be moved to other nodes, because they are done or because
+// they are bound to the node. With the out-of-service taint, pods which tolerate it aren't deleted either.
+func needsDeletion(pod *v1.Pod, strategy v1alpha1.RemediationStrategyType) bool {
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	if _, isMirrorPod := pod.Annotations[mirrorPodAnnotation]; isMirrorPod {
+		return false
+	}
+	for _, ownerRef := range pod.OwnerReferences {
+		if ownerRef.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	if strategy == v1alpha1.OutOfServiceTaintRemediationStrategy {
+		for i := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[i].ToleratesTaint(OutOfServiceTaint) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *PoisonPillRemediationReconciler) handleDeletedNode(ctx context.Context, logger logr.Logger, ppr *v1alpha1.PoisonPillRemediation) (ctrl.Result, error) {
 	if ppr.Status.NodeBackup == nil {
 		err := errors.New("unhealthy node doesn't exist and there's no backup node to restore")
-		r.logger.Error(err, "remediation failed")
+		logger.Error(err, "remediation failed")
+		if setLastError(ppr, v1alpha1.RemediationErrorNodeNotFound, err.Error()) {
+			if err := r.Client.Status().Update(ctx, ppr); err != nil {
+				if apiErrors.IsConflict(err) {
+					return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+				}
+				logger.Error(err, "failed to record remediation error")
+				return ctrl.Result{}, err
+			}
+			// only the agent which won the status update records the metrics
+			remediationErrors.WithLabelValues(string(v1alpha1.RemediationErrorNodeNotFound)).Inc()
+			remediations.WithLabelValues(outcomeFailed).Inc()
+		}
 		// there is nothing we can do about it, stop reconciling
 		return ctrl.Result{}, nil
 	}
 
-	return r.restoreNode(ppr.Status.NodeBackup)
+	if err := r.deleteVolumeAttachments(ctx, logger, ppr.Status.NodeBackup.Name); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.ExternalFencing {
+		if meta.IsStatusConditionTrue(ppr.Status.Conditions, v1alpha1.FailedConditionType) {
+			return ctrl.Result{}, nil
+		}
+		if elapsed, timedOut := r.remediationTimedOut(ppr); timedOut {
+			return r.failRemediation(ctx, logger, ppr, elapsed)
+		}
+		logger.Info("waiting for the deleted node to be recreated by the cloud provider", "node", ppr.Status.NodeBackup.Name)
+		return ctrl.Result{RequeueAfter: recreatedNodeCheckInterval}, nil
+	}
+
+	result, err := r.restoreNode(ctx, logger, ppr.Status.NodeBackup, nodeWasUnschedulable(ppr))
+	if err != nil {
+		r.recordRemediationError(ctx, logger, ppr, v1alpha1.RemediationErrorAPIUnreachable, "failed to restore node: "+err.Error())
+	}
+	return result, err
 }
 
-func (r *PoisonPillRemediationReconciler) restoreNode(nodeToRestore *v1.Node) (ctrl.Result, error) {
-	r.logger.Info("restoring node", "node name", nodeToRestore.Name)
+// restoreNode creates the given node again. It's only kept unschedulable when it was unschedulable before the
+// remediation started.
+func (r *PoisonPillRemediationReconciler) restoreNode(ctx context.Context, logger logr.Logger, nodeToRestore *v1.Node, keepUnschedulable bool) (ctrl.Result, error) {
+	logger.Info("restoring node", "node", nodeToRestore.Name)
 
 	// todo we probably want to have some allowlist/denylist on which things to restore, we already had
 	// a problem when we restored ovn annotations
 	nodeToRestore.ResourceVersion = "" //create won't work with a non-empty value here
-	taints, _ := utils.DeleteTaint(nodeToRestore.Spec.Taints, NodeUnschedulableTaint)
+	taints, _ := r.deleteRemediationTaints(nodeToRestore.Spec.Taints)
 	nodeToRestore.Spec.Taints = taints
-	nodeToRestore.Spec.Unschedulable = false
+	nodeToRestore.Spec.Unschedulable = keepUnschedulable
+	if r.NodeReadyGracePeriod > 0 {
+		// the node is marked as schedulable and unlabeled when it's ready, see verifyRestoredNodeReady
+		taint := r.NodeDeletingTaint
+		taint.TimeAdded = &metav1.Time{Time: time.Now()}
+		nodeToRestore.Spec.Taints = append(nodeToRestore.Spec.Taints, taint)
+		nodeToRestore.Spec.Unschedulable = true
+	} else {
+		r.deleteFencedNodeLabel(nodeToRestore)
+	}
 	nodeToRestore.CreationTimestamp = metav1.Now()
 	nodeToRestore.Status = v1.NodeStatus{}
 
-	if err := r.Client.Create(context.TODO(), nodeToRestore); err != nil {
+	if err := r.Client.Create(ctx, nodeToRestore); err != nil {
 		if apiErrors.IsAlreadyExists(err) {
 			// there is nothing we can do about it, stop reconciling
 			return ctrl.Result{}, nil
 		}
-		r.logger.Error(err, "failed to create node", "node name", nodeToRestore.Name)
+		logger.Error(err, "failed to create node", "node", nodeToRestore.Name)
+		r.recordEvent(nodeToRestore, v1.EventTypeWarning, eventReasonRemediationFailed, "Failed to restore node: "+err.Error())
 		return ctrl.Result{}, err
 	}
+	r.recordEvent(nodeToRestore, v1.EventTypeNormal, eventReasonNodeRestored, "Node has been restored")
 
 	// all done, stop reconciling
 	return ctrl.Result{Requeue: true}, nil
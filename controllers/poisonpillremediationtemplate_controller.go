@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/utils"
+)
+
+// PoisonPillRemediationTemplateReconciler creates remediations from templates. A node health check which only knows
+// the template requests the remediation of a node by annotating the node with the name of the template, and the
+// reconciler creates a remediation named after the node with the spec of the template. When the annotation is
+// removed, the remediation is deleted again.
+type PoisonPillRemediationTemplateReconciler struct {
+	client.Client
+	Log logr.Logger
+	// Namespace is the namespace of the operator, the templates are looked up and the remediations are created in it
+	Namespace string
+	// OperatorVersion is recorded in the OperatorVersionAnnotation of the created remediations, if it's set
+	OperatorVersion string
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PoisonPillRemediationTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("poisonpillremediationtemplate").
+		For(&v1.Node{}).
+		Watches(&source.Kind{Type: &v1alpha1.PoisonPillRemediationTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.nodesOfTemplate)).
+		Complete(r)
+}
+
+func (r *PoisonPillRemediationTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("node", req.Name)
+
+	node := &v1.Node{}
+	if err := r.Get(ctx, req.NamespacedName, node); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed to get node")
+		return ctrl.Result{}, err
+	}
+
+	ppr := &v1alpha1.PoisonPillRemediation{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: node.Name}, ppr)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		logger.Error(err, "failed to get remediation")
+		return ctrl.Result{}, err
+	}
+	pprExists := err == nil
+
+	templateName := node.Annotations[utils.RemediationTemplateAnnotation]
+	if templateName == "" {
+		// remediations which weren't created from a template are left alone
+		if pprExists && ppr.Labels[v1alpha1.RemediationTemplateLabel] != "" {
+			logger.Info("remediation isn't requested anymore, deleting it", "template", ppr.Labels[v1alpha1.RemediationTemplateLabel])
+			if err := r.Delete(ctx, ppr); err != nil && !apiErrors.IsNotFound(err) {
+				logger.Error(err, "failed to delete remediation")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if pprExists {
+		return ctrl.Result{}, nil
+	}
+
+	template := &v1alpha1.PoisonPillRemediationTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: templateName}, template); err != nil {
+		if apiErrors.IsNotFound(err) {
+			// the node is reconciled again when the template is created
+			logger.Info("remediation template not found", "template", templateName)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed to get remediation template", "template", templateName)
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("creating remediation from template", "template", templateName)
+	ppr = template.NewRemediation(node.Name)
+	if r.OperatorVersion != "" {
+		metav1.SetMetaDataAnnotation(&ppr.ObjectMeta, v1alpha1.OperatorVersionAnnotation, r.OperatorVersion)
+	}
+	if err := r.Create(ctx, ppr); err != nil && !apiErrors.IsAlreadyExists(err) {
+		logger.Error(err, "failed to create remediation", "template", templateName)
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// nodesOfTemplate returns the nodes which request a remediation from the given template
+func (r *PoisonPillRemediationTemplateReconciler) nodesOfTemplate(o client.Object) []reconcile.Request {
+	if o.GetNamespace() != r.Namespace {
+		return nil
+	}
+	nodes := &v1.NodeList{}
+	if err := r.List(context.Background(), nodes); err != nil {
+		r.Log.Error(err, "failed to list nodes", "template", o.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, node := range nodes.Items {
+		if node.Annotations[utils.RemediationTemplateAnnotation] == o.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: node.Name}})
+		}
+	}
+	return requests
+}
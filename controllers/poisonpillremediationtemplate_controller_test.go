@@ -0,0 +1,76 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	poisonpillv1alpha1 "github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/utils"
+)
+
+var _ = Describe("ppr template Controller", func() {
+
+	const (
+		templateNodeName = "template-node"
+		templateName     = "test-template"
+	)
+
+	pprKey := client.ObjectKey{Name: templateNodeName, Namespace: pprNamespace}
+
+	var node *v1.Node
+	var template *poisonpillv1alpha1.PoisonPillRemediationTemplate
+
+	BeforeEach(func() {
+		template = &poisonpillv1alpha1.PoisonPillRemediationTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: templateName, Namespace: pprNamespace},
+		}
+		Expect(k8sClient.Create(context.TODO(), template)).To(Succeed())
+
+		// the remediation of the node is disabled, so that the created remediation doesn't fence it
+		node = &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: templateNodeName,
+				Annotations: map[string]string{
+					utils.DisabledAnnotation:            "",
+					utils.RemediationTemplateAnnotation: templateName,
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), node)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), node)).To(Succeed())
+		Expect(k8sClient.Delete(context.TODO(), template)).To(Succeed())
+		ppr := &poisonpillv1alpha1.PoisonPillRemediation{}
+		if err := k8sClient.Get(context.TODO(), pprKey, ppr); err == nil {
+			Expect(k8sClient.Delete(context.TODO(), ppr)).To(Succeed())
+		}
+	})
+
+	It("creates and deletes the remediation of the annotated node", func() {
+		ppr := &poisonpillv1alpha1.PoisonPillRemediation{}
+		Eventually(func() error {
+			return k8sClient.Get(context.TODO(), pprKey, ppr)
+		}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
+		Expect(ppr.Labels).To(HaveKeyWithValue(poisonpillv1alpha1.RemediationTemplateLabel, templateName))
+		Expect(ppr.Annotations).To(HaveKeyWithValue(poisonpillv1alpha1.OperatorVersionAnnotation, "0.1.0"))
+
+		Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Name: templateNodeName}, node)).To(Succeed())
+		delete(node.Annotations, utils.RemediationTemplateAnnotation)
+		Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(context.TODO(), pprKey, ppr)
+			return apiErrors.IsNotFound(err)
+		}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
+	})
+})
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Readiness of restored nodes", func() {
+
+	r := &PoisonPillRemediationReconciler{}
+
+	newNode := func(status v1.ConditionStatus, readySince time.Duration, lastHeartbeat time.Duration) *v1.Node {
+		node := &v1.Node{}
+		node.Status.Conditions = []v1.NodeCondition{{
+			Type:               v1.NodeReady,
+			Status:             status,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-readySince)),
+			LastHeartbeatTime:  metav1.NewTime(time.Now().Add(-lastHeartbeat)),
+		}}
+		return node
+	}
+
+	It("is ready when it was ready for the grace period", func() {
+		ready, _ := r.nodeReadyFor(newNode(v1.ConditionTrue, 2*time.Minute, 10*time.Second), time.Minute)
+		Expect(ready).To(BeTrue())
+	})
+
+	It("waits for the rest of the grace period", func() {
+		ready, requeueAfter := r.nodeReadyFor(newNode(v1.ConditionTrue, 20*time.Second, 10*time.Second), time.Minute)
+		Expect(ready).To(BeFalse())
+		Expect(requeueAfter).To(BeNumerically("~", 41*time.Second, time.Second))
+	})
+
+	It("isn't ready without recent heartbeat", func() {
+		ready, _ := r.nodeReadyFor(newNode(v1.ConditionTrue, 10*time.Minute, 6*time.Minute), time.Minute)
+		Expect(ready).To(BeFalse())
+	})
+
+	It("isn't ready without ready condition", func() {
+		ready, _ := r.nodeReadyFor(newNode(v1.ConditionFalse, 10*time.Minute, 10*time.Second), time.Minute)
+		Expect(ready).To(BeFalse())
+		ready, _ = r.nodeReadyFor(&v1.Node{}, time.Minute)
+		Expect(ready).To(BeFalse())
+	})
+})
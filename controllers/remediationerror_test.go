@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+var _ = Describe("Remediation errors", func() {
+
+	It("records the last error", func() {
+		ppr := &v1alpha1.PoisonPillRemediation{}
+		Expect(setLastError(ppr, v1alpha1.RemediationErrorAPIUnreachable, "failed to delete node")).To(BeTrue())
+		Expect(ppr.Status.LastError.Reason).To(Equal(v1alpha1.RemediationErrorAPIUnreachable))
+		Expect(ppr.Status.LastError.Message).To(Equal("failed to delete node"))
+		Expect(ppr.Status.LastError.Time.IsZero()).To(BeFalse())
+	})
+
+	It("keeps the first occurrence of the same error", func() {
+		ppr := &v1alpha1.PoisonPillRemediation{}
+		Expect(setLastError(ppr, v1alpha1.RemediationErrorWatchdogUnavailable, "first")).To(BeTrue())
+		Expect(setLastError(ppr, v1alpha1.RemediationErrorWatchdogUnavailable, "second")).To(BeFalse())
+		Expect(ppr.Status.LastError.Message).To(Equal("first"))
+
+		Expect(setLastError(ppr, v1alpha1.RemediationErrorNodeRestoreTimeout, "timeout")).To(BeTrue())
+		Expect(ppr.Status.LastError.Reason).To(Equal(v1alpha1.RemediationErrorNodeRestoreTimeout))
+	})
+})
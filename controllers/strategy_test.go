@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+var _ = Describe("Remediation strategies", func() {
+
+	newPpr := func(strategy v1alpha1.RemediationStrategyType) *v1alpha1.PoisonPillRemediation {
+		return &v1alpha1.PoisonPillRemediation{Spec: v1alpha1.PoisonPillRemediationSpec{RemediationStrategy: strategy}}
+	}
+
+	It("deletes the node by default", func() {
+		r := &PoisonPillRemediationReconciler{}
+		Expect(r.remediationStrategy(newPpr(""))).To(Equal(v1alpha1.NodeDeletionRemediationStrategy))
+	})
+
+	It("always deletes externally fenced nodes", func() {
+		r := &PoisonPillRemediationReconciler{ExternalFencing: true}
+		Expect(r.remediationStrategy(newPpr(v1alpha1.ResourceDeletionRemediationStrategy))).To(Equal(v1alpha1.NodeDeletionRemediationStrategy))
+	})
+
+	It("falls back to resource deletion without out-of-service taint support", func() {
+		r := &PoisonPillRemediationReconciler{}
+		Expect(r.remediationStrategy(newPpr(v1alpha1.OutOfServiceTaintRemediationStrategy))).To(Equal(v1alpha1.ResourceDeletionRemediationStrategy))
+		r.OutOfServiceTaintSupported = true
+		Expect(r.remediationStrategy(newPpr(v1alpha1.OutOfServiceTaintRemediationStrategy))).To(Equal(v1alpha1.OutOfServiceTaintRemediationStrategy))
+	})
+
+	It("doesn't delete pods which are bound to the node", func() {
+		pod := &v1.Pod{}
+		Expect(needsDeletion(pod, v1alpha1.ResourceDeletionRemediationStrategy)).To(BeTrue())
+
+		daemonSetPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet"}}}}
+		Expect(needsDeletion(daemonSetPod, v1alpha1.ResourceDeletionRemediationStrategy)).To(BeFalse())
+
+		mirrorPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{mirrorPodAnnotation: ""}}}
+		Expect(needsDeletion(mirrorPod, v1alpha1.ResourceDeletionRemediationStrategy)).To(BeFalse())
+	})
+
+	It("doesn't wait for pods which tolerate the out-of-service taint", func() {
+		pod := &v1.Pod{Spec: v1.PodSpec{Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}}}}
+		Expect(needsDeletion(pod, v1alpha1.OutOfServiceTaintRemediationStrategy)).To(BeFalse())
+		Expect(needsDeletion(pod, v1alpha1.ResourceDeletionRemediationStrategy)).To(BeTrue())
+	})
+
+	It("doesn't force delete pods before the fencing completed", func() {
+		// without a client any pod lookup fails, so nothing is deleted
+		r := &PoisonPillRemediationReconciler{ForceDeletePods: true}
+		Expect(r.forceDeletePods(context.Background(), r.Log, &v1.Node{}, newPpr(""))).To(BeTrue())
+	})
+
+	It("records the PodDisruptionBudgets of force deleted pods", func() {
+		newPdb := func(name string, selector *metav1.LabelSelector) policyv1beta1.PodDisruptionBudget {
+			return policyv1beta1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+				Spec:       policyv1beta1.PodDisruptionBudgetSpec{Selector: selector},
+				Status:     policyv1beta1.PodDisruptionBudgetStatus{DisruptionsAllowed: 1},
+			}
+		}
+		pdbs := []policyv1beta1.PodDisruptionBudget{
+			newPdb("db", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}),
+			newPdb("web", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}),
+			newPdb("empty", &metav1.LabelSelector{}),
+		}
+		pods := []*v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-0", Labels: map[string]string{"app": "db"}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-1", Labels: map[string]string{"app": "db"}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "web-0", Labels: map[string]string{"app": "web"}}},
+		}
+		Expect(disruptedPodDisruptionBudgets(pdbs, pods)).To(Equal([]v1alpha1.DisruptedPodDisruptionBudget{
+			{Namespace: "ns", Name: "db", Pods: []string{"db-0", "db-1"}, DisruptionsAllowed: 1},
+		}))
+	})
+
+	It("deletes the pods of critical namespaces last", func() {
+		pods := []*v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-dns", Name: "dns"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "proxy"}},
+		}
+		r := &PoisonPillRemediationReconciler{}
+		Expect(r.podDeletionBatches(pods)).To(Equal([][]*v1.Pod{pods}))
+
+		r.DeleteCriticalPodsLast = true
+		Expect(r.podDeletionBatches(pods)).To(Equal([][]*v1.Pod{{pods[1]}, {pods[0], pods[2]}}))
+	})
+
+	It("evicts the pods of graceful deletion namespaces until the timeout elapsed", func() {
+		pods := []*v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "prometheus-0"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}},
+		}
+		ppr := newPpr("")
+		ppr.Status.TimeAssumedRebooted = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+
+		r := &PoisonPillRemediationReconciler{}
+		podsToDelete, podsToEvict := r.splitGracefulDeletion(ppr, pods)
+		Expect(podsToDelete).To(Equal(pods))
+		Expect(podsToEvict).To(BeEmpty())
+
+		r.GracefulDeletionNamespaces = []string{"monitoring", "logging"}
+		podsToDelete, podsToEvict = r.splitGracefulDeletion(ppr, pods)
+		Expect(podsToDelete).To(Equal([]*v1.Pod{pods[1]}))
+		Expect(podsToEvict).To(Equal([]*v1.Pod{pods[0]}))
+
+		r.GracefulDeletionTimeout = 30 * time.Second
+		podsToDelete, podsToEvict = r.splitGracefulDeletion(ppr, pods)
+		Expect(podsToDelete).To(Equal(pods))
+		Expect(podsToEvict).To(BeEmpty())
+	})
+
+	It("keeps deleting pods when the deletion of a pod fails", func() {
+		c := &deletingClient{failing: "db-0"}
+		r := &PoisonPillRemediationReconciler{Client: c, Log: ctrl.Log.WithName("strategy-test"), PodDeletionConcurrency: 2}
+		pods := []*v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-0"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-1"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web-0"}},
+		}
+		deleted, failed := r.deletePods(context.Background(), r.Log, pods)
+		Expect(deleted).To(Equal(2))
+		Expect(failed).To(Equal(1))
+		Expect(c.deleted).To(ConsistOf("db-1", "web-0"))
+	})
+})
+
+// deletingClient records deleted objects, and fails to delete the object with the failing name
+type deletingClient struct {
+	client.Client
+	failing string
+	deleted []string
+	mutex   sync.Mutex
+}
+
+func (c *deletingClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	if obj.GetName() == c.failing {
+		return errors.New("delete failed")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deleted = append(c.deleted, obj.GetName())
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -52,6 +54,7 @@ import (
 var k8sClient *K8sClientWrapper
 var testEnv *envtest.Environment
 var dummyDog watchdog.Watchdog
+var unhealthyNodeRebooter *countingRebooter
 var certReader certificates.CertStorageReader
 
 const (
@@ -63,12 +66,16 @@ const (
 	apiCheckInterval   = 1 * time.Second
 	maxErrorThreshold  = 1
 
+	maxConcurrentReconciles = 10
+
 	namespace = "poison-pill"
 )
 
 type K8sClientWrapper struct {
 	client.Client
 	ShouldSimulateFailure bool
+	// SimulatedNodeConflicts is the number of node updates which fail with a conflict
+	SimulatedNodeConflicts int32
 }
 
 func (kcw *K8sClientWrapper) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
@@ -78,6 +85,42 @@ func (kcw *K8sClientWrapper) List(ctx context.Context, list client.ObjectList, o
 	return kcw.Client.List(ctx, list, opts...)
 }
 
+func (kcw *K8sClientWrapper) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, isNode := obj.(*v1.Node); isNode && kcw.simulateNodeConflict() {
+		return apiErrors.NewConflict(v1.Resource("nodes"), obj.GetName(), errors.New("simulation of conflict"))
+	}
+	return kcw.Client.Update(ctx, obj, opts...)
+}
+
+// simulateNodeConflict returns if a node update should fail with a simulated conflict
+func (kcw *K8sClientWrapper) simulateNodeConflict() bool {
+	for {
+		remaining := atomic.LoadInt32(&kcw.SimulatedNodeConflicts)
+		if remaining <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&kcw.SimulatedNodeConflicts, remaining, remaining-1) {
+			return true
+		}
+	}
+}
+
+// countingRebooter counts the reboots requested by the reconciler of the unhealthy node
+type countingRebooter struct {
+	reboot.Rebooter
+	reboots int32
+}
+
+func (r *countingRebooter) Reboot() error {
+	atomic.AddInt32(&r.reboots, 1)
+	return r.Rebooter.Reboot()
+}
+
+// RebootCount returns the number of requested reboots
+func (r *countingRebooter) RebootCount() int32 {
+	return atomic.LoadInt32(&r.reboots)
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -123,6 +166,7 @@ var _ = BeforeSuite(func() {
 	k8sClient = &K8sClientWrapper{
 		k8sManager.GetClient(),
 		false,
+		0,
 	}
 	Expect(k8sClient).ToNot(BeNil())
 
@@ -130,6 +174,7 @@ var _ = BeforeSuite(func() {
 		Client:            k8sManager.GetClient(),
 		Log:               ctrl.Log.WithName("controllers").WithName("poison-pill-config-controller"),
 		InstallFileFolder: "../install/",
+		Namespace:         namespace,
 		Scheme:            scheme.Scheme,
 	}).SetupWithManager(k8sManager)
 
@@ -152,12 +197,15 @@ var _ = BeforeSuite(func() {
 	Expect(err).ToNot(HaveOccurred())
 
 	peerApiServerTimeout := 5 * time.Second
-	peers := peers.New(unhealthyNodeName, peerUpdateInterval, k8sClient, ctrl.Log.WithName("peers"), peerApiServerTimeout)
+	peers := peers.New(unhealthyNodeName, peerUpdateInterval, k8sClient, ctrl.Log.WithName("peers"), peerApiServerTimeout, peers.Random, nil)
+	nodeInformer, err := k8sManager.GetCache().GetInformer(context.Background(), &v1.Node{})
+	Expect(err).ToNot(HaveOccurred())
+	peers.WatchNodes(nodeInformer)
 	err = k8sManager.Add(peers)
 	Expect(err).ToNot(HaveOccurred())
 
 	certReader = certificates.NewSecretCertStorage(k8sClient, ctrl.Log.WithName("SecretCertStorage"), namespace)
-	rebooter := reboot.NewWatchdogRebooter(dummyDog, ctrl.Log.WithName("rebooter"))
+	rebooter := reboot.NewWatchdogRebooter(dummyDog, reboot.RebootMethodStopFeeding, nil, ctrl.Log.WithName("rebooter"))
 	apiConnectivityCheckConfig := &apicheck.ApiConnectivityCheckConfig{
 		Log:                ctrl.Log.WithName("api-check"),
 		MyNodeName:         unhealthyNodeName,
@@ -165,33 +213,54 @@ var _ = BeforeSuite(func() {
 		MaxErrorsThreshold: maxErrorThreshold,
 		Peers:              peers,
 		Rebooter:           rebooter,
+		Watchdog:           dummyDog,
 		Cfg:                cfg,
 		CertReader:         certReader,
+		NodeReader:         k8sClient,
 	}
 	apiCheck := apicheck.New(apiConnectivityCheckConfig)
 	err = k8sManager.Add(apiCheck)
 	Expect(err).ToNot(HaveOccurred())
 
-	timeToAssumeNodeRebooted := time.Duration(maxErrorThreshold) * apiCheckInterval
-	timeToAssumeNodeRebooted += dummyDog.GetTimeout()
+	unhealthyNodeRebooter = &countingRebooter{Rebooter: rebooter}
+
+	timeToAssumeNodeRebooted := controllers.MinSafeTimeToAssumeNodeRebooted(maxErrorThreshold, apiCheckInterval, dummyDog.GetTimeout())
 	timeToAssumeNodeRebooted += 5 * time.Second
 
 	// reconciler for unhealthy node
 	err = (&controllers.PoisonPillRemediationReconciler{
 		Client:                       k8sClient,
+		APIReader:                    k8sManager.GetAPIReader(),
 		Log:                          ctrl.Log.WithName("controllers").WithName("poison-pill-controller").WithName("unhealthy node"),
-		Rebooter:                     rebooter,
+		Rebooter:                     unhealthyNodeRebooter,
+		Recorder:                     k8sManager.GetEventRecorderFor("poison-pill"),
 		SafeTimeToAssumeNodeRebooted: timeToAssumeNodeRebooted,
+		CheckInterval:                apiCheckInterval,
+		MaxErrorsThreshold:           maxErrorThreshold,
+		WatchdogTimeout:              dummyDog.GetTimeout(),
 		MyNodeName:                   unhealthyNodeName,
+		MaxConcurrentReconciles:      maxConcurrentReconciles,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	// reconciler for peer node
 	err = (&controllers.PoisonPillRemediationReconciler{
 		Client:                       k8sClient,
+		APIReader:                    k8sManager.GetAPIReader(),
 		Log:                          ctrl.Log.WithName("controllers").WithName("poison-pill-controller").WithName("peer node"),
+		Recorder:                     k8sManager.GetEventRecorderFor("poison-pill"),
 		SafeTimeToAssumeNodeRebooted: timeToAssumeNodeRebooted,
 		MyNodeName:                   peerNodeName,
+		MaxConcurrentReconciles:      maxConcurrentReconciles,
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	// reconciler for remediation templates
+	err = (&controllers.PoisonPillRemediationTemplateReconciler{
+		Client:          k8sClient,
+		Log:             ctrl.Log.WithName("controllers").WithName("poison-pill-template-controller"),
+		Namespace:       pprNamespace,
+		OperatorVersion: "0.1.0",
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+var _ = Describe("Max remediation duration", func() {
+
+	var reconciler *PoisonPillRemediationReconciler
+	var ppr *v1alpha1.PoisonPillRemediation
+
+	BeforeEach(func() {
+		reconciler = &PoisonPillRemediationReconciler{MaxRemediationDuration: time.Hour}
+		ppr = &v1alpha1.PoisonPillRemediation{ObjectMeta: metav1.ObjectMeta{
+			Name:              "node1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}}
+	})
+
+	It("times out remediations which take longer than the max duration", func() {
+		elapsed, timedOut := reconciler.remediationTimedOut(ppr)
+		Expect(timedOut).To(BeTrue())
+		Expect(elapsed).To(BeNumerically(">=", 2*time.Hour))
+	})
+
+	It("doesn't time out remediations within the max duration", func() {
+		ppr.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+		_, timedOut := reconciler.remediationTimedOut(ppr)
+		Expect(timedOut).To(BeFalse())
+	})
+
+	It("never times out remediations when not configured", func() {
+		reconciler.MaxRemediationDuration = 0
+		_, timedOut := reconciler.remediationTimedOut(ppr)
+		Expect(timedOut).To(BeFalse())
+	})
+})
+
+var _ = Describe("Time to assume node rebooted", func() {
+
+	var reconciler *PoisonPillRemediationReconciler
+	var ppr *v1alpha1.PoisonPillRemediation
+
+	BeforeEach(func() {
+		reconciler = &PoisonPillRemediationReconciler{SafeTimeToAssumeNodeRebooted: 3 * time.Minute, WatchdogTimeout: time.Minute}
+		ppr = &v1alpha1.PoisonPillRemediation{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	})
+
+	It("waits for the safe time to assume node rebooted", func() {
+		Expect(reconciler.timeToAssumeNodeRebooted(ppr)).To(Equal(3 * time.Minute))
+	})
+
+	It("waits for the watchdog timeout only when peer verification is skipped", func() {
+		ppr.Spec.SkipPeerVerification = true
+		Expect(reconciler.timeToAssumeNodeRebooted(ppr)).To(Equal(time.Minute + rebootBuffer))
+	})
+
+	It("doesn't skip peer verification when the watchdog timeout is unknown", func() {
+		reconciler.WatchdogTimeout = 0
+		ppr.Spec.SkipPeerVerification = true
+		Expect(reconciler.timeToAssumeNodeRebooted(ppr)).To(Equal(3 * time.Minute))
+	})
+})
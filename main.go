@@ -18,10 +18,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -30,10 +33,19 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -45,17 +57,93 @@ import (
 	poisonpillv1alpha1 "github.com/medik8s/poison-pill/api/v1alpha1"
 	"github.com/medik8s/poison-pill/controllers"
 	"github.com/medik8s/poison-pill/pkg/apicheck"
+	"github.com/medik8s/poison-pill/pkg/audit"
 	"github.com/medik8s/poison-pill/pkg/certificates"
 	"github.com/medik8s/poison-pill/pkg/peerhealth"
+	"github.com/medik8s/poison-pill/pkg/peerresults"
 	"github.com/medik8s/poison-pill/pkg/peers"
 	"github.com/medik8s/poison-pill/pkg/reboot"
+	"github.com/medik8s/poison-pill/pkg/rebootbudget"
+	"github.com/medik8s/poison-pill/pkg/utils"
+	"github.com/medik8s/poison-pill/pkg/version"
 	"github.com/medik8s/poison-pill/pkg/watchdog"
 	//+kubebuilder:scaffold:imports
 )
 
 const (
-	nodeNameEnvVar        = "MY_NODE_NAME"
-	peerHealthDefaultPort = 30001
+	nodeNameEnvVar              = "MY_NODE_NAME"
+	podNamespaceEnvVar          = "POD_NAMESPACE"
+	podNameEnvVar               = "POD_NAME"
+	operatorVersionEnvVar       = "OPERATOR_VERSION"
+	deploymentNamespaceEnvVar   = "DEPLOYMENT_NAMESPACE"
+	watchdogPathEnvVar          = "WATCHDOG_PATH"
+	watchdogPathsEnvVar         = "WATCHDOG_PATHS"
+	watchdogTimeoutEnvVar       = "WATCHDOG_TIMEOUT"
+	watchdogKeepaliveEnvVar     = "WATCHDOG_KEEPALIVE_INTERVAL"
+	watchdogArmDelayEnvVar      = "WATCHDOG_ARM_DELAY"
+	rebootMethodEnvVar          = "REBOOT_METHOD"
+	rebootTimingFileEnvVar      = "REBOOT_TIMING_FILE"
+	certsDirEnvVar              = "CERTS_DIR"
+	peerResultsFileEnvVar       = "PEER_RESULTS_FILE"
+	auditLogFileEnvVar          = "AUDIT_LOG_FILE"
+	auditLogMaxSizeEnvVar       = "AUDIT_LOG_MAX_SIZE"
+	apiCheckIntervalEnvVar      = "API_CHECK_INTERVAL"
+	apiCheckStartupGraceEnvVar  = "API_CHECK_STARTUP_GRACE_PERIOD"
+	apiServerTimeoutEnvVar      = "API_SERVER_TIMEOUT"
+	dryRunEnvVar                = "DRY_RUN"
+	externalFencingEnvVar       = "EXTERNAL_FENCING"
+	softwareRebootEnvVar        = "SOFTWARE_REBOOT_FALLBACK"
+	annotateMachinesEnvVar      = "ANNOTATE_MACHINES"
+	promptEtcdRemovalEnvVar     = "PROMPT_ETCD_MEMBER_REMOVAL"
+	forceDeletePodsEnvVar       = "FORCE_DELETE_PODS"
+	podDeleteConcurrencyEnvVar  = "POD_DELETION_CONCURRENCY"
+	criticalPodsLastEnvVar      = "DELETE_CRITICAL_PODS_LAST"
+	gracefulNamespacesEnvVar    = "GRACEFUL_DELETION_NAMESPACES"
+	gracefulDeleteTimeoutEnvVar = "GRACEFUL_DELETION_TIMEOUT"
+	configNameEnvVar            = "POISON_PILL_CONFIG_NAME"
+	peerPortEnvVar              = "PEER_PORT"
+	peerMinTLSVersionEnvVar     = "PEER_MIN_TLS_VERSION"
+	peerCipherSuitesEnvVar      = "PEER_TLS_CIPHER_SUITES"
+	peerClientAuthEnvVar        = "PEER_CLIENT_AUTH"
+	peerInterfaceEnvVar         = "PEER_NETWORK_INTERFACE"
+	maxPeerClockSkewEnvVar      = "MAX_PEER_CLOCK_SKEW"
+	minPeersForQuorumEnvVar     = "MIN_PEERS_FOR_QUORUM"
+	fenceOnIndeterminateEnvVar  = "FENCE_ON_INDETERMINATE"
+	peerSampleSizeEnvVar        = "PEER_SAMPLE_SIZE"
+	minClusterSizeEnvVar        = "MIN_CLUSTER_SIZE_FOR_FENCING"
+	gracefulRebootTimeoutEnvVar = "GRACEFUL_REBOOT_TIMEOUT"
+	deleteDaemonSetPodsEnvVar   = "DELETE_DAEMONSET_PODS"
+	statusBindAddressEnvVar     = "STATUS_BIND_ADDRESS"
+	apiFailureSimulationEnvVar  = "API_FAILURE_SIMULATION"
+	simulationFencingEnvVar     = "API_FAILURE_SIMULATION_FENCING"
+	remediationCooldownEnvVar   = "REMEDIATION_COOLDOWN"
+	remediationTimeoutEnvVar    = "MAX_REMEDIATION_DURATION"
+	parallelRemediationsEnvVar  = "MAX_CONCURRENT_REMEDIATIONS"
+	maxConcurrentRebootsEnvVar  = "MAX_CONCURRENT_REBOOTS"
+	apiCheckProbeModeEnvVar     = "API_CHECK_PROBE_MODE"
+	apiServerEndpointsEnvVar    = "API_SERVER_ENDPOINTS"
+	externalProbeEnvVar         = "EXTERNAL_PROBE_ENDPOINTS"
+	probeKubeletEnvVar          = "PROBE_KUBELET"
+	nodeDeletingTaintEnvVar     = "NODE_DELETING_TAINT"
+	fencedNodeLabelKeyEnvVar    = "FENCED_NODE_LABEL_KEY"
+	fencedNodeLabelValueEnvVar  = "FENCED_NODE_LABEL_VALUE"
+	nodeReadyGracePeriodEnvVar  = "NODE_READY_GRACE_PERIOD"
+	peerNodeSelectorEnvVar      = "PEER_NODE_SELECTOR"
+	peerSelectionEnvVar         = "PEER_SELECTION_STRATEGY"
+	enableWebhooksEnvVar        = "ENABLE_WEBHOOKS"
+	peerHealthDefaultPort       = 30001
+
+	// defaultConfigRetryInterval is the interval in which creating the default config is retried
+	defaultConfigRetryInterval = 5 * time.Second
+	// defaultPeerUpdateInterval is used until the config is read, and when it doesn't set an interval
+	defaultPeerUpdateInterval = 15 * time.Minute
+
+	logFormatConsole = "console"
+	logFormatJSON    = "json"
+
+	// checkWatchdogCommand is the subcommand which checks the watchdog device of the node and exits, e.g. in an init
+	// container
+	checkWatchdogCommand = "check-watchdog"
 )
 
 var (
@@ -72,26 +160,52 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == checkWatchdogCommand {
+		os.Exit(checkWatchdog(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var isManager bool
+	var certRotationWindow time.Duration
+	var logFormat string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+			"Enabling this will ensure there is only one active controller manager. It's ignored by the agents.")
 	flag.BoolVar(&isManager, "is-manager", false,
 		"Used to differentiate between the poison pill agents that runs in a daemonset to the 'manager' that only"+
 			"reconciles the config CRD and installs the DS")
+	flag.DurationVar(&certRotationWindow, "cert-rotation-window", 30*24*time.Hour,
+		"The peer certificates are rotated when they expire within this duration")
+	flag.StringVar(&logFormat, "log-format", logFormatConsole,
+		"The log format, either \"console\" for human readable development logs, or \"json\" for structured production logs")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	switch logFormat {
+	case logFormatConsole:
+	case logFormatJSON:
+		opts.Development = false
+		zap.JSONEncoder()(&opts)
+	default:
+		fmt.Fprintf(os.Stderr, "invalid log format %q, must be %q or %q\n", logFormat, logFormatConsole, logFormatJSON)
+		os.Exit(1)
+	}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// the manager's controllers are singletons, but every agent needs to watch and remediate its own node. With leader
+	// election only one agent in the cluster would run.
+	if enableLeaderElection && !isManager {
+		setupLog.Info("ignoring leader election, it's only supported by the manager")
+		enableLeaderElection = false
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -105,10 +219,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the config, the certificates secret and the agents' DaemonSet all live in the namespace of the operator
+	ns, err := getOperatorNamespace()
+	if err != nil {
+		setupLog.Error(err, "unable to get the operator namespace")
+		os.Exit(1)
+	}
+
 	if isManager {
-		initPoisonPillManager(mgr)
+		initPoisonPillManager(mgr, ns, certRotationWindow)
 	} else {
-		initPoisonPillAgent(mgr)
+		initPoisonPillAgent(mgr, ns)
 	}
 
 	//+kubebuilder:scaffold:builder
@@ -129,91 +250,466 @@ func main() {
 	}
 }
 
-func initPoisonPillManager(mgr manager.Manager) {
-	setupLog.Info("Starting as a manager that installs the daemonset")
+func initPoisonPillManager(mgr manager.Manager, ns string, certRotationWindow time.Duration) {
+	setupLog.Info("Starting as a manager that installs the daemonset", "version", version.Version)
+	// the operator pod might have the certificates directory mounted as well
+	var certFileStorage certificates.CertStorageWriter
+	if certFiles := newCertFileStorage(); certFiles != nil {
+		certFileStorage = certFiles
+	}
+
+	// the agents' DaemonSet is rendered for the SCCs of OpenShift
+	isOpenShift := false
+	if discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig()); err != nil {
+		setupLog.Error(err, "failed to create discovery client, assuming that this isn't OpenShift")
+	} else if isOpenShift, err = utils.IsOpenShift(discoveryClient); err != nil {
+		setupLog.Error(err, "failed to check if this is OpenShift, assuming that it isn't")
+	}
+	setupLog.Info("detected cluster type", "OpenShift", isOpenShift)
+
 	if err := (&controllers.PoisonPillConfigReconciler{
 		Client:            mgr.GetClient(),
 		Log:               ctrl.Log.WithName("controllers").WithName("PoisonPillConfig"),
 		Scheme:            mgr.GetScheme(),
 		InstallFileFolder: "./install",
-		DefaultPpcCreator: newConfigIfNotExist,
+		Namespace:         ns,
+		DefaultPpcCreator: func(c client.Client) error {
+			return newConfigIfNotExist(c, ns)
+		},
+		CertFileStorage:    certFileStorage,
+		CertRotationWindow: certRotationWindow,
+		IsOpenShift:        isOpenShift,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PoisonPillConfig")
 		os.Exit(1)
 	}
 
-	if err := newConfigIfNotExist(mgr.GetClient()); err != nil {
-		setupLog.Error(err, "failed to create a default poison pill config CR")
+	if err := (&controllers.PoisonPillRemediationTemplateReconciler{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("PoisonPillRemediationTemplate"),
+		Namespace:       ns,
+		OperatorVersion: version.Version,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PoisonPillRemediationTemplate")
+		os.Exit(1)
+	}
+
+	// webhooks need serving certificates, so they are only served when the deployment provisions them
+	if os.Getenv(enableWebhooksEnvVar) == "true" {
+		if err := (&poisonpillv1alpha1.PoisonPillConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PoisonPillConfig")
+			os.Exit(1)
+		}
+	}
+
+	// the default config is validated by the webhook of this manager, if enabled, so it can only be created once the
+	// manager serves the webhook
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		wait.PollImmediateUntil(defaultConfigRetryInterval, func() (bool, error) {
+			if err := newConfigIfNotExist(mgr.GetClient(), ns); err != nil {
+				setupLog.Error(err, "failed to create a default poison pill config CR, retrying")
+				return false, nil
+			}
+			return true, nil
+		}, ctx.Done())
+		return nil
+	})); err != nil {
+		setupLog.Error(err, "failed to add the default poison pill config creation to the manager")
 		os.Exit(1)
 	}
 
-	if err := newDefaultTemplateIfNotExist(mgr.GetClient()); err != nil {
+	if err := newDefaultTemplateIfNotExist(mgr.GetClient(), ns); err != nil {
 		setupLog.Error(err, "failed to create default remediation template")
 		os.Exit(1)
 	}
 }
 
-func initPoisonPillAgent(mgr manager.Manager) {
-	setupLog.Info("Starting as a poison pill agent that should run as part of the daemonset")
+func initPoisonPillAgent(mgr manager.Manager, ns string) {
+	setupLog.Info("Starting as a poison pill agent that should run as part of the daemonset", "version", version.Version)
 
 	myNodeName := os.Getenv(nodeNameEnvVar)
 	if myNodeName == "" {
-		setupLog.Error(errors.New("failed to get own node name"), "node name was empty",
-			"env var name", nodeNameEnvVar)
+		// the cache isn't started yet, so use the api reader
+		hostname, err := os.Hostname()
+		if err == nil {
+			myNodeName, err = utils.ResolveNodeName(context.Background(), mgr.GetAPIReader(), hostname)
+		}
+		if err != nil {
+			setupLog.Error(err, "node name was empty and resolving it by hostname failed",
+				"env var name", nodeNameEnvVar)
+			os.Exit(1)
+		}
+		setupLog.Info("resolved own node name by hostname", "hostname", hostname, "node name", myNodeName)
 	}
 
-	ns, err := getDeploymentNamespace()
-	if err != nil {
-		setupLog.Error(err, "unable to get the deployment namespace")
-		os.Exit(1)
+	var err error
+
+	// an empty or zero timeout keeps the default timeout of the device
+	var watchdogTimeout time.Duration
+	if watchdogTimeoutString := os.Getenv(watchdogTimeoutEnvVar); watchdogTimeoutString != "" {
+		watchdogTimeoutInt, err := strconv.Atoi(watchdogTimeoutString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", watchdogTimeoutEnvVar)
+			os.Exit(1)
+		}
+		watchdogTimeout = time.Duration(watchdogTimeoutInt) * time.Second
+	}
+
+	// an empty or zero interval feeds the watchdog every third of its timeout
+	var watchdogKeepaliveInterval time.Duration
+	if watchdogKeepaliveString := os.Getenv(watchdogKeepaliveEnvVar); watchdogKeepaliveString != "" {
+		// unlike the other intervals, which are passed in seconds, this is a duration string like "500ms"
+		watchdogKeepaliveInterval, err = time.ParseDuration(watchdogKeepaliveString)
+		if err != nil {
+			setupLog.Error(err, "failed to parse env variable as duration", "env var name", watchdogKeepaliveEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	// failed api server checks are ignored until the watchdog is armed
+	var watchdogArmDelay time.Duration
+	if watchdogArmDelayString := os.Getenv(watchdogArmDelayEnvVar); watchdogArmDelayString != "" {
+		watchdogArmDelayInt, err := strconv.Atoi(watchdogArmDelayString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", watchdogArmDelayEnvVar)
+			os.Exit(1)
+		}
+		watchdogArmDelay = time.Duration(watchdogArmDelayInt) * time.Second
+	}
+
+	externalFencing := false
+	if externalFencingString := os.Getenv(externalFencingEnvVar); externalFencingString != "" {
+		if externalFencing, err = strconv.ParseBool(externalFencingString); err != nil {
+			setupLog.Error(err, "failed to parse external fencing env var", "value", externalFencingString)
+			os.Exit(1)
+		}
 	}
 
-	wd, err := watchdog.NewLinux(ctrl.Log.WithName("watchdog"))
+	// with external fencing no watchdog is used at all
+	var wd watchdog.Watchdog
+	var watchdogErr error
+	if externalFencing {
+		setupLog.Info("external fencing enabled, nodes will be deleted and recreated instead of being rebooted")
+	} else if watchdogPaths := os.Getenv(watchdogPathsEnvVar); watchdogPaths != "" {
+		// several devices take precedence over a single one
+		wd, err = watchdog.NewMulti(ctrl.Log.WithName("watchdog"), strings.Split(watchdogPaths, ","), watchdogTimeout, watchdogKeepaliveInterval, watchdogArmDelay)
+		watchdogErr = err
+	} else if watchdogPath := os.Getenv(watchdogPathEnvVar); watchdogPath != "" {
+		wd, err = watchdog.NewLinux(ctrl.Log.WithName("watchdog"), watchdogPath, watchdogTimeout, watchdogKeepaliveInterval, watchdogArmDelay)
+		watchdogErr = err
+	} else {
+		wd, err = watchdog.NewAutoDetect(ctrl.Log.WithName("watchdog"), watchdogTimeout, watchdogKeepaliveInterval, watchdogArmDelay)
+	}
 	if err != nil {
 		setupLog.Error(err, "failed to init watchdog, using soft reboot")
 	}
+	addWatchdogReadyzCheck(mgr, wd, watchdogErr)
 	if wd != nil {
+		setupLog.Info("using watchdog", "device", wd.Describe())
+		reportLostWatchdog(mgr, wd, myNodeName)
 		if err = mgr.Add(wd); err != nil {
 			setupLog.Error(err, "failed to add watchdog to the manager")
 			os.Exit(1)
 		}
+		// the watchdog clamps the timeout to the range supported by the device, the admin needs to know about that
+		if _, maxTimeout := wd.GetTimeoutRange(); maxTimeout > 0 && watchdogTimeout > maxTimeout {
+			setupLog.Info("configured watchdog timeout exceeds the device maximum, using the maximum",
+				"configured", watchdogTimeout, "maximum", maxTimeout)
+			reportInvalidWatchdogTimeout(mgr, ns, myNodeName, watchdogTimeout, maxTimeout)
+		}
+	}
+	rebootMethod, err := reboot.ParseRebootMethod(os.Getenv(rebootMethodEnvVar))
+	if err != nil {
+		setupLog.Error(err, "failed to parse env variable", "env var name", rebootMethodEnvVar)
+		os.Exit(1)
+	}
+	// the timing of the last reboot is logged on startup, so it needs to be read before it's overwritten
+	var rebootTiming *reboot.RebootTiming
+	if rebootTimingFile := os.Getenv(rebootTimingFileEnvVar); rebootTimingFile != "" {
+		rebootTiming = reboot.NewRebootTiming(rebootTimingFile, ctrl.Log.WithName("reboot-timing"))
+		rebootTiming.LogTimeToReboot()
 	}
 	// it's fine when the watchdog is nil!
-	rebooter := reboot.NewWatchdogRebooter(wd, ctrl.Log.WithName("rebooter"))
+	rebooter := reboot.NewWatchdogRebooter(wd, rebootMethod, rebootTiming, ctrl.Log.WithName("rebooter"))
+	if externalFencing {
+		rebooter = reboot.NewExternalFencingRebooter(ctrl.Log.WithName("rebooter"))
+	} else if wd == nil {
+		softwareReboot := false
+		if softwareRebootString := os.Getenv(softwareRebootEnvVar); softwareRebootString != "" {
+			if softwareReboot, err = strconv.ParseBool(softwareRebootString); err != nil {
+				setupLog.Error(err, "failed to parse env variable", "env var name", softwareRebootEnvVar)
+				os.Exit(1)
+			}
+		}
+		if softwareReboot {
+			setupLog.Info("WARNING: no watchdog available, falling back to software reboots, which are less safe than watchdog based fencing")
+			rebooter = reboot.NewSoftwareRebooter(ctrl.Log.WithName("rebooter"))
+		}
+	}
+
+	dryRun := false
+	if dryRunString := os.Getenv(dryRunEnvVar); dryRunString != "" {
+		if dryRun, err = strconv.ParseBool(dryRunString); err != nil {
+			setupLog.Error(err, "failed to parse dry run env var", "value", dryRunString)
+			os.Exit(1)
+		}
+	}
+	if dryRun {
+		setupLog.Info("dry run mode enabled, nodes will neither be rebooted nor modified")
+		rebooter = reboot.NewDryRunRebooter(ctrl.Log.WithName("rebooter"))
+	}
 
-	// TODO make the interval configurable
-	peerUpdateInterval := 15 * time.Minute
 	peerApiServerTimeout := 5 * time.Second
 
-	myPeers := peers.New(myNodeName, peerUpdateInterval, mgr.GetClient(), ctrl.Log.WithName("peers"), peerApiServerTimeout)
+	var peerNodeSelector labels.Selector
+	if peerNodeSelectorString := os.Getenv(peerNodeSelectorEnvVar); peerNodeSelectorString != "" {
+		labelSelector := &metav1.LabelSelector{}
+		if err := json.Unmarshal([]byte(peerNodeSelectorString), labelSelector); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", peerNodeSelectorEnvVar)
+			os.Exit(1)
+		}
+		if peerNodeSelector, err = metav1.LabelSelectorAsSelector(labelSelector); err != nil {
+			setupLog.Error(err, "invalid peer node selector", "env var name", peerNodeSelectorEnvVar)
+			os.Exit(1)
+		}
+		setupLog.Info("restricting peers", "selector", peerNodeSelector.String())
+	}
+
+	peerSelectionStrategy, err := peers.ParsePeerSelectionStrategy(os.Getenv(peerSelectionEnvVar))
+	if err != nil {
+		setupLog.Error(err, "failed to parse env variable", "env var name", peerSelectionEnvVar)
+		os.Exit(1)
+	}
+
+	myPeers := peers.New(myNodeName, defaultPeerUpdateInterval, mgr.GetClient(), ctrl.Log.WithName("peers"), peerApiServerTimeout, peerSelectionStrategy, peerNodeSelector)
+	nodeInformer, err := mgr.GetCache().GetInformer(context.Background(), &v1.Node{})
+	if err != nil {
+		setupLog.Error(err, "failed to get node informer")
+		os.Exit(1)
+	}
+	myPeers.WatchNodes(nodeInformer)
+	watchPeerUpdateInterval(mgr, ns, myPeers)
 	if err = mgr.Add(myPeers); err != nil {
 		setupLog.Error(err, "failed to add peers to the manager")
 		os.Exit(1)
 	}
 
-	// TODO make the interval and error threshold configurable?
+	// TODO make the error threshold configurable?
 	apiCheckInterval := 15 * time.Second  //the frequency for api-server connectivity check
 	maxErrorThreshold := 3                //after this threshold, the node will start contacting its peers
 	apiServerTimeout := 5 * time.Second   //timeout for each api-connectivity check
 	peerDialTimeout := 5 * time.Second    //timeout for establishing connection to peer
 	peerRequestTimeout := 5 * time.Second //timeout for each peer request
+	certExpiryCheckInterval := 1 * time.Hour
+
+	// zero keeps the defaults. The timeout might exceed the interval, the next check starts after the previous one.
+	if apiCheckIntervalString := os.Getenv(apiCheckIntervalEnvVar); apiCheckIntervalString != "" {
+		apiCheckIntervalInt, err := strconv.Atoi(apiCheckIntervalString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", apiCheckIntervalEnvVar)
+			os.Exit(1)
+		}
+		if apiCheckIntervalInt > 0 {
+			apiCheckInterval = time.Duration(apiCheckIntervalInt) * time.Second
+		}
+	}
+	if apiServerTimeoutString := os.Getenv(apiServerTimeoutEnvVar); apiServerTimeoutString != "" {
+		apiServerTimeoutInt, err := strconv.Atoi(apiServerTimeoutString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", apiServerTimeoutEnvVar)
+			os.Exit(1)
+		}
+		if apiServerTimeoutInt > 0 {
+			apiServerTimeout = time.Duration(apiServerTimeoutInt) * time.Second
+		}
+	}
+
+	var apiCheckStartupGracePeriod time.Duration
+	if gracePeriodString := os.Getenv(apiCheckStartupGraceEnvVar); gracePeriodString != "" {
+		gracePeriodInt, err := strconv.Atoi(gracePeriodString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", apiCheckStartupGraceEnvVar)
+			os.Exit(1)
+		}
+		apiCheckStartupGracePeriod = time.Duration(gracePeriodInt) * time.Second
+	}
+
+	probeKubelet := false
+	if probeKubeletString := os.Getenv(probeKubeletEnvVar); probeKubeletString != "" {
+		if probeKubelet, err = strconv.ParseBool(probeKubeletString); err != nil {
+			setupLog.Error(err, "failed to parse probe kubelet env var", "value", probeKubeletString)
+			os.Exit(1)
+		}
+	}
+
+	peerPort := peerHealthDefaultPort
+	if peerPortString := os.Getenv(peerPortEnvVar); peerPortString != "" {
+		if peerPort, err = strconv.Atoi(peerPortString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", peerPortEnvVar)
+			os.Exit(1)
+		}
+	}
+	peerMinTLSVersion, err := certificates.ParseTLSVersion(os.Getenv(peerMinTLSVersionEnvVar))
+	if err != nil {
+		setupLog.Error(err, "failed to parse env variable", "env var name", peerMinTLSVersionEnvVar)
+		os.Exit(1)
+	}
+	peerCipherSuites, err := certificates.ParseCipherSuites(os.Getenv(peerCipherSuitesEnvVar))
+	if err != nil {
+		setupLog.Error(err, "failed to parse env variable", "env var name", peerCipherSuitesEnvVar)
+		os.Exit(1)
+	}
+	peerClientAuth, err := certificates.ParseClientAuth(os.Getenv(peerClientAuthEnvVar))
+	if err != nil {
+		setupLog.Error(err, "failed to parse env variable", "env var name", peerClientAuthEnvVar)
+		os.Exit(1)
+	}
+	peerTLSOptions := certificates.TLSOptions{
+		MinVersion:   peerMinTLSVersion,
+		CipherSuites: peerCipherSuites,
+		ClientAuth:   peerClientAuth,
+	}
+
+	minPeersForQuorum := 0
+	if minPeersForQuorumString := os.Getenv(minPeersForQuorumEnvVar); minPeersForQuorumString != "" {
+		if minPeersForQuorum, err = strconv.Atoi(minPeersForQuorumString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", minPeersForQuorumEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	fenceOnIndeterminate := false
+	if fenceOnIndeterminateString := os.Getenv(fenceOnIndeterminateEnvVar); fenceOnIndeterminateString != "" {
+		if fenceOnIndeterminate, err = strconv.ParseBool(fenceOnIndeterminateString); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", fenceOnIndeterminateEnvVar)
+			os.Exit(1)
+		}
+	}
+	if fenceOnIndeterminate {
+		setupLog.Info("fencing on indeterminate peer verdicts is enabled, this node reboots without peer confirmation when no quorum can be established")
+	}
+
+	// zero asks all peers
+	peerSampleSize := 0
+	if peerSampleSizeString := os.Getenv(peerSampleSizeEnvVar); peerSampleSizeString != "" {
+		if peerSampleSize, err = strconv.Atoi(peerSampleSizeString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", peerSampleSizeEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	// zero uses the default max clock skew of the api check
+	var maxPeerClockSkew time.Duration
+	if maxPeerClockSkewString := os.Getenv(maxPeerClockSkewEnvVar); maxPeerClockSkewString != "" {
+		maxPeerClockSkewInt, err := strconv.Atoi(maxPeerClockSkewString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", maxPeerClockSkewEnvVar)
+			os.Exit(1)
+		}
+		maxPeerClockSkew = time.Duration(maxPeerClockSkewInt) * time.Second
+	}
+
+	// zero doesn't restrict fencing by the cluster size
+	minClusterSizeForFencing := 0
+	if minClusterSizeString := os.Getenv(minClusterSizeEnvVar); minClusterSizeString != "" {
+		if minClusterSizeForFencing, err = strconv.Atoi(minClusterSizeString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", minClusterSizeEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	apiCheckProbeMode := apicheck.ProbeMode(os.Getenv(apiCheckProbeModeEnvVar))
+	if !apiCheckProbeMode.IsValid() {
+		setupLog.Error(fmt.Errorf("unknown probe mode %s", apiCheckProbeMode), "failed to parse env variable", "env var name", apiCheckProbeModeEnvVar)
+		os.Exit(1)
+	}
+
+	// an empty list checks the endpoint of the in-cluster config
+	var apiServerEndpoints []string
+	if apiServerEndpointsString := os.Getenv(apiServerEndpointsEnvVar); apiServerEndpointsString != "" {
+		apiServerEndpoints = strings.Split(apiServerEndpointsString, ",")
+		setupLog.Info("checking api server endpoints", "endpoints", apiServerEndpoints)
+	}
+
+	// without external endpoints, every api server failure is considered a possible partition
+	var externalProbeEndpoints []string
+	if externalProbeString := os.Getenv(externalProbeEnvVar); externalProbeString != "" {
+		externalProbeEndpoints = strings.Split(externalProbeString, ",")
+		setupLog.Info("probing external endpoints on api server failures", "endpoints", externalProbeEndpoints)
+	}
 
 	// init certificate reader
-	certReader := certificates.NewSecretCertStorage(mgr.GetClient(), ctrl.Log.WithName("SecretCertStorage"), ns)
+	var certReader certificates.CertStorageReader = certificates.NewSecretCertStorage(mgr.GetClient(), ctrl.Log.WithName("SecretCertStorage"), ns)
+	if certFiles := newCertFileStorage(); certFiles != nil {
+		// keep a copy of the certificates on the host, so that peers can be contacted without api server access
+		certReader = certificates.NewFileBackedCertStorage(certReader, certFiles, ctrl.Log.WithName("FileBackedCertStorage"))
+	}
+
+	certExpiryChecker := certificates.NewExpiryChecker(certReader, certExpiryCheckInterval, ctrl.Log.WithName("cert-expiry"))
+	if err = mgr.Add(certExpiryChecker); err != nil {
+		setupLog.Error(err, "failed to add certificate expiry checker to the manager")
+		os.Exit(1)
+	}
+
+	// the peer results which led to a reboot are only recorded when they can be stored on the host
+	var peerResultsStore *peerresults.Store
+	if peerResultsFile := os.Getenv(peerResultsFileEnvVar); peerResultsFile != "" {
+		peerResultsStore = peerresults.NewStore(peerResultsFile, ctrl.Log.WithName("peer-results"))
+	}
+
+	var auditLog *audit.Logger
+	if auditLogFile := os.Getenv(auditLogFileEnvVar); auditLogFile != "" {
+		// the max size is configured in megabytes, zero uses the default size
+		auditLogMaxSize := 0
+		if auditLogMaxSizeString := os.Getenv(auditLogMaxSizeEnvVar); auditLogMaxSizeString != "" {
+			if auditLogMaxSize, err = strconv.Atoi(auditLogMaxSizeString); err != nil {
+				setupLog.Error(err, "failed to parse env variable", "env var name", auditLogMaxSizeEnvVar)
+				os.Exit(1)
+			}
+		}
+		auditLog = audit.NewLogger(auditLogFile, int64(auditLogMaxSize)*1024*1024, ctrl.Log.WithName("audit"))
+		setupLog.Info("recording fencing decisions in the audit log", "path", auditLogFile)
+	}
 
 	apiConnectivityCheckConfig := &apicheck.ApiConnectivityCheckConfig{
-		Log:                ctrl.Log.WithName("api-check"),
-		MyNodeName:         myNodeName,
-		CheckInterval:      apiCheckInterval,
-		MaxErrorsThreshold: maxErrorThreshold,
-		Peers:              myPeers,
-		Rebooter:           rebooter,
-		Cfg:                mgr.GetConfig(),
-		CertReader:         certReader,
-		ApiServerTimeout:   apiServerTimeout,
-		PeerDialTimeout:    peerDialTimeout,
-		PeerRequestTimeout: peerRequestTimeout,
-		PeerHealthPort:     peerHealthDefaultPort,
+		Log:                      ctrl.Log.WithName("api-check"),
+		MyNodeName:               myNodeName,
+		CheckInterval:            apiCheckInterval,
+		MaxErrorsThreshold:       maxErrorThreshold,
+		Peers:                    myPeers,
+		Rebooter:                 rebooter,
+		Watchdog:                 wd,
+		Cfg:                      mgr.GetConfig(),
+		ApiServerEndpoints:       apiServerEndpoints,
+		ExternalProbeEndpoints:   externalProbeEndpoints,
+		ProbeMode:                apiCheckProbeMode,
+		CertReader:               certReader,
+		PeerResults:              peerResultsStore,
+		AuditLog:                 auditLog,
+		NodeReader:               mgr.GetClient(),
+		ApiServerTimeout:         apiServerTimeout,
+		StartupGracePeriod:       apiCheckStartupGracePeriod,
+		WatchdogArmDelay:         watchdogArmDelay,
+		PeerDialTimeout:          peerDialTimeout,
+		PeerRequestTimeout:       peerRequestTimeout,
+		PeerHealthPort:           peerPort,
+		PeerTLSOptions:           peerTLSOptions,
+		MaxPeerClockSkew:         maxPeerClockSkew,
+		MinPeersForQuorum:        minPeersForQuorum,
+		FenceOnIndeterminate:     fenceOnIndeterminate,
+		PeerSampleSize:           peerSampleSize,
+		MinClusterSizeForFencing: minClusterSizeForFencing,
+	}
+	if peerInterface := os.Getenv(peerInterfaceEnvVar); peerInterface != "" {
+		if apiConnectivityCheckConfig.PeerDialer, err = apicheck.NewPeerDialer(peerInterface); err != nil {
+			setupLog.Error(err, "invalid peer network interface", "env var name", peerInterfaceEnvVar)
+			os.Exit(1)
+		}
+		setupLog.Info("connecting to peers through network interface", "interface", peerInterface)
+	}
+	if probeKubelet {
+		apiConnectivityCheckConfig.KubeletHealthzURL = apicheck.DefaultKubeletHealthzURL
+		apiConnectivityCheckConfig.KubeletTransport = apicheck.NewHostNetworkTransport()
 	}
 
 	apiChecker := apicheck.New(apiConnectivityCheckConfig)
@@ -222,6 +718,34 @@ func initPoisonPillAgent(mgr manager.Manager) {
 		os.Exit(1)
 	}
 
+	// the status server is disabled by default
+	if statusBindAddress := os.Getenv(statusBindAddressEnvVar); statusBindAddress != "" {
+		apiFailureSimulation := false
+		if apiFailureSimulationString := os.Getenv(apiFailureSimulationEnvVar); apiFailureSimulationString != "" {
+			if apiFailureSimulation, err = strconv.ParseBool(apiFailureSimulationString); err != nil {
+				setupLog.Error(err, "failed to parse env variable", "env var name", apiFailureSimulationEnvVar)
+				os.Exit(1)
+			}
+		}
+		if apiFailureSimulation {
+			setupLog.Info("api failure simulation enabled, the api server can be treated as unreachable on request from within the pod")
+			if simulationFencingString := os.Getenv(simulationFencingEnvVar); simulationFencingString != "" {
+				if apiConnectivityCheckConfig.SimulatedFailureFencing, err = strconv.ParseBool(simulationFencingString); err != nil {
+					setupLog.Error(err, "failed to parse env variable", "env var name", simulationFencingEnvVar)
+					os.Exit(1)
+				}
+			}
+			if apiConnectivityCheckConfig.SimulatedFailureFencing {
+				setupLog.Info("SIMULATED API FAILURES REBOOT THE NODE when the peers confirm them")
+			}
+		}
+		statusServer := apicheck.NewStatusServer(statusBindAddress, apiChecker, apiFailureSimulation, ctrl.Log.WithName("status-server"))
+		if err = mgr.Add(statusServer); err != nil {
+			setupLog.Error(err, "failed to add status server to the manager")
+			os.Exit(1)
+		}
+	}
+
 	// determine safe reboot time
 	timeToAssumeNodeRebootedInt, err := strconv.Atoi(os.Getenv("TIME_TO_ASSUME_NODE_REBOOTED"))
 	if err != nil {
@@ -232,14 +756,30 @@ func initPoisonPillAgent(mgr manager.Manager) {
 
 	// but the reboot time needs be at least the time we know we need for determining a node issue and trigger the reboot!
 	// 1. time for determing node issue
-	minTimeToAssumeNodeRebooted := (apiCheckInterval + apiServerTimeout) * time.Duration(maxErrorThreshold)
-	// 2. time for asking peers (10% batches + 1st smaller batch)
-	minTimeToAssumeNodeRebooted += (10 + 1) * (peerDialTimeout + peerRequestTimeout)
-	// 3. watchdog timeout
+	minTimeToAssumeNodeRebooted := apicheck.MaxTimeToDetectFailure(apiCheckInterval, apiServerTimeout, maxErrorThreshold)
+	// 2. time for asking peers, the peer requests are cancelled when it's up
+	minTimeToAssumeNodeRebooted += apicheck.MaxTimeToAskPeers(peerDialTimeout, peerRequestTimeout)
+	// 3. watchdog timeout, there is none with external fencing. The watchdog isn't started yet, so this is the
+	// configured timeout clamped to the range of the device, or the default timeout read from the device.
+	var effectiveWatchdogTimeout time.Duration
+	if wd != nil {
+		effectiveWatchdogTimeout = wd.GetTimeout()
+		if effectiveWatchdogTimeout == 0 {
+			// the timeout can't exceed the max timeout of the device
+			_, effectiveWatchdogTimeout = wd.GetTimeoutRange()
+		}
+		if effectiveWatchdogTimeout == 0 {
+			setupLog.Info("WARNING: the watchdog timeout is unknown, it's not included in the time to assume that unhealthy node has been rebooted",
+				"device", wd.Describe())
+		}
+	}
+	minTimeToAssumeNodeRebooted += effectiveWatchdogTimeout
+	// 4. watchdog arm delay, an agent which restarts while its node is partitioned ignores failed checks until the
+	// watchdog is armed
 	if wd != nil {
-		minTimeToAssumeNodeRebooted += wd.GetTimeout()
+		minTimeToAssumeNodeRebooted += watchdogArmDelay
 	}
-	// 4. some buffer
+	// 5. some buffer
 	minTimeToAssumeNodeRebooted += 15 * time.Second
 
 	if timeToAssumeNodeRebooted < minTimeToAssumeNodeRebooted {
@@ -247,13 +787,217 @@ func initPoisonPillAgent(mgr manager.Manager) {
 	}
 	setupLog.Info("Time to assume that unhealthy node has been rebooted", "time", timeToAssumeNodeRebooted)
 
+	// zero disables pod eviction before reboot
+	var gracefulRebootTimeout time.Duration
+	if gracefulRebootTimeoutString := os.Getenv(gracefulRebootTimeoutEnvVar); gracefulRebootTimeoutString != "" {
+		gracefulRebootTimeoutInt, err := strconv.Atoi(gracefulRebootTimeoutString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", gracefulRebootTimeoutEnvVar)
+			os.Exit(1)
+		}
+		gracefulRebootTimeout = time.Duration(gracefulRebootTimeoutInt) * time.Second
+	}
+
+	var deleteDaemonSetPods bool
+	if deleteDaemonSetPodsString := os.Getenv(deleteDaemonSetPodsEnvVar); deleteDaemonSetPodsString != "" {
+		if deleteDaemonSetPods, err = strconv.ParseBool(deleteDaemonSetPodsString); err != nil {
+			setupLog.Error(err, "failed to parse delete daemonset pods env var", "value", deleteDaemonSetPodsString)
+			os.Exit(1)
+		}
+	}
+
+	// zero disables the remediation cooldown
+	var remediationCooldown time.Duration
+	if remediationCooldownString := os.Getenv(remediationCooldownEnvVar); remediationCooldownString != "" {
+		remediationCooldownInt, err := strconv.Atoi(remediationCooldownString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", remediationCooldownEnvVar)
+			os.Exit(1)
+		}
+		remediationCooldown = time.Duration(remediationCooldownInt) * time.Second
+	}
+
+	// zero never gives up on remediations
+	var maxRemediationDuration time.Duration
+	if maxRemediationDurationString := os.Getenv(remediationTimeoutEnvVar); maxRemediationDurationString != "" {
+		maxRemediationDurationInt, err := strconv.Atoi(maxRemediationDurationString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", remediationTimeoutEnvVar)
+			os.Exit(1)
+		}
+		maxRemediationDuration = time.Duration(maxRemediationDurationInt) * time.Second
+	}
+
+	// zero makes restored nodes schedulable right away
+	var nodeReadyGracePeriod time.Duration
+	if nodeReadyGracePeriodString := os.Getenv(nodeReadyGracePeriodEnvVar); nodeReadyGracePeriodString != "" {
+		nodeReadyGracePeriodInt, err := strconv.Atoi(nodeReadyGracePeriodString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", nodeReadyGracePeriodEnvVar)
+			os.Exit(1)
+		}
+		nodeReadyGracePeriod = time.Duration(nodeReadyGracePeriodInt) * time.Second
+	}
+
+	// zero uses the default of a single worker
+	maxConcurrentRemediations := 0
+	if maxConcurrentRemediationsString := os.Getenv(parallelRemediationsEnvVar); maxConcurrentRemediationsString != "" {
+		if maxConcurrentRemediations, err = strconv.Atoi(maxConcurrentRemediationsString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", parallelRemediationsEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "failed to create kubernetes client")
+		os.Exit(1)
+	}
+
+	// remediations which request the out-of-service taint fall back to deleting the pods of older clusters
+	outOfServiceTaintSupported, err := utils.IsOutOfServiceTaintSupported(kubeClient.Discovery())
+	if err != nil {
+		setupLog.Error(err, "failed to check if the out-of-service taint is supported, assuming it isn't")
+	}
+	setupLog.Info("Out-of-service taint support", "supported", outOfServiceTaintSupported)
+
+	annotateMachines := false
+	if annotateMachinesString := os.Getenv(annotateMachinesEnvVar); annotateMachinesString != "" {
+		if annotateMachines, err = strconv.ParseBool(annotateMachinesString); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", annotateMachinesEnvVar)
+			os.Exit(1)
+		}
+	}
+	if annotateMachines {
+		// machines are only annotated when the machine API is installed
+		machineAPIAvailable, err := utils.IsMachineAPIAvailable(kubeClient.Discovery())
+		if err != nil {
+			setupLog.Error(err, "failed to check if the machine API is available, not annotating machines")
+		} else if !machineAPIAvailable {
+			setupLog.Info("machine API not available, not annotating machines")
+		}
+		annotateMachines = machineAPIAvailable
+	}
+
+	promptEtcdMemberRemoval := false
+	if promptEtcdMemberRemovalString := os.Getenv(promptEtcdRemovalEnvVar); promptEtcdMemberRemovalString != "" {
+		if promptEtcdMemberRemoval, err = strconv.ParseBool(promptEtcdMemberRemovalString); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", promptEtcdRemovalEnvVar)
+			os.Exit(1)
+		}
+	}
+	if promptEtcdMemberRemoval {
+		// etcd member removal is only prompted when etcd is managed by the etcd operator
+		etcdOperatorAvailable, err := utils.IsEtcdOperatorAvailable(kubeClient.Discovery())
+		if err != nil {
+			setupLog.Error(err, "failed to check if the etcd operator API is available, not prompting etcd member removal")
+		} else if !etcdOperatorAvailable {
+			setupLog.Info("etcd operator API not available, not prompting etcd member removal")
+		}
+		promptEtcdMemberRemoval = etcdOperatorAvailable
+	}
+
+	forceDeletePods := false
+	if forceDeletePodsString := os.Getenv(forceDeletePodsEnvVar); forceDeletePodsString != "" {
+		if forceDeletePods, err = strconv.ParseBool(forceDeletePodsString); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", forceDeletePodsEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	// zero uses the reconciler's default
+	podDeletionConcurrency := 0
+	if podDeletionConcurrencyString := os.Getenv(podDeleteConcurrencyEnvVar); podDeletionConcurrencyString != "" {
+		if podDeletionConcurrency, err = strconv.Atoi(podDeletionConcurrencyString); err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", podDeleteConcurrencyEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	deleteCriticalPodsLast := false
+	if deleteCriticalPodsLastString := os.Getenv(criticalPodsLastEnvVar); deleteCriticalPodsLastString != "" {
+		if deleteCriticalPodsLast, err = strconv.ParseBool(deleteCriticalPodsLastString); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", criticalPodsLastEnvVar)
+			os.Exit(1)
+		}
+	}
+
+	var gracefulDeletionNamespaces []string
+	if gracefulNamespacesString := os.Getenv(gracefulNamespacesEnvVar); gracefulNamespacesString != "" {
+		gracefulDeletionNamespaces = strings.Split(gracefulNamespacesString, ",")
+	}
+
+	// zero uses the reconciler's default
+	var gracefulDeletionTimeout time.Duration
+	if gracefulDeletionTimeoutString := os.Getenv(gracefulDeleteTimeoutEnvVar); gracefulDeletionTimeoutString != "" {
+		gracefulDeletionTimeoutInt, err := strconv.Atoi(gracefulDeletionTimeoutString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", gracefulDeleteTimeoutEnvVar)
+			os.Exit(1)
+		}
+		gracefulDeletionTimeout = time.Duration(gracefulDeletionTimeoutInt) * time.Second
+	}
+
+	// zero doesn't limit the number of concurrent reboots
+	var rebootBudget *rebootbudget.Budget
+	if maxConcurrentRebootsString := os.Getenv(maxConcurrentRebootsEnvVar); maxConcurrentRebootsString != "" {
+		maxConcurrentReboots, err := strconv.Atoi(maxConcurrentRebootsString)
+		if err != nil {
+			setupLog.Error(err, "failed to convert env variable to int", "env var name", maxConcurrentRebootsEnvVar)
+			os.Exit(1)
+		}
+		if maxConcurrentReboots > 0 {
+			rebootBudget = rebootbudget.New(kubeClient, ns, maxConcurrentReboots, ctrl.Log.WithName("reboot-budget"))
+		}
+	}
+
+	// an empty taint uses the default taint of the reconciler
+	var nodeDeletingTaint v1.Taint
+	if nodeDeletingTaintString := os.Getenv(nodeDeletingTaintEnvVar); nodeDeletingTaintString != "" {
+		if err := json.Unmarshal([]byte(nodeDeletingTaintString), &nodeDeletingTaint); err != nil {
+			setupLog.Error(err, "failed to parse env variable", "env var name", nodeDeletingTaintEnvVar)
+			os.Exit(1)
+		}
+	}
+
 	pprReconciler := &controllers.PoisonPillRemediationReconciler{
 		Client:                       mgr.GetClient(),
+		APIReader:                    mgr.GetAPIReader(),
 		Log:                          ctrl.Log.WithName("controllers").WithName("PoisonPillRemediation"),
 		Scheme:                       mgr.GetScheme(),
 		Rebooter:                     rebooter,
+		Recorder:                     mgr.GetEventRecorderFor("poison-pill"),
 		SafeTimeToAssumeNodeRebooted: timeToAssumeNodeRebooted,
+		CheckInterval:                apiCheckInterval,
+		MaxErrorsThreshold:           maxErrorThreshold,
+		WatchdogTimeout:              effectiveWatchdogTimeout,
 		MyNodeName:                   myNodeName,
+		DryRun:                       dryRun,
+		ExternalFencing:              externalFencing,
+		GracefulRebootTimeout:        gracefulRebootTimeout,
+		DeleteDaemonSetPods:          deleteDaemonSetPods,
+		KubeClient:                   kubeClient,
+		NodeDeletingTaint:            nodeDeletingTaint,
+		FencedNodeLabelKey:           os.Getenv(fencedNodeLabelKeyEnvVar),
+		FencedNodeLabelValue:         os.Getenv(fencedNodeLabelValueEnvVar),
+		RemediationCooldown:          remediationCooldown,
+		MaxRemediationDuration:       maxRemediationDuration,
+		MaxConcurrentReconciles:      maxConcurrentRemediations,
+		RebootBudget:                 rebootBudget,
+		PeerResults:                  peerResultsStore,
+		AuditLog:                     auditLog,
+		NodeReadyGracePeriod:         nodeReadyGracePeriod,
+		OutOfServiceTaintSupported:   outOfServiceTaintSupported,
+		AnnotateMachines:             annotateMachines,
+		PromptEtcdMemberRemoval:      promptEtcdMemberRemoval,
+		ForceDeletePods:              forceDeletePods,
+		PodDeletionConcurrency:       podDeletionConcurrency,
+		DeleteCriticalPodsLast:       deleteCriticalPodsLast,
+		GracefulDeletionNamespaces:   gracefulDeletionNamespaces,
+		GracefulDeletionTimeout:      gracefulDeletionTimeout,
+		MyPodName:                    os.Getenv(podNameEnvVar),
+		Version:                      version.Version,
+		OperatorVersion:              os.Getenv(operatorVersionEnvVar),
 	}
 
 	if err = pprReconciler.SetupWithManager(mgr); err != nil {
@@ -262,8 +1006,7 @@ func initPoisonPillAgent(mgr manager.Manager) {
 	}
 
 	setupLog.Info("init grpc server")
-	// TODO make port configurable?
-	server, err := peerhealth.NewServer(pprReconciler, mgr.GetConfig(), ctrl.Log.WithName("peerhealth").WithName("server"), peerHealthDefaultPort, certReader)
+	server, err := peerhealth.NewServer(pprReconciler, mgr.GetConfig(), ctrl.Log.WithName("peerhealth").WithName("server"), peerPort, peerTLSOptions, certReader)
 	if err != nil {
 		setupLog.Error(err, "failed to init grpc server")
 		os.Exit(1)
@@ -272,20 +1015,197 @@ func initPoisonPillAgent(mgr manager.Manager) {
 		setupLog.Error(err, "failed to add grpc server to the manager")
 		os.Exit(1)
 	}
+
+	selfTest := peerhealth.NewSelfTest(certReader, peerTLSOptions, peerPort, myNodeName, ctrl.Log.WithName("peerhealth").WithName("self-test"))
+	if err = mgr.Add(selfTest); err != nil {
+		setupLog.Error(err, "failed to add peer TLS self test to the manager")
+		os.Exit(1)
+	}
+	if err = mgr.AddReadyzCheck("peer-tls", selfTest.Check); err != nil {
+		setupLog.Error(err, "unable to set up peer TLS ready check")
+		os.Exit(1)
+	}
+	// the readyz endpoint lists every failed check by its name
+	if err = mgr.AddReadyzCheck("certificates", certificates.ReadyzCheck(certReader)); err != nil {
+		setupLog.Error(err, "unable to set up certificates ready check")
+		os.Exit(1)
+	}
+	if err = mgr.AddReadyzCheck("node-name", utils.NodeReadyzCheck(mgr.GetClient(), myNodeName)); err != nil {
+		setupLog.Error(err, "unable to set up node name ready check")
+		os.Exit(1)
+	}
 }
 
-// newConfigIfNotExist creates a new PoisonPillConfig object
-// to initialize the rest of the deployment objects creation.
-func newConfigIfNotExist(c client.Client) error {
-	ns, err := getDeploymentNamespace()
+// checkWatchdog checks if the watchdog device can be armed and disarmed, prints the result and returns the exit code
+func checkWatchdog(args []string) int {
+	flags := flag.NewFlagSet(checkWatchdogCommand, flag.ExitOnError)
+	var path string
+	var timeoutSeconds int
+	flags.StringVar(&path, "path", os.Getenv(watchdogPathEnvVar),
+		"The watchdog device to check, defaults to the "+watchdogPathEnvVar+" env var. When empty the device is auto detected.")
+	flags.IntVar(&timeoutSeconds, "timeout", 0,
+		"The watchdog timeout in seconds to arm the device with, defaults to the "+watchdogTimeoutEnvVar+" env var. 0 keeps the default timeout of the device.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flags)
+	_ = flags.Parse(args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if timeoutSeconds == 0 {
+		if timeoutString := os.Getenv(watchdogTimeoutEnvVar); timeoutString != "" {
+			var err error
+			if timeoutSeconds, err = strconv.Atoi(timeoutString); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid %s env var %q: %v\n", watchdogTimeoutEnvVar, timeoutString, err)
+				return 1
+			}
+		}
+	}
+
+	result, err := watchdog.Check(ctrl.Log.WithName("watchdog"), path, time.Duration(timeoutSeconds)*time.Second)
+	if result != nil {
+		fmt.Printf("watchdog device: %s\n", result.Path)
+		if result.Identity != "" {
+			fmt.Printf("identity: %s\n", result.Identity)
+		}
+		if result.MinTimeout > 0 || result.MaxTimeout > 0 {
+			fmt.Printf("supported timeout range: %v - %v\n", result.MinTimeout, result.MaxTimeout)
+		} else {
+			fmt.Println("supported timeout range: unknown")
+		}
+		if result.Timeout > 0 {
+			fmt.Printf("armed timeout: %v\n", result.Timeout)
+		}
+		if result.ArmSkipped {
+			fmt.Println("arming skipped: the driver uses nowayout, the device can't be disarmed after arming it")
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "watchdog check failed: %v\n", err)
+		return 1
+	}
+	if result.Armed {
+		fmt.Println("arming succeeded, watchdog disarmed")
+	}
+	return 0
+}
+
+// addWatchdogReadyzCheck marks the agent as not ready until the watchdog is armed. When the configured watchdog device
+// can't be used, the agent keeps running with software reboots, but the misconfiguration is visible in the pod status.
+// Without a configured device and without a detected one, software reboots are expected, and there is no check.
+func addWatchdogReadyzCheck(mgr manager.Manager, wd watchdog.Watchdog, watchdogErr error) {
+	var check healthz.Checker
+	if wd != nil {
+		check = watchdog.ReadyzCheck(wd)
+	} else if watchdogErr != nil {
+		check = func(_ *http.Request) error { return watchdogErr }
+	} else {
+		return
+	}
+	if err := mgr.AddReadyzCheck("watchdog", check); err != nil {
+		setupLog.Error(err, "unable to set up watchdog ready check")
+		os.Exit(1)
+	}
+}
+
+// watchPeerUpdateInterval passes the peer update interval of the config to the running peers. Unlike the other
+// settings it isn't passed by env var, so that changing it doesn't restart the agents.
+func watchPeerUpdateInterval(mgr manager.Manager, ns string, myPeers *peers.Peers) {
+	configName := os.Getenv(configNameEnvVar)
+	if configName == "" {
+		setupLog.Info("config name unknown, using the default peer update interval", "env var name", configNameEnvVar, "interval", defaultPeerUpdateInterval)
+		return
+	}
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &poisonpillv1alpha1.PoisonPillConfig{})
 	if err != nil {
-		return errors.Wrap(err, "unable to get the deployment namespace")
+		setupLog.Error(err, "failed to get config informer")
+		os.Exit(1)
+	}
+	setInterval := func(obj interface{}) {
+		ppc, ok := obj.(*poisonpillv1alpha1.PoisonPillConfig)
+		if !ok || ppc.Namespace != ns || ppc.Name != configName {
+			return
+		}
+		// an empty or zero interval keeps the default interval
+		interval := defaultPeerUpdateInterval
+		if ppc.Spec.PeerUpdateIntervalSeconds > 0 {
+			interval = time.Duration(ppc.Spec.PeerUpdateIntervalSeconds) * time.Second
+		}
+		myPeers.SetUpdateInterval(interval)
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: setInterval,
+		UpdateFunc: func(_, newObj interface{}) {
+			setInterval(newObj)
+		},
+	})
+}
+
+// reportInvalidWatchdogTimeout sets the WatchdogTimeoutValid condition of the PoisonPillConfig to false
+// as soon as the manager is started
+func reportInvalidWatchdogTimeout(mgr manager.Manager, ns string, nodeName string, configured time.Duration, maxTimeout time.Duration) {
+	configName := os.Getenv(configNameEnvVar)
+	if configName == "" {
+		setupLog.Info("config name unknown, can't report invalid watchdog timeout", "env var name", configNameEnvVar)
+		return
 	}
 
+	report := manager.RunnableFunc(func(ctx context.Context) error {
+		err := utils.RetryOnConflict(ctx, utils.ConflictBackoff, func(_ bool) error {
+			// read from the api server, so that retries after conflicts don't get the same outdated config from the cache
+			ppc := &poisonpillv1alpha1.PoisonPillConfig{}
+			if err := mgr.GetAPIReader().Get(ctx, client.ObjectKey{Namespace: ns, Name: configName}, ppc); err != nil {
+				return err
+			}
+			meta.SetStatusCondition(&ppc.Status.Conditions, metav1.Condition{
+				Type:   poisonpillv1alpha1.WatchdogTimeoutValidConditionType,
+				Status: metav1.ConditionFalse,
+				Reason: poisonpillv1alpha1.WatchdogTimeoutExceedsMaximumReason,
+				Message: fmt.Sprintf("watchdog timeout %v exceeds the maximum %v of the watchdog device on node %s",
+					configured, maxTimeout, nodeName),
+				ObservedGeneration: ppc.Generation,
+			})
+			return mgr.GetClient().Status().Update(ctx, ppc)
+		})
+		if err != nil {
+			// not critical, the watchdog is used with its max timeout
+			setupLog.Error(err, "failed to report invalid watchdog timeout")
+		}
+		return nil
+	})
+	if err := mgr.Add(report); err != nil {
+		setupLog.Error(err, "failed to add watchdog timeout reporter to the manager")
+	}
+}
+
+// reportLostWatchdog records a warning event on the node when its watchdog device is lost at runtime, so that the
+// admin knows that the watchdog can't reboot the node anymore
+func reportLostWatchdog(mgr manager.Manager, wd watchdog.Watchdog, nodeName string) {
+	recorder := mgr.GetEventRecorderFor("poison-pill")
+	// like the kubelet, refer to the node by its name, its UID isn't needed for the event
+	nodeRef := &v1.ObjectReference{Kind: "Node", Name: nodeName, UID: types.UID(nodeName)}
+	wd.OnDeviceLost(func(err error) {
+		recorder.Eventf(nodeRef, v1.EventTypeWarning, "WatchdogDeviceLost",
+			"Watchdog device %s was lost and couldn't be reopened, the node can't be fenced by its watchdog anymore: %v", wd.Describe(), err)
+	})
+}
+
+// newCertFileStorage returns nil when no certificates directory is configured
+func newCertFileStorage() *certificates.FileCertStorage {
+	certsDir := os.Getenv(certsDirEnvVar)
+	if certsDir == "" {
+		return nil
+	}
+	return certificates.NewFileCertStorage(certsDir, ctrl.Log.WithName("FileCertStorage"))
+}
+
+// newConfigIfNotExist creates a new PoisonPillConfig object
+// to initialize the rest of the deployment objects creation.
+func newConfigIfNotExist(c client.Client, ns string) error {
 	config := poisonpillv1alpha1.NewDefaultPoisonPillConfig()
 	config.SetNamespace(ns)
 
-	err = c.Create(context.Background(), &config, &client.CreateOptions{})
+	err := c.Create(context.Background(), &config, &client.CreateOptions{})
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return errors.Wrap(err, "failed to create a default poison pill config CR")
 	}
@@ -293,32 +1213,25 @@ func newConfigIfNotExist(c client.Client) error {
 }
 
 // newDefaultTemplateIfNotExist creates a new PoisonPillRemediationTemplate object
-func newDefaultTemplateIfNotExist(c client.Client) error {
-	ns, err := getDeploymentNamespace()
-	if err != nil {
-		return errors.Wrap(err, "unable to get the deployment namespace")
-	}
-
+func newDefaultTemplateIfNotExist(c client.Client, ns string) error {
 	pprt := poisonpillv1alpha1.NewDefaultRemediationTemplate()
 	pprt.SetNamespace(ns)
 
-	err = c.Create(context.Background(), &pprt, &client.CreateOptions{})
+	err := c.Create(context.Background(), &pprt, &client.CreateOptions{})
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return errors.Wrap(err, "failed to create a default poison pill template CR")
 	}
 	return nil
 }
 
-// getDeploymentNamespace returns the Namespace this operator is deployed on.
-func getDeploymentNamespace() (string, error) {
-	// deployNamespaceEnvVar is the constant for env variable DEPLOYMENT_NAMESPACE
-	// which specifies the Namespace to watch.
-	// An empty value means the operator is running with cluster scope.
-	var deployNamespaceEnvVar = "DEPLOYMENT_NAMESPACE"
-
-	ns, found := os.LookupEnv(deployNamespaceEnvVar)
-	if !found {
-		return "", fmt.Errorf("%s must be set", deployNamespaceEnvVar)
+// getOperatorNamespace returns the Namespace this operator is deployed on, as set by the downward API. The
+// DEPLOYMENT_NAMESPACE env var is still supported for deployments which don't set POD_NAMESPACE yet.
+func getOperatorNamespace() (string, error) {
+	if ns := os.Getenv(podNamespaceEnvVar); ns != "" {
+		return ns, nil
+	}
+	if ns := os.Getenv(deploymentNamespaceEnvVar); ns != "" {
+		return ns, nil
 	}
-	return ns, nil
+	return "", fmt.Errorf("%s must be set", podNamespaceEnvVar)
 }
@@ -2,33 +2,91 @@ package apicheck
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-logr/logr"
-	"google.golang.org/grpc/credentials"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	poisonPill "github.com/medik8s/poison-pill/api"
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/audit"
 	"github.com/medik8s/poison-pill/pkg/certificates"
 	"github.com/medik8s/poison-pill/pkg/peerhealth"
+	"github.com/medik8s/poison-pill/pkg/peerresults"
 	"github.com/medik8s/poison-pill/pkg/peers"
 	"github.com/medik8s/poison-pill/pkg/reboot"
+	"github.com/medik8s/poison-pill/pkg/utils"
+	"github.com/medik8s/poison-pill/pkg/watchdog"
+)
+
+const (
+	// maxConcurrentPeerRequests is the max number of peers which are asked for our health status at the same time
+	maxConcurrentPeerRequests = 10
+	// maxBackoffExponent limits the exponential backoff of the check interval to 2^maxBackoffExponent
+	maxBackoffExponent = 2
+	maxBackoffFactor   = 1 << maxBackoffExponent
+	// backoffJitter is the max jitter factor added to backed off check intervals
+	backoffJitter = 0.2
+	// DefaultMaxPeerClockSkew is the default max deviation of the clock of a peer from the clock of this node
+	DefaultMaxPeerClockSkew = 30 * time.Second
 )
 
 type ApiConnectivityCheck struct {
 	client.Reader
-	config      *ApiConnectivityCheckConfig
-	errorCount  int
-	clientCreds credentials.TransportCredentials
-	mutex       sync.Mutex
+	config     *ApiConnectivityCheckConfig
+	errorCount int
+	// the number of peers asked in the last round of peer requests
+	peersQueried int
+	// the number of peers which refused all connections in the current round of peer requests
+	peersRefused int32
+	// the responses of the peers in the last round of peer requests
+	peerResults []v1alpha1.PeerResult
+	// the peer which took the longest to respond in the last round of peer requests, and its response time
+	slowestPeer        string
+	slowestPeerLatency time.Duration
+	// if the remediation of this node was disabled by annotation when its node was read successfully the last time
+	remediationDisabled bool
+	// remediationReported is set when a peer reported that this node is unhealthy since the api server was reachable
+	// the last time, which means that a remediation of this node exists
+	remediationReported bool
+	// startupGracePeriodEnd is the end of the StartupGracePeriod, it's reset by the first successful check
+	startupGracePeriodEnd time.Time
+	// armDelayEnd is the end of the WatchdogArmDelay
+	armDelayEnd time.Time
+	// kubeletFailure is the failure of the last kubelet probe, empty when the kubelet is healthy
+	kubeletFailure string
+	kubeletProbed  bool
+	// simulatedFailureEnd is the end of a simulated api server failure as unix nanoseconds, zero when no failure is
+	// simulated
+	simulatedFailureEnd int64
+	// simulatedFencing is set when the node entered the fencing phase because of a simulated api server failure,
+	// without rebooting
+	simulatedFencing bool
+	status           Status
+	statusMutex      sync.Mutex
+
+	// lastPeerResults are the last responses of every peer which was asked since the start, by node name, they are
+	// guarded by the statusMutex
+	lastPeerResults map[string]v1alpha1.PeerResult
+	// lastPeerClockSkews are the last measured clock skews of every peer, by node name, they are guarded by the
+	// statusMutex
+	lastPeerClockSkews map[string]time.Duration
 }
 
 type ApiConnectivityCheckConfig struct {
@@ -37,74 +95,478 @@ type ApiConnectivityCheckConfig struct {
 	CheckInterval      time.Duration
 	MaxErrorsThreshold int
 	Peers              *peers.Peers
-	Rebooter           reboot.Rebooter
-	Cfg                *rest.Config
-	CertReader         certificates.CertStorageReader
-	ApiServerTimeout   time.Duration
+	// Rebooter is used for rebooting the node when it is considered unhealthy, e.g. a WatchdogRebooter
+	// or, less safe, a SoftwareRebooter
+	Rebooter reboot.Rebooter
+	// Watchdog is the watchdog used by the Rebooter, if any. When it is started but its self test fails, the node
+	// doesn't fence itself, because the other nodes would wrongly assume that it was rebooted.
+	Watchdog watchdog.Watchdog
+	Cfg      *rest.Config
+	// ApiServerEndpoints are the hosts of the api servers which should be checked, e.g. https://10.0.0.1:6443
+	// When empty, the host of Cfg is used. The api server is considered to be reachable when any endpoint responds.
+	ApiServerEndpoints []string
+	// ExternalProbeEndpoints are known good endpoints outside of the control plane, e.g. a cluster ingress VIP, as
+	// host:port or URL. They are probed with a TCP connect when the api server isn't reachable. When any of them is
+	// reachable, this node isn't partitioned from the network and the error is assumed to be an api server side
+	// problem, so the node doesn't fence itself. Optional.
+	ExternalProbeEndpoints []string
+	// ProbeMode defines how the api server endpoints are probed, defaults to ProbeModeHTTPGet
+	ProbeMode ProbeMode
+	// Transport is used for the requests to the api server endpoints instead of a transport built from the TLS
+	// settings of Cfg, e.g. for connecting through a proxy. When it is an *http.Transport, its TLS config is used for
+	// TLS handshake probes as well. Optional.
+	Transport  http.RoundTripper
+	CertReader certificates.CertStorageReader
+	// ApiServerTimeout is the max time of a single check of the api server endpoints, and of other api calls. It's
+	// independent of the CheckInterval, a probe which times out is cancelled and counted as a single error.
+	ApiServerTimeout time.Duration
+	// StartupGracePeriod is the time after the start of the check in which failed checks don't count towards the
+	// MaxErrorsThreshold, because the api server connection might not be established yet, e.g. right after a reboot.
+	// The grace period ends with the first successful check. Zero disables the grace period.
+	StartupGracePeriod time.Duration
+	// WatchdogArmDelay is the time after the start of the check in which the watchdog isn't armed yet. Failed checks
+	// don't count towards the MaxErrorsThreshold within it, like in the StartupGracePeriod, but it doesn't end with a
+	// successful check. Zero doesn't delay.
+	WatchdogArmDelay time.Duration
+	// KubeletHealthzURL is the healthz endpoint of the local kubelet, e.g. DefaultKubeletHealthzURL. When it's set,
+	// the kubelet is probed on every check. When the api server isn't reachable and the kubelet is unhealthy as well,
+	// the node itself is sick, so it fences itself without asking its peers when the MaxErrorsThreshold is reached.
+	// Optional.
+	KubeletHealthzURL string
+	// KubeletTransport is used for the kubelet probe, e.g. a transport from NewHostNetworkTransport. Optional.
+	KubeletTransport   http.RoundTripper
 	PeerDialTimeout    time.Duration
 	PeerRequestTimeout time.Duration
 	PeerHealthPort     int
+	PeerTLSOptions     certificates.TLSOptions
+	// PeerDialer connects to the peers, e.g. a dialer from NewPeerDialer. Optional, by default the peers are
+	// connected through the network of the pod.
+	PeerDialer peerhealth.Dialer
+	// MaxPeerClockSkew is the max deviation of the clock of a peer from the clock of this node. The healthy and api
+	// error responses of peers whose clocks deviate more, minus the uncertainty of the measurement, depend on the
+	// freshness of the remediations, so they aren't trusted and don't count towards the majority of api errors. An
+	// unhealthy response is still trusted, since the remediation of this node exists regardless of the clocks. Since
+	// these peers are reachable, this node isn't isolated, so the verdict is indeterminate when no peer reported that
+	// it's unhealthy. Zero uses DefaultMaxPeerClockSkew.
+	MaxPeerClockSkew time.Duration
+	// MinPeersForQuorum is the minimum number of peers which need to be asked without a conclusive response before
+	// this node considers itself isolated and reboots itself. When less peers are asked, the verdict is indeterminate,
+	// so the node does not reboot unless FenceOnIndeterminate is set. Be aware that this means that the node might not
+	// reboot while the other nodes already assume that it was rebooted! On single node clusters the node never
+	// considers itself isolated because it has no peers, and on two node clusters a value above 1 has the same effect.
+	// The first unhealthy response always triggers a reboot, since the remediation of the node exists.
+	// Zero keeps the default behaviour: no response at all triggers a reboot.
+	MinPeersForQuorum int
+	// FenceOnIndeterminate makes the node fence itself when its peers can't establish a quorum, because less than
+	// MinPeersForQuorum peers could be asked, or because there are no peers to ask. This is risky: the node reboots
+	// although nobody confirmed that it's unhealthy. By default the node is considered healthy in these cases.
+	// Authentication failures of the peers indicate a certificate problem and not a partition, so they make the
+	// verdict indeterminate as well, unless another peer reported that this node is unhealthy.
+	FenceOnIndeterminate bool
+	// MinClusterSizeForFencing is the minimum number of nodes in the cluster, including this node, for this node to
+	// reboot itself. In smaller clusters a peer quorum isn't meaningful, so the node doesn't fence itself, unless a
+	// peer reported a remediation of this node, since the other nodes assume that it reboots then. The cluster size
+	// is taken from the last peer updates on every decision, so it follows nodes joining and leaving.
+	// Zero doesn't restrict fencing.
+	MinClusterSizeForFencing int
+	// PeerSampleSize limits the number of peers which are asked, instead of asking all peers until their responses
+	// are conclusive. The sample is random, and the majority of api server errors refers to the sample. It's at least
+	// MinPeersForQuorum. Zero asks all peers.
+	PeerSampleSize int
+	// PeerResults is used for persisting the peer responses which led to a reboot, optional
+	PeerResults *peerresults.Store
+	// SimulatedFailureFencing makes the node reboot when its peers confirm a simulated api server failure. By default
+	// the reboot is only logged, so that simulations can't reboot the node.
+	SimulatedFailureFencing bool
+	// AuditLog is used for recording the fencing decisions of this node on the host, optional
+	AuditLog *audit.Logger
+	// NodeReader is used for reading this node when checking if its remediation is disabled by annotation. It should
+	// be a cached reader, so that the last known node is available when the api server isn't reachable. Optional.
+	NodeReader client.Reader
+}
+
+// peerResponse is the health status reported by the peer with the given IPs
+type peerResponse struct {
+	ips  []string
+	code poisonPill.HealthCheckResponseCode
+
+	// latency is the time the peer took to respond, including the tries of its other IPs
+	latency time.Duration
+	// clockSkew is the deviation of the clock of the peer from our clock, nil when it wasn't measured
+	clockSkew *time.Duration
 }
 
 func New(config *ApiConnectivityCheckConfig) *ApiConnectivityCheck {
+	maxErrorsThreshold.Set(float64(config.MaxErrorsThreshold))
 	return &ApiConnectivityCheck{
-		config: config,
-		mutex:  sync.Mutex{},
+		Reader:      config.NodeReader,
+		config:      config,
+		status:      Status{Phase: PhaseHealthy},
+		statusMutex: sync.Mutex{},
 	}
 }
 
 func (c *ApiConnectivityCheck) Start(ctx context.Context) error {
 
-	cs, err := clientset.NewForConfig(c.config.Cfg)
+	endpoints, err := c.createApiServerEndpoints()
+	if err != nil {
+		return err
+	}
+	externalEndpoints, err := c.createExternalEndpoints()
 	if err != nil {
 		return err
 	}
-	restClient := cs.RESTClient()
 
-	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+	c.startupGracePeriodEnd = time.Now().Add(c.config.StartupGracePeriod)
+	c.armDelayEnd = time.Now().Add(c.config.WatchdogArmDelay)
+	go func() {
+		for {
+			c.check(ctx, endpoints, externalEndpoints)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoffInterval(c.config.CheckInterval, c.errorCount)):
+			}
+		}
+	}()
 
-		readerCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
-		defer cancel()
+	c.config.Log.Info("api connectivity check started")
 
-		result := restClient.Verb(http.MethodGet).RequestURI("/readyz").Do(readerCtx)
-		failure := ""
-		if result.Error() != nil {
-			failure = fmt.Sprintf("api server readyz endpoint error: %v", result.Error())
-		} else {
-			statusCode := 0
-			result.StatusCode(&statusCode)
-			if statusCode != 200 {
-				failure = fmt.Sprintf("api server readyz endpoint status code: %v", statusCode)
-			}
+	<-ctx.Done()
+	return nil
+}
+
+// check checks the api server connectivity and handles errors
+func (c *ApiConnectivityCheck) check(ctx context.Context, endpoints []apiServerEndpoint, externalEndpoints []apiServerEndpoint) {
+	c.probeKubelet(ctx)
+	failure := simulatedFailure
+	if !c.isFailureSimulated() {
+		failure = c.checkApiServerEndpoints(ctx, endpoints)
+	}
+	if failure != "" {
+		err := fmt.Errorf(failure)
+		c.config.Log.Error(err, "failed to check api server")
+		if time.Now().Before(c.startupGracePeriodEnd) {
+			c.setStatus(failure, PhaseSuspect)
+			c.config.Log.Info("ignoring api server error during startup grace period", "grace period end", c.startupGracePeriodEnd)
+			return
+		}
+		if time.Now().Before(c.armDelayEnd) {
+			c.setStatus(failure, PhaseSuspect)
+			c.config.Log.Info("ignoring api server error before the watchdog is armed", "arm delay end", c.armDelayEnd)
+			return
+		}
+		if reachable := c.reachableExternalEndpoints(ctx, externalEndpoints); len(reachable) > 0 {
+			c.setStatus(failure, PhaseSuspect)
+			c.config.Log.Info("api server isn't reachable, but external endpoints are, assuming an api server side problem and not a node partition",
+				"reachable external endpoints", reachable)
+			return
 		}
-		if failure != "" {
-			c.config.Log.Error(fmt.Errorf(failure), "failed to check api server")
-			if isHealthy := c.handleError(); !isHealthy {
-				// we have a problem on this node
-				c.config.Log.Error(err, "we are unhealthy, triggering a reboot")
-				if err := c.config.Rebooter.Reboot(); err != nil {
-					c.config.Log.Error(err, "failed to trigger reboot")
+		if isHealthy := c.handleError(ctx); !isHealthy {
+			// we have a problem on this node
+			if wd := c.config.Watchdog; wd != nil && wd.IsStarted() && !wd.IsArmed() {
+				c.setStatus(failure, PhaseSuspect)
+				c.config.Log.Error(err, "WATCHDOG IS NOT ARMED, REFUSING TO FENCE THIS NODE! We are unhealthy, but a reboot can't be guaranteed",
+					"watchdog", wd.Describe())
+				return
+			}
+			if c.isRemediationDisabled(ctx) {
+				c.setStatus(failure, PhaseDeferred)
+				c.config.Log.Error(err, "we are unhealthy, but remediation of this node is disabled, skipping reboot",
+					"annotation", utils.DisabledAnnotation)
+				return
+			}
+			if clusterSize := c.config.Peers.GetClusterSize(); clusterSize < c.config.MinClusterSizeForFencing {
+				if !c.remediationReported {
+					c.setStatus(failure, PhaseDeferred)
+					c.config.Log.Error(err, "we are unhealthy, but the cluster is too small for safe remediation, skipping reboot",
+						"cluster size", clusterSize, "min cluster size for fencing", c.config.MinClusterSizeForFencing)
+					return
 				}
-			} else {
-				c.config.Log.Error(err, "peers did not confirm that we are unhealthy, ignoring error")
+				// the other nodes assume that we reboot once our remediation exists, not fencing would be a split brain
+				c.config.Log.Info("the cluster is too small for safe remediation, but a peer reported a remediation of this node, fencing anyway",
+					"cluster size", clusterSize, "min cluster size for fencing", c.config.MinClusterSizeForFencing)
+			}
+			c.setStatus(failure, PhaseFencing)
+			c.config.Log.Error(err, "we are unhealthy, triggering a reboot")
+			c.savePeerResults()
+			c.recordFencingDecision(audit.OutcomeFenced, failure)
+			if err := c.reboot(); err != nil {
+				c.config.Log.Error(err, "failed to trigger reboot")
 			}
+		} else {
+			c.setStatus(failure, PhaseSuspect)
+			c.config.Log.Error(err, "peers did not confirm that we are unhealthy, ignoring error")
+		}
+		return
+	}
+
+	if c.GetStatus().Phase == PhaseFencing {
+		c.abortFencing()
+	}
+	if c.GetStatus().Phase != PhaseFencing {
+		c.remediationReported = false
+	}
+
+	// reset error count after a successful API call
+	c.errorCount = 0
+	c.startupGracePeriodEnd = time.Time{}
+	c.peersQueried = 0
+	c.setStatus("", PhaseHealthy)
+}
+
+// reboot triggers the reboot of this node, which can be aborted by abortFencing when the rebooter supports it
+func (c *ApiConnectivityCheck) reboot() error {
+	if c.isFailureSimulated() && !c.config.SimulatedFailureFencing {
+		c.simulatedFencing = true
+		return reboot.NewDryRunRebooter(c.config.Log.WithName("simulation")).Reboot()
+	}
+	if rebooter, ok := c.config.Rebooter.(reboot.AbortableRebooter); ok {
+		return rebooter.RebootAbortable()
+	}
+	return c.config.Rebooter.Reboot()
+}
+
+// abortFencing aborts the reboot of this node, when the api server became reachable again before the watchdog fired.
+// That's only done when no peer reported that this node is unhealthy. Otherwise a remediation of this node exists,
+// and the other nodes assume that it rebooted once its TimeAssumedRebooted is reached, which might happen before
+// the reconciler of this node sees the remediation and reboots the node. When the rebooter doesn't reboot the node,
+// e.g. in dry run mode, there is nothing to abort, and the fencing phase is left right away.
+func (c *ApiConnectivityCheck) abortFencing() {
+	reason := "api server became reachable again before the watchdog fired"
+	if c.simulatedFencing {
+		c.simulatedFencing = false
+		reason = "api server failure simulation ended, the node wasn't rebooted"
+		c.config.Log.Info("The api server failure simulation ended, leaving the fencing phase without reboot")
+	} else if !isRebootPending(c.config.Rebooter) {
+		reason = "api server became reachable again, the rebooter doesn't reboot the node"
+		c.config.Log.Info("api server is reachable again and no reboot is pending, leaving the fencing phase")
+	} else {
+		if c.remediationReported {
+			c.config.Log.Info("api server is reachable again, but peers reported a remediation of this node, not aborting the reboot")
+			return
+		}
+		rebooter, ok := c.config.Rebooter.(reboot.AbortableRebooter)
+		if !ok || !rebooter.AbortReboot() {
+			c.config.Log.Info("api server is reachable again, but the reboot can't be aborted")
 			return
 		}
+		c.config.Log.Info("FENCING NARROWLY AVERTED! The api server became reachable again before the watchdog fired, the reboot was aborted")
+		fencingAborted.Inc()
+	}
+	c.recordFencingDecision(audit.OutcomeAborted, reason)
+	if c.config.PeerResults != nil {
+		if err := c.config.PeerResults.Remove(); err != nil {
+			c.config.Log.Error(err, "failed to remove the peer results of the aborted reboot")
+		}
+	}
+	// the only way out of the fencing phase
+	c.statusMutex.Lock()
+	c.status.Phase = PhaseSuspect
+	c.statusMutex.Unlock()
+}
 
-		// reset error count after a successful API call
-		c.errorCount = 0
+// isRebootPending returns if the given rebooter reboots the node on a reboot request. The dry run and external
+// fencing rebooters only log the request, so there is nothing to wait for.
+func isRebootPending(rebooter reboot.Rebooter) bool {
+	switch rebooter.(type) {
+	case *reboot.DryRunRebooter, *reboot.ExternalFencingRebooter:
+		return false
+	}
+	return true
+}
 
-	}, c.config.CheckInterval)
+// isRemediationDisabled returns if this node is annotated with the DisabledAnnotation. When the node can't be read,
+// the last known value is used.
+func (c *ApiConnectivityCheck) isRemediationDisabled(ctx context.Context) bool {
+	if c.Reader == nil {
+		return false
+	}
+	readCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
+	defer cancel()
+	node := &v1.Node{}
+	if err := c.Get(readCtx, client.ObjectKey{Name: c.config.MyNodeName}, node); err != nil {
+		c.config.Log.Error(err, "failed to get node, using last known value of the disabled annotation",
+			"disabled", c.remediationDisabled)
+		return c.remediationDisabled
+	}
+	c.remediationDisabled = utils.IsRemediationDisabled(node)
+	return c.remediationDisabled
+}
 
-	c.config.Log.Info("api connectivity check started")
+// backoffInterval returns the interval until the next check. With consecutive errors the interval grows
+// exponentially, up to maxBackoffFactor times the check interval, and gets some jitter in order to prevent
+// all nodes from probing the api server at the same time.
+func backoffInterval(checkInterval time.Duration, errorCount int) time.Duration {
+	if errorCount == 0 {
+		return checkInterval
+	}
+	return wait.Jitter(checkInterval*time.Duration(backoffFactor(errorCount)), backoffJitter)
+}
 
-	<-ctx.Done()
-	return nil
+func backoffFactor(errorCount int) int {
+	if errorCount < maxBackoffExponent {
+		return 1 << errorCount
+	}
+	return maxBackoffFactor
+}
+
+// MaxTimeToDetectFailure returns the worst case time between the last successful api server check
+// and the error count reaching the given threshold
+func MaxTimeToDetectFailure(checkInterval time.Duration, apiServerTimeout time.Duration, maxErrorsThreshold int) time.Duration {
+	// the first failure happens at most one check interval after the last success
+	return checkInterval + apiServerTimeout + maxTimeToReachErrorCount(checkInterval, apiServerTimeout, 1, maxErrorsThreshold)
+}
+
+// maxTimeToReachErrorCount returns the worst case time between a failed check with the given error count and the
+// error count reaching the given target count, when all further checks fail
+func maxTimeToReachErrorCount(checkInterval time.Duration, apiServerTimeout time.Duration, errorCount int, targetCount int) time.Duration {
+	var maxTime time.Duration
+	for ; errorCount < targetCount; errorCount++ {
+		maxTime += time.Duration(float64(checkInterval*time.Duration(backoffFactor(errorCount)))*(1+backoffJitter)) + apiServerTimeout
+	}
+	return maxTime
+}
+
+type apiServerEndpoint struct {
+	host       string
+	address    string
+	restClient rest.Interface
+	tlsConfig  *tls.Config
+}
+
+// createApiServerEndpoints creates a rest client for every configured api server endpoint,
+// or for the host of the rest config if no endpoints are configured
+func (c *ApiConnectivityCheck) createApiServerEndpoints() ([]apiServerEndpoint, error) {
+	hosts := c.config.ApiServerEndpoints
+	if len(hosts) == 0 {
+		hosts = []string{c.config.Cfg.Host}
+	}
+
+	endpoints := make([]apiServerEndpoint, 0, len(hosts))
+	for _, host := range hosts {
+		cfg := rest.CopyConfig(c.config.Cfg)
+		cfg.Host = host
+		if c.config.Transport != nil {
+			// a custom transport handles TLS on its own, rest clients refuse to combine it with TLS settings
+			cfg.Transport = c.config.Transport
+			cfg.TLSClientConfig = rest.TLSClientConfig{}
+		}
+		cs, err := clientset.NewForConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		address, err := hostAddress(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api server endpoint %s: %v", host, err)
+		}
+		endpoint := apiServerEndpoint{
+			host:       host,
+			address:    address,
+			restClient: cs.RESTClient(),
+		}
+		if c.config.ProbeMode == ProbeModeTLSHandshake {
+			if endpoint.tlsConfig, err = probeTLSConfig(cfg, address); err != nil {
+				return nil, err
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// checkApiServerEndpoints checks all given endpoints and returns an empty string if any of them is healthy,
+// else a description of the failures
+func (c *ApiConnectivityCheck) checkApiServerEndpoints(ctx context.Context, endpoints []apiServerEndpoint) string {
+	// probe all endpoints at the same time, so that a check never takes longer than ApiServerTimeout
+	results := make([]string, len(endpoints))
+	var wg sync.WaitGroup
+	for i := range endpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.checkApiServerEndpoint(ctx, endpoints[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var reachable []string
+	var failures []string
+	for i, endpoint := range endpoints {
+		if failure := results[i]; failure != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", endpoint.host, failure))
+		} else {
+			reachable = append(reachable, endpoint.host)
+		}
+	}
+
+	if len(reachable) == 0 {
+		return strings.Join(failures, "; ")
+	}
+	if len(failures) > 0 {
+		// a single control plane member might be down, but we are not partitioned from the api server
+		c.config.Log.Info("some api server endpoints are not reachable", "reachable", reachable, "failures", failures)
+	}
+	return ""
+}
+
+// createExternalEndpoints creates the endpoints of the configured external probe endpoints, which are only probed with
+// a TCP connect
+func (c *ApiConnectivityCheck) createExternalEndpoints() ([]apiServerEndpoint, error) {
+	endpoints := make([]apiServerEndpoint, 0, len(c.config.ExternalProbeEndpoints))
+	for _, host := range c.config.ExternalProbeEndpoints {
+		address, err := hostAddress(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external probe endpoint %s: %v", host, err)
+		}
+		endpoints = append(endpoints, apiServerEndpoint{
+			host:    host,
+			address: address,
+		})
+	}
+	return endpoints, nil
+}
+
+// reachableExternalEndpoints probes the given external endpoints at the same time and returns the reachable ones
+func (c *ApiConnectivityCheck) reachableExternalEndpoints(ctx context.Context, endpoints []apiServerEndpoint) []string {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
+	defer cancel()
+
+	results := make([]string, len(endpoints))
+	var wg sync.WaitGroup
+	for i := range endpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = probeTCPConnect(probeCtx, endpoints[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var reachable []string
+	for i, endpoint := range endpoints {
+		if results[i] == "" {
+			reachable = append(reachable, endpoint.host)
+		} else {
+			c.config.Log.Info("external endpoint isn't reachable", "endpoint", endpoint.host, "failure", results[i])
+		}
+	}
+	return reachable
+}
+
+func (c *ApiConnectivityCheck) checkApiServerEndpoint(ctx context.Context, endpoint apiServerEndpoint) string {
+	probeCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
+	defer cancel()
+
+	return c.probe(probeCtx, endpoint)
 }
 
 // HandleError keeps track of the number of errors reported, and when a certain amount of error occur within a certain
 // time, ask peers if this node is healthy. Returns if the node is considered to be healthy or not.
-func (c *ApiConnectivityCheck) handleError() bool {
+func (c *ApiConnectivityCheck) handleError(ctx context.Context) bool {
 
 	c.errorCount++
 	if c.errorCount < c.config.MaxErrorsThreshold {
@@ -112,175 +574,466 @@ func (c *ApiConnectivityCheck) handleError() bool {
 		return true
 	}
 
+	if c.kubeletFailure != "" {
+		c.config.Log.Info("Error count exceeds threshold and the local kubelet is unhealthy as well, the node itself is sick, not asking other nodes",
+			"kubelet failure", c.kubeletFailure)
+		return false
+	}
+
 	c.config.Log.Info("Error count exceeds threshold, trying to ask other nodes if I'm healthy")
 	nodesToAsk := c.config.Peers.GetPeersAddresses()
 	if nodesToAsk == nil || len(nodesToAsk) == 0 {
 		c.config.Log.Info("Peers list is empty and / or couldn't be retrieved from server, nothing we can do, so consider the node being healthy")
 		//todo maybe we need to check if this happens too much and reboot
-		return true
+		return c.indeterminateVerdict()
 	}
 
-	apiErrorsResponsesSum := 0
 	nrAllNodes := len(nodesToAsk)
-	// nodesToAsk is being reduced in every iteration, iterate until no nodes left to ask
-	for i := 0; len(nodesToAsk) > 0; i++ {
-
-		// start asking a few nodes only in first iteration to cover the case we get a healthy / unhealthy result
-		nodesBatchCount := 3
-		if i > 0 {
-			// after that ask 10% of the cluster each time to check the api problem case
-			nodesBatchCount = len(nodesToAsk) / 10
-			if nodesBatchCount == 0 {
-				nodesBatchCount = 1
-			}
-		}
-
-		// but do not ask more then we have
-		if len(nodesToAsk) < nodesBatchCount {
-			nodesBatchCount = len(nodesToAsk)
-		}
+	peersIps := c.getPeersIps(nodesToAsk)
+	if sampleSize := c.peerSampleSize(); sampleSize > 0 && sampleSize < len(peersIps) {
+		// the peers are shuffled on every peer update, so the first ones are a random sample
+		peersIps = peersIps[:sampleSize]
+		// the majority of api errors refers to the sample as well
+		nrAllNodes = sampleSize
+		c.config.Log.Info("Asking a sample of the peers", "sample size", sampleSize, "peers", len(nodesToAsk))
+	}
+	peerNames := getPeerNames(nodesToAsk)
+	c.peersQueried = len(peersIps)
+	c.peerResults = make([]v1alpha1.PeerResult, 0, len(peersIps))
+	c.slowestPeer = ""
+	c.slowestPeerLatency = 0
+	atomic.StoreInt32(&c.peersRefused, 0)
+	defer c.refreshPeersIfRefused(ctx, len(peersIps))
 
-		chosenNodesAddresses := c.popNodes(&nodesToAsk, nodesBatchCount)
-		nrAddresses := len(chosenNodesAddresses)
-		responsesChan := make(chan poisonPill.HealthCheckResponseCode, nrAddresses)
+	// cancelling the context stops all outstanding peer requests as soon as we have a result, and the deadline stops
+	// them when asking the peers takes longer than the time reserved for it before the other nodes assume that we
+	// were rebooted
+	peersCtx, cancel := context.WithTimeout(ctx, MaxTimeToAskPeers(c.config.PeerDialTimeout, c.config.PeerRequestTimeout))
+	defer cancel()
+	start := time.Now()
+	responsesChan := c.askPeers(peersCtx, peersIps)
+	healthy := c.evaluatePeerResponses(peersCtx, responsesChan, len(peersIps), nrAllNodes, peerNames)
+	// the end-to-end time of the decision, which is what the timeouts of the peer requests need to be tuned for
+	latency := time.Since(start)
+	quorumLatency.Observe(latency.Seconds())
+	c.config.Log.Info("Peers reached a verdict", "latency", latency, "responses", len(c.peerResults),
+		"peers", len(peersIps), "slowest peer", c.slowestPeer, "slowest peer latency", c.slowestPeerLatency)
+	return healthy
+}
 
-		for _, address := range chosenNodesAddresses {
-			go c.getHealthStatusFromPeer(address, responsesChan)
-		}
+// MaxTimeToAskPeers returns the max time of a round of peer requests: all rounds of concurrent requests, where
+// dual-stack peers might be asked on 2 addresses
+func MaxTimeToAskPeers(peerDialTimeout time.Duration, peerRequestTimeout time.Duration) time.Duration {
+	return 2 * (maxConcurrentPeerRequests + 1) * (peerDialTimeout + peerRequestTimeout)
+}
 
-		healthyResponses, unhealthyResponses, apiErrorsResponses, _ := c.sumPeersResponses(nrAddresses, responsesChan)
+// peerSampleSize returns the number of peers to ask, zero means all peers. The sample is never smaller than the
+// MinPeersForQuorum, since a smaller sample could never establish the quorum.
+func (c *ApiConnectivityCheck) peerSampleSize() int {
+	if c.config.PeerSampleSize <= 0 {
+		return 0
+	}
+	if c.config.PeerSampleSize < c.config.MinPeersForQuorum {
+		return c.config.MinPeersForQuorum
+	}
+	return c.config.PeerSampleSize
+}
 
-		if healthyResponses > 0 {
+// evaluatePeerResponses reads the given number of responses from the channel until they are conclusive, and returns
+// if this node is healthy. nrAllNodes is the number of peers whose majority decides about an api server failure.
+// When the deadline of the context is exceeded, the peers which didn't respond yet count as unresponsive. When the
+// context is cancelled, e.g. on shutdown, no decision is made and the node is considered healthy.
+func (c *ApiConnectivityCheck) evaluatePeerResponses(ctx context.Context, responsesChan <-chan peerResponse, nrResponses int, nrAllNodes int, peerNames map[string]string) bool {
+	apiErrorsResponsesSum := 0
+	authFailuresSum := 0
+	clockSkewedSum := 0
+	throttledSum := 0
+	// peers with skewed clocks don't vote, so they don't count for the majority either
+	isControlPlaneFailure := func() bool {
+		//todo consider using [m|n]hc.spec.maxUnhealthy instead of 50%
+		if apiErrorsResponsesSum > (nrAllNodes-clockSkewedSum)/2 { //already reached more than 50% of the nodes and all of them returned api error
+			//assuming this is a control plane failure as others can't access api-server as well
+			c.config.Log.Info("More than 50% of the nodes couldn't access the api-server, assuming this is a control plane failure")
+			quorumHealthy.Inc()
+			return true
+		}
+		return false
+	}
+responses:
+	for i := 0; i < nrResponses; i++ {
+		var peerResponse peerResponse
+		select {
+		case peerResponse = <-responsesChan:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				c.config.Log.Info("Asking peers was cancelled, consider the node being healthy")
+				return true
+			}
+			c.config.Log.Info("Time for asking peers is up, the remaining peers count as unresponsive",
+				"responses", i, "peers", nrResponses)
+			break responses
+		}
+		c.recordPeerResult(peerNames, peerResponse)
+		response := peerResponse.code
+		switch response {
+		case poisonPill.Healthy:
 			c.config.Log.Info("Peer told me I'm healthy.")
 			c.errorCount = 0
+			quorumHealthy.Inc()
 			return true
-		}
-
-		if unhealthyResponses > 0 {
+		case poisonPill.Unhealthy:
+			// the remediation of this node exists, so it must fence itself, no matter what other peers respond
 			c.config.Log.Info("Peer told me I'm unhealthy!")
+			c.remediationReported = true
+			quorumUnhealthy.Inc()
 			return false
-		}
-
-		if apiErrorsResponses > 0 {
-			apiErrorsResponsesSum += apiErrorsResponses
-			//todo consider using [m|n]hc.spec.maxUnhealthy instead of 50%
-			if apiErrorsResponsesSum > nrAllNodes/2 { //already reached more than 50% of the nodes and all of them returned api error
-				//assuming this is a control plane failure as others can't access api-server as well
-				c.config.Log.Info("More than 50% of the nodes couldn't access the api-server, assuming this is a control plane failure")
+		case poisonPill.ApiError:
+			apiErrorsResponsesSum++
+			if isControlPlaneFailure() {
+				return true
+			}
+		case poisonPill.AuthFailed:
+			// not a vote, the peer is reachable but we couldn't verify each other's certificates
+			authFailuresSum++
+		case poisonPill.ClockSkewed:
+			// not a vote, the peer might judge the freshness of the remediations wrongly, but it's reachable
+			clockSkewedSum++
+			if apiErrorsResponsesSum > 0 && isControlPlaneFailure() {
 				return true
 			}
+		case poisonPill.Throttled:
+			// not a vote, the peer is busy with the requests of other nodes, but it's reachable
+			throttledSum++
+		case poisonPill.RequestFailed:
+		default:
+			c.config.Log.Error(fmt.Errorf("unexpected response"),
+				"Received unexpected value from peer while trying to retrieve health status", "value", response)
 		}
+	}
+
+	if authFailuresSum > 0 {
+		// the TLS handshake needs a working connection, so we aren't isolated, this is a certificate problem. That's
+		// only the case when no peer responded conclusively, an unhealthy response of another peer already made us
+		// fence.
+		c.config.Log.Info("Peers rejected the authentication, this indicates a certificate problem and not a partition",
+			"auth failures", authFailuresSum)
+		return c.indeterminateVerdict()
+	}
 
+	if throttledSum > 0 {
+		// many nodes asking at the same time, e.g. during an api server outage, must not make them all fence
+		// themselves. That's only the case when no peer responded conclusively, an unhealthy response of another
+		// peer already made us fence.
+		c.config.Log.Info("Peers are too busy to answer, but they are reachable, so this isn't a partition",
+			"throttled peers", throttledSum)
+		return c.indeterminateVerdict()
+	}
+
+	if clockSkewedSum > 0 {
+		// the peers responded, so we aren't isolated, and our own clock might be the skewed one
+		c.config.Log.Info("WARNING: peers with skewed clocks responded, this indicates a clock problem and not a partition",
+			"clock skewed peers", clockSkewedSum)
+		return c.indeterminateVerdict()
+	}
+
+	if nrResponses < c.config.MinPeersForQuorum {
+		c.config.Log.Info("Not enough peers to tell if I'm isolated, can't establish quorum",
+			"peers", nrResponses, "min peers for quorum", c.config.MinPeersForQuorum)
+		return c.indeterminateVerdict()
 	}
 
 	//we asked all peers
+	// this isn't an indeterminate verdict: no peer of the quorum responding conclusively means that this node is
+	// isolated, which is the baseline reason for fencing, independent of FenceOnIndeterminate
 	c.config.Log.Error(fmt.Errorf("failed health check"), "Failed to get health status peers. Assuming unhealthy")
+	quorumUnresponsive.Inc()
+	return false
+}
+
+// indeterminateVerdict returns if this node is healthy when its peers can't establish a quorum. It's healthy,
+// unless FenceOnIndeterminate is set.
+func (c *ApiConnectivityCheck) indeterminateVerdict() bool {
+	quorumIndeterminate.Inc()
+	if !c.config.FenceOnIndeterminate {
+		c.config.Log.Info("Peer verdict is indeterminate, consider the node being healthy")
+		return true
+	}
+	c.config.Log.Error(fmt.Errorf("peer verdict is indeterminate"),
+		"FENCING ON INDETERMINATE PEER VERDICT! No peer quorum confirmed that I'm unhealthy, but FenceOnIndeterminate is set, assuming unhealthy")
+	indeterminateFencing.Inc()
 	return false
 }
 
-func (c *ApiConnectivityCheck) popNodes(nodes *[][]v1.NodeAddress, count int) []string {
-	nrOfNodes := len(*nodes)
-	if nrOfNodes == 0 {
-		return []string{}
+// refreshPeersIfRefused refreshes the peer addresses when more than half of the asked peers refused all connections,
+// because the addresses likely point to nodes which are gone
+func (c *ApiConnectivityCheck) refreshPeersIfRefused(ctx context.Context, nrPeers int) {
+	if refused := int(atomic.LoadInt32(&c.peersRefused)); refused > nrPeers/2 {
+		c.config.Log.Info("More than 50% of the peers refused the connection, refreshing peers", "refused", refused, "peers", nrPeers)
+		ctx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
+		defer cancel()
+		c.config.Peers.Refresh(ctx)
 	}
+}
 
-	if count > nrOfNodes {
-		count = nrOfNodes
+// askPeers requests the health status from the given peers, with at most maxConcurrentPeerRequests requests
+// at the same time. The responses are written to the returned channel, which has room for a response of every peer.
+func (c *ApiConnectivityCheck) askPeers(ctx context.Context, peersIps [][]string) <-chan peerResponse {
+	responsesChan := make(chan peerResponse, len(peersIps))
+	peerIpsChan := make(chan []string)
+
+	nrWorkers := maxConcurrentPeerRequests
+	if len(peersIps) < nrWorkers {
+		nrWorkers = len(peersIps)
+	}
+	for i := 0; i < nrWorkers; i++ {
+		go func() {
+			for peerIps := range peerIpsChan {
+				start := time.Now()
+				code, clockSkew := c.getHealthStatusFromPeer(ctx, peerIps)
+				responsesChan <- peerResponse{
+					ips:       peerIps,
+					code:      code,
+					latency:   time.Since(start),
+					clockSkew: clockSkew,
+				}
+			}
+		}()
 	}
 
-	//todo maybe we should pick nodes randomly rather than relying on the order returned from api-server
-	addresses := make([]string, count)
-	for i := 0; i < count; i++ {
-		nodeAddresses := (*nodes)[i]
-		if len(nodeAddresses) == 0 || nodeAddresses[0].Address == "" {
-			c.config.Log.Info("ignoring node without IP address")
+	go func() {
+		defer close(peerIpsChan)
+		for _, peerIps := range peersIps {
+			select {
+			case peerIpsChan <- peerIps:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return responsesChan
+}
+
+// getPeersIps returns the internal IPs of every node which has at least one
+func (c *ApiConnectivityCheck) getPeersIps(nodes [][]v1.NodeAddress) [][]string {
+	peersIps := make([][]string, 0, len(nodes))
+	for _, nodeAddresses := range nodes {
+		ips := peers.GetInternalIPs(nodeAddresses)
+		if len(ips) == 0 {
+			c.config.Log.Info("ignoring node without internal IP address")
 			continue
 		}
-		addresses[i] = nodeAddresses[0].Address //todo node might have multiple addresses or none
+		peersIps = append(peersIps, ips)
 	}
+	return peersIps
+}
 
-	*nodes = (*nodes)[count:] //remove popped nodes from the list
+// getPeerNames returns the node names of the given peers by their first internal IP
+func getPeerNames(nodes [][]v1.NodeAddress) map[string]string {
+	names := make(map[string]string, len(nodes))
+	for _, nodeAddresses := range nodes {
+		if ips := peers.GetInternalIPs(nodeAddresses); len(ips) > 0 {
+			names[ips[0]] = peers.GetHostname(nodeAddresses)
+		}
+	}
+	return names
+}
+
+// recordPeerResult adds the given peer response to the peer results of the current round of peer requests, and
+// keeps track of the slowest peer
+func (c *ApiConnectivityCheck) recordPeerResult(peerNames map[string]string, response peerResponse) {
+	nodeName := peerNames[response.ips[0]]
+	if nodeName == "" {
+		nodeName = response.ips[0]
+	}
+	var result v1alpha1.PeerResponse
+	switch response.code {
+	case poisonPill.Healthy:
+		result = v1alpha1.PeerResponseHealthy
+	case poisonPill.Unhealthy:
+		result = v1alpha1.PeerResponseUnhealthy
+	case poisonPill.ApiError:
+		result = v1alpha1.PeerResponseApiError
+	case poisonPill.AuthFailed:
+		result = v1alpha1.PeerResponseAuthFailed
+	case poisonPill.ClockSkewed:
+		result = v1alpha1.PeerResponseClockSkewed
+	case poisonPill.Throttled:
+		result = v1alpha1.PeerResponseThrottled
+	default:
+		result = v1alpha1.PeerResponseTimeout
+	}
+	if response.latency > c.slowestPeerLatency {
+		c.slowestPeer = nodeName
+		c.slowestPeerLatency = response.latency
+	}
+	peerResult := v1alpha1.PeerResult{
+		NodeName: nodeName,
+		Response: result,
+		Time:     metav1.Now(),
+	}
+	c.peerResults = append(c.peerResults, peerResult)
+
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if c.lastPeerResults == nil {
+		c.lastPeerResults = map[string]v1alpha1.PeerResult{}
+	}
+	c.lastPeerResults[nodeName] = peerResult
+	if response.clockSkew != nil {
+		if c.lastPeerClockSkews == nil {
+			c.lastPeerClockSkews = map[string]time.Duration{}
+		}
+		c.lastPeerClockSkews[nodeName] = *response.clockSkew
+	}
+}
+
+// savePeerResults persists the peer results which led to the reboot, so that they can be attached to the remediation
+// after the reboot
+func (c *ApiConnectivityCheck) savePeerResults() {
+	if c.config.PeerResults == nil {
+		return
+	}
+	if err := c.config.PeerResults.Save(c.peerResults); err != nil {
+		c.config.Log.Error(err, "failed to save peer results")
+	}
+}
+
+// recordFencingDecision appends the given decision with the peer results which led to it to the audit log
+func (c *ApiConnectivityCheck) recordFencingDecision(outcome audit.Outcome, reason string) {
+	if c.config.AuditLog == nil {
+		return
+	}
+	entry := audit.Entry{
+		Node:        c.config.MyNodeName,
+		PeerResults: c.peerResults,
+		Outcome:     outcome,
+		Reason:      reason,
+	}
+	if err := c.config.AuditLog.Record(entry); err != nil {
+		c.config.Log.Error(err, "failed to record fencing decision in the audit log", "outcome", outcome)
+	}
+}
 
-	return addresses
+// getHealthStatusFromPeer tries the given IPs of a peer in order, until one of them returns a response
+func (c *ApiConnectivityCheck) getHealthStatusFromPeer(ctx context.Context, peerIps []string) (poisonPill.HealthCheckResponseCode, *time.Duration) {
+	allRefused := true
+	for _, ip := range peerIps {
+		response, clockSkew, refused := c.getHealthStatusFromIp(ctx, ip)
+		if response != poisonPill.RequestFailed {
+			return response, clockSkew
+		}
+		allRefused = allRefused && refused
+	}
+	if allRefused {
+		atomic.AddInt32(&c.peersRefused, 1)
+	}
+	return poisonPill.RequestFailed, nil
 }
 
-//getHealthStatusFromPeer issues a GET request to the specified IP and returns the result from the peer into the given channel
-func (c *ApiConnectivityCheck) getHealthStatusFromPeer(endpointIp string, results chan<- poisonPill.HealthCheckResponseCode) {
+//getHealthStatusFromIp issues a GET request to the specified IP and returns the result from the peer, the clock skew
+//of the peer if it was measured, and if the peer refused the connection
+func (c *ApiConnectivityCheck) getHealthStatusFromIp(ctx context.Context, endpointIp string) (poisonPill.HealthCheckResponseCode, *time.Duration, bool) {
 
 	logger := c.config.Log.WithValues("IP", endpointIp)
+
+	if ctx.Err() != nil {
+		// we already have a result, no need to ask this peer anymore
+		return poisonPill.RequestFailed, nil, false
+	}
 	logger.Info("getting health status from peer")
 
-	if err := c.initClientCreds(); err != nil {
+	// always create new credentials, in order to pick up rotated certificates
+	clientCreds, err := certificates.GetClientCredentialsFromCerts(c.config.CertReader, c.config.PeerTLSOptions)
+	if err != nil {
 		logger.Error(err, "failed to init client credentials")
-		results <- poisonPill.RequestFailed
-		return
+		return poisonPill.RequestFailed, nil, false
 	}
 
-	// TODO does this work with IPv6?
-	phClient, err := peerhealth.NewClient(fmt.Sprintf("%v:%v", endpointIp, c.config.PeerHealthPort), c.config.PeerDialTimeout, c.config.Log.WithName("peerhealth client"), c.clientCreds)
+	endpoint := net.JoinHostPort(endpointIp, strconv.Itoa(c.config.PeerHealthPort))
+	phClient, err := peerhealth.NewClient(ctx, endpoint, c.config.PeerDialTimeout, c.config.Log.WithName("peerhealth client"), clientCreds, c.config.PeerDialer)
 	if err != nil {
+		if peerhealth.IsAuthFailure(err) {
+			logger.Error(err, "failed to authenticate with peer")
+			peerAuthFailures.Inc()
+			return poisonPill.AuthFailed, nil, false
+		}
 		logger.Error(err, "failed to init grpc client")
-		results <- poisonPill.RequestFailed
-		return
+		return poisonPill.RequestFailed, nil, isConnectionRefused(err)
 	}
 	defer phClient.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.PeerRequestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.config.PeerRequestTimeout)
 	defer cancel()
 
+	requestStart := time.Now()
 	resp, err := phClient.IsHealthy(ctx, &peerhealth.HealthRequest{
 		NodeName: c.config.MyNodeName,
 	})
 	if err != nil {
+		if peerhealth.IsAuthFailure(err) {
+			// with TLS 1.3 a rejected client certificate is only noticed on the first request
+			logger.Error(err, "failed to authenticate with peer")
+			peerAuthFailures.Inc()
+			return poisonPill.AuthFailed, nil, false
+		}
+		if peerhealth.IsThrottled(err) {
+			logger.Info("peer is too busy to answer", "error", err.Error())
+			peerThrottled.Inc()
+			return poisonPill.Throttled, nil, false
+		}
 		logger.Error(err, "failed to read health response from peer")
-		results <- poisonPill.RequestFailed
-		return
+		return poisonPill.RequestFailed, nil, false
 	}
 
-	logger.Info("got response from peer", "status", resp.Status)
+	roundTrip := time.Since(requestStart)
 
-	results <- poisonPill.HealthCheckResponseCode(resp.Status)
-	return
-}
+	logger.Info("got response from peer", "status", resp.Status)
 
-func (c *ApiConnectivityCheck) initClientCreds() error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if c.clientCreds == nil {
-		clientCreds, err := certificates.GetClientCredentialsFromCerts(c.config.CertReader)
-		if err != nil {
-			return err
+	if resp.Timestamp == 0 {
+		// peers of older versions don't report their time
+		return poisonPill.HealthCheckResponseCode(resp.Status), nil, false
+	}
+	clockSkew := peerClockSkew(time.Unix(0, resp.Timestamp), requestStart, roundTrip)
+	if c.isClockSkewExceeded(clockSkew, roundTrip) {
+		peerClockSkewExceeded.Inc()
+		if poisonPill.HealthCheckResponseCode(resp.Status) == poisonPill.Unhealthy {
+			// the remediation of this node exists, no matter how the clocks deviate
+			logger.Info("WARNING: the clock of the peer deviates too much from ours, but it reported a remediation of this node",
+				"clock skew", clockSkew, "max clock skew", c.maxPeerClockSkew())
+			return poisonPill.Unhealthy, &clockSkew, false
 		}
-		c.clientCreds = clientCreds
+		logger.Info("WARNING: the clock of the peer deviates too much from ours, ignoring its response",
+			"clock skew", clockSkew, "max clock skew", c.maxPeerClockSkew(), "status", resp.Status)
+		return poisonPill.ClockSkewed, &clockSkew, false
 	}
-	return nil
+	return poisonPill.HealthCheckResponseCode(resp.Status), &clockSkew, false
 }
 
-func (c *ApiConnectivityCheck) sumPeersResponses(nodesBatchCount int, responsesChan chan poisonPill.HealthCheckResponseCode) (int, int, int, int) {
-	healthyResponses := 0
-	unhealthyResponses := 0
-	apiErrorsResponses := 0
-	noResponse := 0
+// peerClockSkew returns the deviation of the given peer time from our clock. The peer is assumed to take its time in
+// the middle of the round trip of the request which started at the given time.
+func peerClockSkew(peerTime time.Time, requestStart time.Time, roundTrip time.Duration) time.Duration {
+	return peerTime.Sub(requestStart.Add(roundTrip / 2))
+}
 
-	for i := 0; i < nodesBatchCount; i++ {
-		response := <-responsesChan
-		switch response {
-		case poisonPill.Unhealthy:
-			unhealthyResponses++
-			break
-		case poisonPill.Healthy:
-			healthyResponses++
-			break
-		case poisonPill.ApiError:
-			apiErrorsResponses++
-			break
-		case poisonPill.RequestFailed:
-			noResponse++
-		default:
-			c.config.Log.Error(fmt.Errorf("unexpected response"),
-				"Received unexpected value from peer while trying to retrieve health status", "value", response)
-		}
+// isClockSkewExceeded returns if the given clock skew exceeds the MaxPeerClockSkew for sure, considering that the
+// skew is only known up to half of the round trip of its measurement
+func (c *ApiConnectivityCheck) isClockSkewExceeded(clockSkew time.Duration, roundTrip time.Duration) bool {
+	if clockSkew < 0 {
+		clockSkew = -clockSkew
 	}
+	return clockSkew-roundTrip/2 > c.maxPeerClockSkew()
+}
+
+func (c *ApiConnectivityCheck) maxPeerClockSkew() time.Duration {
+	if c.config.MaxPeerClockSkew <= 0 {
+		return DefaultMaxPeerClockSkew
+	}
+	return c.config.MaxPeerClockSkew
+}
 
-	return healthyResponses, unhealthyResponses, apiErrorsResponses, noResponse
+// isConnectionRefused returns if the given dial error was caused by a refused connection. The grpc dial error only
+// contains the description of the underlying connection error.
+func isConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "connection refused")
 }
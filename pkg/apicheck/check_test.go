@@ -0,0 +1,909 @@
+package apicheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	poisonPill "github.com/medik8s/poison-pill/api"
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/certificates"
+	"github.com/medik8s/poison-pill/pkg/peerhealth"
+	"github.com/medik8s/poison-pill/pkg/peers"
+	"github.com/medik8s/poison-pill/pkg/reboot"
+	"github.com/medik8s/poison-pill/pkg/watchdog"
+)
+
+// countingRebooter counts the triggered reboots
+type countingRebooter struct {
+	reboots int
+}
+
+func (r *countingRebooter) Reboot() error {
+	r.reboots++
+	return nil
+}
+
+var _ = Describe("Startup grace period", func() {
+
+	var check *ApiConnectivityCheck
+	var rebooter *countingRebooter
+	var unreachable []apiServerEndpoint
+	var reachable []apiServerEndpoint
+	var listener net.Listener
+
+	newEndpoints := func(host string) []apiServerEndpoint {
+		check.config.ApiServerEndpoints = []string{host}
+		endpoints, err := check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+		return endpoints
+	}
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		rebooter = &countingRebooter{}
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			CheckInterval:      time.Second,
+			MaxErrorsThreshold: 1,
+			// not started, so there are no peers to ask
+			Peers:              peers.New("node1", time.Hour, nil, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil),
+			Rebooter:           rebooter,
+			Cfg:                &rest.Config{},
+			ProbeMode:          ProbeModeTCPConnect,
+			ApiServerTimeout:   time.Second,
+			StartupGracePeriod: time.Minute,
+		})
+		// nothing listens on port 1
+		unreachable = newEndpoints("https://127.0.0.1:1")
+		reachable = newEndpoints("https://" + listener.Addr().String())
+		check.startupGracePeriodEnd = time.Now().Add(check.config.StartupGracePeriod)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("should not count errors of an initially unreachable api server", func() {
+		for i := 0; i < 3; i++ {
+			check.check(context.Background(), unreachable, nil)
+		}
+		Expect(check.errorCount).To(BeZero())
+		Expect(check.GetStatus().Phase).To(Equal(PhaseSuspect))
+		Expect(rebooter.reboots).To(BeZero())
+	})
+
+	It("should count errors after the first successful check", func() {
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseHealthy))
+
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.errorCount).To(Equal(1))
+	})
+
+	It("should not count errors before the watchdog is armed, even after a successful check", func() {
+		check.armDelayEnd = time.Now().Add(time.Minute)
+		check.check(context.Background(), reachable, nil)
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.errorCount).To(BeZero())
+		Expect(check.GetStatus().Phase).To(Equal(PhaseSuspect))
+
+		check.armDelayEnd = time.Now().Add(-time.Second)
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.errorCount).To(Equal(1))
+	})
+
+	It("should count errors after the grace period", func() {
+		check.startupGracePeriodEnd = time.Now().Add(-time.Second)
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.errorCount).To(Equal(1))
+	})
+
+	It("should only count down to fencing when errors are counted", func() {
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().TimeUntilFencing).To(BeEmpty())
+
+		check.startupGracePeriodEnd = time.Now().Add(-time.Second)
+		check.check(context.Background(), unreachable, nil)
+		status := check.GetStatus()
+		Expect(status.Phase).To(Equal(PhaseSuspect))
+		Expect(status.ConsecutiveErrors).To(Equal(1))
+		Expect(status.ErrorThreshold).To(Equal(1))
+		// the threshold is reached, so the next failed check with backoff decides about fencing
+		Expect(status.TimeUntilFencing).To(Equal("3.4s"))
+	})
+})
+
+var _ = Describe("Aborted fencing", func() {
+
+	var check *ApiConnectivityCheck
+	var wd watchdog.Watchdog
+	var rebooter reboot.Rebooter
+	var unreachable []apiServerEndpoint
+	var reachable []apiServerEndpoint
+	var listener net.Listener
+	var cancel context.CancelFunc
+
+	newEndpoints := func(host string) []apiServerEndpoint {
+		check.config.ApiServerEndpoints = []string{host}
+		endpoints, err := check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+		return endpoints
+	}
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		wd, err = watchdog.NewFakeWithMaxTimeout(ctrl.Log.WithName("watchdog"), 10*time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() {
+			_ = wd.Start(ctx)
+		}()
+		Eventually(wd.IsStarted, time.Second, 10*time.Millisecond).Should(BeTrue())
+		rebooter = reboot.NewWatchdogRebooter(wd, reboot.RebootMethodStopFeeding, nil, ctrl.Log.WithName("rebooter"))
+
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			CheckInterval:      time.Second,
+			MaxErrorsThreshold: 1,
+			// not started, so there are no peers to ask, which fences the node
+			Peers:                peers.New("node1", time.Hour, nil, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil),
+			FenceOnIndeterminate: true,
+			Rebooter:             rebooter,
+			Watchdog:             wd,
+			Cfg:                  &rest.Config{},
+			ProbeMode:            ProbeModeTCPConnect,
+			ApiServerTimeout:     time.Second,
+		})
+		unreachable = newEndpoints("https://127.0.0.1:1")
+		reachable = newEndpoints("https://" + listener.Addr().String())
+	})
+
+	AfterEach(func() {
+		cancel()
+		listener.Close()
+	})
+
+	It("should abort the reboot when the api server becomes reachable before the watchdog fires", func() {
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		lastFoodTime := wd.LastFoodTime()
+		Consistently(wd.LastFoodTime, 400*time.Millisecond, 100*time.Millisecond).Should(Equal(lastFoodTime))
+
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseHealthy))
+		Expect(wd.LastFoodTime()).To(BeTemporally(">", lastFoodTime))
+	})
+
+	It("should not abort the reboot when a peer reported a remediation of this node", func() {
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		// e.g. when the peers were asked again after the reboot was triggered
+		responsesChan := make(chan peerResponse, 1)
+		responsesChan <- peerResponse{ips: []string{"10.0.0.2"}, code: poisonPill.Unhealthy}
+		Expect(check.evaluatePeerResponses(context.Background(), responsesChan, 1, 1, nil)).To(BeFalse())
+
+		lastFoodTime := wd.LastFoodTime()
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		Expect(wd.LastFoodTime()).To(Equal(lastFoodTime))
+	})
+
+	It("should leave the fencing phase in dry run mode, since no reboot is pending", func() {
+		check.config.Rebooter = reboot.NewDryRunRebooter(ctrl.Log.WithName("rebooter"))
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseHealthy))
+	})
+
+	It("should not abort a reboot which was requested for another reason as well", func() {
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		// e.g. by the reconciler for a remediation of this node
+		Expect(rebooter.Reboot()).To(Succeed())
+
+		lastFoodTime := wd.LastFoodTime()
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		Expect(wd.LastFoodTime()).To(Equal(lastFoodTime))
+	})
+})
+
+var _ = Describe("Time to fencing", func() {
+
+	It("should sum up the backed off intervals and timeouts of the remaining checks", func() {
+		Expect(maxTimeToReachErrorCount(10*time.Second, time.Second, 3, 3)).To(BeZero())
+		Expect(maxTimeToReachErrorCount(10*time.Second, time.Second, 1, 3)).To(Equal(24*time.Second + 48*time.Second + 2*time.Second))
+	})
+
+	It("should include the first interval in the max time to detect a failure", func() {
+		Expect(MaxTimeToDetectFailure(10*time.Second, time.Second, 3)).To(Equal(10*time.Second + 24*time.Second + 48*time.Second + 3*time.Second))
+	})
+})
+
+var _ = Describe("Kubelet probe", func() {
+
+	var check *ApiConnectivityCheck
+	var rebooter *countingRebooter
+	var unreachable []apiServerEndpoint
+	var kubeletHealthy bool
+	var kubelet *httptest.Server
+
+	BeforeEach(func() {
+		kubeletHealthy = true
+		kubelet = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !kubeletHealthy {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+
+		rebooter = &countingRebooter{}
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			CheckInterval:      time.Second,
+			MaxErrorsThreshold: 1,
+			// not started, so there are no peers to ask, which is considered healthy
+			Peers:              peers.New("node1", time.Hour, nil, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil),
+			Rebooter:           rebooter,
+			Cfg:                &rest.Config{},
+			ApiServerEndpoints: []string{"https://127.0.0.1:1"},
+			ProbeMode:          ProbeModeTCPConnect,
+			ApiServerTimeout:   time.Second,
+			KubeletHealthzURL:  kubelet.URL + "/healthz",
+		})
+		var err error
+		unreachable, err = check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		kubelet.Close()
+	})
+
+	It("should not reboot without peers when the kubelet is healthy", func() {
+		check.check(context.Background(), unreachable, nil)
+		Expect(rebooter.reboots).To(BeZero())
+		Expect(check.GetStatus().Phase).To(Equal(PhaseSuspect))
+		Expect(*check.GetStatus().KubeletHealthy).To(BeTrue())
+	})
+
+	It("should reboot without peers when the kubelet is unhealthy as well", func() {
+		kubeletHealthy = false
+		check.check(context.Background(), unreachable, nil)
+		Expect(rebooter.reboots).To(Equal(1))
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+		Expect(*check.GetStatus().KubeletHealthy).To(BeFalse())
+		Expect(check.GetStatus().KubeletError).To(ContainSubstring("500"))
+	})
+
+	It("should not report the kubelet health when it isn't probed", func() {
+		check.config.KubeletHealthzURL = ""
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.GetStatus().KubeletHealthy).To(BeNil())
+	})
+})
+
+var _ = Describe("Peer sampling", func() {
+
+	var check *ApiConnectivityCheck
+
+	BeforeEach(func() {
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			MaxErrorsThreshold: 1,
+		})
+	})
+
+	// responses returns a channel with the given responses of peers with distinct IPs
+	responses := func(codes ...poisonPill.HealthCheckResponseCode) <-chan peerResponse {
+		responsesChan := make(chan peerResponse, len(codes))
+		for i, code := range codes {
+			responsesChan <- peerResponse{ips: []string{fmt.Sprintf("10.0.0.%d", i+1)}, code: code}
+		}
+		return responsesChan
+	}
+
+	It("should ask all peers by default", func() {
+		Expect(check.peerSampleSize()).To(BeZero())
+	})
+
+	It("should not sample less peers than needed for the quorum", func() {
+		check.config.PeerSampleSize = 3
+		Expect(check.peerSampleSize()).To(Equal(3))
+		check.config.MinPeersForQuorum = 5
+		Expect(check.peerSampleSize()).To(Equal(5))
+	})
+
+	It("should reach a verdict with the majority of the sample", func() {
+		// 2 api errors out of a sample of 3 are a majority, out of all 10 peers they aren't
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.ApiError, poisonPill.ApiError, poisonPill.RequestFailed), 3, 3, nil)).To(BeTrue())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.ApiError, poisonPill.ApiError, poisonPill.RequestFailed), 3, 10, nil)).To(BeFalse())
+	})
+
+	It("should keep the last response of every peer", func() {
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.Unhealthy), 2, 2, map[string]string{"10.0.0.2": "node2"})).To(BeFalse())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.ApiError, poisonPill.ApiError), 2, 2, nil)).To(BeTrue())
+		Expect(check.lastPeerResults).To(HaveLen(3))
+		Expect(check.lastPeerResults["10.0.0.1"].Response).To(Equal(v1alpha1.PeerResponseApiError))
+		Expect(check.lastPeerResults["node2"].Response).To(Equal(v1alpha1.PeerResponseUnhealthy))
+
+		recorder := httptest.NewRecorder()
+		NewStatusServer("", check, false, ctrl.Log.WithName("status-server")).servePeers(recorder, httptest.NewRequest(http.MethodGet, "/peers", nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"peers":[]`))
+	})
+
+	It("should fence on the first unhealthy response, regardless of the quorum", func() {
+		check.config.MinPeersForQuorum = 3
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.Unhealthy), 2, 2, nil)).To(BeFalse())
+		Expect(check.remediationReported).To(BeTrue())
+	})
+
+	It("should not let throttled peers override an unhealthy response", func() {
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.Throttled, poisonPill.Unhealthy, poisonPill.Throttled), 3, 3, nil)).To(BeFalse())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.Throttled, poisonPill.RequestFailed), 2, 2, nil)).To(BeTrue())
+	})
+
+	It("should not let auth failures override an unhealthy response", func() {
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.AuthFailed, poisonPill.Unhealthy, poisonPill.AuthFailed), 3, 3, nil)).To(BeFalse())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.AuthFailed, poisonPill.RequestFailed), 2, 2, nil)).To(BeTrue())
+	})
+
+	It("should only consider the node isolated when enough peers were asked", func() {
+		check.config.MinPeersForQuorum = 3
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.RequestFailed), 2, 2, nil)).To(BeTrue())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.RequestFailed, poisonPill.RequestFailed), 3, 3, nil)).To(BeFalse())
+	})
+
+	It("should only fence on an indeterminate verdict when configured", func() {
+		check.config.MinPeersForQuorum = 3
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.ApiError, poisonPill.RequestFailed), 2, 2, nil)).To(BeTrue())
+		check.config.FenceOnIndeterminate = true
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.ApiError, poisonPill.RequestFailed), 2, 2, nil)).To(BeFalse())
+	})
+
+	It("should fence when no peer responds, independent of the indeterminate verdict", func() {
+		Expect(check.config.FenceOnIndeterminate).To(BeFalse())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.RequestFailed), 2, 2, nil)).To(BeFalse())
+	})
+
+	It("should keep track of the slowest peer", func() {
+		responsesChan := make(chan peerResponse, 3)
+		responsesChan <- peerResponse{ips: []string{"10.0.0.1"}, code: poisonPill.RequestFailed, latency: time.Second}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.2"}, code: poisonPill.RequestFailed, latency: 3 * time.Second}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.3"}, code: poisonPill.Unhealthy, latency: 2 * time.Second}
+		Expect(check.evaluatePeerResponses(context.Background(), responsesChan, 3, 3, map[string]string{"10.0.0.2": "node2"})).To(BeFalse())
+		Expect(check.slowestPeer).To(Equal("node2"))
+		Expect(check.slowestPeerLatency).To(Equal(3 * time.Second))
+	})
+
+	It("should reach a verdict with a single response of the sample", func() {
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.Unhealthy), 2, 2, nil)).To(BeFalse())
+		Expect(check.evaluatePeerResponses(context.Background(), responses(poisonPill.RequestFailed, poisonPill.Healthy), 2, 2, nil)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Peer clock skew", func() {
+
+	var check *ApiConnectivityCheck
+
+	BeforeEach(func() {
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			MaxErrorsThreshold: 1,
+		})
+	})
+
+	It("should measure the skew against the middle of the round trip", func() {
+		requestStart := time.Now()
+		Expect(peerClockSkew(requestStart.Add(time.Second), requestStart, 2*time.Second)).To(BeZero())
+		Expect(peerClockSkew(requestStart.Add(-time.Minute), requestStart, 2*time.Second)).To(Equal(-time.Minute - time.Second))
+	})
+
+	It("should only flag skews which exceed the max skew despite the uncertainty of the measurement", func() {
+		Expect(check.isClockSkewExceeded(DefaultMaxPeerClockSkew, 0)).To(BeFalse())
+		Expect(check.isClockSkewExceeded(-DefaultMaxPeerClockSkew-time.Second, 0)).To(BeTrue())
+		Expect(check.isClockSkewExceeded(DefaultMaxPeerClockSkew+time.Second, 4*time.Second)).To(BeFalse())
+
+		check.config.MaxPeerClockSkew = 5 * time.Second
+		Expect(check.isClockSkewExceeded(6*time.Second, 0)).To(BeTrue())
+	})
+
+	It("should keep track of the responses of peers with skewed clocks", func() {
+		clockSkew := -time.Minute
+		responsesChan := make(chan peerResponse, 2)
+		responsesChan <- peerResponse{ips: []string{"10.0.0.2"}, code: poisonPill.ClockSkewed, clockSkew: &clockSkew}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.1"}, code: poisonPill.Unhealthy}
+		Expect(check.evaluatePeerResponses(context.Background(), responsesChan, 2, 2, map[string]string{"10.0.0.2": "node2"})).To(BeFalse())
+		Expect(check.lastPeerResults["node2"].Response).To(Equal(v1alpha1.PeerResponseClockSkewed))
+		Expect(check.lastPeerClockSkews).To(Equal(map[string]time.Duration{"node2": -time.Minute}))
+	})
+
+	It("should exclude peers with skewed clocks from the majority of api errors", func() {
+		check.config.FenceOnIndeterminate = true
+		clockSkew := time.Hour
+		responsesChan := make(chan peerResponse, 4)
+		responsesChan <- peerResponse{ips: []string{"10.0.0.1"}, code: poisonPill.ApiError}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.2"}, code: poisonPill.ApiError}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.3"}, code: poisonPill.RequestFailed}
+		responsesChan <- peerResponse{ips: []string{"10.0.0.4"}, code: poisonPill.ClockSkewed, clockSkew: &clockSkew}
+		// 2 api errors out of 4 peers aren't a majority, out of the 3 peers with a correct clock they are
+		Expect(check.evaluatePeerResponses(context.Background(), responsesChan, 4, 4, nil)).To(BeTrue())
+	})
+
+	It("should consider the verdict indeterminate when all peers seem skewed, because our own clock might be skewed", func() {
+		clockSkew := time.Hour
+		allSkewed := func() <-chan peerResponse {
+			responsesChan := make(chan peerResponse, 2)
+			responsesChan <- peerResponse{ips: []string{"10.0.0.1"}, code: poisonPill.ClockSkewed, clockSkew: &clockSkew}
+			responsesChan <- peerResponse{ips: []string{"10.0.0.2"}, code: poisonPill.ClockSkewed, clockSkew: &clockSkew}
+			return responsesChan
+		}
+		Expect(check.evaluatePeerResponses(context.Background(), allSkewed(), 2, 2, nil)).To(BeTrue())
+		check.config.FenceOnIndeterminate = true
+		Expect(check.evaluatePeerResponses(context.Background(), allSkewed(), 2, 2, nil)).To(BeFalse())
+	})
+})
+
+// answeringPeer answers every health request with the given status and a time which deviates by the given skew
+type answeringPeer struct {
+	peerhealth.UnimplementedPeerHealthServer
+	status poisonPill.HealthCheckResponseCode
+	skew   time.Duration
+}
+
+func (p *answeringPeer) IsHealthy(_ context.Context, _ *peerhealth.HealthRequest) (*peerhealth.HealthResponse, error) {
+	return &peerhealth.HealthResponse{
+		Status:    int32(p.status),
+		Timestamp: time.Now().Add(p.skew).UnixNano(),
+	}, nil
+}
+
+var _ = Describe("Peers with skewed clocks", func() {
+
+	var check *ApiConnectivityCheck
+	var peer *answeringPeer
+	var grpcServer *grpc.Server
+
+	BeforeEach(func() {
+		caPem, certPem, keyPem, err := certificates.CreateCerts()
+		Expect(err).ToNot(HaveOccurred())
+		certReader := &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+
+		serverCreds, err := certificates.GetServerCredentialsFromCerts(certReader, certificates.DefaultTLSOptions())
+		Expect(err).ToNot(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		peer = &answeringPeer{skew: time.Hour}
+		grpcServer = grpc.NewServer(grpc.Creds(serverCreds))
+		peerhealth.RegisterPeerHealthServer(grpcServer, peer)
+		go grpcServer.Serve(listener)
+
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			MaxErrorsThreshold: 1,
+			CertReader:         certReader,
+			PeerTLSOptions:     certificates.DefaultTLSOptions(),
+			PeerHealthPort:     listener.Addr().(*net.TCPAddr).Port,
+			PeerDialTimeout:    5 * time.Second,
+			PeerRequestTimeout: 5 * time.Second,
+		})
+	})
+
+	AfterEach(func() {
+		grpcServer.Stop()
+	})
+
+	It("should ignore healthy and api error responses", func() {
+		for _, peerStatus := range []poisonPill.HealthCheckResponseCode{poisonPill.Healthy, poisonPill.ApiError} {
+			peer.status = peerStatus
+			code, clockSkew, _ := check.getHealthStatusFromIp(context.Background(), "127.0.0.1")
+			Expect(code).To(Equal(poisonPill.ClockSkewed))
+			Expect(*clockSkew).To(BeNumerically(">", DefaultMaxPeerClockSkew))
+		}
+	})
+
+	It("should keep unhealthy responses, because the remediation exists regardless of the clocks", func() {
+		peer.status = poisonPill.Unhealthy
+		code, clockSkew, _ := check.getHealthStatusFromIp(context.Background(), "127.0.0.1")
+		Expect(code).To(Equal(poisonPill.Unhealthy))
+		Expect(*clockSkew).To(BeNumerically(">", DefaultMaxPeerClockSkew))
+	})
+})
+
+var _ = Describe("Api failure simulation", func() {
+
+	var check *ApiConnectivityCheck
+	var rebooter *countingRebooter
+	var reachable []apiServerEndpoint
+	var listener net.Listener
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		rebooter = &countingRebooter{}
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			CheckInterval:      time.Second,
+			MaxErrorsThreshold: 2,
+			Peers:              peers.New("node1", time.Hour, nil, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil),
+			Rebooter:           rebooter,
+			Cfg:                &rest.Config{},
+			ApiServerEndpoints: []string{"https://" + listener.Addr().String()},
+			ProbeMode:          ProbeModeTCPConnect,
+			ApiServerTimeout:   time.Second,
+		})
+		reachable, err = check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("should treat a reachable api server as unreachable while simulating", func() {
+		check.SimulateFailure(time.Minute)
+		check.check(context.Background(), reachable, nil)
+		status := check.GetStatus()
+		Expect(status.Phase).To(Equal(PhaseSuspect))
+		Expect(status.LastCheckError).To(Equal(simulatedFailure))
+		Expect(check.errorCount).To(Equal(1))
+
+		check.SimulateFailure(0)
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseHealthy))
+		Expect(rebooter.reboots).To(BeZero())
+	})
+
+	It("should end the simulation after its duration", func() {
+		check.SimulateFailure(time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		check.check(context.Background(), reachable, nil)
+		Expect(check.GetStatus().Phase).To(Equal(PhaseHealthy))
+	})
+
+	It("should only start simulations with a valid duration", func() {
+		server := NewStatusServer("", check, true, ctrl.Log.WithName("status-server"))
+		simulate := func(method string, duration string) int {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, "/simulate-api-failure?duration="+duration, nil)
+			request.RemoteAddr = "127.0.0.1:40000"
+			server.serveSimulateFailure(recorder, request)
+			return recorder.Code
+		}
+		Expect(simulate(http.MethodGet, "60s")).To(Equal(http.StatusMethodNotAllowed))
+		Expect(simulate(http.MethodPost, "forever")).To(Equal(http.StatusBadRequest))
+		Expect(simulate(http.MethodPost, "2h")).To(Equal(http.StatusBadRequest))
+		Expect(check.isFailureSimulated()).To(BeFalse())
+
+		Expect(simulate(http.MethodPost, "60s")).To(Equal(http.StatusAccepted))
+		Expect(check.isFailureSimulated()).To(BeTrue())
+	})
+
+	It("should only accept simulations from within the pod", func() {
+		server := NewStatusServer("", check, true, ctrl.Log.WithName("status-server"))
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/simulate-api-failure?duration=60s", nil)
+		request.RemoteAddr = "10.0.0.2:40000"
+		server.serveSimulateFailure(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(check.isFailureSimulated()).To(BeFalse())
+	})
+
+	It("should not reboot on a simulated failure unless configured", func() {
+		check.SimulateFailure(time.Minute)
+		Expect(check.reboot()).To(Succeed())
+		Expect(rebooter.reboots).To(BeZero())
+
+		// the fencing phase of the simulation ends without reboot
+		check.setStatus(simulatedFailure, PhaseFencing)
+		check.abortFencing()
+		Expect(check.GetStatus().Phase).To(Equal(PhaseSuspect))
+
+		check.config.SimulatedFailureFencing = true
+		Expect(check.reboot()).To(Succeed())
+		Expect(rebooter.reboots).To(Equal(1))
+	})
+})
+
+var _ = Describe("Slow peers", func() {
+
+	var check *ApiConnectivityCheck
+	var listener net.Listener
+	var slowPeers [][]string
+
+	BeforeEach(func() {
+		caPem, certPem, keyPem, err := certificates.CreateCerts()
+		Expect(err).ToNot(HaveOccurred())
+
+		// the kernel accepts connections to the listener, but nobody completes the TLS handshake
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			MaxErrorsThreshold: 1,
+			CertReader:         &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem},
+			PeerTLSOptions:     certificates.DefaultTLSOptions(),
+			PeerHealthPort:     listener.Addr().(*net.TCPAddr).Port,
+			PeerDialTimeout:    time.Minute,
+			PeerRequestTimeout: time.Minute,
+		})
+		slowPeers = [][]string{{"127.0.0.1"}, {"127.0.0.1"}, {"127.0.0.1"}}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("should stop asking them when the time is up, without leaking goroutines", func() {
+		goroutinesBefore := runtime.NumGoroutine()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		// no peer responded, so we are unhealthy
+		Expect(check.evaluatePeerResponses(ctx, check.askPeers(ctx, slowPeers), len(slowPeers), len(slowPeers), nil)).To(BeFalse())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+
+		Eventually(runtime.NumGoroutine, 5*time.Second, 100*time.Millisecond).Should(BeNumerically("<=", goroutinesBefore))
+	})
+
+	It("should not decide when asking them is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(500*time.Millisecond, cancel)
+		Expect(check.evaluatePeerResponses(ctx, check.askPeers(ctx, slowPeers), len(slowPeers), len(slowPeers), nil)).To(BeTrue())
+	})
+})
+
+// nodeReader is a read only client for tests, which knows the given nodes
+type nodeReader struct {
+	nodes []v1.Node
+}
+
+var _ client.Reader = &nodeReader{}
+
+func (r *nodeReader) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	for _, node := range r.nodes {
+		if node.Name == key.Name {
+			node.DeepCopyInto(obj.(*v1.Node))
+			return nil
+		}
+	}
+	return fmt.Errorf("node %s not found", key.Name)
+}
+
+func (r *nodeReader) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	nodes := list.(*v1.NodeList)
+	for _, node := range r.nodes {
+		if listOpts.LabelSelector == nil || listOpts.LabelSelector.Matches(labels.Set(node.Labels)) {
+			nodes.Items = append(nodes.Items, *node.DeepCopy())
+		}
+	}
+	return nil
+}
+
+// throttlingPeer drops every health request, like a peer which is busy with the requests of other nodes
+type throttlingPeer struct {
+	peerhealth.UnimplementedPeerHealthServer
+}
+
+func (p *throttlingPeer) IsHealthy(_ context.Context, _ *peerhealth.HealthRequest) (*peerhealth.HealthResponse, error) {
+	return nil, status.Error(codes.ResourceExhausted, "too many concurrent health requests")
+}
+
+var _ = Describe("Throttled peers", func() {
+
+	var check *ApiConnectivityCheck
+	var rebooter *countingRebooter
+	var grpcServer *grpc.Server
+	var cancel context.CancelFunc
+
+	newNode := func(name string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"kubernetes.io/hostname":         name,
+					"node-role.kubernetes.io/worker": "",
+				},
+			},
+			// all peers are served by the same throttling peer
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "127.0.0.1"}}},
+		}
+	}
+
+	BeforeEach(func() {
+		caPem, certPem, keyPem, err := certificates.CreateCerts()
+		Expect(err).ToNot(HaveOccurred())
+		certReader := &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+
+		serverCreds, err := certificates.GetServerCredentialsFromCerts(certReader, certificates.DefaultTLSOptions())
+		Expect(err).ToNot(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer = grpc.NewServer(grpc.Creds(serverCreds))
+		peerhealth.RegisterPeerHealthServer(grpcServer, &throttlingPeer{})
+		go grpcServer.Serve(listener)
+
+		reader := &nodeReader{nodes: []v1.Node{newNode("node1"), newNode("node2"), newNode("node3"), newNode("node4")}}
+		myPeers := peers.New("node1", time.Hour, reader, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go myPeers.Start(ctx)
+		Eventually(myPeers.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(3))
+
+		rebooter = &countingRebooter{}
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                ctrl.Log.WithName("api-check"),
+			MyNodeName:         "node1",
+			CheckInterval:      time.Second,
+			MaxErrorsThreshold: 1,
+			Peers:              myPeers,
+			Rebooter:           rebooter,
+			Cfg:                &rest.Config{},
+			ProbeMode:          ProbeModeTCPConnect,
+			ApiServerTimeout:   time.Second,
+			CertReader:         certReader,
+			PeerTLSOptions:     certificates.DefaultTLSOptions(),
+			PeerHealthPort:     listener.Addr().(*net.TCPAddr).Port,
+			PeerDialTimeout:    5 * time.Second,
+			PeerRequestTimeout: 5 * time.Second,
+		})
+	})
+
+	AfterEach(func() {
+		cancel()
+		grpcServer.Stop()
+	})
+
+	It("should not reboot when all peers are too busy to answer", func() {
+		// nothing listens on port 1
+		check.config.ApiServerEndpoints = []string{"https://127.0.0.1:1"}
+		unreachable, err := check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+
+		check.check(context.Background(), unreachable, nil)
+		Expect(check.peerResults).To(HaveLen(3))
+		for _, result := range check.peerResults {
+			Expect(result.Response).To(Equal(v1alpha1.PeerResponseThrottled))
+		}
+		Expect(rebooter.reboots).To(BeZero())
+		Expect(check.GetStatus().Phase).To(Equal(PhaseSuspect))
+	})
+})
+
+var _ = Describe("Small clusters", func() {
+
+	var check *ApiConnectivityCheck
+	var rebooter *countingRebooter
+	var peer *answeringPeer
+	var grpcServer *grpc.Server
+	var cancel context.CancelFunc
+
+	newNode := func(name string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"kubernetes.io/hostname":         name,
+					"node-role.kubernetes.io/worker": "",
+				},
+			},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "127.0.0.1"}}},
+		}
+	}
+
+	BeforeEach(func() {
+		caPem, certPem, keyPem, err := certificates.CreateCerts()
+		Expect(err).ToNot(HaveOccurred())
+		certReader := &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+
+		serverCreds, err := certificates.GetServerCredentialsFromCerts(certReader, certificates.DefaultTLSOptions())
+		Expect(err).ToNot(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		peer = &answeringPeer{}
+		grpcServer = grpc.NewServer(grpc.Creds(serverCreds))
+		peerhealth.RegisterPeerHealthServer(grpcServer, peer)
+		go grpcServer.Serve(listener)
+
+		reader := &nodeReader{nodes: []v1.Node{newNode("node1"), newNode("node2")}}
+		myPeers := peers.New("node1", time.Hour, reader, ctrl.Log.WithName("peers"), time.Second, peers.Random, nil)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go myPeers.Start(ctx)
+		Eventually(myPeers.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(1))
+
+		rebooter = &countingRebooter{}
+		check = New(&ApiConnectivityCheckConfig{
+			Log:                      ctrl.Log.WithName("api-check"),
+			MyNodeName:               "node1",
+			CheckInterval:            time.Second,
+			MaxErrorsThreshold:       1,
+			Peers:                    myPeers,
+			Rebooter:                 rebooter,
+			Cfg:                      &rest.Config{},
+			ProbeMode:                ProbeModeTCPConnect,
+			ApiServerTimeout:         time.Second,
+			ApiServerEndpoints:       []string{"https://127.0.0.1:1"},
+			CertReader:               certReader,
+			PeerTLSOptions:           certificates.DefaultTLSOptions(),
+			PeerHealthPort:           listener.Addr().(*net.TCPAddr).Port,
+			PeerDialTimeout:          5 * time.Second,
+			PeerRequestTimeout:       5 * time.Second,
+			MinClusterSizeForFencing: 3,
+		})
+	})
+
+	AfterEach(func() {
+		cancel()
+		grpcServer.Stop()
+	})
+
+	It("should not fence without a remediation of this node", func() {
+		// nothing listens on port 1
+		unreachable, err := check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+
+		peer.status = poisonPill.RequestFailed
+		check.check(context.Background(), unreachable, nil)
+		Expect(rebooter.reboots).To(BeZero())
+		Expect(check.GetStatus().Phase).To(Equal(PhaseDeferred))
+	})
+
+	It("should fence when a peer reported a remediation of this node", func() {
+		unreachable, err := check.createApiServerEndpoints()
+		Expect(err).ToNot(HaveOccurred())
+
+		peer.status = poisonPill.Unhealthy
+		check.check(context.Background(), unreachable, nil)
+		Expect(rebooter.reboots).To(Equal(1))
+		Expect(check.GetStatus().Phase).To(Equal(PhaseFencing))
+	})
+})
+
+var _ = Describe("Peer network interface", func() {
+
+	It("should refuse an interface which doesn't exist on the host", func() {
+		_, err := NewPeerDialer("no-such-nic")
+		Expect(err).To(HaveOccurred())
+	})
+})
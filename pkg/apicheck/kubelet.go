@@ -0,0 +1,135 @@
+package apicheck
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultKubeletHealthzURL is the default healthz endpoint of the kubelet, it's only bound to the loopback
+	// interface of the host
+	DefaultKubeletHealthzURL = "http://127.0.0.1:10248/healthz"
+	// hostNetNSPath is the network namespace of the host, it's visible because the agent runs with hostPID
+	hostNetNSPath = "/proc/1/ns/net"
+)
+
+// probeKubelet checks the local kubelet, if configured, and records its health
+func (c *ApiConnectivityCheck) probeKubelet(ctx context.Context) {
+	if c.config.KubeletHealthzURL == "" {
+		return
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, c.config.ApiServerTimeout)
+	defer cancel()
+
+	failure := c.getKubeletHealthz(probeCtx)
+	if failure != c.kubeletFailure || !c.kubeletProbed {
+		if failure != "" {
+			c.config.Log.Info("local kubelet is unhealthy", "failure", failure)
+		} else {
+			c.config.Log.Info("local kubelet is healthy")
+		}
+	}
+	c.kubeletFailure = failure
+	c.kubeletProbed = true
+}
+
+// getKubeletHealthz requests the healthz endpoint of the kubelet and returns an empty string when it's healthy, else a
+// description of the failure
+func (c *ApiConnectivityCheck) getKubeletHealthz(ctx context.Context) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.KubeletHealthzURL, nil)
+	if err != nil {
+		return fmt.Sprintf("invalid kubelet healthz url: %v", err)
+	}
+	transport := c.config.KubeletTransport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return fmt.Sprintf("kubelet healthz request error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("kubelet healthz status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return ""
+}
+
+// NewHostNetworkTransport returns a transport which connects from the network namespace of the host, e.g. for
+// reaching the kubelet healthz endpoint on the loopback interface of the host
+func NewHostNetworkTransport() http.RoundTripper {
+	return &http.Transport{
+		DialContext:       dialHostNetwork,
+		DisableKeepAlives: true,
+	}
+}
+
+// dialHostNetwork opens the connection on an OS thread which temporarily switched to the network namespace of the
+// host. The socket stays in that namespace after the thread switched back.
+func dialHostNetwork(ctx context.Context, network, address string) (net.Conn, error) {
+	return dialHostNetworkWith(ctx, &net.Dialer{}, network, address)
+}
+
+// dialHostNetworkWith opens the connection with the given dialer like dialHostNetwork
+func dialHostNetworkWith(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	var conn net.Conn
+	var dialErr error
+	if err := runInHostNetwork(func() {
+		conn, dialErr = dialer.DialContext(ctx, network, address)
+	}); err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, err
+	}
+	return conn, dialErr
+}
+
+// runInHostNetwork runs the given func on an OS thread which temporarily switched to the network namespace of the
+// host
+func runInHostNetwork(fn func()) error {
+	errChan := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		restored, err := runInNetNS(hostNetNSPath, fn)
+		// a thread which is still in the wrong namespace must not be reused, it's terminated together with this
+		// goroutine when it stays locked
+		if restored {
+			runtime.UnlockOSThread()
+		}
+		errChan <- err
+	}()
+	return <-errChan
+}
+
+// runInNetNS runs the given func in the given network namespace, it must be called on a locked OS thread. It returns
+// if the thread was switched back to its own namespace.
+func runInNetNS(nsPath string, fn func()) (bool, error) {
+	ownNS, err := unix.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return true, fmt.Errorf("failed to open own network namespace: %v", err)
+	}
+	defer unix.Close(ownNS)
+	targetNS, err := unix.Open(nsPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return true, fmt.Errorf("failed to open network namespace %s: %v", nsPath, err)
+	}
+	defer unix.Close(targetNS)
+
+	if err := unix.Setns(targetNS, unix.CLONE_NEWNET); err != nil {
+		return true, fmt.Errorf("failed to switch to network namespace %s: %v", nsPath, err)
+	}
+	fn()
+	if err := unix.Setns(ownNS, unix.CLONE_NEWNET); err != nil {
+		return false, fmt.Errorf("failed to switch back from network namespace %s: %v", nsPath, err)
+	}
+	return true, nil
+}
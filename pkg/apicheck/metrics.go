@@ -0,0 +1,63 @@
+package apicheck
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	quorumHealthy = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_quorum_healthy_total",
+		Help: "Number of peer verdicts which considered this node healthy",
+	})
+	quorumUnhealthy = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_quorum_unhealthy_total",
+		Help: "Number of peer verdicts which confirmed that this node is unhealthy",
+	})
+	quorumIndeterminate = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_quorum_indeterminate_total",
+		Help: "Number of peer verdicts without enough peer responses, the decision was made without confirmation of the peers",
+	})
+	quorumUnresponsive = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_quorum_unresponsive_total",
+		Help: "Number of peer verdicts which considered this node unhealthy, because no peer responded conclusively",
+	})
+	fencingAborted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_fencing_aborted_total",
+		Help: "Number of reboots of this node which were aborted, because the api server became reachable again before the watchdog fired",
+	})
+	consecutiveErrors = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poison_pill_api_check_consecutive_errors",
+		Help: "Number of consecutive failed api server checks, the node asks its peers when it reaches the max errors threshold",
+	})
+	maxErrorsThreshold = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poison_pill_api_check_max_errors_threshold",
+		Help: "Number of consecutive failed api server checks after which the node asks its peers if it is healthy",
+	})
+	indeterminateFencing = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_quorum_indeterminate_fencing_total",
+		Help: "Number of indeterminate peer verdicts which considered this node unhealthy, because fencing on indeterminate verdicts is enabled",
+	})
+	quorumLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "poison_pill_peer_quorum_latency_seconds",
+		Help:    "Time from the start of asking the peers until a verdict was reached",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+	peerAuthFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_auth_failures_total",
+		Help: "Number of peer requests which failed because of a TLS authentication error",
+	})
+	peerClockSkewExceeded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_clock_skew_exceeded_total",
+		Help: "Number of peer responses which were ignored, because the clock of the peer deviated more than the max peer clock skew",
+	})
+	peerThrottled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_peer_throttled_total",
+		Help: "Number of peer requests which the peer dropped, because it was busy with the requests of other nodes",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(quorumHealthy, quorumUnhealthy, quorumIndeterminate, quorumUnresponsive, indeterminateFencing, quorumLatency, fencingAborted, consecutiveErrors, maxErrorsThreshold, peerAuthFailures, peerClockSkewExceeded, peerThrottled)
+}
@@ -0,0 +1,55 @@
+package apicheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/medik8s/poison-pill/pkg/peerhealth"
+)
+
+// NewPeerDialer returns a dialer which connects to the peers from the network namespace of the host through the given
+// network interface, e.g. the one of the cluster network, so that an outage of another network of the node, which the
+// default route might use, doesn't affect the peer health checks. The connections are bound to the interface, so the
+// source address is one of its addresses. It returns an error when the interface doesn't exist on the host or doesn't
+// have an address.
+func NewPeerDialer(interfaceName string) (peerhealth.Dialer, error) {
+	var addrs []net.Addr
+	var lookupErr error
+	if err := runInHostNetwork(func() {
+		var iface *net.Interface
+		if iface, lookupErr = net.InterfaceByName(interfaceName); lookupErr != nil {
+			return
+		}
+		addrs, lookupErr = iface.Addrs()
+	}); err != nil {
+		return nil, err
+	}
+	if lookupErr != nil {
+		return nil, fmt.Errorf("failed to look up peer network interface %s: %v", interfaceName, lookupErr)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("peer network interface %s has no address", interfaceName)
+	}
+
+	dialer := &net.Dialer{
+		Control: func(_, _ string, conn syscall.RawConn) error {
+			var bindErr error
+			if err := conn.Control(func(fd uintptr) {
+				bindErr = unix.SetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE, interfaceName)
+			}); err != nil {
+				return err
+			}
+			if bindErr != nil {
+				return fmt.Errorf("failed to bind to peer network interface %s: %v", interfaceName, bindErr)
+			}
+			return nil
+		},
+	}
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		return dialHostNetworkWith(ctx, dialer, "tcp", address)
+	}, nil
+}
@@ -0,0 +1,123 @@
+package apicheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"k8s.io/client-go/rest"
+)
+
+// ProbeMode defines how the api server endpoints are probed
+type ProbeMode string
+
+const (
+	// ProbeModeTCPConnect only opens a TCP connection to the api server
+	ProbeModeTCPConnect ProbeMode = "TCPConnect"
+	// ProbeModeTLSHandshake opens a TCP connection and completes a TLS handshake with the api server
+	ProbeModeTLSHandshake ProbeMode = "TLSHandshake"
+	// ProbeModeHTTPGet requests the /readyz endpoint of the api server and treats every status code but 200 as error.
+	// This is the default.
+	ProbeModeHTTPGet ProbeMode = "HTTPGet"
+)
+
+// IsValid returns if the probe mode is known. An empty probe mode is valid and means ProbeModeHTTPGet.
+func (m ProbeMode) IsValid() bool {
+	switch m {
+	case "", ProbeModeTCPConnect, ProbeModeTLSHandshake, ProbeModeHTTPGet:
+		return true
+	}
+	return false
+}
+
+// probe checks a single api server endpoint with the configured probe mode and returns an empty string on success,
+// else a description of the failure
+func (c *ApiConnectivityCheck) probe(ctx context.Context, endpoint apiServerEndpoint) string {
+	switch c.config.ProbeMode {
+	case ProbeModeTCPConnect:
+		return probeTCPConnect(ctx, endpoint)
+	case ProbeModeTLSHandshake:
+		return probeTLSHandshake(ctx, endpoint)
+	default:
+		return probeHTTPGet(ctx, endpoint)
+	}
+}
+
+func probeTCPConnect(ctx context.Context, endpoint apiServerEndpoint) string {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint.address)
+	if err != nil {
+		return fmt.Sprintf("api server tcp connect error: %v", err)
+	}
+	_ = conn.Close()
+	return ""
+}
+
+func probeTLSHandshake(ctx context.Context, endpoint apiServerEndpoint) string {
+	dialer := &tls.Dialer{Config: endpoint.tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint.address)
+	if err != nil {
+		return fmt.Sprintf("api server tls handshake error: %v", err)
+	}
+	_ = conn.Close()
+	return ""
+}
+
+func probeHTTPGet(ctx context.Context, endpoint apiServerEndpoint) string {
+	result := endpoint.restClient.Verb(http.MethodGet).RequestURI("/readyz").Do(ctx)
+	if result.Error() != nil {
+		return fmt.Sprintf("api server readyz endpoint error: %v", result.Error())
+	}
+	statusCode := 0
+	result.StatusCode(&statusCode)
+	if statusCode != 200 {
+		return fmt.Sprintf("api server readyz endpoint status code: %v", statusCode)
+	}
+	return ""
+}
+
+// hostAddress returns the host:port address of the given api server host, which might be a URL or a plain host
+func hostAddress(host string) (string, error) {
+	hostURL, err := url.Parse(host)
+	if err != nil || hostURL.Host == "" {
+		// no scheme, e.g. 10.0.0.1:6443
+		hostURL, err = url.Parse("https://" + host)
+		if err != nil {
+			return "", err
+		}
+	}
+	if hostURL.Port() != "" {
+		return hostURL.Host, nil
+	}
+	if hostURL.Scheme == "http" {
+		return net.JoinHostPort(hostURL.Hostname(), "80"), nil
+	}
+	return net.JoinHostPort(hostURL.Hostname(), "443"), nil
+}
+
+// probeTLSConfig returns the TLS config used for TLS handshake probes of the given rest config
+func probeTLSConfig(cfg *rest.Config, address string) (*tls.Config, error) {
+	var tlsConfig *tls.Config
+	if transport, ok := cfg.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	} else {
+		var err error
+		if tlsConfig, err = rest.TLSConfigFor(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = host
+	}
+	return tlsConfig, nil
+}
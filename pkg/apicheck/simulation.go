@@ -0,0 +1,72 @@
+package apicheck
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// simulatedFailure is the failure reported by the api server checks while a failure is simulated
+	simulatedFailure = "simulated api server failure"
+	// maxSimulatedFailureDuration limits the duration of a single failure simulation, so that a forgotten simulation
+	// ends eventually
+	maxSimulatedFailureDuration = time.Hour
+)
+
+// SimulateFailure makes the check treat the api server as unreachable for the given duration, without touching the
+// real api server connection. Everything else works as on a real failure: the errors count towards the
+// MaxErrorsThreshold and the peers are asked. When they confirm that the node is unhealthy, the reboot is only logged,
+// unless SimulatedFailureFencing is set. A zero duration ends a running simulation.
+func (c *ApiConnectivityCheck) SimulateFailure(duration time.Duration) {
+	if duration <= 0 {
+		atomic.StoreInt64(&c.simulatedFailureEnd, 0)
+		c.config.Log.Info("api server failure simulation ended")
+		return
+	}
+	end := time.Now().Add(duration)
+	atomic.StoreInt64(&c.simulatedFailureEnd, end.UnixNano())
+	c.config.Log.Info("SIMULATING API SERVER FAILURE, the api server is treated as unreachable", "until", end)
+}
+
+// isFailureSimulated returns if the api server should be treated as unreachable because of a SimulateFailure call
+func (c *ApiConnectivityCheck) isFailureSimulated() bool {
+	end := atomic.LoadInt64(&c.simulatedFailureEnd)
+	return end != 0 && time.Now().UnixNano() < end
+}
+
+// serveSimulateFailure starts an api server failure simulation with the duration given by the duration parameter,
+// e.g. POST /simulate-api-failure?duration=60s. Only requests from the loopback interface are accepted, so that
+// simulations can only be started from within the pod, e.g. by kubectl exec, which requires permissions on the pod.
+func (s *StatusServer) serveSimulateFailure(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		s.log.Info("rejected api server failure simulation request from outside of the pod", "remote address", r.RemoteAddr)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil || duration < 0 || duration > maxSimulatedFailureDuration {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "duration must be a duration between 0s and %s\n", maxSimulatedFailureDuration)
+		return
+	}
+	s.log.Info("api server failure simulation requested", "duration", duration, "remote address", r.RemoteAddr)
+	s.check.SimulateFailure(duration)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// isLoopback returns if the given remote address of a request is a loopback address
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,218 @@
+package apicheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+	"github.com/medik8s/poison-pill/pkg/peers"
+)
+
+// Phase is the self assessment of the node
+type Phase string
+
+const (
+	// PhaseHealthy means that the api server is reachable
+	PhaseHealthy Phase = "healthy"
+	// PhaseSuspect means that the api server isn't reachable, but the node isn't considered unhealthy (yet)
+	PhaseSuspect Phase = "suspect"
+	// PhaseFencing means that the node considers itself unhealthy and triggered a reboot
+	PhaseFencing Phase = "fencing"
+	// PhaseDeferred means that the node considers itself unhealthy, but doesn't reboot because its remediation is
+	// disabled by annotation, or because the cluster is too small for safe remediation
+	PhaseDeferred Phase = "deferred"
+)
+
+// Status is the current state of the api connectivity check
+type Status struct {
+	LastCheckTime      time.Time `json:"lastCheckTime"`
+	LastCheckSucceeded bool      `json:"lastCheckSucceeded"`
+	LastCheckError     string    `json:"lastCheckError,omitempty"`
+	ConsecutiveErrors  int       `json:"consecutiveErrors"`
+	// ErrorThreshold is the number of consecutive errors which makes the node ask its peers whether it's unhealthy
+	ErrorThreshold int `json:"errorThreshold"`
+	// TimeUntilFencing is only set in the suspect phase. It's the worst case time between the last check and the
+	// next time the node decides about fencing, when all checks until then fail.
+	TimeUntilFencing string `json:"timeUntilFencing,omitempty"`
+	PeersQueried     int    `json:"peersQueried"`
+	Phase            Phase  `json:"phase"`
+	// KubeletHealthy is only set when the local kubelet is probed
+	KubeletHealthy *bool  `json:"kubeletHealthy,omitempty"`
+	KubeletError   string `json:"kubeletError,omitempty"`
+}
+
+// PeerStatus is the state of a peer as known by this node
+type PeerStatus struct {
+	// NodeName is the hostname of the peer, or its first internal IP if its hostname is unknown
+	NodeName string `json:"nodeName"`
+	// Addresses are the internal IPs of the peer, which are asked in order
+	Addresses []string `json:"addresses"`
+	// Reachable, LastResponse and LastResponseTime are only set when the peer was asked since the start of the agent.
+	// Reachable tells if the peer responded the last time it was asked, and LastResponse is its last vote.
+	Reachable        *bool                 `json:"reachable,omitempty"`
+	LastResponse     v1alpha1.PeerResponse `json:"lastResponse,omitempty"`
+	LastResponseTime *metav1.Time          `json:"lastResponseTime,omitempty"`
+	// ClockSkew is the last measured deviation of the clock of the peer from the clock of this node, e.g. "-1.5s".
+	// It's only set when the peer reported its time.
+	ClockSkew string `json:"clockSkew,omitempty"`
+}
+
+// PeersStatus is the state of the peers as known by this node
+type PeersStatus struct {
+	PeerGroup peers.PeerGroup `json:"peerGroup"`
+	// ClusterSize is the number of nodes in the cluster, including this node, as observed by the last peer updates
+	ClusterSize int          `json:"clusterSize"`
+	Peers       []PeerStatus `json:"peers"`
+}
+
+// GetPeersStatus returns the peers this node currently knows about, and their last responses
+func (c *ApiConnectivityCheck) GetPeersStatus() PeersStatus {
+	status := PeersStatus{Peers: []PeerStatus{}}
+	if c.config.Peers == nil {
+		return status
+	}
+	status.PeerGroup = c.config.Peers.GetPeerGroup()
+	status.ClusterSize = c.config.Peers.GetClusterSize()
+	peersAddresses := c.config.Peers.GetPeersAddresses()
+
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	for _, addresses := range peersAddresses {
+		ips := peers.GetInternalIPs(addresses)
+		peer := PeerStatus{
+			NodeName:  peers.GetHostname(addresses),
+			Addresses: ips,
+		}
+		if peer.NodeName == "" && len(ips) > 0 {
+			peer.NodeName = ips[0]
+		}
+		if result, asked := c.lastPeerResults[peer.NodeName]; asked {
+			reachable := result.Response != v1alpha1.PeerResponseTimeout
+			peer.Reachable = &reachable
+			peer.LastResponse = result.Response
+			peer.LastResponseTime = result.Time.DeepCopy()
+		}
+		if clockSkew, measured := c.lastPeerClockSkews[peer.NodeName]; measured {
+			peer.ClockSkew = clockSkew.String()
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+	return status
+}
+
+// GetStatus returns the current state of the api connectivity check
+func (c *ApiConnectivityCheck) GetStatus() Status {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	return c.status
+}
+
+func (c *ApiConnectivityCheck) setStatus(failure string, phase Phase) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	c.status.LastCheckTime = time.Now()
+	c.status.LastCheckSucceeded = failure == ""
+	c.status.LastCheckError = failure
+	c.status.ConsecutiveErrors = c.errorCount
+	consecutiveErrors.Set(float64(c.errorCount))
+	c.status.ErrorThreshold = c.config.MaxErrorsThreshold
+	c.status.TimeUntilFencing = ""
+	if phase == PhaseSuspect && c.errorCount > 0 {
+		// once the threshold is reached, the peers are asked again on every failed check
+		targetCount := c.config.MaxErrorsThreshold
+		if targetCount <= c.errorCount {
+			targetCount = c.errorCount + 1
+		}
+		c.status.TimeUntilFencing = maxTimeToReachErrorCount(c.config.CheckInterval, c.config.ApiServerTimeout, c.errorCount, targetCount).String()
+	}
+	c.status.PeersQueried = c.peersQueried
+	if c.kubeletProbed {
+		kubeletHealthy := c.kubeletFailure == ""
+		c.status.KubeletHealthy = &kubeletHealthy
+		c.status.KubeletError = c.kubeletFailure
+	}
+	// a triggered reboot is only undone by abortFencing
+	if c.status.Phase != PhaseFencing && c.status.Phase != phase {
+		c.config.Log.Info("phase changed", "phase", phase, "previous phase", c.status.Phase)
+		c.status.Phase = phase
+	}
+}
+
+// StatusServer serves the status of the api connectivity check and the peers as JSON. When the failure simulation is enabled, it
+// also serves the /simulate-api-failure endpoint for chaos testing.
+type StatusServer struct {
+	bindAddress       string
+	check             *ApiConnectivityCheck
+	failureSimulation bool
+	log               logr.Logger
+}
+
+func NewStatusServer(bindAddress string, check *ApiConnectivityCheck, failureSimulation bool, log logr.Logger) *StatusServer {
+	return &StatusServer{
+		bindAddress:       bindAddress,
+		check:             check,
+		failureSimulation: failureSimulation,
+		log:               log,
+	}
+}
+
+// Start implements Runnable for usage by manager
+func (s *StatusServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.serveStatus)
+	mux.HandleFunc("/peers", s.servePeers)
+	if s.failureSimulation {
+		mux.HandleFunc("/simulate-api-failure", s.serveSimulateFailure)
+	}
+	server := &http.Server{
+		Addr:    s.bindAddress,
+		Handler: mux,
+	}
+
+	errChan := make(chan error)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- err
+		}
+	}()
+
+	s.log.Info("status server started", "address", s.bindAddress, "api failure simulation", s.failureSimulation)
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *StatusServer) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.check.GetStatus()); err != nil {
+		s.log.Error(err, "failed to write status")
+	}
+}
+
+func (s *StatusServer) servePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.check.GetPeersStatus()); err != nil {
+		s.log.Error(err, "failed to write peers status")
+	}
+}
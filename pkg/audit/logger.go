@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+// DefaultMaxSize is the size at which the audit log is rotated, when no other size is configured
+const DefaultMaxSize int64 = 10 * 1024 * 1024
+
+// Outcome is the outcome of a fencing decision
+type Outcome string
+
+const (
+	// OutcomeFenced is used when the node decided to reboot itself, because its api server access failed and its
+	// peers didn't report it as healthy
+	OutcomeFenced Outcome = "Fenced"
+	// OutcomeRemediated is used when the node reboots itself with api server access, because it's remediated
+	OutcomeRemediated Outcome = "Remediated"
+	// OutcomeAborted is used when the reboot of the node was aborted, because the api server became reachable again
+	// before the watchdog fired
+	OutcomeAborted Outcome = "Aborted"
+)
+
+// Entry is a fencing decision, it's logged as a single JSON line
+type Entry struct {
+	Time time.Time `json:"time"`
+	Node string    `json:"node"`
+	// PeerResults are the responses of the peers which were asked for the health of the node, if any
+	PeerResults []v1alpha1.PeerResult `json:"peerResults,omitempty"`
+	Outcome     Outcome               `json:"outcome"`
+	// Reason is a human readable explanation of the decision
+	Reason string `json:"reason,omitempty"`
+}
+
+// Logger appends fencing decisions to a file on the host, so that the record survives the reboot of the node. The
+// file is rotated when it exceeds the max size, the rotated files get the time of the rotation as suffix and are never
+// removed.
+type Logger struct {
+	path    string
+	maxSize int64
+	log     logr.Logger
+	mutex   sync.Mutex
+}
+
+// NewLogger returns a logger which appends to the file with the given path. A maxSize of 0 uses DefaultMaxSize.
+func NewLogger(path string, maxSize int64, log logr.Logger) *Logger {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Logger{
+		path:    path,
+		maxSize: maxSize,
+		log:     log,
+	}
+}
+
+// Record appends the given entry to the audit log and syncs it to disk, since the node might reboot right after it.
+// Entries without time are recorded with the current time.
+func (l *Logger) Record(entry Entry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	if err := l.rotate(int64(len(line))); err != nil {
+		// losing the rotation is better than losing the entry
+		l.log.Error(err, "failed to rotate audit log", "path", l.path)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rotate renames the audit log when appending the given number of bytes would exceed the max size.
+// The mutex needs to be held by the caller.
+func (l *Logger) rotate(size int64) error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= l.maxSize {
+		return nil
+	}
+	rotatedPath := fmt.Sprintf("%s.%s", l.path, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	if err := os.Rename(l.path, rotatedPath); err != nil {
+		return err
+	}
+	l.log.Info("rotated audit log", "path", l.path, "rotated path", rotatedPath)
+	return nil
+}
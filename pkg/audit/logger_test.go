@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+// TestRecord tests that entries are appended as JSON lines
+func TestRecord(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "audit")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log", "audit.log")
+
+	l := NewLogger(path, 0, ctrl.Log.WithName("audit"))
+	g.Expect(l.Record(Entry{
+		Node:        "worker-1",
+		PeerResults: []v1alpha1.PeerResult{{NodeName: "worker-2", Response: v1alpha1.PeerResponseUnhealthy}},
+		Outcome:     OutcomeFenced,
+	})).To(Succeed())
+	g.Expect(l.Record(Entry{Node: "worker-1", Outcome: OutcomeAborted})).To(Succeed())
+
+	file, err := os.Open(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer file.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := Entry{}
+		g.Expect(json.Unmarshal(scanner.Bytes(), &entry)).To(Succeed())
+		entries = append(entries, entry)
+	}
+	g.Expect(entries).To(HaveLen(2))
+	g.Expect(entries[0].Outcome).To(Equal(OutcomeFenced))
+	g.Expect(entries[0].PeerResults).To(HaveLen(1))
+	g.Expect(entries[0].Time.IsZero()).To(BeFalse())
+	g.Expect(entries[1].Outcome).To(Equal(OutcomeAborted))
+}
+
+// TestRotate tests that the log is rotated when it would exceed the max size, without removing rotated files
+func TestRotate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "audit")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l := NewLogger(path, 100, ctrl.Log.WithName("audit"))
+	for i := 0; i < 3; i++ {
+		g.Expect(l.Record(Entry{Node: "worker-1", Outcome: OutcomeFenced})).To(Succeed())
+	}
+
+	files, err := filepath.Glob(path + "*")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(files).To(HaveLen(3))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(info.Size()).To(BeNumerically("<=", 100))
+	}
+}
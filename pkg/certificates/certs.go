@@ -17,6 +17,9 @@ import (
 // TODO reconsider a better to deal with the IP check...?
 var fixedCertIP = net.IPv4(192, 0, 2, 1)
 
+// agentCommonName is the CN of the server / client certificate, which identifies the sender of peer requests
+const agentCommonName = "poison-pill-agent"
+
 func createCertTemplate(isCa bool) *x509.Certificate {
 	cert := &x509.Certificate{
 		SerialNumber: big.NewInt(12345), // TODO randomize?
@@ -34,6 +37,7 @@ func createCertTemplate(isCa bool) *x509.Certificate {
 		cert.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
 	} else {
 		cert.IPAddresses = []net.IP{fixedCertIP}
+		cert.Subject.CommonName = agentCommonName
 	}
 	return cert
 }
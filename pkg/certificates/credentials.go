@@ -4,25 +4,68 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"google.golang.org/grpc/credentials"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
-func GetServerCredentialsFromCerts(certReader CertStorageReader) (credentials.TransportCredentials, error) {
+const (
+	// ClientAuthRequired requires peers to present a client certificate signed by the CA
+	ClientAuthRequired = "Required"
+	// ClientAuthOptional only verifies client certificates which are presented, connections without client
+	// certificate are accepted. Certificates signed by another CA are still rejected.
+	ClientAuthOptional = "Optional"
+)
 
-	keyPair, pool, err := prepareCredentials(certReader)
-	if err != nil {
+// TLSOptions are the settings of the TLS connections between peers
+type TLSOptions struct {
+	// MinVersion is the minimum TLS version
+	MinVersion uint16
+	// CipherSuites restricts the cipher suites of TLS 1.2 connections, nil uses the defaults of crypto/tls. The cipher
+	// suites of TLS 1.3 aren't configurable.
+	CipherSuites []uint16
+	// ClientAuth is the verification mode of client certificates on the server side
+	ClientAuth tls.ClientAuthType
+}
+
+// DefaultTLSOptions returns the default TLS options, which require mutual TLS
+func DefaultTLSOptions() TLSOptions {
+	return TLSOptions{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+}
+
+func GetServerCredentialsFromCerts(certReader CertStorageReader, tlsOptions TLSOptions) (credentials.TransportCredentials, error) {
+
+	// fail early on invalid certificates
+	if _, _, err := prepareCredentials(certReader); err != nil {
 		return nil, err
 	}
 
+	// read the certificates for every connection, in order to pick up rotated certificates without restart
 	return credentials.NewTLS(&tls.Config{
-		Certificates: []tls.Certificate{*keyPair},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    pool,
+		MinVersion:   tlsOptions.MinVersion,
+		CipherSuites: tlsOptions.CipherSuites,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			keyPair, pool, err := prepareCredentials(certReader)
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				Certificates: []tls.Certificate{*keyPair},
+				ClientAuth:   tlsOptions.ClientAuth,
+				ClientCAs:    pool,
+				MinVersion:   tlsOptions.MinVersion,
+				CipherSuites: tlsOptions.CipherSuites,
+			}, nil
+		},
 	}), nil
 }
 
-func GetClientCredentialsFromCerts(certReader CertStorageReader) (credentials.TransportCredentials, error) {
+func GetClientCredentialsFromCerts(certReader CertStorageReader, tlsOptions TLSOptions) (credentials.TransportCredentials, error) {
 
 	keyPair, pool, err := prepareCredentials(certReader)
 	if err != nil {
@@ -33,9 +76,58 @@ func GetClientCredentialsFromCerts(certReader CertStorageReader) (credentials.Tr
 		Certificates: []tls.Certificate{*keyPair},
 		RootCAs:      pool,
 		ServerName:   fixedCertIP.String(),
+		MinVersion:   tlsOptions.MinVersion,
+		CipherSuites: tlsOptions.CipherSuites,
 	}), nil
 }
 
+// ParseTLSVersion converts the given TLS version, e.g. "1.2", to its crypto/tls constant
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %s", version)
+	}
+}
+
+// ParseCipherSuites converts the given comma separated cipher suite names, e.g.
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", to their crypto/tls constants. Insecure cipher suites are rejected. An
+// empty string returns nil, which uses the defaults of crypto/tls.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, exists := known[name]
+		if !exists {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ParseClientAuth converts the given client certificate verification mode, e.g. "Required", to its crypto/tls constant
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", ClientAuthRequired:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unsupported client certificate verification mode %s", mode)
+	}
+}
+
 func prepareCredentials(certReader CertStorageReader) (*tls.Certificate, *x509.CertPool, error) {
 	caPem, certPem, keyPem, err := certReader.GetCerts()
 	if err != nil {
@@ -52,3 +144,14 @@ func prepareCredentials(certReader CertStorageReader) (*tls.Certificate, *x509.C
 	}
 	return &keyPair, cp, nil
 }
+
+// ReadyzCheck returns a healthz.Checker which fails when the certificates of the given reader can't be loaded, so that
+// an agent which can neither verify its peers nor be verified by them isn't ready
+func ReadyzCheck(certReader CertStorageReader) healthz.Checker {
+	return func(_ *http.Request) error {
+		if _, _, err := prepareCredentials(certReader); err != nil {
+			return fmt.Errorf("failed to load peer certificates: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package certificates
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/grpc/credentials"
+)
+
+var _ = Describe("Credentials", func() {
+
+	var certReader *MemoryCertStorage
+
+	newCertReader := func() *MemoryCertStorage {
+		caPem, certPem, keyPem, err := CreateCerts()
+		Expect(err).ToNot(HaveOccurred())
+		return &MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+	}
+
+	// handshake performs a TLS handshake between the given credentials and returns the error of the server side
+	handshake := func(serverCreds credentials.TransportCredentials, clientCreds credentials.TransportCredentials) error {
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		go func() {
+			clientCreds.ClientHandshake(ctx, "", clientConn)
+			// unblock the server when the client rejected the handshake
+			clientConn.Close()
+		}()
+		_, _, err := serverCreds.ServerHandshake(serverConn)
+		return err
+	}
+
+	BeforeEach(func() {
+		certReader = newCertReader()
+	})
+
+	Context("with required client certificates", func() {
+
+		var serverCreds credentials.TransportCredentials
+
+		BeforeEach(func() {
+			var err error
+			serverCreds, err = GetServerCredentialsFromCerts(certReader, DefaultTLSOptions())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should accept a peer with a certificate of the CA", func() {
+			clientCreds, err := GetClientCredentialsFromCerts(certReader, DefaultTLSOptions())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(handshake(serverCreds, clientCreds)).To(Succeed())
+		})
+
+		It("should reject a peer without certificate", func() {
+			_, pool, err := prepareCredentials(certReader)
+			Expect(err).ToNot(HaveOccurred())
+			clientCreds := credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: fixedCertIP.String()})
+			Expect(handshake(serverCreds, clientCreds)).ToNot(Succeed())
+		})
+
+		It("should reject a peer with a certificate of another CA", func() {
+			keyPair, _, err := prepareCredentials(newCertReader())
+			Expect(err).ToNot(HaveOccurred())
+			_, pool, err := prepareCredentials(certReader)
+			Expect(err).ToNot(HaveOccurred())
+			clientCreds := credentials.NewTLS(&tls.Config{
+				Certificates: []tls.Certificate{*keyPair},
+				RootCAs:      pool,
+				ServerName:   fixedCertIP.String(),
+			})
+			Expect(handshake(serverCreds, clientCreds)).ToNot(Succeed())
+		})
+	})
+
+	Context("with optional client certificates", func() {
+
+		It("should accept a peer without certificate", func() {
+			tlsOptions := DefaultTLSOptions()
+			tlsOptions.ClientAuth = tls.VerifyClientCertIfGiven
+			serverCreds, err := GetServerCredentialsFromCerts(certReader, tlsOptions)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, pool, err := prepareCredentials(certReader)
+			Expect(err).ToNot(HaveOccurred())
+			clientCreds := credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: fixedCertIP.String()})
+			Expect(handshake(serverCreds, clientCreds)).To(Succeed())
+		})
+	})
+
+	Context("parsing options", func() {
+
+		It("should parse cipher suites", func() {
+			suites, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(suites).To(Equal([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}))
+
+			suites, err = ParseCipherSuites("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(suites).To(BeNil())
+		})
+
+		It("should reject unknown and insecure cipher suites", func() {
+			_, err := ParseCipherSuites("TLS_UNKNOWN")
+			Expect(err).To(HaveOccurred())
+			_, err = ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should parse client auth modes", func() {
+			Expect(ParseClientAuth("")).To(Equal(tls.RequireAndVerifyClientCert))
+			Expect(ParseClientAuth(ClientAuthRequired)).To(Equal(tls.RequireAndVerifyClientCert))
+			Expect(ParseClientAuth(ClientAuthOptional)).To(Equal(tls.VerifyClientCertIfGiven))
+			_, err := ParseClientAuth("None")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("readiness", func() {
+
+		It("should be ready when the certificates can be loaded", func() {
+			Expect(ReadyzCheck(certReader)(nil)).To(Succeed())
+		})
+
+		It("should not be ready with a key which doesn't match the certificate", func() {
+			certReader.KeyPem = newCertReader().KeyPem
+			Expect(ReadyzCheck(certReader)(nil)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// expiryWarningRatio is the remaining part of the certificate validity window below which we warn about the
+// upcoming expiry
+const expiryWarningRatio = 0.2
+
+// TimeUntilExpiry returns the remaining validity of the peer certificate of the given reader
+func TimeUntilExpiry(reader CertStorageReader) (time.Duration, error) {
+	cert, err := getPeerCert(reader)
+	if err != nil {
+		return 0, err
+	}
+	return time.Until(cert.NotAfter), nil
+}
+
+func getPeerCert(reader CertStorageReader) (*x509.Certificate, error) {
+	_, certPem, _, err := reader.GetCerts()
+	if err != nil {
+		return nil, err
+	}
+	if certPem == nil {
+		return nil, errors.New("no peer certificate found")
+	}
+	return parseCertPem(certPem.Bytes())
+}
+
+// ExpiryChecker periodically checks the expiry of the peer certificate and exposes it as metric
+type ExpiryChecker struct {
+	reader   CertStorageReader
+	interval time.Duration
+	log      logr.Logger
+}
+
+func NewExpiryChecker(reader CertStorageReader, interval time.Duration, log logr.Logger) *ExpiryChecker {
+	return &ExpiryChecker{
+		reader:   reader,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Start implements the Runnable interface of the controller-runtime manager
+func (e *ExpiryChecker) Start(ctx context.Context) error {
+	for {
+		e.check()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+func (e *ExpiryChecker) check() {
+	cert, err := getPeerCert(e.reader)
+	if err != nil {
+		e.log.Error(err, "failed to get peer certificate for checking its expiry")
+		return
+	}
+	certExpiryTimestamp.Set(float64(cert.NotAfter.Unix()))
+
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	remaining := time.Until(cert.NotAfter)
+	if remaining < time.Duration(float64(validity)*expiryWarningRatio) {
+		e.log.Info("warning: peer certificate expires soon, peer communication will fail after expiry",
+			"notAfter", cert.NotAfter, "remaining", remaining)
+	}
+}
@@ -0,0 +1,142 @@
+package certificates
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	caPemFile   = "ca.pem"
+	certPemFile = "cert.pem"
+	keyPemFile  = "key.pem"
+)
+
+var _ CertStorageReader = &FileCertStorage{}
+var _ CertStorageWriter = &FileCertStorage{}
+
+// FileCertStorage stores the certificates as files in the given directory, e.g. on a hostPath volume.
+// In contrast to the SecretCertStorage it doesn't need api server access for reading the certificates.
+type FileCertStorage struct {
+	dir   string
+	log   logr.Logger
+	mutex sync.Mutex
+}
+
+func NewFileCertStorage(dir string, log logr.Logger) *FileCertStorage {
+	return &FileCertStorage{
+		dir:   dir,
+		log:   log,
+		mutex: sync.Mutex{},
+	}
+}
+
+func (f *FileCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	readFile := func(name string) (*bytes.Buffer, error) {
+		content, err := ioutil.ReadFile(filepath.Join(f.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(content), nil
+	}
+	if caPem, err = readFile(caPemFile); err != nil {
+		return nil, nil, nil, err
+	}
+	if certPem, err = readFile(certPemFile); err != nil {
+		return nil, nil, nil, err
+	}
+	if keyPem, err = readFile(keyPemFile); err != nil {
+		return nil, nil, nil, err
+	}
+	return
+}
+
+func (f *FileCertStorage) StoreCerts(caPem, certPem, keyPem *bytes.Buffer) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+	if err := f.writeFile(caPemFile, caPem.Bytes()); err != nil {
+		return err
+	}
+	if err := f.writeFile(certPemFile, certPem.Bytes()); err != nil {
+		return err
+	}
+	return f.writeFile(keyPemFile, keyPem.Bytes())
+}
+
+// writeFile writes to a temporary file first and renames it afterwards, so that readers never see partial content
+func (f *FileCertStorage) writeFile(name string, content []byte) error {
+	tmpFile, err := ioutil.TempFile(f.dir, name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filepath.Join(f.dir, name))
+}
+
+var _ CertStorageReader = &FileBackedCertStorage{}
+
+// FileBackedCertStorage reads the certificates from the given primary storage and keeps a copy of them in a
+// FileCertStorage. The copy is used when the primary storage can't be read, e.g. because the api server isn't reachable.
+type FileBackedCertStorage struct {
+	primary CertStorageReader
+	files   *FileCertStorage
+	log     logr.Logger
+	// the last stored CA bundle and certificate, used for avoiding needless writes
+	storedPem []byte
+	mutex     sync.Mutex
+}
+
+func NewFileBackedCertStorage(primary CertStorageReader, files *FileCertStorage, log logr.Logger) *FileBackedCertStorage {
+	return &FileBackedCertStorage{
+		primary: primary,
+		files:   files,
+		log:     log,
+		mutex:   sync.Mutex{},
+	}
+}
+
+func (s *FileBackedCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error) {
+	caPem, certPem, keyPem, err = s.primary.GetCerts()
+	if err != nil {
+		s.log.Error(err, "failed to read certificates, falling back to certificate files")
+		return s.files.GetCerts()
+	}
+	s.storeFiles(caPem, certPem, keyPem)
+	return
+}
+
+func (s *FileBackedCertStorage) storeFiles(caPem, certPem, keyPem *bytes.Buffer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// the CA bundle changes during rotation without the certificate changing
+	stored := append(append([]byte{}, caPem.Bytes()...), certPem.Bytes()...)
+	if bytes.Equal(stored, s.storedPem) {
+		return
+	}
+	if err := s.files.StoreCerts(caPem, certPem, keyPem); err != nil {
+		// not critical as long as the primary storage works
+		s.log.Error(err, "failed to store certificate files")
+		return
+	}
+	s.storedPem = stored
+}
@@ -0,0 +1,18 @@
+package certificates
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	certExpiryTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poison_pill_peer_cert_expiry_timestamp_seconds",
+		Help: "Unix timestamp of the expiry of the peer certificate",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(certExpiryTimestamp)
+}
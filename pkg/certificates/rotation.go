@@ -0,0 +1,168 @@
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	rotationPhaseAnnotation     = "poison-pill.medik8s.io/cert-rotation-phase"
+	rotationTimestampAnnotation = "poison-pill.medik8s.io/cert-rotation-timestamp"
+
+	rotationPhaseTrustNewCA  = "TrustNewCA"
+	rotationPhaseRetireOldCA = "RetireOldCA"
+
+	nextCertPemKey = "nextCertPem"
+	nextKeyPemKey  = "nextKeyPem"
+
+	// rotationStepDelay is the time we wait between rotation steps, it needs to be long enough for all agents to
+	// pick up the updated secret
+	rotationStepDelay = 3 * SecretRefreshInterval
+)
+
+// RotateCerts rotates the certificates when the peer certificate expires within the given window.
+// In order to avoid failing peer authentication the rotation happens in 3 steps, each step is only done after all
+// agents picked up the result of the previous one:
+// 1. create a new CA and peer certificate, and add the new CA to the trusted CAs
+// 2. replace the peer certificate and key with the new ones
+// 3. remove the old CA from the trusted CAs
+// It returns the time after which this should be called again.
+func (s *SecretCertStorage) RotateCerts(window time.Duration) (time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secret, err := s.getSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	phase := secret.Annotations[rotationPhaseAnnotation]
+	if phase != "" {
+		timestamp, err := time.Parse(time.RFC3339, secret.Annotations[rotationTimestampAnnotation])
+		if err != nil {
+			s.log.Error(err, "invalid certificate rotation timestamp, continuing rotation")
+		} else if wait := time.Until(timestamp.Add(rotationStepDelay)); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	data := secret.Data
+	nextPhase := ""
+	switch phase {
+	case "":
+		cert, err := parseCertPem(data[certPemKey])
+		if err != nil {
+			return 0, err
+		}
+		if untilRotation := time.Until(cert.NotAfter.Add(-window)); untilRotation > 0 {
+			return untilRotation, nil
+		}
+		s.log.Info("peer certificate expires soon, starting rotation", "notAfter", cert.NotAfter)
+		caPem, certPem, keyPem, err := CreateCerts()
+		if err != nil {
+			return 0, err
+		}
+		data[caPemKey] = append(append([]byte{}, data[caPemKey]...), caPem.Bytes()...)
+		data[nextCertPemKey] = certPem.Bytes()
+		data[nextKeyPemKey] = keyPem.Bytes()
+		nextPhase = rotationPhaseTrustNewCA
+	case rotationPhaseTrustNewCA:
+		s.log.Info("switching to new peer certificate")
+		data[certPemKey] = data[nextCertPemKey]
+		data[keyPemKey] = data[nextKeyPemKey]
+		delete(data, nextCertPemKey)
+		delete(data, nextKeyPemKey)
+		nextPhase = rotationPhaseRetireOldCA
+	case rotationPhaseRetireOldCA:
+		s.log.Info("removing old CA")
+		caPem, err := issuingCAs(data[caPemKey], data[certPemKey])
+		if err != nil {
+			return 0, err
+		}
+		data[caPemKey] = caPem
+	default:
+		return 0, fmt.Errorf("unknown certificate rotation phase %s", phase)
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	if nextPhase == "" {
+		delete(secret.Annotations, rotationPhaseAnnotation)
+		delete(secret.Annotations, rotationTimestampAnnotation)
+	} else {
+		secret.Annotations[rotationPhaseAnnotation] = nextPhase
+		secret.Annotations[rotationTimestampAnnotation] = time.Now().Format(time.RFC3339)
+	}
+	if err := s.updateSecret(secret); err != nil {
+		return 0, err
+	}
+	return rotationStepDelay, nil
+}
+
+func (s *SecretCertStorage) updateSecret(secret *v1.Secret) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	if secret.Immutable == nil || !*secret.Immutable {
+		return s.Update(ctx, secret)
+	}
+
+	// secrets created by older versions are immutable, so they need to be recreated
+	s.log.Info("recreating immutable certificate secret")
+	if err := s.Delete(ctx, secret); err != nil {
+		return err
+	}
+	newSecret := &v1.Secret{
+		ObjectMeta: secret.ObjectMeta,
+		Data:       secret.Data,
+		Type:       secret.Type,
+	}
+	newSecret.ResourceVersion = ""
+	newSecret.UID = ""
+	return s.Create(ctx, newSecret)
+}
+
+func parseCertPem(certPem []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// issuingCAs returns the CAs of the given PEM bundle which signed the given certificate
+func issuingCAs(caBundlePem []byte, certPem []byte) ([]byte, error) {
+	cert, err := parseCertPem(certPem)
+	if err != nil {
+		return nil, err
+	}
+	issuers := &bytes.Buffer{}
+	rest := caBundlePem
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if cert.CheckSignatureFrom(ca) == nil {
+			if err := pem.Encode(issuers, block); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if issuers.Len() == 0 {
+		return nil, fmt.Errorf("no CA found which signed the peer certificate")
+	}
+	return issuers.Bytes(), nil
+}
@@ -12,7 +12,6 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -20,6 +19,10 @@ type CertStorageReader interface {
 	GetCerts() (caPem, certPem, keyPem *bytes.Buffer, err error)
 }
 
+type CertStorageWriter interface {
+	StoreCerts(caPem, certPem, keyPem *bytes.Buffer) error
+}
+
 // for tests only
 var _ CertStorageReader = &MemoryCertStorage{}
 
@@ -38,16 +41,23 @@ const (
 	keyPemKey  = "keyPem"
 
 	apiTimeout = 10 * time.Second
+
+	// SecretRefreshInterval is the interval after which the cached secret is read again, in order to pick up
+	// rotated certificates
+	SecretRefreshInterval = 5 * time.Minute
 )
 
 var _ CertStorageReader = &SecretCertStorage{}
+var _ CertStorageWriter = &SecretCertStorage{}
 
 type SecretCertStorage struct {
 	client.Client
 	log       logr.Logger
 	namespace string
 	secret    *v1.Secret
-	mutex     sync.Mutex
+	// the time the secret was read the last time, successful or not
+	lastRefresh time.Time
+	mutex       sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
@@ -65,18 +75,18 @@ func (s *SecretCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, er
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.secret == nil {
-		certSecret := &v1.Secret{}
-		key := types.NamespacedName{
-			Namespace: s.namespace,
-			Name:      secretName,
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
-		defer cancel()
-		if err := s.Get(ctx, key, certSecret); err != nil {
-			return nil, nil, nil, err
+	if s.secret == nil || time.Since(s.lastRefresh) > SecretRefreshInterval {
+		certSecret, err := s.getSecret()
+		// don't retry on every call when the api server isn't reachable
+		s.lastRefresh = time.Now()
+		if err != nil {
+			if s.secret == nil {
+				return nil, nil, nil, err
+			}
+			s.log.Error(err, "failed to refresh certificate secret, using cached certificates")
+		} else {
+			s.secret = certSecret
 		}
-		s.secret = certSecret
 	}
 
 	toBuffer := func(key string) *bytes.Buffer {
@@ -90,14 +100,27 @@ func (s *SecretCertStorage) GetCerts() (caPem, certPem, keyPem *bytes.Buffer, er
 	return
 }
 
+func (s *SecretCertStorage) getSecret() (*v1.Secret, error) {
+	certSecret := &v1.Secret{}
+	key := types.NamespacedName{
+		Namespace: s.namespace,
+		Name:      secretName,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	if err := s.Get(ctx, key, certSecret); err != nil {
+		return nil, err
+	}
+	return certSecret, nil
+}
+
 func (s *SecretCertStorage) StoreCerts(caPem, certPem, keyPem *bytes.Buffer) error {
 	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.namespace,
 			Name:      secretName,
 		},
-		Immutable: pointer.BoolPtr(true),
-		Data:      nil,
+		Data: nil,
 		StringData: map[string]string{
 			caPemKey:   caPem.String(),
 			certPemKey: certPem.String(),
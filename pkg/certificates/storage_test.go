@@ -2,10 +2,17 @@ package certificates
 
 import (
 	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -39,5 +46,131 @@ var _ = Describe("Certificates", func() {
 			})
 
 		})
+
+		Describe("File", func() {
+
+			It("should create and get certificates via files", func() {
+
+				caData := "myCA"
+				certData := "myCert"
+				keyData := "myKey"
+
+				toBuffer := func(data string) *bytes.Buffer {
+					b := &bytes.Buffer{}
+					b.WriteString(data)
+					return b
+				}
+
+				dir, err := ioutil.TempDir("", "certs")
+				Expect(err).ToNot(HaveOccurred())
+				defer os.RemoveAll(dir)
+
+				store := NewFileCertStorage(filepath.Join(dir, "poison-pill"), ctrl.Log.WithName("TestFileCertStore"))
+				_, _, _, err = store.GetCerts()
+				Expect(err).To(HaveOccurred(), "expected error for missing files")
+
+				Expect(store.StoreCerts(toBuffer(caData), toBuffer(certData), toBuffer(keyData))).ToNot(HaveOccurred())
+
+				caBuf, certBuf, keyBuf, err := store.GetCerts()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(caBuf.String()).To(Equal(caData), "caData doesn't equal")
+				Expect(certBuf.String()).To(Equal(certData), "certData doesn't equal")
+				Expect(keyBuf.String()).To(Equal(keyData), "keyData doesn't equal")
+
+			})
+
+		})
+
+		Describe("Expiry", func() {
+
+			It("should return the remaining validity of the peer certificate", func() {
+				ca, cert, key, err := CreateCerts()
+				Expect(err).ToNot(HaveOccurred())
+
+				store := &MemoryCertStorage{CaPem: ca, CertPem: cert, KeyPem: key}
+				remaining, err := TimeUntilExpiry(store)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(remaining).To(BeNumerically(">", time.Hour*24*365*9), "certificate should be valid for 10 years")
+			})
+
+			It("should fail for invalid certificates", func() {
+				store := &MemoryCertStorage{CertPem: bytes.NewBufferString("invalid")}
+				_, err := TimeUntilExpiry(store)
+				Expect(err).To(HaveOccurred())
+			})
+
+		})
+
+		Describe("Rotation", func() {
+
+			const namespace = "cert-rotation"
+			var store *SecretCertStorage
+
+			BeforeEach(func() {
+				ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+				Expect(k8sClient.Create(context.Background(), ns)).To(Succeed())
+
+				store = NewSecretCertStorage(k8sClient, ctrl.Log.WithName("TestSecretCertStore"), namespace)
+				ca, cert, key, err := CreateCerts()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(store.StoreCerts(ca, cert, key)).To(Succeed())
+			})
+
+			// skips the wait time of the current rotation step
+			skipStepDelay := func() {
+				Eventually(func() error {
+					secret, err := store.getSecret()
+					if err != nil {
+						return err
+					}
+					secret.Annotations[rotationTimestampAnnotation] = time.Now().Add(-rotationStepDelay).Format(time.RFC3339)
+					return k8sClient.Update(context.Background(), secret)
+				}, 5*time.Second, 250*time.Millisecond).Should(Succeed())
+			}
+
+			rotateUntilPhase := func(phase string) *v1.Secret {
+				var secret *v1.Secret
+				Eventually(func() (string, error) {
+					if _, err := store.RotateCerts(11 * 365 * 24 * time.Hour); err != nil {
+						return "", err
+					}
+					var err error
+					secret, err = store.getSecret()
+					if err != nil {
+						return "", err
+					}
+					return secret.Annotations[rotationPhaseAnnotation], nil
+				}, 5*time.Second, 250*time.Millisecond).Should(Equal(phase))
+				return secret
+			}
+
+			It("should trust the new CA before using the new certificate", func() {
+				oldSecret, err := store.getSecret()
+				Expect(err).ToNot(HaveOccurred())
+
+				By("adding the new CA")
+				secret := rotateUntilPhase(rotationPhaseTrustNewCA)
+				Expect(bytes.Count(secret.Data[caPemKey], []byte("BEGIN CERTIFICATE"))).To(Equal(2))
+				Expect(secret.Data[certPemKey]).To(Equal(oldSecret.Data[certPemKey]))
+				Expect(secret.Data).To(HaveKey(nextCertPemKey))
+				nextCertPem := secret.Data[nextCertPemKey]
+
+				By("switching to the new certificate")
+				skipStepDelay()
+				secret = rotateUntilPhase(rotationPhaseRetireOldCA)
+				Expect(secret.Data[certPemKey]).To(Equal(nextCertPem))
+				Expect(secret.Data).ToNot(HaveKey(nextCertPemKey))
+
+				By("removing the old CA")
+				skipStepDelay()
+				secret = rotateUntilPhase("")
+				Expect(bytes.Count(secret.Data[caPemKey], []byte("BEGIN CERTIFICATE"))).To(Equal(1))
+				_, err = issuingCAs(secret.Data[caPemKey], secret.Data[certPemKey])
+				Expect(err).ToNot(HaveOccurred())
+				_, err = issuingCAs(secret.Data[caPemKey], oldSecret.Data[certPemKey])
+				Expect(err).To(HaveOccurred(), "old CA should be removed")
+			})
+
+		})
 	})
 })
@@ -2,23 +2,36 @@ package peerhealth
 
 import (
 	"context"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
+// Dialer opens the connection to the given peer address, e.g. through a dedicated network
+type Dialer func(ctx context.Context, address string) (net.Conn, error)
+
 type Client struct {
 	PeerHealthClient
 	conn *grpc.ClientConn
 }
 
-// NewClient return a new client for peer health checks. Don't forget to close it when done
-func NewClient(serverAddr string, peerDialTimeout time.Duration, log logr.Logger, clientCreds credentials.TransportCredentials) (*Client, error) {
+// NewClient return a new client for peer health checks. Don't forget to close it when done. The dial is cancelled
+// when the given context is done or the peerDialTimeout elapsed, whichever happens first. A nil dialer connects through
+// the network of the pod.
+func NewClient(ctx context.Context, serverAddr string, peerDialTimeout time.Duration, log logr.Logger, clientCreds credentials.TransportCredentials, dialer Dialer) (*Client, error) {
 
 	var opts []grpc.DialOption
 
+	if dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(dialer))
+	}
+
 	if clientCreds != nil {
 		opts = append(opts, grpc.WithTransportCredentials(clientCreds))
 	} else {
@@ -28,10 +41,10 @@ func NewClient(serverAddr string, peerDialTimeout time.Duration, log logr.Logger
 	// this option implies WithBlock()
 	opts = append(opts, grpc.WithReturnConnectionError())
 
-	ctx, cancel := context.WithTimeout(context.Background(), peerDialTimeout)
+	dialCtx, cancel := context.WithTimeout(ctx, peerDialTimeout)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, serverAddr, opts...)
+	conn, err := grpc.DialContext(dialCtx, serverAddr, opts...)
 	if err != nil {
 		log.Error(err, "failed to dial")
 		return nil, err
@@ -45,3 +58,28 @@ func NewClient(serverAddr string, peerDialTimeout time.Duration, log logr.Logger
 func (c *Client) Close() {
 	c.conn.Close()
 }
+
+// IsThrottled returns if the given error of a request was caused by the peer dropping the request, because it's busy
+// with the requests of other nodes. Such an error means that the peer is reachable.
+func IsThrottled(err error) bool {
+	return status.Code(err) == codes.ResourceExhausted
+}
+
+// IsAuthFailure returns if the given error of a dial or a request was caused by a failed authentication, e.g. because
+// the server rejected our client certificate or its server certificate couldn't be verified. Such an error means that
+// the peer is reachable, so it must not be confused with a connectivity error.
+func IsAuthFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	}
+	// handshake errors are only available as description of the connection error
+	msg := err.Error()
+	return strings.Contains(msg, "authentication handshake failed") ||
+		strings.Contains(msg, "tls: bad certificate") ||
+		strings.Contains(msg, "tls: unknown certificate") ||
+		strings.Contains(msg, "x509: ")
+}
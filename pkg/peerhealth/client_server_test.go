@@ -2,11 +2,14 @@ package peerhealth
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -20,6 +23,7 @@ var _ = Describe("Checking health using grpc client and server", func() {
 	var phServer *Server
 	var cancel context.CancelFunc
 	var phClient *Client
+	var certReader *certificates.MemoryCertStorage
 
 	BeforeEach(func() {
 
@@ -28,14 +32,14 @@ var _ = Describe("Checking health using grpc client and server", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		By("Creating test memory cert storage")
-		certReader := &certificates.MemoryCertStorage{
+		certReader = &certificates.MemoryCertStorage{
 			CaPem:   caPem,
 			CertPem: certPem,
 			KeyPem:  keyPem,
 		}
 
 		By("Creating server")
-		phServer, err = NewServer(pprr, cfg, ctrl.Log.WithName("peerhealth test").WithName("phServer"), 9000, certReader)
+		phServer, err = NewServer(pprr, cfg, ctrl.Log.WithName("peerhealth test").WithName("phServer"), 9000, certificates.DefaultTLSOptions(), certReader)
 		Expect(err).ToNot(HaveOccurred())
 
 		By("Starting server")
@@ -46,11 +50,11 @@ var _ = Describe("Checking health using grpc client and server", func() {
 		}()
 
 		By("Creating client credentials")
-		clientCreds, err := certificates.GetClientCredentialsFromCerts(certReader)
+		clientCreds, err := certificates.GetClientCredentialsFromCerts(certReader, certificates.DefaultTLSOptions())
 		Expect(err).ToNot(HaveOccurred())
 
 		By("Creating client")
-		phClient, err = NewClient("127.0.0.1:9000", 5*time.Second, ctrl.Log.WithName("peerhealth test").WithName("phClient"), clientCreds)
+		phClient, err = NewClient(ctx, "127.0.0.1:9000", 5*time.Second, ctrl.Log.WithName("peerhealth test").WithName("phClient"), clientCreds, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 	})
@@ -60,6 +64,28 @@ var _ = Describe("Checking health using grpc client and server", func() {
 		phClient.Close()
 	})
 
+	Describe("self test", func() {
+
+		It("should pass with the certificates of the server", func() {
+			selfTest := NewSelfTest(certReader, certificates.DefaultTLSOptions(), 9000, nodeName, ctrl.Log.WithName("peerhealth test").WithName("selfTest"))
+			Expect(selfTest.Check(nil)).ToNot(Succeed())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go selfTest.Start(ctx)
+			Eventually(func() error {
+				return selfTest.Check(nil)
+			}, 20*time.Second, 250*time.Millisecond).Should(Succeed())
+		})
+
+		It("should fail with certificates of another CA", func() {
+			caPem, certPem, keyPem, err := certificates.CreateCerts()
+			Expect(err).ToNot(HaveOccurred())
+			otherCertReader := &certificates.MemoryCertStorage{CaPem: caPem, CertPem: certPem, KeyPem: keyPem}
+			selfTest := NewSelfTest(otherCertReader, certificates.DefaultTLSOptions(), 9000, nodeName, ctrl.Log.WithName("peerhealth test").WithName("selfTest"))
+			Expect(selfTest.run(context.Background())).ToNot(Succeed())
+		})
+	})
+
 	Describe("for a healthy node", func() {
 		It("should return healthy", func() {
 
@@ -109,4 +135,113 @@ var _ = Describe("Checking health using grpc client and server", func() {
 
 	})
 
+	Describe("for an unhealthy node whose remediation isn't named after it", func() {
+
+		otherNodeName := "other-node"
+
+		BeforeEach(func() {
+			By("creating a PPR with a node name")
+			ppr := &v1alpha1.PoisonPillRemediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "remediation-of-" + otherNodeName,
+					Namespace: "default",
+				},
+				Spec: v1alpha1.PoisonPillRemediationSpec{
+					NodeName: otherNodeName,
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), ppr)).To(Succeed())
+		})
+
+		It("should return unhealthy", func() {
+
+			By("calling isHealthy")
+			Eventually(func() (api.HealthCheckResponseCode, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer (cancel)()
+				resp, err := phClient.IsHealthy(ctx, &HealthRequest{
+					NodeName: otherNodeName,
+				})
+				if err != nil {
+					return api.ApiError, err
+				}
+				return api.HealthCheckResponseCode(resp.Status), nil
+			}, 5*time.Second, 250*time.Millisecond).Should(Equal(api.Unhealthy))
+
+		})
+
+	})
+
+})
+
+var _ = Describe("Distinguishing authentication failures", func() {
+
+	It("should detect authentication failures", func() {
+		Expect(IsAuthFailure(status.Error(codes.Unauthenticated, "unauthenticated"))).To(BeTrue())
+		Expect(IsAuthFailure(errors.New("connection error: desc = \"transport: authentication handshake failed: x509: certificate signed by unknown authority\""))).To(BeTrue())
+		Expect(IsAuthFailure(status.Error(codes.Unavailable, "connection error: desc = \"transport: remote error: tls: bad certificate\""))).To(BeTrue())
+	})
+
+	It("should not treat connectivity failures as authentication failures", func() {
+		Expect(IsAuthFailure(nil)).To(BeFalse())
+		Expect(IsAuthFailure(errors.New("connection error: desc = \"transport: error while dialing: dial tcp 10.0.0.1:30001: connect: connection refused\""))).To(BeFalse())
+		Expect(IsAuthFailure(context.DeadlineExceeded)).To(BeFalse())
+	})
+
+})
+
+var _ = Describe("Limiting health requests", func() {
+
+	It("should drop frequent requests of the same source", func() {
+		limiter := newRateLimiter(1, time.Second)
+		now := time.Now()
+		Expect(limiter.allowSource("10.0.0.1", now)).To(BeTrue())
+		Expect(limiter.allowSource("10.0.0.1", now.Add(500*time.Millisecond))).To(BeFalse())
+		Expect(limiter.allowSource("10.0.0.2", now.Add(500*time.Millisecond))).To(BeTrue())
+		Expect(limiter.allowSource("10.0.0.1", now.Add(time.Second))).To(BeTrue())
+	})
+
+	It("should drop requests exceeding the concurrency cap", func() {
+		limiter := newRateLimiter(1, 0)
+		handlerStarted := make(chan struct{})
+		releaseHandler := make(chan struct{})
+		blockingHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(handlerStarted)
+			<-releaseHandler
+			return nil, nil
+		}
+		go limiter.intercept(context.Background(), nil, nil, blockingHandler)
+		<-handlerStarted
+
+		_, err := limiter.intercept(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+		Expect(IsThrottled(err)).To(BeTrue())
+
+		close(releaseHandler)
+		Eventually(func() error {
+			_, err := limiter.intercept(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			return err
+		}, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+	})
+
+})
+
+var _ = Describe("Peer request metrics", func() {
+
+	It("should map responses to results", func() {
+		Expect(resultOf(&HealthResponse{Status: int32(api.Healthy)}, nil)).To(Equal(requestResultHealthy))
+		Expect(resultOf(&HealthResponse{Status: int32(api.Unhealthy)}, nil)).To(Equal(requestResultUnhealthy))
+		Expect(resultOf(&HealthResponse{Status: int32(api.ApiError)}, nil)).To(Equal(requestResultApiError))
+		Expect(resultOf(nil, status.Error(codes.ResourceExhausted, "dropped"))).To(Equal(requestResultDropped))
+		Expect(resultOf(nil, errors.New("empty node name"))).To(Equal(requestResultError))
+	})
+
+	It("should not identify requests without client certificate", func() {
+		Expect(identityOf(context.Background())).To(Equal(identityUnauthenticated))
+	})
+
 })
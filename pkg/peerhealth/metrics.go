@@ -0,0 +1,105 @@
+package peerhealth
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	poisonPillApis "github.com/medik8s/poison-pill/api"
+)
+
+const (
+	requestResultHealthy   = "healthy"
+	requestResultUnhealthy = "unhealthy"
+	requestResultApiError  = "api-error"
+	requestResultDropped   = "dropped"
+	requestResultError     = "error"
+
+	// identityUnauthenticated is used for peers which didn't present a verified client certificate
+	identityUnauthenticated = "unauthenticated"
+	// identityUnknown is used for verified client certificates without CN
+	identityUnknown = "unknown"
+)
+
+var (
+	droppedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_peer_requests_dropped_total",
+		Help: "Number of health requests of peers which were dropped by the rate limiter, by reason",
+	}, []string{"reason"})
+
+	// the identity label is the CN of the client certificate; all agents share the same certificate, so it only
+	// distinguishes agents from other clients and doesn't grow with the cluster size
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "poison_pill_peer_request_duration_seconds",
+		Help:    "Duration of health requests of peers, by remote identity",
+		Buckets: []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"identity"})
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_peer_requests_total",
+		Help: "Number of health requests of peers, by result and remote identity",
+	}, []string{"result", "identity"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(droppedRequests, requestDuration, requestsTotal)
+}
+
+// instrument implements grpc.UnaryServerInterceptor, it records the duration and result of health requests. It needs
+// to run before the rate limiter, in order to also count dropped requests.
+func instrument(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	identity := identityOf(ctx)
+	requestDuration.WithLabelValues(identity).Observe(time.Since(start).Seconds())
+	requestsTotal.WithLabelValues(resultOf(resp, err), identity).Inc()
+	return resp, err
+}
+
+// identityOf returns the CN of the verified client certificate of the sender of the request
+func identityOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return identityUnauthenticated
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return identityUnauthenticated
+	}
+	if cn := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+		return cn
+	}
+	return identityUnknown
+}
+
+// resultOf returns the result label for the given health response
+func resultOf(resp interface{}, err error) string {
+	if err != nil {
+		if status.Code(err) == codes.ResourceExhausted {
+			return requestResultDropped
+		}
+		return requestResultError
+	}
+	healthResponse, ok := resp.(*HealthResponse)
+	if !ok {
+		return requestResultError
+	}
+	switch poisonPillApis.HealthCheckResponseCode(healthResponse.GetStatus()) {
+	case poisonPillApis.Healthy:
+		return requestResultHealthy
+	case poisonPillApis.Unhealthy:
+		return requestResultUnhealthy
+	case poisonPillApis.ApiError:
+		return requestResultApiError
+	default:
+		return requestResultError
+	}
+}
@@ -0,0 +1,100 @@
+package peerhealth
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxConcurrentRequests is the max number of health requests which are handled at the same time. Each request
+	// might wait for the api server until apiServerTimeout, so without a cap a cluster wide partition, in which every
+	// node asks every peer, piles up requests until the server doesn't answer in time anymore.
+	maxConcurrentRequests = 20
+	// minRequestInterval is the min time between two health requests of the same source. A node asks each peer only
+	// once per round, so anything more frequent is a retry storm or a misbehaving client.
+	minRequestInterval = 1 * time.Second
+
+	droppedReasonConcurrency = "concurrency"
+	droppedReasonRate        = "rate"
+)
+
+// rateLimiter drops health requests which exceed the concurrency cap or which arrive too frequently from the same
+// source. Dropped requests fail fast with ResourceExhausted. The asking node doesn't count them as a vote, but since the
+// peer is reachable, they keep the asking node from fencing itself when no other peer responds conclusively. They
+// never override another peer reporting the asking node as unhealthy.
+type rateLimiter struct {
+	inFlight     chan struct{}
+	minInterval  time.Duration
+	mutex        sync.Mutex
+	lastRequests map[string]time.Time
+}
+
+func newRateLimiter(maxConcurrent int, minInterval time.Duration) *rateLimiter {
+	return &rateLimiter{
+		inFlight:     make(chan struct{}, maxConcurrent),
+		minInterval:  minInterval,
+		lastRequests: make(map[string]time.Time),
+	}
+}
+
+// intercept implements grpc.UnaryServerInterceptor
+func (l *rateLimiter) intercept(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !l.allowSource(sourceOf(ctx), time.Now()) {
+		droppedRequests.WithLabelValues(droppedReasonRate).Inc()
+		return nil, status.Error(codes.ResourceExhausted, "too many health requests from this source")
+	}
+
+	select {
+	case l.inFlight <- struct{}{}:
+		defer func() { <-l.inFlight }()
+	default:
+		droppedRequests.WithLabelValues(droppedReasonConcurrency).Inc()
+		return nil, status.Error(codes.ResourceExhausted, "too many concurrent health requests")
+	}
+
+	return handler(ctx, req)
+}
+
+// allowSource returns if the given source didn't send a request within the min interval, and records the request
+func (l *rateLimiter) allowSource(source string, now time.Time) bool {
+	if source == "" || l.minInterval <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if last, exists := l.lastRequests[source]; exists && now.Sub(last) < l.minInterval {
+		return false
+	}
+	if _, exists := l.lastRequests[source]; !exists {
+		// new sources are rare, so that's a cheap point in time for forgetting sources which are quiet again
+		for s, last := range l.lastRequests {
+			if now.Sub(last) >= l.minInterval {
+				delete(l.lastRequests, s)
+			}
+		}
+	}
+	l.lastRequests[source] = now
+	return true
+}
+
+// sourceOf returns the IP address of the sender of the request, or an empty string if it's unknown
+func sourceOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
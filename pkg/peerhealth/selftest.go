@@ -0,0 +1,93 @@
+package peerhealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/medik8s/poison-pill/pkg/certificates"
+)
+
+const (
+	// selfTestInterval is the interval in which a failed self test is repeated
+	selfTestInterval = 10 * time.Second
+	// selfTestTimeout is the max time for connecting to the own server and getting a response
+	selfTestTimeout = 10 * time.Second
+)
+
+// SelfTest verifies the certificates of the agent end to end, by sending a health request to its own server with the
+// same client credentials which are used for asking peers. A broken certificate chain otherwise only shows up when
+// the peers need to be asked during an outage. The agent isn't ready until the self test passed.
+type SelfTest struct {
+	certReader certificates.CertStorageReader
+	tlsOptions certificates.TLSOptions
+	address    string
+	nodeName   string
+	log        logr.Logger
+	mutex      sync.Mutex
+	err        error
+}
+
+// NewSelfTest returns a new SelfTest for the server on the given port of this node
+func NewSelfTest(certReader certificates.CertStorageReader, tlsOptions certificates.TLSOptions, port int, nodeName string, log logr.Logger) *SelfTest {
+	return &SelfTest{
+		certReader: certReader,
+		tlsOptions: tlsOptions,
+		address:    fmt.Sprintf("127.0.0.1:%d", port),
+		nodeName:   nodeName,
+		log:        log,
+		err:        errors.New("peer TLS self test didn't pass yet"),
+	}
+}
+
+// Start implements Runnable for usage by manager. It repeats the self test until it passed.
+func (t *SelfTest) Start(ctx context.Context) error {
+	wait.PollImmediateUntil(selfTestInterval, func() (bool, error) {
+		err := t.run(ctx)
+		t.mutex.Lock()
+		t.err = err
+		t.mutex.Unlock()
+		if err != nil {
+			t.log.Error(err, "peer TLS self test failed, peers might not be able to verify this node, or this node its peers")
+			return false, nil
+		}
+		t.log.Info("peer TLS self test passed")
+		return true, nil
+	}, ctx.Done())
+	return nil
+}
+
+// Check implements healthz.Checker, it returns the error of the last self test
+func (t *SelfTest) Check(_ *http.Request) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.err
+}
+
+func (t *SelfTest) run(ctx context.Context) error {
+	clientCreds, err := certificates.GetClientCredentialsFromCerts(t.certReader, t.tlsOptions)
+	if err != nil {
+		return fmt.Errorf("failed to load peer certificates: %v", err)
+	}
+
+	client, err := NewClient(ctx, t.address, selfTestTimeout, t.log, clientCreds, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to own peer server: %v", err)
+	}
+	defer client.Close()
+
+	// with TLS 1.3 the server verifies the client certificate after the handshake, so only a request proves that
+	// it was accepted
+	requestCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+	if _, err := client.IsHealthy(requestCtx, &HealthRequest{NodeName: t.nodeName}); err != nil {
+		return fmt.Errorf("health request to own peer server failed: %v", err)
+	}
+	return nil
+}
@@ -52,10 +52,11 @@ type Server struct {
 	log        logr.Logger
 	certReader certificates.CertStorageReader
 	port       int
+	tlsOptions certificates.TLSOptions
 }
 
 // NewServer returns a new Server
-func NewServer(ppr *controllers.PoisonPillRemediationReconciler, conf *rest.Config, log logr.Logger, port int, certReader certificates.CertStorageReader) (*Server, error) {
+func NewServer(ppr *controllers.PoisonPillRemediationReconciler, conf *rest.Config, log logr.Logger, port int, tlsOptions certificates.TLSOptions, certReader certificates.CertStorageReader) (*Server, error) {
 
 	// create dynamic client
 	c, err := dynamic.NewForConfig(conf)
@@ -69,13 +70,14 @@ func NewServer(ppr *controllers.PoisonPillRemediationReconciler, conf *rest.Conf
 		log:        log,
 		certReader: certReader,
 		port:       port,
+		tlsOptions: tlsOptions,
 	}, nil
 }
 
 // Start implements Runnable for usage by manager
 func (s *Server) Start(ctx context.Context) error {
 
-	serverCreds, err := certificates.GetServerCredentialsFromCerts(s.certReader)
+	serverCreds, err := certificates.GetServerCredentialsFromCerts(s.certReader, s.tlsOptions)
 	if err != nil {
 		s.log.Error(err, "failed to get server credentials")
 		return err
@@ -90,6 +92,7 @@ func (s *Server) Start(ctx context.Context) error {
 	opts := []grpc.ServerOption{
 		grpc.ConnectionTimeout(connectionTimeout),
 		grpc.Creds(serverCreds),
+		grpc.ChainUnaryInterceptor(instrument, newRateLimiter(maxConcurrentRequests, minRequestInterval).intercept),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	RegisterPeerHealthServer(grpcServer, s)
@@ -146,7 +149,35 @@ func (s Server) IsHealthy(ctx context.Context, request *HealthRequest) (*HealthR
 }
 
 func (s Server) isHealthyNode(ctx context.Context, nodeName string, namespace string) poisonPillApis.HealthCheckResponseCode {
-	return s.isHealthyByPpr(ctx, nodeName, namespace)
+	apiCtx, cancelFunc := context.WithTimeout(ctx, apiServerTimeout)
+	defer cancelFunc()
+
+	// remediations are usually named after their node, which is looked up without cache, like machine remediations
+	ppr, err := s.client.Resource(pprRes).Namespace(namespace).Get(apiCtx, nodeName, metav1.GetOptions{})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		s.log.Error(err, "api error")
+		return poisonPillApis.ApiError
+	}
+	if err == nil {
+		if pprNodeName, _, _ := unstructured.NestedString(ppr.Object, "spec", "nodeName"); pprNodeName == "" || pprNodeName == nodeName {
+			s.log.Info("node is unhealthy", "ppr", ppr.GetName())
+			return poisonPillApis.Unhealthy
+		}
+	}
+
+	// remediations don't need to be named after their node, so look them up by spec.nodeName as well
+	pprs, err := s.ppr.ListPprsOfNode(apiCtx, nodeName, namespace)
+	if err != nil {
+		s.log.Error(err, "failed to list remediations of node")
+		return poisonPillApis.ApiError
+	}
+	if len(pprs) > 0 {
+		s.log.Info("node is unhealthy", "ppr", pprs[0].Name)
+		return poisonPillApis.Unhealthy
+	}
+
+	s.log.Info("node is healthy")
+	return poisonPillApis.Healthy
 }
 
 func (s Server) isHealthyMachine(ctx context.Context, nodeName string, namespace string) poisonPillApis.HealthCheckResponseCode {
@@ -204,6 +235,7 @@ func (s Server) getNode(ctx context.Context, nodeName string) (*unstructured.Uns
 
 func toResponse(status poisonPillApis.HealthCheckResponseCode) (*HealthResponse, error) {
 	return &HealthResponse{
-		Status: int32(status),
+		Status:    int32(status),
+		Timestamp: time.Now().UnixNano(),
 	}, nil
 }
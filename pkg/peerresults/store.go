@@ -0,0 +1,94 @@
+package peerresults
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/medik8s/poison-pill/api/v1alpha1"
+)
+
+// Store persists the peer results of the last self fencing decision in a file on the host, so that they survive
+// the reboot of the node and can be attached to the remediation afterwards
+type Store struct {
+	path  string
+	log   logr.Logger
+	mutex sync.Mutex
+}
+
+func NewStore(path string, log logr.Logger) *Store {
+	return &Store{
+		path:  path,
+		log:   log,
+		mutex: sync.Mutex{},
+	}
+}
+
+// Save replaces the stored peer results with the given ones
+func (s *Store) Save(results []v1alpha1.PeerResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first and rename it afterwards, so that a reboot never leaves partial content
+	tmpFile, err := ioutil.TempFile(dir, filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	// the node is going to reboot, make sure the results are on disk
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), s.path)
+}
+
+// Load returns the stored peer results, or nil if there are none
+func (s *Store) Load() ([]v1alpha1.PeerResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var results []v1alpha1.PeerResult
+	if err := json.Unmarshal(content, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Remove deletes the stored peer results
+func (s *Store) Remove() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.log.Info("removed peer results", "path", s.path)
+	return nil
+}
@@ -0,0 +1,25 @@
+package peers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	refreshReasonExpired = "expired"
+	refreshReasonForced  = "forced"
+	// refreshReasonNodeChange is used when a node was added or deleted, or when its labels or addresses changed
+	refreshReasonNodeChange = "node-change"
+)
+
+var (
+	refreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_peers_refreshes_total",
+		Help: "Number of peer address refreshes outside of the regular update interval, by reason",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(refreshes)
+}
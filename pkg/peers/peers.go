@@ -3,36 +3,92 @@ package peers
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
-	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	hostnameLabelName = "kubernetes.io/hostname"
-	workerLabelName   = "node-role.kubernetes.io/worker"
+	hostnameLabelName     = "kubernetes.io/hostname"
+	workerLabelName       = "node-role.kubernetes.io/worker"
+	controlPlaneLabelName = "node-role.kubernetes.io/control-plane"
+	zoneLabelName         = "topology.kubernetes.io/zone"
+	// peerAddressesTTL is the max age of cached peer addresses. Older addresses are updated before they are returned.
+	peerAddressesTTL = 1 * time.Minute
+)
+
+// PeerSelectionStrategy defines the order in which peers are returned
+type PeerSelectionStrategy string
+
+const (
+	// Random returns the peers in random order
+	Random PeerSelectionStrategy = "Random"
+	// SameZoneFirst returns the peers of our own failure zone first, followed by the peers of other zones
+	SameZoneFirst PeerSelectionStrategy = "SameZoneFirst"
+)
+
+// ParsePeerSelectionStrategy returns the strategy for the given name, an empty name returns the default strategy
+func ParsePeerSelectionStrategy(name string) (PeerSelectionStrategy, error) {
+	switch strategy := PeerSelectionStrategy(name); strategy {
+	case "":
+		return Random, nil
+	case Random, SameZoneFirst:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown peer selection strategy %q, valid strategies are %s and %s", name, Random, SameZoneFirst)
+	}
+}
+
+// PeerGroup is a group of nodes with the same role, which are asked for each other's health
+type PeerGroup string
+
+const (
+	// Workers are the nodes with the worker role label
+	Workers PeerGroup = "Workers"
+	// ControlPlane are the nodes with the control-plane role label
+	ControlPlane PeerGroup = "ControlPlane"
 )
 
 type Peers struct {
 	client.Reader
 	log                logr.Logger
-	peerSelector       labels.Selector
+	peerSelectors      map[PeerGroup]labels.Selector
+	peerNodeSelector   labels.Selector
 	peerUpdateInterval time.Duration
 	myNodeName         string
+	myPeerGroup        PeerGroup
 	mutex              sync.Mutex
 	apiServerTimeout   time.Duration
-	peersAddresses     [][]v1.NodeAddress
+	strategy           PeerSelectionStrategy
+	peersAddresses     map[PeerGroup][][]v1.NodeAddress
+	// lastUpdates are the times of the last successful update of the peer groups
+	lastUpdates map[PeerGroup]time.Time
+	// nodeChanges is signalled by the node informer when the peers need to be updated before the next regular update
+	nodeChanges chan struct{}
+	// intervalChanges passes a changed peerUpdateInterval to the update loop
+	intervalChanges chan time.Duration
 }
 
-func New(myNodeName string, peerUpdateInterval time.Duration, reader client.Reader, log logr.Logger, apiServerTimeout time.Duration) *Peers {
+// New returns a new Peers instance. An empty strategy defaults to Random. When a peerNodeSelector is given, only the
+// nodes matching it are peers. The other nodes are still remediated, but they aren't asked for the health of others.
+func New(myNodeName string, peerUpdateInterval time.Duration, reader client.Reader, log logr.Logger, apiServerTimeout time.Duration, strategy PeerSelectionStrategy, peerNodeSelector labels.Selector) *Peers {
+	if strategy == "" {
+		strategy = Random
+	}
+	if peerNodeSelector == nil {
+		peerNodeSelector = labels.Everything()
+	}
 	return &Peers{
 		Reader:             reader,
 		log:                log,
@@ -40,7 +96,66 @@ func New(myNodeName string, peerUpdateInterval time.Duration, reader client.Read
 		myNodeName:         myNodeName,
 		mutex:              sync.Mutex{},
 		apiServerTimeout:   apiServerTimeout,
-		peersAddresses:     [][]v1.NodeAddress{},
+		strategy:           strategy,
+		peerNodeSelector:   peerNodeSelector,
+		peersAddresses:     map[PeerGroup][][]v1.NodeAddress{},
+		lastUpdates:        map[PeerGroup]time.Time{},
+		nodeChanges:        make(chan struct{}, 1),
+		intervalChanges:    make(chan time.Duration, 1),
+	}
+}
+
+// WatchNodes updates the peers as soon as the given node informer reports that a node was added or deleted, or that
+// its labels or addresses changed. The regular updates are kept as a safety net for missed events. The peers are
+// still read with the reader of the Peers, so that its errors surface the same way as for the regular updates.
+func (p *Peers) WatchNodes(informer cache.Informer) {
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			p.signalNodeChange()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, oldOk := oldObj.(*v1.Node)
+			newNode, newOk := newObj.(*v1.Node)
+			// the status of each node is updated frequently, ignore everything which doesn't affect the peers
+			if oldOk && newOk &&
+				equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) &&
+				equality.Semantic.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) {
+				return
+			}
+			p.signalNodeChange()
+		},
+		DeleteFunc: func(_ interface{}) {
+			p.signalNodeChange()
+		},
+	})
+}
+
+// SetUpdateInterval changes the interval of the regular updates, the running update loop restarts its ticker with
+// it. Non-positive intervals are ignored.
+func (p *Peers) SetUpdateInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for {
+		select {
+		case p.intervalChanges <- interval:
+			return
+		default:
+			// replace the change which wasn't applied yet
+			select {
+			case <-p.intervalChanges:
+			default:
+			}
+		}
+	}
+}
+
+// signalNodeChange requests an update of the peers without blocking, changes which arrive before the update started
+// are handled by the same update
+func (p *Peers) signalNodeChange() {
+	select {
+	case p.nodeChanges <- struct{}{}:
+	default:
 	}
 }
 
@@ -66,57 +181,191 @@ func (p *Peers) Start(ctx context.Context) error {
 	} else {
 		reqNotMe, _ := labels.NewRequirement(hostnameLabelName, selection.NotEquals, []string{hostname})
 		reqWorkers, _ := labels.NewRequirement(workerLabelName, selection.Exists, []string{})
-		selector := labels.NewSelector()
-		selector = selector.Add(*reqNotMe, *reqWorkers)
-		p.peerSelector = selector
+		reqControlPlane, _ := labels.NewRequirement(controlPlaneLabelName, selection.Exists, []string{})
+		p.mutex.Lock()
+		p.peerSelectors = map[PeerGroup]labels.Selector{
+			Workers:      p.peerNodeSelector.Add(*reqNotMe, *reqWorkers),
+			ControlPlane: p.peerNodeSelector.Add(*reqNotMe, *reqControlPlane),
+		}
+		p.mutex.Unlock()
 	}
 
-	go wait.UntilWithContext(ctx, func(ctx context.Context) {
-		p.updatePeers(ctx)
-	}, p.peerUpdateInterval)
+	// control plane nodes restart regularly during upgrades, so they shouldn't be asked by workers, and vice versa
+	myPeerGroup := Workers
+	if _, isControlPlane := myNode.Labels[controlPlaneLabelName]; isControlPlane {
+		myPeerGroup = ControlPlane
+	}
+	p.mutex.Lock()
+	p.myPeerGroup = myPeerGroup
+	p.mutex.Unlock()
+	p.log.Info("using peer group", "group", myPeerGroup)
 
 	p.log.Info("peers started")
 
-	<-ctx.Done()
-	return nil
+	p.updatePeers(ctx)
+	ticker := time.NewTicker(p.peerUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.updatePeers(ctx)
+		case <-p.nodeChanges:
+			refreshes.WithLabelValues(refreshReasonNodeChange).Inc()
+			p.updatePeers(ctx)
+		case interval := <-p.intervalChanges:
+			if interval != p.peerUpdateInterval {
+				p.log.Info("changing peer update interval", "old interval", p.peerUpdateInterval, "new interval", interval)
+				p.peerUpdateInterval = interval
+				ticker.Reset(interval)
+			}
+		}
+	}
 }
 
 func (p *Peers) updatePeers(ctx context.Context) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
+	for group, selector := range p.peerSelectors {
+		p.updatePeerGroup(ctx, group, selector)
+	}
+}
+
+// Refresh updates the addresses of all peer groups immediately, e.g. when the cached addresses point to nodes
+// which are gone
+func (p *Peers) Refresh(ctx context.Context) {
+	p.log.Info("refreshing peers")
+	refreshes.WithLabelValues(refreshReasonForced).Inc()
+	p.updatePeers(ctx)
+}
+
+func (p *Peers) updatePeerGroup(ctx context.Context, group PeerGroup, selector labels.Selector) {
 	readerCtx, cancel := context.WithTimeout(ctx, p.apiServerTimeout)
 	defer cancel()
 
 	nodes := v1.NodeList{}
 	// get some nodes, but not ourself
-	if err := p.List(readerCtx, &nodes, client.MatchingLabelsSelector{Selector: p.peerSelector}); err != nil {
+	if err := p.List(readerCtx, &nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
 		if errors.IsNotFound(err) {
 			// we are the only node at the moment... reset peerList
-			p.peersAddresses = [][]v1.NodeAddress{}
+			p.peersAddresses[group] = [][]v1.NodeAddress{}
 		}
-		p.log.Error(err, "failed to update peer list")
+		p.log.Error(err, "failed to update peer list", "group", group)
 		return
 	}
+	p.sortPeers(readerCtx, nodes.Items)
 	nodesCount := len(nodes.Items)
 	addresses := make([][]v1.NodeAddress, nodesCount)
 	for i, node := range nodes.Items {
 		addresses[i] = node.Status.Addresses
 	}
-	p.peersAddresses = addresses
+	p.peersAddresses[group] = addresses
+	p.lastUpdates[group] = time.Now()
+}
+
+// sortPeers orders the given nodes according to the peer selection strategy
+func (p *Peers) sortPeers(ctx context.Context, nodes []v1.Node) {
+	rand.Shuffle(len(nodes), func(i, j int) {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	})
+	if p.strategy != SameZoneFirst {
+		return
+	}
+
+	// read our own zone on every update, it might have been changed
+	myNode := &v1.Node{}
+	if err := p.Get(ctx, client.ObjectKey{Name: p.myNodeName}, myNode); err != nil {
+		p.log.Error(err, "failed to get own node for reading its zone, using random peer order")
+		return
+	}
+	myZone, exists := myNode.Labels[zoneLabelName]
+	if !exists {
+		p.log.Info("own node has no zone label, using random peer order", "label", zoneLabelName)
+		return
+	}
+
+	sameZoneIndex := 0
+	for i := range nodes {
+		if nodes[i].Labels[zoneLabelName] == myZone {
+			nodes[sameZoneIndex], nodes[i] = nodes[i], nodes[sameZoneIndex]
+			sameZoneIndex++
+		}
+	}
 }
 
+// GetPeersAddresses returns the addresses of the peers in our own peer group
 func (p *Peers) GetPeersAddresses() [][]v1.NodeAddress {
+	return p.GetPeersAddressesOfGroup(p.GetPeerGroup())
+}
+
+// GetPeerGroup returns our own peer group
+func (p *Peers) GetPeerGroup() PeerGroup {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.myPeerGroup
+}
+
+// GetPeersAddressesOfGroup returns the addresses of the peers in the given peer group
+func (p *Peers) GetPeersAddressesOfGroup(group PeerGroup) [][]v1.NodeAddress {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
+	if selector, exists := p.peerSelectors[group]; exists && time.Since(p.lastUpdates[group]) > peerAddressesTTL {
+		// the addresses might point to nodes which are gone meanwhile, on failure we still use them
+		refreshes.WithLabelValues(refreshReasonExpired).Inc()
+		p.updatePeerGroup(context.Background(), group, selector)
+	}
+
 	//we don't want the caller to be able to change the addresses
 	//so we create a deep copy and return it
-	addressesCopy := make([][]v1.NodeAddress, len(p.peersAddresses))
-	for i := range p.peersAddresses {
-		addressesCopy[i] = make([]v1.NodeAddress, len(p.peersAddresses[i]))
-		copy(addressesCopy, p.peersAddresses)
+	peersAddresses := p.peersAddresses[group]
+	addressesCopy := make([][]v1.NodeAddress, len(peersAddresses))
+	for i := range peersAddresses {
+		addressesCopy[i] = make([]v1.NodeAddress, len(peersAddresses[i]))
+		copy(addressesCopy[i], peersAddresses[i])
 	}
 
 	return addressesCopy
 }
+
+// GetClusterSize returns the number of nodes in the cluster as observed by the last peer updates, including our own
+// node. Nodes which are member of several peer groups are counted once.
+func (p *Peers) GetClusterSize() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	nodes := map[string]bool{}
+	for _, peersAddresses := range p.peersAddresses {
+		for _, addresses := range peersAddresses {
+			key := GetHostname(addresses)
+			if ips := GetInternalIPs(addresses); len(ips) > 0 {
+				key = ips[0]
+			}
+			nodes[key] = true
+		}
+	}
+	return len(nodes) + 1
+}
+
+// GetInternalIPs returns the internal IPs of both IPv4 and IPv6 family of the given node addresses, in their original order
+func GetInternalIPs(addresses []v1.NodeAddress) []string {
+	ips := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address.Type == v1.NodeInternalIP && address.Address != "" {
+			ips = append(ips, address.Address)
+		}
+	}
+	return ips
+}
+
+// GetHostname returns the hostname of the given node addresses, or an empty string if there is none
+func GetHostname(addresses []v1.NodeAddress) string {
+	for _, address := range addresses {
+		if address.Type == v1.NodeHostName {
+			return address.Address
+		}
+	}
+	return ""
+}
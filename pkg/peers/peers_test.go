@@ -0,0 +1,304 @@
+package peers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeNodeReader is a reader of nodes for tests, it only supports what the peers need: getting nodes by name and
+// listing them by label selector
+type fakeNodeReader struct {
+	lock  sync.Mutex
+	nodes map[string]*v1.Node
+}
+
+var _ client.Reader = &fakeNodeReader{}
+
+func newFakeNodeReader(nodes ...*v1.Node) *fakeNodeReader {
+	r := &fakeNodeReader{nodes: map[string]*v1.Node{}}
+	for _, node := range nodes {
+		r.add(node)
+	}
+	return r
+}
+
+func (r *fakeNodeReader) add(node *v1.Node) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.nodes[node.Name] = node.DeepCopy()
+}
+
+func (r *fakeNodeReader) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return fmt.Errorf("unsupported object %T", obj)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	stored, exists := r.nodes[key.Name]
+	if !exists {
+		return apiErrors.NewNotFound(v1.Resource("nodes"), key.Name)
+	}
+	stored.DeepCopyInto(node)
+	return nil
+}
+
+func (r *fakeNodeReader) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	nodes, ok := list.(*v1.NodeList)
+	if !ok {
+		return fmt.Errorf("unsupported list %T", list)
+	}
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	nodes.Items = nil
+	for _, node := range r.nodes {
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		nodes.Items = append(nodes.Items, *node.DeepCopy())
+	}
+	return nil
+}
+
+// fakeNodeInformer is an informer for tests, which passes added nodes to its event handlers
+type fakeNodeInformer struct {
+	handlers []toolscache.ResourceEventHandler
+}
+
+var _ cache.Informer = &fakeNodeInformer{}
+
+func (i *fakeNodeInformer) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	i.handlers = append(i.handlers, handler)
+}
+
+func (i *fakeNodeInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, _ time.Duration) {
+	i.AddEventHandler(handler)
+}
+
+func (i *fakeNodeInformer) AddIndexers(_ toolscache.Indexers) error {
+	return nil
+}
+
+func (i *fakeNodeInformer) HasSynced() bool {
+	return true
+}
+
+func (i *fakeNodeInformer) add(obj runtime.Object) {
+	for _, handler := range i.handlers {
+		handler.OnAdd(obj)
+	}
+}
+
+var _ = Describe("Peers", func() {
+
+	Describe("Internal IPs", func() {
+
+		It("should return IPv4 and IPv6 internal IPs in order", func() {
+			addresses := []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "node1"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: v1.NodeExternalIP, Address: "192.0.2.1"},
+				{Type: v1.NodeInternalIP, Address: "fd00::1"},
+			}
+			Expect(GetInternalIPs(addresses)).To(Equal([]string{"10.0.0.1", "fd00::1"}))
+		})
+
+		It("should return internal IPs of IPv6 only nodes", func() {
+			nodes := []v1.Node{
+				{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+					{Type: v1.NodeHostName, Address: "node1"},
+					{Type: v1.NodeInternalIP, Address: "fd00::1"},
+				}}},
+				{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "fd00::2"},
+					{Type: v1.NodeInternalIP, Address: "fd00::3"},
+				}}},
+			}
+			Expect(GetInternalIPs(nodes[0].Status.Addresses)).To(Equal([]string{"fd00::1"}))
+			Expect(GetInternalIPs(nodes[1].Status.Addresses)).To(Equal([]string{"fd00::2", "fd00::3"}))
+		})
+
+		It("should return no IPs for nodes without internal IPs", func() {
+			addresses := []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "node1"},
+				{Type: v1.NodeExternalIP, Address: "192.0.2.1"},
+			}
+			Expect(GetInternalIPs(addresses)).To(BeEmpty())
+		})
+	})
+
+	Describe("Peer groups", func() {
+
+		newNode := func(name string, role string, ip string) *v1.Node {
+			return &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						hostnameLabelName: name,
+						role:              "",
+					},
+				},
+				Status: v1.NodeStatus{
+					Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: ip}},
+				},
+			}
+		}
+
+		startPeers := func(myNodeName string) (*Peers, context.CancelFunc) {
+			reader := newFakeNodeReader(
+				newNode("worker1", workerLabelName, "10.0.0.1"),
+				newNode("worker2", workerLabelName, "10.0.0.2"),
+				newNode("master1", controlPlaneLabelName, "10.0.1.1"),
+				newNode("master2", controlPlaneLabelName, "10.0.1.2"),
+			)
+			p := New(myNodeName, time.Second, reader, ctrl.Log.WithName("peers"), time.Second, Random, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				defer GinkgoRecover()
+				Expect(p.Start(ctx)).To(Succeed())
+			}()
+			return p, cancel
+		}
+
+		It("should only return workers to workers", func() {
+			p, cancel := startPeers("worker1")
+			defer cancel()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(Equal(
+				[][]v1.NodeAddress{{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}},
+			))
+			Expect(p.GetPeerGroup()).To(Equal(Workers))
+		})
+
+		It("should only return control plane nodes to control plane nodes", func() {
+			p, cancel := startPeers("master1")
+			defer cancel()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(Equal(
+				[][]v1.NodeAddress{{{Type: v1.NodeInternalIP, Address: "10.0.1.2"}}},
+			))
+			Expect(p.GetPeerGroup()).To(Equal(ControlPlane))
+			Expect(p.GetPeersAddressesOfGroup(Workers)).To(HaveLen(2))
+		})
+
+		It("should only return peers matching the peer node selector", func() {
+			flakyWorker := newNode("worker3", workerLabelName, "10.0.0.3")
+			flakyWorker.Labels["example.com/flaky-network"] = ""
+			reader := newFakeNodeReader(
+				newNode("worker1", workerLabelName, "10.0.0.1"),
+				newNode("worker2", workerLabelName, "10.0.0.2"),
+				flakyWorker,
+			)
+			selector, err := labels.Parse("!example.com/flaky-network")
+			Expect(err).ToNot(HaveOccurred())
+			p := New("worker1", time.Second, reader, ctrl.Log.WithName("peers"), time.Second, Random, selector)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				defer GinkgoRecover()
+				Expect(p.Start(ctx)).To(Succeed())
+			}()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(Equal(
+				[][]v1.NodeAddress{{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}},
+			))
+		})
+
+		It("should update the peers on node changes", func() {
+			reader := newFakeNodeReader(
+				newNode("worker1", workerLabelName, "10.0.0.1"),
+				newNode("worker2", workerLabelName, "10.0.0.2"),
+			)
+			informer := &fakeNodeInformer{}
+			// no regular update during the test
+			p := New("worker1", time.Hour, reader, ctrl.Log.WithName("peers"), time.Second, Random, nil)
+			p.WatchNodes(informer)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				defer GinkgoRecover()
+				Expect(p.Start(ctx)).To(Succeed())
+			}()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(1))
+
+			newWorker := newNode("worker3", workerLabelName, "10.0.0.3")
+			reader.add(newWorker)
+			informer.add(newWorker)
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(ConsistOf(
+				[]v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}},
+				[]v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.3"}},
+			))
+		})
+
+		It("should update the peers with a changed interval", func() {
+			reader := newFakeNodeReader(
+				newNode("worker1", workerLabelName, "10.0.0.1"),
+				newNode("worker2", workerLabelName, "10.0.0.2"),
+			)
+			// no regular update before the interval is changed
+			p := New("worker1", time.Hour, reader, ctrl.Log.WithName("peers"), time.Second, Random, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				defer GinkgoRecover()
+				Expect(p.Start(ctx)).To(Succeed())
+			}()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(1))
+
+			reader.add(newNode("worker3", workerLabelName, "10.0.0.3"))
+			Consistently(p.GetPeersAddresses, time.Second, 100*time.Millisecond).Should(HaveLen(1))
+			p.SetUpdateInterval(100 * time.Millisecond)
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(2))
+		})
+
+		It("should return new peers after a refresh", func() {
+			reader := newFakeNodeReader(
+				newNode("worker1", workerLabelName, "10.0.0.1"),
+				newNode("worker2", workerLabelName, "10.0.0.2"),
+			)
+			// no regular update during the test
+			p := New("worker1", time.Hour, reader, ctrl.Log.WithName("peers"), time.Second, Random, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				defer GinkgoRecover()
+				Expect(p.Start(ctx)).To(Succeed())
+			}()
+			Eventually(p.GetPeersAddresses, 5*time.Second, 100*time.Millisecond).Should(HaveLen(1))
+
+			reader.add(newNode("worker3", workerLabelName, "10.0.0.3"))
+			Expect(p.GetPeersAddresses()).To(HaveLen(1))
+			p.Refresh(context.Background())
+			Expect(p.GetPeersAddresses()).To(ConsistOf(
+				[]v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}},
+				[]v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.3"}},
+			))
+		})
+	})
+
+	Describe("Peer selection strategy", func() {
+
+		It("should default to random", func() {
+			Expect(ParsePeerSelectionStrategy("")).To(Equal(Random))
+			Expect(ParsePeerSelectionStrategy("SameZoneFirst")).To(Equal(SameZoneFirst))
+		})
+
+		It("should reject unknown strategies", func() {
+			_, err := ParsePeerSelectionStrategy("OtherZoneFirst")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,23 @@
+package reboot
+
+import (
+	"github.com/go-logr/logr"
+)
+
+var _ Rebooter = &DryRunRebooter{}
+
+// DryRunRebooter only logs reboot requests, it's used in dry run mode
+type DryRunRebooter struct {
+	log logr.Logger
+}
+
+func NewDryRunRebooter(log logr.Logger) Rebooter {
+	return &DryRunRebooter{
+		log: log,
+	}
+}
+
+func (r *DryRunRebooter) Reboot() error {
+	r.log.Info("dry run: skipping reboot")
+	return nil
+}
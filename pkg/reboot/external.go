@@ -0,0 +1,24 @@
+package reboot
+
+import (
+	"github.com/go-logr/logr"
+)
+
+var _ Rebooter = &ExternalFencingRebooter{}
+
+// ExternalFencingRebooter doesn't reboot the node. It's used when no watchdog can be exposed to the agents, and
+// unhealthy nodes are fenced by deleting their node object and waiting for the cloud provider to recreate them.
+type ExternalFencingRebooter struct {
+	log logr.Logger
+}
+
+func NewExternalFencingRebooter(log logr.Logger) Rebooter {
+	return &ExternalFencingRebooter{
+		log: log,
+	}
+}
+
+func (r *ExternalFencingRebooter) Reboot() error {
+	r.log.Info("external fencing: skipping reboot, the node is fenced by deleting it")
+	return nil
+}
@@ -0,0 +1,39 @@
+package reboot
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Rebooter = &FakeRebooter{}
+
+// FakeRebooter records reboot requests without rebooting, it's used in tests
+type FakeRebooter struct {
+	rebootTimes []time.Time
+	mutex       sync.Mutex
+}
+
+func NewFakeRebooter() *FakeRebooter {
+	return &FakeRebooter{}
+}
+
+func (r *FakeRebooter) Reboot() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rebootTimes = append(r.rebootTimes, time.Now())
+	return nil
+}
+
+// RebootCount returns the number of reboot requests
+func (r *FakeRebooter) RebootCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.rebootTimes)
+}
+
+// RebootTimes returns the times of all reboot requests
+func (r *FakeRebooter) RebootTimes() []time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]time.Time(nil), r.rebootTimes...)
+}
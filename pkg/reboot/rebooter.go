@@ -1,7 +1,10 @@
 package reboot
 
 import (
+	"fmt"
 	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/medik8s/poison-pill/pkg/watchdog"
@@ -12,22 +15,85 @@ type Rebooter interface {
 	Reboot() error
 }
 
+// DelayedRebooter is implemented by rebooters which can delay a reboot, e.g. for draining the node gracefully
+type DelayedRebooter interface {
+	// RebootAfter triggers a node reboot which happens when the given delay elapsed at the latest. When the delay
+	// isn't supported, the node is rebooted right away.
+	RebootAfter(delay time.Duration) error
+}
+
+// AbortableRebooter is implemented by rebooters which can abort a reboot until it commences
+type AbortableRebooter interface {
+	// RebootAbortable triggers a node reboot like Reboot, which can be aborted with AbortReboot
+	RebootAbortable() error
+	// AbortReboot aborts the reboot triggered by RebootAbortable, unless the node is about to reboot already, or
+	// another reboot was requested in the meantime. It returns if the reboot was aborted.
+	AbortReboot() bool
+}
+
+// RebootMethod defines how the watchdog is used for rebooting the node
+type RebootMethod string
+
+const (
+	// RebootMethodStopFeeding stops feeding the watchdog, the node reboots when the current timeout elapsed
+	RebootMethodStopFeeding RebootMethod = "StopFeeding"
+	// RebootMethodShortTimeout sets the min timeout of the device before it stops feeding the watchdog, which reboots
+	// the node faster with long timeouts, and with drivers which only evaluate the timeout when it's set
+	RebootMethodShortTimeout RebootMethod = "ShortTimeout"
+
+	// shortRebootTimeout is the timeout requested by RebootMethodShortTimeout, it's raised to the min timeout of the
+	// device
+	shortRebootTimeout = 1 * time.Second
+)
+
+// ParseRebootMethod returns the reboot method for the given name, an empty name returns the default method
+func ParseRebootMethod(name string) (RebootMethod, error) {
+	switch method := RebootMethod(name); method {
+	case "":
+		return RebootMethodStopFeeding, nil
+	case RebootMethodStopFeeding, RebootMethodShortTimeout:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown reboot method %q, valid methods are %s and %s", name, RebootMethodStopFeeding, RebootMethodShortTimeout)
+	}
+}
+
 var _ Rebooter = &WatchdogRebooter{}
+var _ DelayedRebooter = &WatchdogRebooter{}
+var _ AbortableRebooter = &WatchdogRebooter{}
 
 // WatchdogRebooter uses a watchdog for triggering reboots
 type WatchdogRebooter struct {
-	wd  watchdog.Watchdog
-	log logr.Logger
+	wd     watchdog.Watchdog
+	method RebootMethod
+	// timing is optional, it records the reboot requests
+	timing *RebootTiming
+	log    logr.Logger
+
+	// abortable is set while the only requested reboot is one of RebootAbortable
+	abortable bool
+	mutex     sync.Mutex
 }
 
-func NewWatchdogRebooter(wd watchdog.Watchdog, log logr.Logger) Rebooter {
+func NewWatchdogRebooter(wd watchdog.Watchdog, method RebootMethod, timing *RebootTiming, log logr.Logger) Rebooter {
 	return &WatchdogRebooter{
-		wd:  wd,
-		log: log,
+		wd:     wd,
+		method: method,
+		timing: timing,
+		log:    log,
 	}
 }
 
 func (r *WatchdogRebooter) Reboot() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// the reboot was requested for another reason as well, e.g. for a remediation of this node
+	r.abortable = false
+	return r.reboot()
+}
+
+func (r *WatchdogRebooter) reboot() error {
+	r.timing.RecordRequest(time.Now())
 	if r.wd == nil || !r.wd.IsStarted() {
 		r.log.Info("no watchdog is present on this host, trying software reboot")
 		//we couldn't init a watchdog so far but requested to be rebooted. we issue a software reboot
@@ -39,12 +105,67 @@ func (r *WatchdogRebooter) Reboot() error {
 		}
 		return nil
 	}
+	if r.method == RebootMethodShortTimeout {
+		if _, err := r.wd.ShortenTimeout(shortRebootTimeout); err != nil {
+			r.log.Error(err, "failed to shorten watchdog timeout, rebooting with the current timeout")
+		}
+	}
 	// we stop feeding the watchdog for a reboot
 	r.wd.Stop()
-	r.log.Info("watchdog feeding has stopped, waiting for reboot to commence")
+	r.log.Info("watchdog feeding has stopped, waiting for reboot to commence", "timeout", r.wd.GetTimeout())
 	return nil
 }
 
+// RebootAfter extends the watchdog timeout to the given delay and stops feeding the watchdog, so that the node
+// reboots when the delay elapsed, even when this process stops in the meantime. When the timeout can't be extended,
+// feeding is stopped right away.
+func (r *WatchdogRebooter) RebootAfter(delay time.Duration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.abortable = false
+	if r.wd == nil || !r.wd.IsStarted() {
+		return r.reboot()
+	}
+	r.timing.RecordRequest(time.Now())
+	timeout, err := r.wd.ExtendTimeout(delay)
+	if err != nil {
+		r.log.Error(err, "failed to extend watchdog timeout for delayed reboot, rebooting right away", "delay", delay)
+		return r.reboot()
+	}
+	if timeout < delay {
+		r.log.Info("watchdog timeout is limited by the device, rebooting earlier", "delay", delay, "timeout", timeout)
+	}
+	r.wd.Stop()
+	r.log.Info("watchdog feeding has stopped, waiting for delayed reboot to commence", "timeout", timeout)
+	return nil
+}
+
+// RebootAbortable triggers a reboot like Reboot. As long as the watchdog didn't fire, the reboot can be aborted with
+// AbortReboot. Software reboots can't be aborted.
+func (r *WatchdogRebooter) RebootAbortable() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.abortable = r.wd != nil && r.wd.IsStarted()
+	return r.reboot()
+}
+
+// AbortReboot resumes feeding the watchdog, when the reboot was triggered by RebootAbortable and no other reboot was
+// requested since
+func (r *WatchdogRebooter) AbortReboot() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.abortable {
+		return false
+	}
+	r.abortable = false
+	if !r.wd.Resume() {
+		return false
+	}
+	// the node didn't reboot, the next reboot request needs to be timed instead
+	r.timing.ClearRequest()
+	return true
+}
+
 // softwareReboot performs software reboot by running systemctl reboot
 func (r *WatchdogRebooter) softwareReboot() error {
 	// hostPID: true and privileged:true required to run this
@@ -0,0 +1,172 @@
+package reboot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/medik8s/poison-pill/pkg/watchdog"
+)
+
+var _ watchdog.Watchdog = &recordingWatchdog{}
+
+// recordingWatchdog is a started watchdog which records how the rebooter uses it
+type recordingWatchdog struct {
+	timeout    time.Duration
+	minTimeout time.Duration
+	maxTimeout time.Duration
+	stopped    bool
+	// resumable is returned by Resume
+	resumable bool
+}
+
+func (w *recordingWatchdog) Start(_ context.Context) error  { return nil }
+func (w *recordingWatchdog) IsStarted() bool                { return true }
+func (w *recordingWatchdog) Stop()                          { w.stopped = true }
+func (w *recordingWatchdog) GetTimeout() time.Duration      { return w.timeout }
+func (w *recordingWatchdog) LastFoodTime() time.Time        { return time.Now() }
+func (w *recordingWatchdog) Describe() string               { return "recording watchdog" }
+func (w *recordingWatchdog) IsArmed() bool                  { return true }
+func (w *recordingWatchdog) OnDeviceLost(_ func(err error)) {}
+
+func (w *recordingWatchdog) GetTimeoutRange() (time.Duration, time.Duration) {
+	return w.minTimeout, w.maxTimeout
+}
+
+func (w *recordingWatchdog) Resume() bool {
+	if !w.stopped || !w.resumable {
+		return false
+	}
+	w.stopped = false
+	return true
+}
+
+func (w *recordingWatchdog) ExtendTimeout(timeout time.Duration) (time.Duration, error) {
+	if w.maxTimeout <= w.timeout {
+		return w.timeout, watchdog.ErrTimeoutNotExtendable
+	}
+	if timeout > w.maxTimeout {
+		timeout = w.maxTimeout
+	}
+	w.timeout = timeout
+	return w.timeout, nil
+}
+
+func (w *recordingWatchdog) ShortenTimeout(timeout time.Duration) (time.Duration, error) {
+	if timeout < w.minTimeout {
+		timeout = w.minTimeout
+	}
+	w.timeout = timeout
+	return w.timeout, nil
+}
+
+var _ = Describe("Watchdog rebooter", func() {
+
+	var wd *recordingWatchdog
+	var dir string
+	var timingPath string
+	var rebooter *WatchdogRebooter
+
+	BeforeEach(func() {
+		wd = &recordingWatchdog{timeout: time.Minute, minTimeout: 2 * time.Second, maxTimeout: 5 * time.Minute, resumable: true}
+		var err error
+		dir, err = ioutil.TempDir("", "rebooter")
+		Expect(err).ToNot(HaveOccurred())
+		timingPath = filepath.Join(dir, "reboot-request")
+		timing := NewRebootTiming(timingPath, ctrl.Log.WithName("reboot-timing"))
+		rebooter = NewWatchdogRebooter(wd, RebootMethodStopFeeding, timing, ctrl.Log.WithName("rebooter")).(*WatchdogRebooter)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should reboot with the current timeout by default", func() {
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(wd.timeout).To(Equal(time.Minute))
+		Expect(timingPath).To(BeAnExistingFile())
+	})
+
+	It("should shorten the timeout to the min timeout of the device with the ShortTimeout method", func() {
+		rebooter.method = RebootMethodShortTimeout
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(wd.timeout).To(Equal(2 * time.Second))
+	})
+
+	It("should extend the timeout to the delay of a delayed reboot", func() {
+		Expect(rebooter.RebootAfter(3 * time.Minute)).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(wd.timeout).To(Equal(3 * time.Minute))
+		Expect(timingPath).To(BeAnExistingFile())
+	})
+
+	It("should reboot earlier when the device limits the delay", func() {
+		Expect(rebooter.RebootAfter(time.Hour)).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(wd.timeout).To(Equal(5 * time.Minute))
+	})
+
+	It("should reboot right away when the timeout can't be extended", func() {
+		wd.maxTimeout = wd.timeout
+		Expect(rebooter.RebootAfter(time.Hour)).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(wd.timeout).To(Equal(time.Minute))
+	})
+
+	It("should abort an abortable reboot and clear its timing", func() {
+		Expect(rebooter.RebootAbortable()).To(Succeed())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(timingPath).To(BeAnExistingFile())
+
+		Expect(rebooter.AbortReboot()).To(BeTrue())
+		Expect(wd.stopped).To(BeFalse())
+		Expect(timingPath).ToNot(BeAnExistingFile())
+
+		// the next reboot request is recorded again
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(timingPath).To(BeAnExistingFile())
+	})
+
+	It("should not abort a reboot which was requested for another reason as well", func() {
+		Expect(rebooter.RebootAbortable()).To(Succeed())
+		Expect(rebooter.Reboot()).To(Succeed())
+		Expect(rebooter.AbortReboot()).To(BeFalse())
+		Expect(wd.stopped).To(BeTrue())
+		Expect(timingPath).To(BeAnExistingFile())
+	})
+
+	It("should not abort a reboot which isn't abortable", func() {
+		Expect(rebooter.RebootAfter(time.Minute)).To(Succeed())
+		Expect(rebooter.AbortReboot()).To(BeFalse())
+		Expect(wd.stopped).To(BeTrue())
+	})
+
+	It("should keep the timing when the watchdog can't be resumed anymore", func() {
+		wd.resumable = false
+		Expect(rebooter.RebootAbortable()).To(Succeed())
+		Expect(rebooter.AbortReboot()).To(BeFalse())
+		Expect(timingPath).To(BeAnExistingFile())
+	})
+})
+
+var _ = Describe("Reboot method", func() {
+
+	It("should default to stop feeding", func() {
+		Expect(ParseRebootMethod("")).To(Equal(RebootMethodStopFeeding))
+		Expect(ParseRebootMethod("ShortTimeout")).To(Equal(RebootMethodShortTimeout))
+	})
+
+	It("should reject unknown methods", func() {
+		_, err := ParseRebootMethod("PowerOff")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,72 @@
+package reboot
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sysrqConfigPath  = "/proc/sys/kernel/sysrq"
+	sysrqTriggerPath = "/proc/sysrq-trigger"
+	// sysrqRebootMask is the bit in /proc/sys/kernel/sysrq which enables the reboot command
+	sysrqRebootMask = 128
+)
+
+var _ Rebooter = &SoftwareRebooter{}
+
+// SoftwareRebooter reboots the node using the reboot syscall, and falls back to the sysrq trigger.
+//
+// IMPORTANT: a software reboot is less safe than watchdog based fencing! It relies on a kernel which is still
+// responsive enough to execute the reboot. A hanging kernel or a stuck process might prevent the reboot, while the
+// healthy peers will still assume that the node has been rebooted after SafeTimeToAssumeNodeRebooted.
+// Use it only when no watchdog device is available.
+type SoftwareRebooter struct {
+	log logr.Logger
+}
+
+func NewSoftwareRebooter(log logr.Logger) Rebooter {
+	return &SoftwareRebooter{
+		log: log,
+	}
+}
+
+func (r *SoftwareRebooter) Reboot() error {
+	r.log.Info("syncing filesystems and rebooting")
+	// flush filesystem buffers, the reboot syscall won't do it for us
+	unix.Sync()
+	err := unix.Reboot(unix.LINUX_REBOOT_CMD_RESTART)
+	if err == nil {
+		return nil
+	}
+	r.log.Error(err, "reboot syscall failed")
+
+	if !r.isSysrqRebootAllowed(sysrqConfigPath) {
+		return err
+	}
+	r.log.Info("trying reboot via sysrq trigger")
+	if sysrqErr := ioutil.WriteFile(sysrqTriggerPath, []byte("b"), 0200); sysrqErr != nil {
+		r.log.Error(sysrqErr, "sysrq reboot failed")
+		return err
+	}
+	return nil
+}
+
+// isSysrqRebootAllowed checks if the kernel config in the given sysrq config file allows reboots via sysrq
+func (r *SoftwareRebooter) isSysrqRebootAllowed(configPath string) bool {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		r.log.Error(err, "failed to read sysrq config")
+		return false
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		r.log.Error(err, "failed to parse sysrq config")
+		return false
+	}
+	// 1 enables all sysrq functions, else it's a bitmask
+	return value == 1 || value&sysrqRebootMask != 0
+}
@@ -0,0 +1,53 @@
+package reboot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Software reboot", func() {
+
+	var dir string
+	var rebooter *SoftwareRebooter
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sysrq")
+		Expect(err).ToNot(HaveOccurred())
+		rebooter = NewSoftwareRebooter(ctrl.Log.WithName("software-rebooter")).(*SoftwareRebooter)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	isSysrqRebootAllowed := func(config string) bool {
+		configPath := filepath.Join(dir, "sysrq")
+		Expect(ioutil.WriteFile(configPath, []byte(config), 0600)).To(Succeed())
+		return rebooter.isSysrqRebootAllowed(configPath)
+	}
+
+	It("should allow sysrq reboots when all sysrq functions are enabled", func() {
+		Expect(isSysrqRebootAllowed("1\n")).To(BeTrue())
+	})
+
+	It("should allow sysrq reboots when the reboot bit is set", func() {
+		Expect(isSysrqRebootAllowed("176\n")).To(BeTrue())
+	})
+
+	It("should not allow sysrq reboots when the reboot bit isn't set", func() {
+		Expect(isSysrqRebootAllowed("0\n")).To(BeFalse())
+		Expect(isSysrqRebootAllowed("16\n")).To(BeFalse())
+	})
+
+	It("should not allow sysrq reboots when the config can't be read", func() {
+		Expect(isSysrqRebootAllowed("all")).To(BeFalse())
+		Expect(rebooter.isSysrqRebootAllowed(filepath.Join(dir, "missing"))).To(BeFalse())
+	})
+})
@@ -0,0 +1,124 @@
+package reboot
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const procStatPath = "/proc/stat"
+
+// RebootTiming persists the time of a reboot request, so that the agent can log the actual time it took until the
+// node rebooted after it started again. That's the lower bound for SafeTimeToAssumeNodeRebooted.
+type RebootTiming struct {
+	path string
+	log  logr.Logger
+}
+
+// NewRebootTiming returns a RebootTiming which persists reboot requests in the given file. It needs to be located on
+// the host, since the file needs to survive the reboot.
+func NewRebootTiming(path string, log logr.Logger) *RebootTiming {
+	return &RebootTiming{
+		path: path,
+		log:  log,
+	}
+}
+
+// RecordRequest persists the time of the reboot request. Repeated requests don't overwrite the first one, which
+// started the reboot.
+func (t *RebootTiming) RecordRequest(requestTime time.Time) {
+	if t == nil {
+		return
+	}
+	file, err := os.OpenFile(t.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if !os.IsExist(err) {
+			t.log.Error(err, "failed to record reboot request", "path", t.path)
+		}
+		return
+	}
+	defer file.Close()
+	if _, err := file.WriteString(requestTime.Format(time.RFC3339Nano)); err != nil {
+		t.log.Error(err, "failed to record reboot request", "path", t.path)
+	}
+}
+
+// ClearRequest removes the recorded reboot request, e.g. when the reboot was aborted, so that the next request is
+// recorded again
+func (t *RebootTiming) ClearRequest() {
+	if t == nil {
+		return
+	}
+	if err := os.Remove(t.path); err != nil && !os.IsNotExist(err) {
+		t.log.Error(err, "failed to remove recorded reboot request", "path", t.path)
+	}
+}
+
+// LogTimeToReboot logs the time between the last recorded reboot request and the boot of the node, and removes the
+// recorded request
+func (t *RebootTiming) LogTimeToReboot() {
+	if t == nil {
+		return
+	}
+	content, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.log.Error(err, "failed to read recorded reboot request", "path", t.path)
+		}
+		return
+	}
+	defer func() {
+		if err := os.Remove(t.path); err != nil {
+			t.log.Error(err, "failed to remove recorded reboot request", "path", t.path)
+		}
+	}()
+
+	requestTime, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(content)))
+	if err != nil {
+		t.log.Error(err, "failed to parse recorded reboot request", "path", t.path)
+		return
+	}
+	bootTime, err := readBootTime(procStatPath)
+	if err != nil {
+		t.log.Error(err, "failed to read boot time")
+		return
+	}
+	if bootTime.Before(requestTime) {
+		t.log.Info("node didn't reboot after the last reboot request", "request time", requestTime, "boot time", bootTime)
+		return
+	}
+	t.log.Info("node rebooted after reboot request", "time to reboot", bootTime.Sub(requestTime),
+		"request time", requestTime, "boot time", bootTime)
+}
+
+// readBootTime reads the boot time of the node from the btime line of the given /proc/stat file
+func readBootTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse btime %q: %v", fields[1], err)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("btime not found in %s", path)
+}
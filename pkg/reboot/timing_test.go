@@ -0,0 +1,84 @@
+package reboot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reboot timing", func() {
+
+	var dir string
+	var path string
+	var timing *RebootTiming
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "reboot-timing")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "reboot-request")
+		timing = NewRebootTiming(path, ctrl.Log.WithName("reboot-timing"))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readRequest := func() time.Time {
+		content, err := ioutil.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		requestTime, err := time.Parse(time.RFC3339Nano, string(content))
+		Expect(err).ToNot(HaveOccurred())
+		return requestTime
+	}
+
+	It("should keep the first reboot request", func() {
+		firstRequest := time.Now().Add(-time.Minute)
+		timing.RecordRequest(firstRequest)
+		timing.RecordRequest(time.Now())
+		Expect(readRequest().Equal(firstRequest)).To(BeTrue())
+	})
+
+	It("should record the next reboot request after the request was cleared", func() {
+		timing.RecordRequest(time.Now().Add(-time.Minute))
+		timing.ClearRequest()
+		Expect(path).ToNot(BeAnExistingFile())
+
+		nextRequest := time.Now()
+		timing.RecordRequest(nextRequest)
+		Expect(readRequest().Equal(nextRequest)).To(BeTrue())
+	})
+
+	It("should remove the reboot request after logging the time to reboot", func() {
+		timing.RecordRequest(time.Now())
+		timing.LogTimeToReboot()
+		Expect(path).ToNot(BeAnExistingFile())
+	})
+
+	It("should ignore a missing timing", func() {
+		var noTiming *RebootTiming
+		noTiming.RecordRequest(time.Now())
+		noTiming.ClearRequest()
+		noTiming.LogTimeToReboot()
+		// nothing was recorded, so there is nothing to clear
+		timing.ClearRequest()
+	})
+
+	It("should read the boot time from the btime line", func() {
+		statPath := filepath.Join(dir, "stat")
+		Expect(ioutil.WriteFile(statPath, []byte("cpu  1 2 3 4\nbtime 1600000000\nprocesses 42\n"), 0600)).To(Succeed())
+		bootTime, err := readBootTime(statPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bootTime).To(Equal(time.Unix(1600000000, 0)))
+
+		Expect(ioutil.WriteFile(statPath, []byte("cpu  1 2 3 4\n"), 0600)).To(Succeed())
+		_, err = readBootTime(statPath)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,87 @@
+package rebootbudget
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// configMapName is the name of the ConfigMap which tracks the nodes which are rebooting
+const configMapName = "poison-pill-reboot-budget"
+
+// Budget limits the number of nodes which reboot themselves at the same time, in order to prevent that a correlated
+// failure takes down the whole cluster. The rebooting nodes are tracked in a ConfigMap, together with the time until
+// which they are assumed to be rebooted. Updates of the ConfigMap are protected by its resource version, so
+// concurrent reservations fail with a conflict instead of exceeding the budget.
+// Nodes which can't reach the api server can't consult the budget, so it only helps in partial failures.
+type Budget struct {
+	configMaps           corev1client.ConfigMapInterface
+	maxConcurrentReboots int
+	log                  logr.Logger
+}
+
+//+kubebuilder:rbac:groups=core,namespace=system,resources=configmaps,verbs=get;create;update
+
+func New(kubeClient kubernetes.Interface, namespace string, maxConcurrentReboots int, log logr.Logger) *Budget {
+	return &Budget{
+		configMaps:           kubeClient.CoreV1().ConfigMaps(namespace),
+		maxConcurrentReboots: maxConcurrentReboots,
+		log:                  log,
+	}
+}
+
+// Acquire reserves a reboot of the given node until the given time. It returns false when the max number of
+// concurrent reboots is reached by other nodes. Reserving a node again updates its reservation.
+func (b *Budget) Acquire(ctx context.Context, nodeName string, until time.Time) (bool, error) {
+	cm, err := b.configMaps.Get(ctx, configMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return false, err
+		}
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: configMapName,
+			},
+			Data: map[string]string{
+				nodeName: until.UTC().Format(time.RFC3339),
+			},
+		}
+		if _, err := b.configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return false, err
+		}
+		b.log.Info("reserved reboot", "node", nodeName, "until", until)
+		return true, nil
+	}
+
+	// drop expired reservations, they belong to nodes which are assumed to be rebooted already
+	now := time.Now()
+	reservations := make(map[string]string, len(cm.Data))
+	var rebootingNodes []string
+	for node, value := range cm.Data {
+		reservedUntil, err := time.Parse(time.RFC3339, value)
+		if err != nil || !reservedUntil.After(now) || node == nodeName {
+			continue
+		}
+		reservations[node] = value
+		rebootingNodes = append(rebootingNodes, node)
+	}
+	if len(rebootingNodes) >= b.maxConcurrentReboots {
+		b.log.Info("max number of concurrent reboots reached", "node", nodeName, "rebooting nodes", rebootingNodes)
+		return false, nil
+	}
+
+	reservations[nodeName] = until.UTC().Format(time.RFC3339)
+	cm.Data = reservations
+	if _, err := b.configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+	b.log.Info("reserved reboot", "node", nodeName, "until", until)
+	return true, nil
+}
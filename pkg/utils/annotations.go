@@ -0,0 +1,25 @@
+package utils
+
+import v1 "k8s.io/api/core/v1"
+
+// DisabledAnnotation is the node annotation which suppresses the remediation of the node, e.g. during planned
+// maintenance. While it exists, the node neither fences itself nor is it fenced by other nodes. When it's added during
+// a remediation, which didn't delete the node yet, the remediation is aborted and the node changes are reverted.
+const DisabledAnnotation = "poison-pill.medik8s.io/disabled"
+
+// RemediationTemplateAnnotation is the node annotation which requests the remediation of the node. Its value is the
+// name of the PoisonPillRemediationTemplate in the operator namespace the remediation is created from.
+const RemediationTemplateAnnotation = "poison-pill.medik8s.io/remediation-template"
+
+// IsRemediationDisabled returns if the remediation of the given node is suppressed by the DisabledAnnotation
+func IsRemediationDisabled(node *v1.Node) bool {
+	_, exists := node.Annotations[DisabledAnnotation]
+	return exists
+}
+
+// MachineAnnotation is the node annotation which references the Machine of the node as "namespace/name"
+const MachineAnnotation = "machine.openshift.io/machine"
+
+// FencedAnnotation is the Machine annotation which tells that the node of the machine was fenced by a remediation,
+// so that the machine health check flow is aware of it. Its value is the time the fencing completed in RFC3339 format.
+const FencedAnnotation = "poison-pill.medik8s.io/fenced"
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ConflictBackoff is the backoff for retrying updates which failed because of a conflict with an update of another
+// client, e.g. of another controller editing the same node
+var ConflictBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.0,
+	Jitter:   0.1,
+}
+
+// RetryOnConflict calls update until it doesn't fail with a conflict, as often as the given backoff allows, or until
+// the context is done. The first call gets false, the retries get true, so that they can read the latest version of
+// the object from the api server before updating it again. The last conflict is returned when the retries are
+// exhausted.
+func RetryOnConflict(ctx context.Context, backoff wait.Backoff, update func(isRetry bool) error) error {
+	var lastConflict error
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		err := update(lastConflict != nil)
+		switch {
+		case err == nil:
+			return true, nil
+		case apiErrors.IsConflict(err):
+			lastConflict = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		return lastConflict
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"fmt"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// etcdOperatorGroupVersion is the group version of the Etcd resource of the etcd operator
+var etcdOperatorGroupVersion = schema.GroupVersion{Group: "operator.openshift.io", Version: "v1"}
+
+// IsEtcdOperatorAvailable returns if the etcd operator API with its Etcd resource is installed in the cluster
+func IsEtcdOperatorAvailable(resourcesGetter discovery.ServerResourcesInterface) (bool, error) {
+	resources, err := resourcesGetter.ServerResourcesForGroupVersion(etcdOperatorGroupVersion.String())
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get resources of %s: %v", etcdOperatorGroupVersion, err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "etcds" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"fmt"
+
+	machinev1beta1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+)
+
+// IsMachineAPIAvailable returns if the machine API with its Machine resource is installed in the cluster
+func IsMachineAPIAvailable(resourcesGetter discovery.ServerResourcesInterface) (bool, error) {
+	resources, err := resourcesGetter.ServerResourcesForGroupVersion(machinev1beta1.SchemeGroupVersion.String())
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get resources of %s: %v", machinev1beta1.SchemeGroupVersion, err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "machines" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+const (
+	// HostnameLabel is the well known label of nodes which contains the hostname reported by the kubelet
+	HostnameLabel = "kubernetes.io/hostname"
+	// ControlPlaneLabel and MasterLabel are the role labels of control plane nodes, older clusters only use the
+	// master label
+	ControlPlaneLabel = "node-role.kubernetes.io/control-plane"
+	MasterLabel       = "node-role.kubernetes.io/master"
+)
+
+// NodeReadyzCheck returns a healthz.Checker which fails when the node with the given name doesn't exist, e.g. because
+// the node name of the agent is wrong
+func NodeReadyzCheck(reader client.Reader, nodeName string) healthz.Checker {
+	return func(req *http.Request) error {
+		if err := reader.Get(req.Context(), client.ObjectKey{Name: nodeName}, &v1.Node{}); err != nil {
+			return fmt.Errorf("failed to get own node %s: %v", nodeName, err)
+		}
+		return nil
+	}
+}
+
+// IsControlPlaneNode returns if the given node has the role label of control plane nodes
+func IsControlPlaneNode(node *v1.Node) bool {
+	_, isControlPlane := node.Labels[ControlPlaneLabel]
+	_, isMaster := node.Labels[MasterLabel]
+	return isControlPlane || isMaster
+}
+
+// ResolveNodeName returns the name of the node with the given hostname, by looking up the node with the matching
+// hostname label. It is a fallback for when the node name isn't injected, since the node name isn't guaranteed to be
+// the same as the hostname.
+func ResolveNodeName(ctx context.Context, reader client.Reader, hostname string) (string, error) {
+	if hostname == "" {
+		return "", fmt.Errorf("failed to resolve node name: empty hostname")
+	}
+	// the kubelet lowercases the hostname for the label
+	hostname = strings.ToLower(hostname)
+
+	nodes := &v1.NodeList{}
+	if err := reader.List(ctx, nodes, client.MatchingLabels{HostnameLabel: hostname}); err != nil {
+		return "", fmt.Errorf("failed to list nodes with %s label %s: %v", HostnameLabel, hostname, err)
+	}
+	switch len(nodes.Items) {
+	case 0:
+		return "", fmt.Errorf("failed to resolve node name: no node with %s label %s", HostnameLabel, hostname)
+	case 1:
+		return nodes.Items[0].Name, nil
+	default:
+		return "", fmt.Errorf("failed to resolve node name: %d nodes with %s label %s", len(nodes.Items), HostnameLabel, hostname)
+	}
+}
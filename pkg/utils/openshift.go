@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// securityGroupVersion is the group version of the SecurityContextConstraints resource of OpenShift
+var securityGroupVersion = schema.GroupVersion{Group: "security.openshift.io", Version: "v1"}
+
+// IsOpenShift returns if the cluster is an OpenShift cluster, which is detected by its SecurityContextConstraints
+// resource
+func IsOpenShift(resourcesGetter discovery.ServerResourcesInterface) (bool, error) {
+	resources, err := resourcesGetter.ServerResourcesForGroupVersion(securityGroupVersion.String())
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get resources of %s: %v", securityGroupVersion, err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "securitycontextconstraints" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// outOfServiceTaintMinMinorVersion is the first minor version of Kubernetes 1 which enables the
+	// NodeOutOfServiceVolumeDetach feature by default
+	outOfServiceTaintMinMinorVersion = 26
+)
+
+// IsOutOfServiceTaintSupported returns if the pod garbage collector of the cluster handles the out-of-service taint
+func IsOutOfServiceTaintSupported(versionGetter discovery.ServerVersionInterface) (bool, error) {
+	serverVersion, err := versionGetter.ServerVersion()
+	if err != nil {
+		return false, fmt.Errorf("failed to get server version: %v", err)
+	}
+	major, err := strconv.Atoi(serverVersion.Major)
+	if err != nil {
+		return false, fmt.Errorf("invalid major server version %q: %v", serverVersion.Major, err)
+	}
+	// some distributions append a "+" to the minor version, e.g. "26+"
+	minor, err := strconv.Atoi(strings.TrimSuffix(serverVersion.Minor, "+"))
+	if err != nil {
+		return false, fmt.Errorf("invalid minor server version %q: %v", serverVersion.Minor, err)
+	}
+	return major > 1 || (major == 1 && minor >= outOfServiceTaintMinMinorVersion), nil
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version is the build version of the operator and the agents. It's set at build time with
+// -ldflags "-X github.com/medik8s/poison-pill/pkg/version.Version=<version>".
+var Version = "unknown"
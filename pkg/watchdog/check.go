@@ -0,0 +1,101 @@
+package watchdog
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// CheckResult describes a watchdog device which was checked by Check
+type CheckResult struct {
+	Path     string
+	Identity string
+	// MinTimeout and MaxTimeout are the supported timeout range, 0 means unknown
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+	// Timeout is the timeout the device was armed with
+	Timeout time.Duration
+	// ArmSkipped is set when the device can't be disarmed after arming it, because its driver uses nowayout
+	ArmSkipped bool
+	// Armed is set when the self test confirmed that feeding resets the timer of the device
+	Armed bool
+}
+
+// Check verifies that the given watchdog device is usable without rebooting the node: it opens the device, arms it
+// with the requested timeout, verifies that feeding resets its timer, and disarms it again with a magic close.
+// An empty path auto detects the device like NewAutoDetect. A requestedTimeout of 0 keeps the device's default timeout.
+// Check doesn't claim the device, it must not be used while a Watchdog of this process is running.
+func Check(log logr.Logger, path string, requestedTimeout time.Duration) (*CheckResult, error) {
+	wd, err := checkedDevice(log, path)
+	if err != nil {
+		return nil, err
+	}
+	wd.requestedTimeout = requestedTimeout
+	result := &CheckResult{
+		Path:       wd.path,
+		Identity:   wd.identity(),
+		MinTimeout: wd.minTimeout,
+		MaxTimeout: wd.maxTimeout,
+	}
+
+	if isNowayout(wd.path) {
+		// closing the device would not stop the timer, and the node would be rebooted
+		result.ArmSkipped = true
+		return result, nil
+	}
+
+	timeout, err := wd.start()
+	if err != nil {
+		return result, fmt.Errorf("failed to arm watchdog device %s: %v", wd.path, err)
+	}
+	result.Identity = wd.identity()
+	result.Timeout = *timeout
+	verifyErr := wd.verifyArmed(*timeout)
+	if err := wd.disarm(); err != nil {
+		return result, fmt.Errorf("failed to disarm watchdog device %s, the node might reboot: %v", wd.path, err)
+	}
+	if verifyErr != nil {
+		return result, fmt.Errorf("watchdog self test of device %s failed: %v", wd.path, verifyErr)
+	}
+	result.Armed = true
+	return result, nil
+}
+
+// checkedDevice returns the device with the given path, or the first usable device when the path is empty
+func checkedDevice(log logr.Logger, path string) (*linuxWatchdog, error) {
+	if path == "" {
+		for _, candidate := range watchdogCandidates() {
+			wd, err := probeDevice(candidate, log)
+			if err != nil {
+				log.Info("skipping watchdog device", "path", candidate, "reason", err.Error())
+				continue
+			}
+			return wd, nil
+		}
+		return nil, ErrNoWatchdogDevice
+	}
+
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("watchdog device not found: %v", err)
+		}
+		return nil, fmt.Errorf("failed to check for watchdog device: %v", err)
+	}
+	wd := &linuxWatchdog{
+		path: path,
+		log:  log,
+	}
+	wd.minTimeout, wd.maxTimeout = readTimeoutRange(path)
+	return wd, nil
+}
+
+// isNowayout returns if the driver of the given device was loaded with nowayout, which means that the device can't be
+// disarmed anymore once it was opened
+func isNowayout(path string) bool {
+	return readSysfsAttribute(path, "nowayout") == "1"
+}
@@ -1,6 +1,8 @@
 package watchdog
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -13,10 +15,28 @@ const (
 var _ watchdogImpl = &fakeWatchdog{}
 
 // fakeWatchdog provides the fake implementation of the watchdogImpl interface for tests
-type fakeWatchdog struct{}
+type fakeWatchdog struct {
+	// disarmed is set to 1 when the watchdog was disarmed with a magic close
+	disarmed int32
+	// maxTimeout is the max timeout the timeout can be extended to
+	maxTimeout time.Duration
+
+	// feedErr and reopenErr are returned by feed and reopen, for simulating a lost device
+	errMutex  sync.Mutex
+	feedErr   error
+	reopenErr error
+
+	// timeLeftUnsupported simulates a device without support for reading the time left
+	timeLeftUnsupported bool
+}
 
 func NewFake(log logr.Logger) (Watchdog, error) {
-	return newSynced(log, &fakeWatchdog{}), nil
+	return NewFakeWithMaxTimeout(log, fakeTimeout)
+}
+
+// NewFakeWithMaxTimeout returns a fake watchdog whose timeout can be extended up to the given max timeout
+func NewFakeWithMaxTimeout(log logr.Logger, maxTimeout time.Duration) (Watchdog, error) {
+	return newSynced(log, &fakeWatchdog{maxTimeout: maxTimeout}), nil
 }
 
 func (f *fakeWatchdog) start() (*time.Duration, error) {
@@ -25,9 +45,68 @@ func (f *fakeWatchdog) start() (*time.Duration, error) {
 }
 
 func (f *fakeWatchdog) feed() error {
-	return nil
+	f.errMutex.Lock()
+	defer f.errMutex.Unlock()
+	return f.feedErr
+}
+
+func (f *fakeWatchdog) setErrors(feedErr error, reopenErr error) {
+	f.errMutex.Lock()
+	defer f.errMutex.Unlock()
+	f.feedErr = feedErr
+	f.reopenErr = reopenErr
+}
+
+func (f *fakeWatchdog) reopen(timeout time.Duration) (*time.Duration, error) {
+	f.errMutex.Lock()
+	defer f.errMutex.Unlock()
+	if f.reopenErr != nil {
+		return nil, f.reopenErr
+	}
+	// the device works again after it was reopened
+	f.feedErr = nil
+	return f.updateTimeout(timeout)
 }
 
 func (f *fakeWatchdog) disarm() error {
+	atomic.StoreInt32(&f.disarmed, 1)
+	return nil
+}
+
+func (f *fakeWatchdog) isDisarmed() bool {
+	return atomic.LoadInt32(&f.disarmed) == 1
+}
+
+func (f *fakeWatchdog) describe() string {
+	return "fake watchdog"
+}
+
+func (f *fakeWatchdog) getTimeoutRange() (time.Duration, time.Duration) {
+	return fakeTimeout, f.maxTimeout
+}
+
+func (f *fakeWatchdog) expectedTimeout() time.Duration {
+	return fakeTimeout
+}
+
+func (f *fakeWatchdog) updateTimeout(timeout time.Duration) (*time.Duration, error) {
+	if timeout > f.maxTimeout {
+		timeout = f.maxTimeout
+	}
+	if timeout < fakeTimeout {
+		timeout = fakeTimeout
+	}
+	return &timeout, nil
+}
+
+// getTimeLeft returns the fake timeout, as if the watchdog was just fed
+func (f *fakeWatchdog) getTimeLeft() (time.Duration, error) {
+	if f.timeLeftUnsupported {
+		return 0, errTimeLeftUnsupported
+	}
+	return fakeTimeout, nil
+}
+
+func (f *fakeWatchdog) verifyArmed(_ time.Duration) error {
 	return nil
 }
@@ -2,21 +2,52 @@ package watchdog
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrTimeoutNotExtendable is returned by ExtendTimeout when the device doesn't support a longer timeout, and by
+// ShortenTimeout when the device doesn't support setting the timeout at all
+var ErrTimeoutNotExtendable = errors.New("watchdog timeout can't be extended")
+
+// errTimeLeftUnsupported is returned by getTimeLeft when the device doesn't support reading the time left
+var errTimeLeftUnsupported = errors.New("watchdog device doesn't support reading the time left")
+
 // Watchdog is the public facing interface for the watchdog
 type Watchdog interface {
-	// Start should be called by the manager and block on the given context
+	// Start should be called by the manager and block on the given context. When the context is done, e.g. on a
+	// graceful shutdown of the manager, the watchdog is disarmed with a magic close, unless Stop was called before.
 	Start(ctx context.Context) error
 	// IsStarted return if the watchdog is running
 	IsStarted() bool
-	// Stop stops feeding the watchdog, which results in a reboot of the node
+	// Stop stops feeding the watchdog without disarming it, which results in a reboot of the node
 	Stop()
-	// GetTimeout returns the watchdog timeout when it reboots the node without feeding
+	// Resume continues feeding the watchdog after Stop, with the timeout it was started with, as long as its timer
+	// didn't come close to firing yet. It returns if feeding was resumed, and so the reboot averted.
+	Resume() bool
+	// GetTimeout returns the watchdog timeout when it reboots the node without feeding. Before the watchdog is
+	// started, it returns the timeout the device is expected to be started with, 0 means unknown.
 	GetTimeout() time.Duration
 	// LastFoodTime return the last time the watchdog was fed
 	LastFoodTime() time.Time
+	// Describe returns a human readable description of the watchdog device, e.g. its path and identity
+	Describe() string
+	// GetTimeoutRange returns the min and max timeout supported by the device, 0 means unknown
+	GetTimeoutRange() (time.Duration, time.Duration)
+	// IsArmed returns if the last self test confirmed that feeding actually resets the watchdog timer, so that
+	// stopping to feed it will reboot the node
+	IsArmed() bool
+	// ExtendTimeout sets a longer timeout, bounded by the max timeout of the device, and resets the timer. It returns
+	// the new timeout, e.g. for letting the watchdog reboot the node after a graceful drain when it isn't fed anymore.
+	// ErrTimeoutNotExtendable is returned when the device doesn't support a longer timeout.
+	ExtendTimeout(timeout time.Duration) (time.Duration, error)
+	// ShortenTimeout sets a shorter timeout, bounded by the min timeout of the device, and resets the timer. It returns
+	// the new timeout, e.g. for letting the watchdog reboot the node as fast as possible when it isn't fed anymore.
+	ShortenTimeout(timeout time.Duration) (time.Duration, error)
+	// OnDeviceLost registers a handler which is called when feeding the device failed repeatedly and it couldn't be
+	// reopened, e.g. because it was hot-removed or its kernel module was unloaded. The node isn't protected by the
+	// watchdog anymore then, and IsArmed returns false.
+	OnDeviceLost(handler func(err error))
 }
 
 // watchdogImpl is the internal interface providing the implementation specific methods of a watchdog
@@ -24,4 +55,18 @@ type watchdogImpl interface {
 	start() (*time.Duration, error)
 	feed() error
 	disarm() error
+	describe() string
+	getTimeoutRange() (time.Duration, time.Duration)
+	// expectedTimeout returns the timeout the device is expected to be started with, 0 means unknown
+	expectedTimeout() time.Duration
+	// verifyArmed checks that the timer of the device was reset by the last feed
+	verifyArmed(timeout time.Duration) error
+	// updateTimeout sets the given timeout, clamped to the range of the device, resets the timer, and returns the
+	// resulting timeout
+	updateTimeout(timeout time.Duration) (*time.Duration, error)
+	// reopen closes the device without disarming it and opens it again with the given timeout, and returns the
+	// resulting timeout
+	reopen(timeout time.Duration) (*time.Duration, error)
+	// getTimeLeft returns the time left until the device fires, or errTimeLeftUnsupported
+	getTimeLeft() (time.Duration, error)
 }
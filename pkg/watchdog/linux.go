@@ -1,8 +1,14 @@
 package watchdog
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -13,8 +19,16 @@ import (
 	"github.com/go-logr/logr"
 )
 
+const (
+	defaultWatchdogDevice = "/dev/watchdog"
+)
+
 var (
-	watchdogDevice = os.Getenv("WATCHDOG_PATH")
+	// ErrNoWatchdogDevice is returned by NewAutoDetect when none of the probed devices is usable
+	ErrNoWatchdogDevice = errors.New("no usable watchdog device found")
+
+	// sysfsWatchdogDir contains the attributes of the watchdog devices, it's a var for testing
+	sysfsWatchdogDir = "/sys/class/watchdog"
 )
 
 // ensure we only have 1 instance
@@ -25,9 +39,17 @@ var _ watchdogImpl = &linuxWatchdog{}
 
 // linuxWatchdog provides the linux specific implementation of the watchdogImpl interface
 type linuxWatchdog struct {
-	fd   int
-	info *watchdogInfo
-	log  logr.Logger
+	fd               int
+	path             string
+	info             *watchdogInfo
+	requestedTimeout time.Duration
+	minTimeout       time.Duration
+	maxTimeout       time.Duration
+	// defaultTimeout is the timeout of the device before it's started, 0 means unknown
+	defaultTimeout time.Duration
+	// timeLeftUnsupported is set when the device doesn't support the WDIOC_GETTIMELEFT ioctl
+	timeLeftUnsupported bool
+	log                 logr.Logger
 }
 
 type watchdogInfo struct {
@@ -36,15 +58,17 @@ type watchdogInfo struct {
 	identity        [32]byte
 }
 
-func NewLinux(log logr.Logger) (Watchdog, error) {
-	mutex.Lock()
-	if linuxWatchDogInstantiated {
-		return nil, fmt.Errorf("linux watchdog already instantiated")
+// NewLinux returns a watchdog for the given device path, e.g. /dev/watchdog.
+// A requestedTimeout of 0 keeps the device's default timeout, a keepaliveInterval of 0 feeds the watchdog every
+// third of its timeout. The device isn't armed before the armDelay elapsed after the start.
+func NewLinux(log logr.Logger, watchdogDevice string, requestedTimeout time.Duration, keepaliveInterval time.Duration, armDelay time.Duration) (Watchdog, error) {
+	if err := claimLinuxWatchdog(); err != nil {
+		return nil, err
 	}
 
-	linuxWatchDogInstantiated = true
-	mutex.Unlock()
-
+	if err := ValidatePath(watchdogDevice); err != nil {
+		return nil, err
+	}
 	if _, err := os.Stat(watchdogDevice); err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("watchdog device not found: %v", err)
@@ -53,29 +77,207 @@ func NewLinux(log logr.Logger) (Watchdog, error) {
 	}
 
 	wd := &linuxWatchdog{
-		log: log,
+		path:             watchdogDevice,
+		requestedTimeout: requestedTimeout,
+		log:              log,
+	}
+	wd.minTimeout, wd.maxTimeout = readTimeoutRange(watchdogDevice)
+	wd.defaultTimeout = readDefaultTimeout(watchdogDevice)
+
+	swd := newSynced(log, wd)
+	swd.requestedKeepaliveInterval = keepaliveInterval
+	swd.armDelay = armDelay
+	return swd, nil
+}
+
+// NewAutoDetect probes /dev/watchdog0../dev/watchdogN and /dev/watchdog, and returns a watchdog for the first device
+// which is described in sysfs or answers the WDIOC_GETSUPPORT ioctl. It returns ErrNoWatchdogDevice if none of them
+// is usable.
+// A requestedTimeout of 0 keeps the device's default timeout, a keepaliveInterval of 0 feeds the watchdog every
+// third of its timeout. The device isn't armed before the armDelay elapsed after the start.
+func NewAutoDetect(log logr.Logger, requestedTimeout time.Duration, keepaliveInterval time.Duration, armDelay time.Duration) (Watchdog, error) {
+	if err := claimLinuxWatchdog(); err != nil {
+		return nil, err
+	}
+
+	for _, path := range watchdogCandidates() {
+		wd, err := probeDevice(path, log)
+		if err != nil {
+			log.Info("skipping watchdog device", "path", path, "reason", err.Error())
+			continue
+		}
+		wd.requestedTimeout = requestedTimeout
+		swd := newSynced(log, wd)
+		swd.requestedKeepaliveInterval = keepaliveInterval
+		swd.armDelay = armDelay
+		return swd, nil
+	}
+	return nil, ErrNoWatchdogDevice
+}
+
+// ValidatePath returns an error if the given watchdog device path isn't located in /dev
+func ValidatePath(path string) error {
+	if !strings.HasPrefix(filepath.Clean(path), "/dev/") {
+		return fmt.Errorf("invalid watchdog device path %q, it must be located in /dev", path)
+	}
+	return nil
+}
+
+// claimLinuxWatchdog ensures that the linux watchdog is instantiated only once
+func claimLinuxWatchdog() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if linuxWatchDogInstantiated {
+		return fmt.Errorf("linux watchdog already instantiated")
 	}
+	linuxWatchDogInstantiated = true
+	return nil
+}
 
-	return newSynced(log, wd), nil
+// watchdogCandidates returns the numbered watchdog devices in ascending order, followed by the default device
+func watchdogCandidates() []string {
+	paths, _ := filepath.Glob(defaultWatchdogDevice + "[0-9]*")
+	sort.Slice(paths, func(i, j int) bool {
+		return deviceNumber(paths[i]) < deviceNumber(paths[j])
+	})
+	return append(paths, defaultWatchdogDevice)
+}
+
+func deviceNumber(path string) int {
+	nr, err := strconv.Atoi(strings.TrimPrefix(path, defaultWatchdogDevice))
+	if err != nil {
+		return -1
+	}
+	return nr
+}
+
+// probeDevice reads the identity and timeouts of the given device. They are read from sysfs when possible, since
+// opening the device starts its timer. Devices which aren't described in sysfs are opened and disarmed again, unless
+// their driver uses nowayout, because their timer couldn't be stopped anymore. The device will be opened again when
+// the watchdog is started.
+func probeDevice(path string, log logr.Logger) (*linuxWatchdog, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if info := readSysfsInfo(path); info != nil {
+		wd := &linuxWatchdog{
+			path:           path,
+			info:           info,
+			defaultTimeout: readDefaultTimeout(path),
+			log:            log,
+		}
+		wd.minTimeout, wd.maxTimeout = readTimeoutRange(path)
+		return wd, nil
+	}
+	if isNowayout(path) {
+		return nil, fmt.Errorf("the driver uses nowayout, the device can't be probed without starting its timer")
+	}
+
+	fd, err := openDevice(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wd := &linuxWatchdog{
+		fd:   fd,
+		path: path,
+		log:  log,
+	}
+	defer func() {
+		if err := wd.disarm(); err != nil {
+			log.Error(err, "failed to disarm watchdog after probing", "path", path)
+		}
+	}()
+
+	if wd.info = getInfo(fd); wd.info == nil {
+		return nil, fmt.Errorf("WDIOC_GETSUPPORT failed")
+	}
+	timeout, err := wd.getTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeout: %v", err)
+	}
+	wd.defaultTimeout = *timeout
+	wd.minTimeout, wd.maxTimeout = readTimeoutRange(path)
+	return wd, nil
+}
+
+// readTimeoutRange reads the supported timeout range from sysfs. Unknown values are returned as 0.
+func readTimeoutRange(path string) (time.Duration, time.Duration) {
+	return readSysfsSeconds(path, "min_timeout"), readSysfsSeconds(path, "max_timeout")
+}
+
+// readDefaultTimeout reads the current timeout of the device from sysfs without opening the device, which would
+// start its timer. An unknown timeout is returned as 0.
+func readDefaultTimeout(path string) time.Duration {
+	return readSysfsSeconds(path, "timeout")
+}
+
+// readSysfsInfo reads the identity, options and firmware version of the device from sysfs, without opening the
+// device. It returns nil when the device isn't described in sysfs.
+func readSysfsInfo(path string) *watchdogInfo {
+	identity := readSysfsAttribute(path, "identity")
+	if identity == "" {
+		return nil
+	}
+	info := &watchdogInfo{}
+	copy(info.identity[:], identity)
+	// the options are formatted in hex, the firmware version in decimal
+	if options, err := strconv.ParseUint(readSysfsAttribute(path, "options"), 0, 32); err == nil {
+		info.options = uint32(options)
+	}
+	if firmwareVersion, err := strconv.ParseUint(readSysfsAttribute(path, "fw_version"), 0, 32); err == nil {
+		info.firmwareVersion = uint32(firmwareVersion)
+	}
+	return info
+}
+
+// readSysfsSeconds reads the given sysfs attribute of the device in seconds, it returns 0 when it can't be read
+func readSysfsSeconds(path string, attribute string) time.Duration {
+	seconds, err := strconv.Atoi(readSysfsAttribute(path, attribute))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// readSysfsAttribute reads the given sysfs attribute of the device, it returns an empty string when it can't be read
+func readSysfsAttribute(path string, attribute string) string {
+	device := filepath.Base(path)
+	// the legacy device is the first registered one
+	if device == filepath.Base(defaultWatchdogDevice) {
+		device += "0"
+	}
+	content, err := ioutil.ReadFile(filepath.Join(sysfsWatchdogDir, device, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
 }
 
 func (wd *linuxWatchdog) start() (*time.Duration, error) {
-	wdFd, err := openDevice()
+	wdFd, err := openDevice(wd.path)
 	if err != nil {
 		// Only log the error! Else the pod won't start at all. Users need to check the isStarted flag!
-		wd.log.Error(err, fmt.Sprintf("failed to open LinuxWatchdog device %s", watchdogDevice))
+		wd.log.Error(err, "failed to open LinuxWatchdog device", "path", wd.path)
 		return nil, err
 	}
 
 	wd.fd = wdFd
 	wd.info = getInfo(wdFd)
 
+	if wd.requestedTimeout > 0 {
+		if err := wd.setTimeout(wd.requestedTimeout); err != nil {
+			// the device still works with its default timeout, so only log the error
+			wd.log.Error(err, "failed to set watchdog timeout, using default timeout", "requested timeout", wd.requestedTimeout)
+		}
+	}
+
 	timeout, err := wd.getTimeout()
 	if err != nil {
 		// no feeding without timeout, so disarm
 		_ = wd.disarm()
 		// Only log the error! Else the pod won't start at all. Users need to check the isStarted flag!
-		wd.log.Error(err, fmt.Sprintf("failed to get timeout of watchdog, disarmed: %s", watchdogDevice))
+		wd.log.Error(err, "failed to get timeout of watchdog, disarmed", "path", wd.path)
 		return nil, err
 	}
 	return timeout, nil
@@ -90,6 +292,79 @@ func (wd *linuxWatchdog) getTimeout() (*time.Duration, error) {
 	return &timeoutDuration, nil
 }
 
+// setTimeout sets the given timeout, clamped to the range supported by the device
+func (wd *linuxWatchdog) setTimeout(timeout time.Duration) error {
+	timeout = wd.clampTimeout(timeout)
+	return IoctlSetPointerInt(wd.fd, WDIOC_SETTIMEOUT, int(timeout.Seconds()))
+}
+
+// expectedTimeout returns the requested timeout clamped to the range of the device, or the default timeout of the
+// device. Unless the device was probed and supports setting the timeout, setting the requested timeout might fail on
+// start, so the larger one of both is returned, which is the safe choice for waiting until the node rebooted.
+func (wd *linuxWatchdog) expectedTimeout() time.Duration {
+	if wd.requestedTimeout <= 0 {
+		return wd.defaultTimeout
+	}
+	timeout := wd.requestedTimeout
+	if wd.minTimeout > 0 && timeout < wd.minTimeout {
+		timeout = wd.minTimeout
+	}
+	if wd.maxTimeout > 0 && timeout > wd.maxTimeout {
+		timeout = wd.maxTimeout
+	}
+	if settable := wd.info != nil && wd.info.options&WDIOF_SETTIMEOUT != 0; !settable && wd.defaultTimeout > timeout {
+		return wd.defaultTimeout
+	}
+	return timeout
+}
+
+func (wd *linuxWatchdog) clampTimeout(timeout time.Duration) time.Duration {
+	if wd.minTimeout > 0 && timeout < wd.minTimeout {
+		wd.log.Info("requested watchdog timeout is below the device minimum", "requested", timeout, "minimum", wd.minTimeout)
+		return wd.minTimeout
+	}
+	if wd.maxTimeout > 0 && timeout > wd.maxTimeout {
+		wd.log.Info("requested watchdog timeout exceeds the device maximum", "requested", timeout, "maximum", wd.maxTimeout)
+		return wd.maxTimeout
+	}
+	return timeout
+}
+
+// updateTimeout sets the given timeout, which also resets the timer of the device
+func (wd *linuxWatchdog) updateTimeout(timeout time.Duration) (*time.Duration, error) {
+	if wd.info != nil && wd.info.options&WDIOF_SETTIMEOUT == 0 {
+		return nil, ErrTimeoutNotExtendable
+	}
+	if err := wd.setTimeout(timeout); err != nil {
+		return nil, err
+	}
+	return wd.getTimeout()
+}
+
+// reopen closes the device without the magic close, so that its timer keeps running when it can't be opened again,
+// and opens it again with the given timeout
+func (wd *linuxWatchdog) reopen(timeout time.Duration) (*time.Duration, error) {
+	if err := Close(wd.fd); err != nil {
+		wd.log.Info("failed to close watchdog device before reopening it", "path", wd.path, "reason", err.Error())
+	}
+	wdFd, err := openDevice(wd.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watchdog device %s: %v", wd.path, err)
+	}
+	wd.fd = wdFd
+	wd.info = getInfo(wdFd)
+	// the device might have been replaced by another one
+	wd.timeLeftUnsupported = false
+	if err := wd.setTimeout(timeout); err != nil {
+		wd.log.Error(err, "failed to restore watchdog timeout after reopening the device, using its default timeout", "timeout", timeout)
+	}
+	return wd.getTimeout()
+}
+
+func (wd *linuxWatchdog) getTimeoutRange() (time.Duration, time.Duration) {
+	return wd.minTimeout, wd.maxTimeout
+}
+
 func (wd *linuxWatchdog) feed() error {
 	food := []byte("a")
 	_, err := Write(wd.fd, food)
@@ -97,6 +372,48 @@ func (wd *linuxWatchdog) feed() error {
 	return err
 }
 
+// verifyArmed writes a keepalive and reads the time left until the watchdog fires. When feeding works, the timer
+// was just reset, so the time left is about the full timeout. Devices which don't support reading the time left
+// can't be verified and are assumed to be armed.
+func (wd *linuxWatchdog) verifyArmed(timeout time.Duration) error {
+	if wd.timeLeftUnsupported {
+		return nil
+	}
+	if err := wd.feed(); err != nil {
+		return fmt.Errorf("failed to write keepalive: %v", err)
+	}
+	timeLeft, err := wd.getTimeLeft()
+	if err != nil {
+		if errors.Is(err, errTimeLeftUnsupported) {
+			wd.log.Info("watchdog device doesn't support reading the time left, skipping self test", "path", wd.path)
+			return nil
+		}
+		return fmt.Errorf("failed to get time left: %v", err)
+	}
+	// the time left is reported in whole seconds and might be rounded down
+	if timeLeft < timeout-time.Second {
+		return fmt.Errorf("watchdog timer wasn't reset by keepalive, time left %v, timeout %v", timeLeft, timeout)
+	}
+	return nil
+}
+
+// getTimeLeft reads the time left until the device fires with the WDIOC_GETTIMELEFT ioctl, which isn't supported by
+// all drivers
+func (wd *linuxWatchdog) getTimeLeft() (time.Duration, error) {
+	if wd.timeLeftUnsupported {
+		return 0, errTimeLeftUnsupported
+	}
+	timeLeft, err := IoctlGetInt(wd.fd, WDIOC_GETTIMELEFT)
+	if err != nil {
+		if errors.Is(err, EOPNOTSUPP) || errors.Is(err, ENOTTY) || errors.Is(err, EINVAL) {
+			wd.timeLeftUnsupported = true
+			return 0, errTimeLeftUnsupported
+		}
+		return 0, err
+	}
+	return time.Duration(timeLeft) * time.Second, nil
+}
+
 //Disarm closes the LinuxWatchdog without triggering reboots, even if the LinuxWatchdog will not be fed any more
 func (wd *linuxWatchdog) disarm() error {
 	b := []byte("V") // "V" is a special char for signaling LinuxWatchdog disarm
@@ -108,6 +425,28 @@ func (wd *linuxWatchdog) disarm() error {
 	return Close(wd.fd)
 }
 
+func (wd *linuxWatchdog) getPath() string {
+	return wd.path
+}
+
+func (wd *linuxWatchdog) describe() string {
+	if wd.info == nil {
+		return wd.path
+	}
+	if wd.minTimeout == 0 && wd.maxTimeout == 0 {
+		return fmt.Sprintf("%s (%s)", wd.path, wd.identity())
+	}
+	return fmt.Sprintf("%s (%s, timeout range %v - %v)", wd.path, wd.identity(), wd.minTimeout, wd.maxTimeout)
+}
+
+// identity returns the identity reported by the device, or an empty string if it is unknown
+func (wd *linuxWatchdog) identity() string {
+	if wd.info == nil {
+		return ""
+	}
+	return strings.TrimRight(string(wd.info.identity[:]), "\x00")
+}
+
 func getInfo(fd int) *watchdogInfo {
 	info := watchdogInfo{}
 	_, _, errNo := syscall.Syscall(
@@ -121,6 +460,6 @@ func getInfo(fd int) *watchdogInfo {
 	return &info
 }
 
-func openDevice() (int, error) {
-	return Open(watchdogDevice, O_WRONLY, 0644)
+func openDevice(path string) (int, error) {
+	return Open(path, O_WRONLY, 0644)
 }
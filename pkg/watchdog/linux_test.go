@@ -0,0 +1,69 @@
+package watchdog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Watchdog probing", func() {
+
+	var dir string
+	var devicePath string
+	var origSysfsWatchdogDir string
+
+	writeAttribute := func(attribute string, value string) {
+		Expect(ioutil.WriteFile(filepath.Join(sysfsWatchdogDir, "watchdog7", attribute), []byte(value+"\n"), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "watchdog")
+		Expect(err).ToNot(HaveOccurred())
+		origSysfsWatchdogDir = sysfsWatchdogDir
+		sysfsWatchdogDir = filepath.Join(dir, "sys")
+		Expect(os.MkdirAll(filepath.Join(sysfsWatchdogDir, "watchdog7"), 0755)).To(Succeed())
+
+		// a regular file doesn't answer the ioctls of a watchdog device, so probing it by opening it fails
+		devicePath = filepath.Join(dir, "watchdog7")
+		Expect(ioutil.WriteFile(devicePath, nil, 0644)).To(Succeed())
+		writeAttribute("nowayout", "1")
+	})
+
+	AfterEach(func() {
+		sysfsWatchdogDir = origSysfsWatchdogDir
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("should read the identity and timeouts from sysfs without opening the device", func() {
+		writeAttribute("identity", "iTCO_wdt")
+		writeAttribute("options", "0x8180")
+		writeAttribute("timeout", "30")
+		writeAttribute("min_timeout", "2")
+		writeAttribute("max_timeout", "600")
+
+		wd, err := probeDevice(devicePath, ctrl.Log.WithName("watchdog"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wd.identity()).To(Equal("iTCO_wdt"))
+		Expect(wd.info.options).To(Equal(uint32(0x8180)))
+		Expect(wd.defaultTimeout).To(Equal(30 * time.Second))
+		Expect(wd.minTimeout).To(Equal(2 * time.Second))
+		Expect(wd.maxTimeout).To(Equal(600 * time.Second))
+	})
+
+	It("should not open nowayout devices which aren't described in sysfs", func() {
+		_, err := probeDevice(devicePath, ctrl.Log.WithName("watchdog"))
+		Expect(err).To(MatchError(ContainSubstring("nowayout")))
+	})
+
+	It("should skip devices which don't exist", func() {
+		_, err := probeDevice(filepath.Join(dir, "watchdog8"), ctrl.Log.WithName("watchdog"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,41 @@
+package watchdog
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	feedErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poison_pill_watchdog_feed_errors_total",
+		Help: "Number of failed attempts to feed the watchdog device",
+	})
+	lastFeedTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poison_pill_watchdog_last_feed_timestamp_seconds",
+		Help: "Unix timestamp of the last successful watchdog feed",
+	})
+	deviceReopens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "poison_pill_watchdog_device_reopens_total",
+		Help: "Number of attempts to reopen the watchdog device after repeated feed errors, by result",
+	}, []string{"result"})
+	armed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poison_pill_watchdog_armed",
+		Help: "Whether the last self test confirmed that feeding resets the watchdog timer (1) or not (0)",
+	})
+)
+
+const timeLeftMetricName = "poison_pill_watchdog_time_left_seconds"
+
+func init() {
+	metrics.Registry.MustRegister(feedErrors, lastFeedTimestamp, deviceReopens, armed)
+}
+
+// newTimeLeftMetric returns the gauge of the time left until the watchdog fires. Unlike the other metrics it's only
+// registered by a started watchdog whose device supports reading the time left.
+func newTimeLeftMetric(timeLeft func() float64) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: timeLeftMetricName,
+		Help: "Seconds left until the watchdog fires and reboots the node, as reported by the device",
+	}, timeLeft)
+}
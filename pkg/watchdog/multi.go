@@ -0,0 +1,257 @@
+package watchdog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+var _ watchdogImpl = &multiWatchdog{}
+var _ multiDevice = &linuxWatchdog{}
+
+// multiDevice is a device of a multiWatchdog
+type multiDevice interface {
+	watchdogImpl
+	// getPath returns the path of the device, e.g. /dev/watchdog1
+	getPath() string
+	// getTimeout returns the current timeout of the device
+	getTimeout() (*time.Duration, error)
+}
+
+// multiWatchdog arms several linux watchdog devices at once, so that a single failing device doesn't prevent the
+// reboot of the node
+type multiWatchdog struct {
+	devices []multiDevice
+	// started contains the devices which were opened successfully, and which need to be fed and disarmed
+	started []multiDevice
+	log     logr.Logger
+}
+
+// NewMulti returns a watchdog which feeds all the given devices, e.g. an IPMI and a TCO watchdog.
+// The watchdog only starts when all devices can be armed with the same timeout, which is the smallest timeout of all
+// devices. The requestedTimeout, keepaliveInterval and armDelay are applied like with NewLinux.
+func NewMulti(log logr.Logger, paths []string, requestedTimeout time.Duration, keepaliveInterval time.Duration, armDelay time.Duration) (Watchdog, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no watchdog device paths given")
+	}
+	if err := claimLinuxWatchdog(); err != nil {
+		return nil, err
+	}
+
+	mwd := &multiWatchdog{
+		log: log,
+	}
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if seen[path] {
+			return nil, fmt.Errorf("watchdog device %s was given more than once", path)
+		}
+		seen[path] = true
+		if err := ValidatePath(path); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("watchdog device not found: %v", err)
+			}
+			return nil, fmt.Errorf("failed to check for watchdog device: %v", err)
+		}
+		wd := &linuxWatchdog{
+			path:             path,
+			requestedTimeout: requestedTimeout,
+			log:              log,
+		}
+		wd.minTimeout, wd.maxTimeout = readTimeoutRange(path)
+		wd.defaultTimeout = readDefaultTimeout(path)
+		mwd.devices = append(mwd.devices, wd)
+	}
+
+	swd := newSynced(log, mwd)
+	swd.requestedKeepaliveInterval = keepaliveInterval
+	swd.armDelay = armDelay
+	return swd, nil
+}
+
+// start opens all devices and sets the smallest of their timeouts on all of them, because feeding needs to happen in
+// time for every device, and because the node must not be rebooted by a device with a longer timeout after it was
+// assumed to be rebooted, when the device with the smallest timeout fails. When one of the devices can't be armed
+// with that timeout, the already armed ones are disarmed again.
+func (mwd *multiWatchdog) start() (*time.Duration, error) {
+	var minTimeout *time.Duration
+	timeouts := make([]time.Duration, 0, len(mwd.devices))
+	for _, wd := range mwd.devices {
+		timeout, err := wd.start()
+		if err != nil {
+			return nil, mwd.abortStart(fmt.Errorf("failed to arm watchdog device %s: %v", wd.getPath(), err))
+		}
+		mwd.started = append(mwd.started, wd)
+		timeouts = append(timeouts, *timeout)
+		if minTimeout == nil || *timeout < *minTimeout {
+			minTimeout = timeout
+		}
+	}
+
+	for i, wd := range mwd.started {
+		if timeouts[i] == *minTimeout {
+			continue
+		}
+		timeout, err := wd.updateTimeout(*minTimeout)
+		if err != nil {
+			return nil, mwd.abortStart(fmt.Errorf("failed to set timeout %v of watchdog device %s: %v", *minTimeout, wd.getPath(), err))
+		}
+		if *timeout != *minTimeout {
+			return nil, mwd.abortStart(fmt.Errorf("watchdog device %s doesn't support timeout %v, its timeout is %v", wd.getPath(), *minTimeout, *timeout))
+		}
+	}
+	return minTimeout, nil
+}
+
+// abortStart disarms the already armed devices after the start failed with the given error, and returns the error
+func (mwd *multiWatchdog) abortStart(err error) error {
+	mwd.log.Error(err, "failed to arm watchdog devices, disarming all devices")
+	if disarmErr := mwd.disarm(); disarmErr != nil {
+		mwd.log.Error(disarmErr, "failed to disarm watchdog devices")
+	}
+	return err
+}
+
+// feed feeds all devices, also when feeding one of them fails
+func (mwd *multiWatchdog) feed() error {
+	var errs []string
+	for _, wd := range mwd.started {
+		if err := wd.feed(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", wd.getPath(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to feed watchdog devices: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// disarm disarms all started devices. Since the node is rebooted by any device which isn't fed anymore, none of them
+// is skipped when disarming another one fails.
+func (mwd *multiWatchdog) disarm() error {
+	var errs []string
+	for _, wd := range mwd.started {
+		if err := wd.disarm(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", wd.getPath(), err))
+		}
+	}
+	mwd.started = nil
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to disarm watchdog devices: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (mwd *multiWatchdog) describe() string {
+	descriptions := make([]string, 0, len(mwd.devices))
+	for _, wd := range mwd.devices {
+		descriptions = append(descriptions, wd.describe())
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// getTimeoutRange returns the timeout range supported by all devices, 0 means unknown
+func (mwd *multiWatchdog) getTimeoutRange() (time.Duration, time.Duration) {
+	var minTimeout, maxTimeout time.Duration
+	for _, wd := range mwd.devices {
+		deviceMinTimeout, deviceMaxTimeout := wd.getTimeoutRange()
+		if deviceMinTimeout > minTimeout {
+			minTimeout = deviceMinTimeout
+		}
+		if deviceMaxTimeout > 0 && (maxTimeout == 0 || deviceMaxTimeout < maxTimeout) {
+			maxTimeout = deviceMaxTimeout
+		}
+	}
+	return minTimeout, maxTimeout
+}
+
+// expectedTimeout returns the smallest expected timeout of all devices, which is set on all of them on start. Devices
+// with an unknown timeout are skipped, they can only lower the timeout.
+func (mwd *multiWatchdog) expectedTimeout() time.Duration {
+	var minTimeout time.Duration
+	for _, wd := range mwd.devices {
+		if timeout := wd.expectedTimeout(); timeout > 0 && (minTimeout == 0 || timeout < minTimeout) {
+			minTimeout = timeout
+		}
+	}
+	return minTimeout
+}
+
+// updateTimeout updates the timeout of all devices and returns the smallest of their new timeouts
+func (mwd *multiWatchdog) updateTimeout(timeout time.Duration) (*time.Duration, error) {
+	var minTimeout *time.Duration
+	for _, wd := range mwd.started {
+		newTimeout, err := wd.updateTimeout(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update timeout of watchdog device %s: %w", wd.getPath(), err)
+		}
+		if minTimeout == nil || *newTimeout < *minTimeout {
+			minTimeout = newTimeout
+		}
+	}
+	return minTimeout, nil
+}
+
+// reopen reopens all started devices and returns the smallest of their new timeouts. The devices which can't be
+// reopened are retried with the next reopen.
+func (mwd *multiWatchdog) reopen(timeout time.Duration) (*time.Duration, error) {
+	var minTimeout *time.Duration
+	var errs []string
+	for _, wd := range mwd.started {
+		newTimeout, err := wd.reopen(timeout)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", wd.getPath(), err))
+			continue
+		}
+		if minTimeout == nil || *newTimeout < *minTimeout {
+			minTimeout = newTimeout
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to reopen watchdog devices: %s", strings.Join(errs, ", "))
+	}
+	return minTimeout, nil
+}
+
+// getTimeLeft returns the smallest time left of all started devices which support reading it, since the first device
+// which fires reboots the node
+func (mwd *multiWatchdog) getTimeLeft() (time.Duration, error) {
+	var minTimeLeft *time.Duration
+	for _, wd := range mwd.started {
+		timeLeft, err := wd.getTimeLeft()
+		if err != nil {
+			if errors.Is(err, errTimeLeftUnsupported) {
+				continue
+			}
+			return 0, fmt.Errorf("failed to get time left of watchdog device %s: %w", wd.getPath(), err)
+		}
+		if minTimeLeft == nil || timeLeft < *minTimeLeft {
+			minTimeLeft = &timeLeft
+		}
+	}
+	if minTimeLeft == nil {
+		return 0, errTimeLeftUnsupported
+	}
+	return *minTimeLeft, nil
+}
+
+// verifyArmed verifies every device against its own timeout, the given one is the smallest of all devices
+func (mwd *multiWatchdog) verifyArmed(_ time.Duration) error {
+	for _, wd := range mwd.started {
+		timeout, err := wd.getTimeout()
+		if err != nil {
+			return fmt.Errorf("failed to get timeout of watchdog device %s: %v", wd.getPath(), err)
+		}
+		if err := wd.verifyArmed(*timeout); err != nil {
+			return fmt.Errorf("watchdog device %s: %v", wd.getPath(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package watchdog
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeDevice is a device of a multiWatchdog for tests, its timeout is clamped to its timeout range like on a real
+// device
+type fakeDevice struct {
+	*fakeWatchdog
+	path       string
+	timeout    time.Duration
+	minTimeout time.Duration
+	maxTimeout time.Duration
+	startErr   error
+	armed      bool
+}
+
+var _ multiDevice = &fakeDevice{}
+
+func (d *fakeDevice) start() (*time.Duration, error) {
+	if d.startErr != nil {
+		return nil, d.startErr
+	}
+	d.armed = true
+	return d.getTimeout()
+}
+
+func (d *fakeDevice) disarm() error {
+	d.armed = false
+	return nil
+}
+
+func (d *fakeDevice) getPath() string {
+	return d.path
+}
+
+func (d *fakeDevice) getTimeout() (*time.Duration, error) {
+	timeout := d.timeout
+	return &timeout, nil
+}
+
+func (d *fakeDevice) expectedTimeout() time.Duration {
+	return d.timeout
+}
+
+func (d *fakeDevice) getTimeoutRange() (time.Duration, time.Duration) {
+	return d.minTimeout, d.maxTimeout
+}
+
+func (d *fakeDevice) updateTimeout(timeout time.Duration) (*time.Duration, error) {
+	if timeout < d.minTimeout {
+		timeout = d.minTimeout
+	}
+	if timeout > d.maxTimeout {
+		timeout = d.maxTimeout
+	}
+	d.timeout = timeout
+	return d.getTimeout()
+}
+
+var _ = Describe("Multi watchdog", func() {
+
+	var fast, slow *fakeDevice
+	var mwd *multiWatchdog
+
+	BeforeEach(func() {
+		fast = &fakeDevice{fakeWatchdog: &fakeWatchdog{}, path: "/dev/watchdog0", timeout: 10 * time.Second, minTimeout: time.Second, maxTimeout: time.Minute}
+		slow = &fakeDevice{fakeWatchdog: &fakeWatchdog{}, path: "/dev/watchdog1", timeout: time.Minute, minTimeout: 5 * time.Second, maxTimeout: 5 * time.Minute}
+		mwd = &multiWatchdog{
+			devices: []multiDevice{fast, slow},
+			log:     ctrl.Log.WithName("multi-watchdog"),
+		}
+	})
+
+	It("should set the smallest timeout on all devices", func() {
+		timeout, err := mwd.start()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*timeout).To(Equal(10 * time.Second))
+		Expect(fast.timeout).To(Equal(10 * time.Second))
+		Expect(slow.timeout).To(Equal(10 * time.Second))
+		Expect(fast.armed).To(BeTrue())
+		Expect(slow.armed).To(BeTrue())
+	})
+
+	It("should disarm all devices when a device doesn't support the smallest timeout", func() {
+		slow.minTimeout = 30 * time.Second
+		_, err := mwd.start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(slow.path))
+		Expect(fast.armed).To(BeFalse())
+		Expect(slow.armed).To(BeFalse())
+		Expect(mwd.started).To(BeEmpty())
+	})
+
+	It("should disarm all devices when a device can't be armed", func() {
+		slow.startErr = errors.New("device busy")
+		_, err := mwd.start()
+		Expect(err).To(HaveOccurred())
+		Expect(fast.armed).To(BeFalse())
+		Expect(mwd.started).To(BeEmpty())
+	})
+
+	It("should expect the smallest timeout of all devices before it's started", func() {
+		slow.timeout = 0
+		Expect(mwd.expectedTimeout()).To(Equal(10 * time.Second))
+		fast.timeout = 0
+		Expect(mwd.expectedTimeout()).To(BeZero(), "the timeout is unknown")
+	})
+
+	It("should report the timeout range supported by all devices", func() {
+		minTimeout, maxTimeout := mwd.getTimeoutRange()
+		Expect(minTimeout).To(Equal(5 * time.Second))
+		Expect(maxTimeout).To(Equal(time.Minute))
+	})
+})
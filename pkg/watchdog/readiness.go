@@ -0,0 +1,23 @@
+package watchdog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// ReadyzCheck returns a healthz.Checker which fails until the given watchdog is started and its self test confirmed
+// that it's armed, so that an agent whose watchdog wouldn't reboot its node isn't ready
+func ReadyzCheck(wd Watchdog) healthz.Checker {
+	return func(_ *http.Request) error {
+		if !wd.IsStarted() {
+			return errors.New("watchdog isn't started yet")
+		}
+		if !wd.IsArmed() {
+			return fmt.Errorf("watchdog %s isn't armed", wd.Describe())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package watchdog
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Watchdog readiness", func() {
+
+	It("should only be ready when the watchdog is started and armed", func() {
+		wd := newSynced(ctrl.Log.WithName("watchdog"), &fakeWatchdog{})
+		check := ReadyzCheck(wd)
+		Expect(check(nil)).ToNot(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(wd.Start(ctx)).To(Succeed())
+		}()
+		defer func() {
+			cancel()
+			Eventually(done, 1*time.Second).Should(BeClosed())
+		}()
+		Eventually(func() error {
+			return check(nil)
+		}, 1*time.Second, 10*time.Millisecond).Should(Succeed())
+	})
+})
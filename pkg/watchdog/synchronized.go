@@ -3,26 +3,50 @@ package watchdog
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 
 	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 var _ Watchdog = &synchronizedWatchdog{}
 
+// maxConsecutiveFeedErrors is the number of consecutive failed feeds after which the device is reopened
+const maxConsecutiveFeedErrors = 3
+
 // synchronizedWatchdog implements the Watchdog interface with synchronized calls of the implementation specific methods
 type synchronizedWatchdog struct {
 	impl         watchdogImpl
 	timeout      time.Duration
 	isStarted    bool
+	isArmed      bool
 	stop         context.CancelFunc
 	isStopped    bool
 	mutex        sync.Mutex
 	lastFoodTime time.Time
 	log          logr.Logger
+
+	// requestedKeepaliveInterval is the requested interval for feeding the watchdog, zero uses the default interval
+	requestedKeepaliveInterval time.Duration
+	// armDelay is the time Start waits before it arms the watchdog, so that a node which just booted can settle
+	armDelay time.Duration
+
+	// startTimeout is the timeout the watchdog was started with, and interval is the keepalive interval for it
+	startTimeout time.Duration
+	interval     time.Duration
+	isDisarmed   bool
+
+	// consecutiveFeedErrors counts the failed feeds since the last successful one, isLost is set when the device
+	// couldn't be reopened after them
+	consecutiveFeedErrors int
+	isLost                bool
+	deviceLostHandler     func(err error)
 }
 
 func newSynced(log logr.Logger, impl watchdogImpl) *synchronizedWatchdog {
@@ -33,6 +57,15 @@ func newSynced(log logr.Logger, impl watchdogImpl) *synchronizedWatchdog {
 }
 
 func (swd *synchronizedWatchdog) Start(ctx context.Context) error {
+	if swd.armDelay > 0 {
+		swd.log.Info("delaying the arming of the watchdog", "arm delay", swd.armDelay)
+		select {
+		case <-time.After(swd.armDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
 	swd.mutex.Lock()
 	defer swd.mutex.Unlock()
 	if swd.isStarted {
@@ -44,13 +77,46 @@ func (swd *synchronizedWatchdog) Start(ctx context.Context) error {
 		return nil
 	}
 	swd.timeout = *timeout
+	swd.startTimeout = *timeout
 	swd.isStarted = true
-	swd.log.Info("watchdog started")
+	swd.interval, err = keepaliveInterval(swd.requestedKeepaliveInterval, swd.timeout)
+	if err != nil {
+		swd.log.Error(err, "invalid keepalive interval, using the default interval")
+	}
+	swd.log.Info("watchdog started", "keepalive interval", swd.interval)
+	swd.selfTest()
+	if unregister := swd.registerTimeLeftMetric(); unregister != nil {
+		defer unregister()
+	}
+	// Stop might be called as soon as the mutex is released
+	swd.startFeeding()
 	swd.mutex.Unlock()
 
+	<-ctx.Done()
+
+	// pod is being stopped, e.g. during an upgrade, disarm so that the node doesn't reboot!
+	// When Stop was called before, a reboot was requested and the watchdog must not be disarmed.
+	swd.mutex.Lock()
+	if swd.isStarted && !swd.isStopped {
+		if err := swd.impl.disarm(); err != nil {
+			swd.log.Error(err, "failed to disarm watchdog!")
+		} else {
+			swd.log.Info("disarmed watchdog")
+			// we can stop feeding after disarm
+			swd.stop()
+			swd.isStopped = true
+			swd.isDisarmed = true
+		}
+	}
+
+	return nil
+}
+
+// startFeeding feeds the watchdog in the keepalive interval until it's stopped.
+// The mutex needs to be held by the caller.
+func (swd *synchronizedWatchdog) startFeeding() {
 	feedCtx, cancel := context.WithCancel(context.Background())
 	swd.stop = cancel
-	// feed until stopped
 	go wait.NonSlidingUntilWithContext(feedCtx, func(feedCtx context.Context) {
 		swd.mutex.Lock()
 		defer swd.mutex.Unlock()
@@ -60,27 +126,117 @@ func (swd *synchronizedWatchdog) Start(ctx context.Context) error {
 		}
 		if err := swd.impl.feed(); err != nil {
 			swd.log.Error(err, "failed to feed watchdog!")
+			feedErrors.Inc()
+			swd.handleFeedError()
 		} else {
+			swd.consecutiveFeedErrors = 0
 			swd.lastFoodTime = time.Now()
+			lastFeedTimestamp.Set(float64(swd.lastFoodTime.Unix()))
+			swd.selfTest()
 		}
-	}, swd.timeout/3)
+	}, swd.interval)
+}
 
-	<-ctx.Done()
+// handleFeedError reopens the device after repeated feed errors, e.g. when it was hot-removed or its kernel module was
+// reloaded. When that fails, the watchdog can't reboot the node anymore, so it isn't armed anymore and the device lost
+// handler is called. Feeding and reopening are retried, in case the device comes back.
+// The mutex needs to be held by the caller.
+func (swd *synchronizedWatchdog) handleFeedError() {
+	swd.consecutiveFeedErrors++
+	if swd.consecutiveFeedErrors < maxConsecutiveFeedErrors {
+		return
+	}
+	swd.consecutiveFeedErrors = 0
 
-	// pod is being stopped, disarm!
-	swd.mutex.Lock()
-	if swd.isStarted && !swd.isStopped {
-		if err := swd.impl.disarm(); err != nil {
-			swd.log.Error(err, "failed to disarm watchdog!")
-		} else {
-			swd.log.Info("disarmed watchdog")
-			// we can stop feeding after disarm
-			swd.stop()
-			swd.isStopped = true
+	swd.log.Info("feeding the watchdog failed repeatedly, reopening the device", "device", swd.impl.describe())
+	timeout, err := swd.impl.reopen(swd.timeout)
+	if err != nil {
+		deviceReopens.WithLabelValues("failed").Inc()
+		swd.isArmed = false
+		armed.Set(0)
+		if swd.isLost {
+			return
 		}
+		swd.isLost = true
+		swd.log.Error(err, "failed to reopen the watchdog device, THE WATCHDOG CAN'T REBOOT THIS NODE ANYMORE!")
+		if swd.deviceLostHandler != nil {
+			go swd.deviceLostHandler(err)
+		}
+		return
 	}
 
-	return nil
+	deviceReopens.WithLabelValues("succeeded").Inc()
+	swd.isLost = false
+	swd.timeout = *timeout
+	// opening the device starts its timer
+	swd.lastFoodTime = time.Now()
+	lastFeedTimestamp.Set(float64(swd.lastFoodTime.Unix()))
+	swd.log.Info("reopened the watchdog device", "timeout", swd.timeout)
+	swd.selfTest()
+}
+
+// registerTimeLeftMetric registers the time left metric, if the device supports reading the time left, and returns
+// the func for unregistering it. The mutex needs to be held by the caller.
+func (swd *synchronizedWatchdog) registerTimeLeftMetric() func() {
+	if _, err := swd.impl.getTimeLeft(); errors.Is(err, errTimeLeftUnsupported) {
+		swd.log.Info("watchdog device doesn't support reading the time left, not exposing it as metric")
+		return nil
+	}
+	timeLeftMetric := newTimeLeftMetric(swd.timeLeftSeconds)
+	if err := metrics.Registry.Register(timeLeftMetric); err != nil {
+		swd.log.Error(err, "failed to register watchdog time left metric")
+		return nil
+	}
+	return func() {
+		metrics.Registry.Unregister(timeLeftMetric)
+	}
+}
+
+// timeLeftSeconds returns the time left until the watchdog fires, or NaN when it can't be read, e.g. after the
+// device was disarmed
+func (swd *synchronizedWatchdog) timeLeftSeconds() float64 {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	if swd.isDisarmed {
+		return math.NaN()
+	}
+	timeLeft, err := swd.impl.getTimeLeft()
+	if err != nil {
+		return math.NaN()
+	}
+	return timeLeft.Seconds()
+}
+
+// keepaliveInterval returns the interval in which a watchdog with the given timeout is fed. The requested interval is
+// only used when it's well below the timeout, i.e. at most half of it, so that a single delayed feed doesn't reboot
+// the node. Otherwise, and when no interval is requested, a third of the timeout is used.
+func keepaliveInterval(requested time.Duration, timeout time.Duration) (time.Duration, error) {
+	defaultInterval := timeout / 3
+	if requested <= 0 {
+		return defaultInterval, nil
+	}
+	if requested > timeout/2 {
+		return defaultInterval, fmt.Errorf("keepalive interval %s exceeds half of the watchdog timeout %s", requested, timeout)
+	}
+	return requested, nil
+}
+
+// selfTest verifies that the watchdog timer was reset by the last feed and updates the armed state.
+// The mutex needs to be held by the caller.
+func (swd *synchronizedWatchdog) selfTest() {
+	err := swd.impl.verifyArmed(swd.timeout)
+	isArmed := err == nil
+	if !isArmed {
+		swd.log.Error(err, "watchdog self test failed, the watchdog might not reboot the node!")
+	} else if !swd.isArmed {
+		swd.log.Info("watchdog self test succeeded, watchdog is armed")
+	}
+	swd.isArmed = isArmed
+	if isArmed {
+		armed.Set(1)
+	} else {
+		armed.Set(0)
+	}
 }
 
 func (swd *synchronizedWatchdog) IsStarted() bool {
@@ -101,9 +257,44 @@ func (swd *synchronizedWatchdog) Stop() {
 	}
 }
 
+func (swd *synchronizedWatchdog) Resume() bool {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	if !swd.isStarted || !swd.isStopped || swd.isDisarmed {
+		return false
+	}
+	// leave a margin for the time it takes to reach the device, the node might reboot any moment
+	if elapsed := time.Since(swd.lastFoodTime); elapsed >= swd.timeout-swd.timeout/10 {
+		swd.log.Info("too late for resuming the watchdog, it's about to fire", "since last feed", elapsed, "timeout", swd.timeout)
+		return false
+	}
+	// setting the timeout resets the timer as well, e.g. after it was shortened for a faster reboot
+	if swd.timeout != swd.startTimeout {
+		newTimeout, err := swd.impl.updateTimeout(swd.startTimeout)
+		if err != nil {
+			swd.log.Error(err, "failed to restore the watchdog timeout, not resuming it")
+			return false
+		}
+		swd.timeout = *newTimeout
+	} else if err := swd.impl.feed(); err != nil {
+		swd.log.Error(err, "failed to feed watchdog, not resuming it")
+		feedErrors.Inc()
+		return false
+	}
+	swd.lastFoodTime = time.Now()
+	swd.isStopped = false
+	swd.startFeeding()
+	swd.log.Info("watchdog feeding resumed", "timeout", swd.timeout)
+	return true
+}
+
 func (swd *synchronizedWatchdog) GetTimeout() time.Duration {
 	swd.mutex.Lock()
 	defer swd.mutex.Unlock()
+	// the timeout is needed for the safe time to assume a node rebooted before the watchdog is started
+	if !swd.isStarted {
+		return swd.impl.expectedTimeout()
+	}
 	return swd.timeout
 }
 
@@ -112,3 +303,70 @@ func (swd *synchronizedWatchdog) LastFoodTime() time.Time {
 	defer swd.mutex.Unlock()
 	return swd.lastFoodTime
 }
+
+func (swd *synchronizedWatchdog) Describe() string {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	return swd.impl.describe()
+}
+
+func (swd *synchronizedWatchdog) GetTimeoutRange() (time.Duration, time.Duration) {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	return swd.impl.getTimeoutRange()
+}
+
+func (swd *synchronizedWatchdog) ExtendTimeout(timeout time.Duration) (time.Duration, error) {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	if !swd.isStarted || swd.isStopped {
+		return swd.timeout, errors.New("watchdog isn't running")
+	}
+	if timeout <= swd.timeout {
+		return swd.timeout, nil
+	}
+	newTimeout, err := swd.impl.updateTimeout(timeout)
+	if err != nil {
+		return swd.timeout, err
+	}
+	if *newTimeout <= swd.timeout {
+		return swd.timeout, ErrTimeoutNotExtendable
+	}
+	swd.log.Info("extended watchdog timeout", "previous timeout", swd.timeout, "timeout", *newTimeout)
+	swd.timeout = *newTimeout
+	// setting the timeout resets the timer
+	swd.lastFoodTime = time.Now()
+	return swd.timeout, nil
+}
+
+func (swd *synchronizedWatchdog) ShortenTimeout(timeout time.Duration) (time.Duration, error) {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	if !swd.isStarted || swd.isStopped {
+		return swd.timeout, errors.New("watchdog isn't running")
+	}
+	if timeout >= swd.timeout {
+		return swd.timeout, nil
+	}
+	newTimeout, err := swd.impl.updateTimeout(timeout)
+	if err != nil {
+		return swd.timeout, err
+	}
+	swd.log.Info("shortened watchdog timeout", "previous timeout", swd.timeout, "timeout", *newTimeout)
+	swd.timeout = *newTimeout
+	// setting the timeout resets the timer
+	swd.lastFoodTime = time.Now()
+	return swd.timeout, nil
+}
+
+func (swd *synchronizedWatchdog) OnDeviceLost(handler func(err error)) {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	swd.deviceLostHandler = handler
+}
+
+func (swd *synchronizedWatchdog) IsArmed() bool {
+	swd.mutex.Lock()
+	defer swd.mutex.Unlock()
+	return swd.isArmed
+}
@@ -0,0 +1,308 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/sys/unix"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Synchronized watchdog", func() {
+
+	var fake *fakeWatchdog
+	var wd *synchronizedWatchdog
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	BeforeEach(func() {
+		fake = &fakeWatchdog{}
+		wd = newSynced(ctrl.Log.WithName("watchdog"), fake)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(wd.Start(ctx)).To(Succeed())
+		}()
+		Eventually(wd.IsStarted, 1*time.Second, 10*time.Millisecond).Should(BeTrue())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done, 1*time.Second).Should(BeClosed())
+	})
+
+	It("should be disarmed on a graceful shutdown", func() {
+		Eventually(func() time.Time {
+			return wd.LastFoodTime()
+		}, 2*fakeTimeout, 50*time.Millisecond).ShouldNot(BeZero())
+
+		cancel()
+		Eventually(done, 1*time.Second).Should(BeClosed())
+		Expect(fake.isDisarmed()).To(BeTrue(), "the node would be rebooted")
+	})
+
+	It("should not be disarmed after it was stopped for a reboot", func() {
+		wd.Stop()
+		lastFoodTime := wd.LastFoodTime()
+		Consistently(func() time.Time {
+			return wd.LastFoodTime()
+		}, fakeTimeout, 50*time.Millisecond).Should(Equal(lastFoodTime))
+
+		cancel()
+		Eventually(done, 1*time.Second).Should(BeClosed())
+		Expect(fake.isDisarmed()).To(BeFalse(), "the node would not be rebooted")
+	})
+
+	It("should resume feeding after it was stopped for a reboot", func() {
+		wd.Stop()
+		Expect(wd.Resume()).To(BeTrue())
+		lastFoodTime := wd.LastFoodTime()
+		Eventually(wd.LastFoodTime, 2*fakeTimeout, 50*time.Millisecond).Should(BeTemporally(">", lastFoodTime))
+		Expect(wd.Resume()).To(BeFalse(), "it's running")
+	})
+
+	It("should not resume feeding when it's about to fire", func() {
+		wd.Stop()
+		time.Sleep(fakeTimeout)
+		Expect(wd.Resume()).To(BeFalse())
+	})
+
+	It("should restore its timeout when resuming", func() {
+		fake.maxTimeout = 10 * fakeTimeout
+		_, err := wd.ExtendTimeout(5 * fakeTimeout)
+		Expect(err).ToNot(HaveOccurred())
+		wd.Stop()
+		Expect(wd.Resume()).To(BeTrue())
+		Expect(wd.GetTimeout()).To(Equal(fakeTimeout))
+	})
+
+	It("should not resume feeding after it was disarmed", func() {
+		cancel()
+		Eventually(done, 1*time.Second).Should(BeClosed())
+		Expect(wd.Resume()).To(BeFalse())
+	})
+
+	It("should extend its timeout up to the max timeout", func() {
+		fake.maxTimeout = 10 * fakeTimeout
+		timeout, err := wd.ExtendTimeout(5 * fakeTimeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeout).To(Equal(5 * fakeTimeout))
+		Expect(wd.GetTimeout()).To(Equal(5 * fakeTimeout))
+
+		timeout, err = wd.ExtendTimeout(20 * fakeTimeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeout).To(Equal(10 * fakeTimeout))
+	})
+
+	It("should fail to extend its timeout beyond the max timeout", func() {
+		fake.maxTimeout = fakeTimeout
+		timeout, err := wd.ExtendTimeout(5 * fakeTimeout)
+		Expect(err).To(MatchError(ErrTimeoutNotExtendable))
+		Expect(timeout).To(Equal(fakeTimeout))
+	})
+
+	It("should not extend its timeout after it was stopped", func() {
+		fake.maxTimeout = 10 * fakeTimeout
+		wd.Stop()
+		_, err := wd.ExtendTimeout(5 * fakeTimeout)
+		Expect(err).To(HaveOccurred())
+		Expect(wd.GetTimeout()).To(Equal(fakeTimeout))
+	})
+
+	It("should shorten its timeout down to the min timeout", func() {
+		fake.maxTimeout = 10 * fakeTimeout
+		_, err := wd.ExtendTimeout(5 * fakeTimeout)
+		Expect(err).ToNot(HaveOccurred())
+
+		timeout, err := wd.ShortenTimeout(fakeTimeout / 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeout).To(Equal(fakeTimeout))
+		Expect(wd.GetTimeout()).To(Equal(fakeTimeout))
+	})
+
+	It("should not lengthen its timeout when shortening it", func() {
+		timeout, err := wd.ShortenTimeout(5 * fakeTimeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeout).To(Equal(fakeTimeout))
+	})
+
+	It("should reopen the device after repeated feed errors", func() {
+		lost := make(chan error, 1)
+		wd.OnDeviceLost(func(err error) {
+			lost <- err
+		})
+		fake.setErrors(errors.New("bad file descriptor"), nil)
+		lastFoodTime := wd.LastFoodTime()
+		Eventually(wd.LastFoodTime, 4*fakeTimeout, 50*time.Millisecond).Should(BeTemporally(">", lastFoodTime))
+		Expect(wd.IsArmed()).To(BeTrue())
+		Consistently(lost, fakeTimeout, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should not be armed anymore when the device can't be reopened", func() {
+		lost := make(chan error, 1)
+		wd.OnDeviceLost(func(err error) {
+			lost <- err
+		})
+		Expect(wd.IsArmed()).To(BeTrue())
+		fake.setErrors(errors.New("bad file descriptor"), errors.New("no such device"))
+		Eventually(lost, 4*fakeTimeout, 50*time.Millisecond).Should(Receive(MatchError("no such device")))
+		Expect(wd.IsArmed()).To(BeFalse())
+
+		By("reporting the lost device only once")
+		Consistently(lost, 2*fakeTimeout, 50*time.Millisecond).ShouldNot(Receive())
+
+		By("recovering when the device comes back")
+		fake.setErrors(errors.New("bad file descriptor"), nil)
+		Eventually(wd.IsArmed, 4*fakeTimeout, 50*time.Millisecond).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Arm delay", func() {
+
+	It("should not arm the watchdog before the delay elapsed", func() {
+		wd := newSynced(ctrl.Log.WithName("watchdog"), &fakeWatchdog{})
+		wd.armDelay = 500 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(wd.Start(ctx)).To(Succeed())
+		}()
+		defer func() {
+			cancel()
+			Eventually(done, 1*time.Second).Should(BeClosed())
+		}()
+		Consistently(wd.IsStarted, 300*time.Millisecond, 50*time.Millisecond).Should(BeFalse())
+		Eventually(wd.IsStarted, 1*time.Second, 50*time.Millisecond).Should(BeTrue())
+	})
+
+	It("should not arm the watchdog when it's stopped during the delay", func() {
+		fake := &fakeWatchdog{}
+		wd := newSynced(ctrl.Log.WithName("watchdog"), fake)
+		wd.armDelay = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(wd.Start(ctx)).To(Succeed())
+		Expect(wd.IsStarted()).To(BeFalse())
+	})
+})
+
+var _ = Describe("Time left metric", func() {
+
+	// timeLeftMetric returns the time left metric, or nil if it isn't registered
+	timeLeftMetric := func() *dto.Metric {
+		families, err := metrics.Registry.Gather()
+		Expect(err).ToNot(HaveOccurred())
+		for _, family := range families {
+			if family.GetName() == timeLeftMetricName {
+				return family.GetMetric()[0]
+			}
+		}
+		return nil
+	}
+
+	startWatchdog := func(fake *fakeWatchdog) (context.CancelFunc, chan struct{}) {
+		wd := newSynced(ctrl.Log.WithName("watchdog"), fake)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			Expect(wd.Start(ctx)).To(Succeed())
+		}()
+		Eventually(wd.IsStarted, 1*time.Second, 10*time.Millisecond).Should(BeTrue())
+		return cancel, done
+	}
+
+	It("should expose the time left until the watchdog fires", func() {
+		cancel, done := startWatchdog(&fakeWatchdog{})
+		Eventually(timeLeftMetric, 1*time.Second, 10*time.Millisecond).ShouldNot(BeNil())
+		Expect(timeLeftMetric().GetGauge().GetValue()).To(Equal(fakeTimeout.Seconds()))
+
+		By("unregistering it when the watchdog is disarmed")
+		cancel()
+		Eventually(done, 1*time.Second).Should(BeClosed())
+		Expect(timeLeftMetric()).To(BeNil())
+	})
+
+	It("should not register the metric when the device can't report the time left", func() {
+		cancel, done := startWatchdog(&fakeWatchdog{timeLeftUnsupported: true})
+		defer func() {
+			cancel()
+			Eventually(done, 1*time.Second).Should(BeClosed())
+		}()
+		Consistently(timeLeftMetric, 200*time.Millisecond, 50*time.Millisecond).Should(BeNil())
+	})
+})
+
+var _ = Describe("Keepalive interval", func() {
+
+	It("should feed every third of the timeout by default", func() {
+		interval, err := keepaliveInterval(0, 30*time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(interval).To(Equal(10 * time.Second))
+	})
+
+	It("should use the requested interval when it's well below the timeout", func() {
+		interval, err := keepaliveInterval(200*time.Millisecond, time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(interval).To(Equal(200 * time.Millisecond))
+	})
+
+	It("should fall back to the default when the requested interval is too long", func() {
+		interval, err := keepaliveInterval(20*time.Second, 30*time.Second)
+		Expect(err).To(HaveOccurred())
+		Expect(interval).To(Equal(10 * time.Second))
+	})
+})
+
+var _ = Describe("Timeout before start", func() {
+
+	var device *linuxWatchdog
+
+	BeforeEach(func() {
+		device = &linuxWatchdog{defaultTimeout: 60 * time.Second, minTimeout: 5 * time.Second, maxTimeout: 120 * time.Second}
+	})
+
+	It("should report the default timeout of the device", func() {
+		wd := newSynced(ctrl.Log.WithName("watchdog"), device)
+		Expect(wd.GetTimeout()).To(Equal(60 * time.Second))
+	})
+
+	It("should report the requested timeout clamped to the range of the device", func() {
+		device.defaultTimeout = 0
+		device.requestedTimeout = 300 * time.Second
+		wd := newSynced(ctrl.Log.WithName("watchdog"), device)
+		Expect(wd.GetTimeout()).To(Equal(120 * time.Second))
+	})
+
+	It("should report the default timeout when it exceeds the requested one", func() {
+		device.requestedTimeout = 10 * time.Second
+		wd := newSynced(ctrl.Log.WithName("watchdog"), device)
+		Expect(wd.GetTimeout()).To(Equal(60*time.Second), "setting the requested timeout might fail")
+	})
+
+	It("should report the requested timeout when the device supports setting it", func() {
+		device.requestedTimeout = 10 * time.Second
+		device.info = &watchdogInfo{options: unix.WDIOF_SETTIMEOUT}
+		wd := newSynced(ctrl.Log.WithName("watchdog"), device)
+		Expect(wd.GetTimeout()).To(Equal(10 * time.Second))
+	})
+
+	It("should report an unknown timeout as zero", func() {
+		device.defaultTimeout = 0
+		wd := newSynced(ctrl.Log.WithName("watchdog"), device)
+		Expect(wd.GetTimeout()).To(BeZero())
+	})
+})